@@ -3,6 +3,9 @@ package database
 import (
     "gorm.io/gorm"
     "gorm.io/driver/postgres"
+    "myapp/internal/concurrency"
+    "myapp/internal/tenant"
+    "myapp/pkg/tracing"
     "os"
     "log"
 )
@@ -19,7 +22,38 @@ func ConnectDB() error {
         return err
     }
     
+    // Scope every query on a tenant table to the tenant of its context
+    if err := db.Use(tenant.NewPlugin(TenantModels...)); err != nil {
+        log.Println("Tenant scoping setup failed:", err)
+        return err
+    }
+
+    // Version rows and make If-Match requests conditional on the version they read
+    if err := db.Use(concurrency.NewPlugin()); err != nil {
+        log.Println("Optimistic concurrency setup failed:", err)
+        return err
+    }
+
+    // Trace every statement as a child of the span in its context
+    if err := db.Use(tracing.NewGormPlugin()); err != nil {
+        log.Println("Tracing setup failed:", err)
+        return err
+    }
+
     log.Println("Database connected successfully!")
     DB = db
     return nil
-}
\ No newline at end of file
+}
+
+// Close closes the connection pool, once the server and the jobs no longer run queries
+func Close() error {
+    if DB == nil {
+        return nil
+    }
+    sqlDB, err := DB.DB()
+    if err != nil {
+        return err
+    }
+    log.Println("Closing database connection")
+    return sqlDB.Close()
+}
@@ -1,34 +1,404 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"myapp/internal/model"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func Migrate() error {
-	log.Println("Starting database migration...")
+// TenantModels are the models with a TenantID, scoped to the tenant of the request
+var TenantModels = []interface{}{
+	&model.User{},
+	&model.Role{},
+	&model.Brand{},
+	&model.Category{},
+	&model.Product{},
+	&model.ProductBatch{},
+	&model.ProductBatchTrack{},
+	&model.ProductUnit{},
+	&model.ProductUnitTrack{},
+	&model.Location{},
+	&model.SubLocation{},
+	&model.ProductStock{},
+	&model.ProductStockTrack{},
+	&model.ProductItem{},
+	&model.ProductItemTrack{},
+	&model.ReplenishmentRule{},
+	&model.ReplenishmentAlert{},
+	&model.WebhookSubscription{},
+	&model.WebhookDelivery{},
+	&model.OutboxEvent{},
+}
+
+// MigrationsDir is where migrate create writes new migrations, relative to the root of the repository
+const MigrationsDir = "database/migrations"
+
+// migrationsTable records the version of every migration applied to the database
+const migrationsTable = "schema_migrations"
+
+// migrationLockID is the advisory lock held while a migration runs, so two instances never apply the same one
+const migrationLockID = 7223560138
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches 0003_add_bin_capacity.up.sql and 0003_add_bin_capacity.down.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// migrationNameSeparators are the runs of characters migrate create turns into underscores
+var migrationNameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Migration is a versioned schema change: the SQL of Up applies it and the SQL of Down reverts it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration is applied; AppliedAt is nil while it is pending
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// ErrPendingMigrations is returned by CheckMigrations when the schema is behind the migrations of this build
+var ErrPendingMigrations = errors.New("database schema is not migrated, run the migrate up command")
+
+// LoadMigrations returns the migrations built into the binary, ordered by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_create_table.up.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(migrationFiles, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatuses lists every migration of the build with the time it was applied, by version. Versions the database
+// applied that this build does not know, such as after rolling back a deploy, are listed too.
+func MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &record.AppliedAt})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// MigrateUp applies the pending migrations in order, at most steps of them when steps > 0. Each migration runs in
+// its own transaction: one that fails leaves the schema at the migration before it.
+func MigrateUp(ctx context.Context, steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if steps > 0 && count == steps {
+			break
+		}
+
+		log.Printf("Applying migration %d_%s", migration.Version, migration.Name)
+		if err := runMigration(ctx, migration, true); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+
+	if count == 0 {
+		log.Println("Database schema is up to date")
+	} else {
+		log.Printf("Applied %d migrations", count)
+	}
+	return nil
+}
 
-	err := DB.AutoMigrate(
-		&model.User{},
-		&model.Role{},
-		&model.Brand{},
-		&model.Category{},
-		&model.Product{},
-		&model.ProductBatch{},
-		&model.ProductBatchTrack{},
-		&model.ProductUnit{},
-		&model.ProductUnitTrack{},
-		&model.Location{},
-		&model.ProductStock{},
-		&model.ProductStockTrack{},
-		&model.ProductItem{},
-		&model.ProductItemTrack{},
-	)
+// MigrateDown reverts the last steps applied migrations, newest first
+func MigrateDown(ctx context.Context, steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			return fmt.Errorf("migration %d_%s has no down file and cannot be reverted", migration.Version, migration.Name)
+		}
+
+		log.Printf("Reverting migration %d_%s", migration.Version, migration.Name)
+		if err := runMigration(ctx, migration, false); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+
+	log.Printf("Reverted %d migrations", count)
+	return nil
+}
+
+// CheckMigrations returns ErrPendingMigrations, naming the pending versions, when the database has not applied every
+// migration of this build. Versions applied by a newer build are logged but accepted, so a deploy can be rolled back.
+func CheckMigrations(ctx context.Context) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, false)
 	if err != nil {
-		log.Println("Migration failed:", err)
 		return err
 	}
 
-	log.Println("Migration completed successfully!")
+	var pending []string
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			delete(applied, migration.Version)
+			continue
+		}
+		pending = append(pending, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+	}
+	for _, record := range applied {
+		log.Printf("Database has applied migration %d_%s, which this build does not know", record.Version, record.Name)
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending %s", ErrPendingMigrations, strings.Join(pending, ", "))
+	}
 	return nil
 }
+
+// CreateMigration writes empty up and down files for the next version into dir and returns their paths
+func CreateMigration(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = migrationNameSeparators.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required, e.g. add_bin_capacity")
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return "", "", err
+	}
+	next := int64(1)
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+	// Files created since the build are not embedded yet
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for _, entry := range entries {
+		if match := migrationFileName.FindStringSubmatch(entry.Name()); match != nil {
+			if version, err := strconv.ParseInt(match[1], 10, 64); err == nil && version >= next {
+				next = version + 1
+			}
+		}
+	}
+
+	base := fmt.Sprintf("%04d_%s", next, name)
+	upPath := filepath.Join(dir, base+".up.sql")
+	downPath := filepath.Join(dir, base+".down.sql")
+	if err := os.WriteFile(upPath, []byte("-- "+base+": describe the change\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte("-- Reverts "+base+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// migrationRecord is a row of the migrations table
+type migrationRecord struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// appliedMigrations reads the migrations table. Only migrate up and down create it (create); for the read-only startup
+// check and status a database without the table has applied nothing.
+func appliedMigrations(ctx context.Context, create bool) (map[int64]migrationRecord, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if create {
+		_, err = sqlDB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var exists bool
+		if err := sqlDB.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", migrationsTable).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return map[int64]migrationRecord{}, nil
+		}
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, "SELECT version, name, applied_at FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]migrationRecord{}
+	for rows.Next() {
+		var record migrationRecord
+		if err := rows.Scan(&record.Version, &record.Name, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[record.Version] = record
+	}
+	return applied, rows.Err()
+}
+
+// runMigration applies (up) or reverts a migration and records it in one transaction. It holds the migration lock
+// and checks the table again, so a migration another instance applied meanwhile is skipped.
+func runMigration(ctx context.Context, migration Migration, up bool) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+migrationsTable+" WHERE version = $1)", migration.Version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied == up {
+		return nil
+	}
+
+	if up {
+		err = execMigration(ctx, tx, migration.Up)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+		}
+	} else {
+		err = execMigration(ctx, tx, migration.Down)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version = $1", migration.Version)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execMigration runs the statements of a migration file; without arguments they are sent as one batch
+func execMigration(ctx context.Context, tx *sql.Tx, statements string) error {
+	if strings.TrimSpace(statements) == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, statements)
+	return err
+}
+
+// DefaultTenantID returns the ID of the tenant created by the migration
+func DefaultTenantID() (uint, error) {
+	var defaultTenant model.Tenant
+	err := DB.Where("code = ?", model.DefaultTenantCode).First(&defaultTenant).Error
+	return defaultTenant.ID, err
+}
@@ -0,0 +1,24 @@
+-- Drops every table of the baseline, with all its data
+
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS replenishment_alerts;
+DROP TABLE IF EXISTS replenishment_rules;
+DROP TABLE IF EXISTS product_item_tracks;
+DROP TABLE IF EXISTS product_items;
+DROP TABLE IF EXISTS product_stock_tracks;
+DROP TABLE IF EXISTS product_stocks;
+DROP TABLE IF EXISTS sub_locations;
+DROP TABLE IF EXISTS product_unit_tracks;
+DROP TABLE IF EXISTS product_units;
+DROP TABLE IF EXISTS locations;
+DROP TABLE IF EXISTS product_batch_tracks;
+DROP TABLE IF EXISTS product_batches;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS brands;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS tenants;
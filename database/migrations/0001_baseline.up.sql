@@ -0,0 +1,586 @@
+-- Schema of the last release that created its tables with GORM AutoMigrate. Every statement is conditional, so a
+-- database of any earlier release is upgraded in place: missing tables and indexes are created, columns added since
+-- are added to existing tables, the global brand and role name constraints are dropped and rows that predate
+-- multi-tenancy are assigned to the default tenant.
+
+CREATE TABLE IF NOT EXISTS tenants (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "code" varchar(50) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "is_active" boolean NOT NULL DEFAULT true,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tenants_code" ON "tenants" ("code");
+CREATE INDEX IF NOT EXISTS "idx_tenants_deleted_at" ON "tenants" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "scope" varchar(100) NOT NULL,
+    "key" varchar(255) NOT NULL,
+    "method" varchar(10) NOT NULL,
+    "path" varchar(255) NOT NULL,
+    "fingerprint" varchar(64) NOT NULL,
+    "status" varchar(10) NOT NULL DEFAULT 'processing',
+    "response_status" bigint,
+    "content_type" varchar(100),
+    "response_body" bytea,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_keys_scope_key" ON "idempotency_keys" ("scope","key");
+
+CREATE TABLE IF NOT EXISTS users (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "role_id" bigint,
+    "name" text,
+    "email" text,
+    "password" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "role_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_users_role_id" ON "users" ("role_id");
+CREATE INDEX IF NOT EXISTS "idx_users_tenant_id" ON "users" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS roles (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "name" text NOT NULL,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_roles_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_roles_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+-- Role names were unique across the table; they are unique per tenant now
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS "uni_roles_name";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_roles_tenant_name" ON "roles" ("tenant_id","name");
+CREATE INDEX IF NOT EXISTS "idx_roles_tenant_id" ON "roles" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_roles_deleted_at" ON "roles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS brands (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "name" text NOT NULL,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_brands_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_brands_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE brands ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE brands ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+-- Brand names were unique across the table; they are unique per tenant now
+ALTER TABLE brands DROP CONSTRAINT IF EXISTS "uni_brands_name";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_brands_tenant_name" ON "brands" ("tenant_id","name");
+CREATE INDEX IF NOT EXISTS "idx_brands_tenant_id" ON "brands" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_brands_deleted_at" ON "brands" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS categories (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "brand_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_categories_brand" FOREIGN KEY ("brand_id") REFERENCES "brands"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_categories_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_categories_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS "idx_categories_tenant_id" ON "categories" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_categories_deleted_at" ON "categories" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS products (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "category_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_products_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_products_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_products_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS "idx_products_tenant_id" ON "products" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_batches (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "product_id" bigint NOT NULL,
+    "code_batch" text,
+    "unit_price" decimal,
+    "exp_date" timestamptz,
+    "description" text,
+    "status" varchar(20) NOT NULL DEFAULT 'available',
+    "status_reason" text,
+    "status_updated_at" timestamptz,
+    "status_updated_by" bigint,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_products_product_batches" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_product_batches_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_batches_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "status" varchar(20) NOT NULL DEFAULT 'available';
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "status_reason" text;
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "status_updated_at" timestamptz;
+ALTER TABLE product_batches ADD COLUMN IF NOT EXISTS "status_updated_by" bigint;
+CREATE INDEX IF NOT EXISTS "idx_product_batches_status" ON "product_batches" ("status");
+CREATE INDEX IF NOT EXISTS "idx_product_batches_tenant_id" ON "product_batches" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_batches_deleted_at" ON "product_batches" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_batch_tracks (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "product_batch_id" bigint NOT NULL,
+    "description" text NOT NULL,
+    "user_inst" bigint NOT NULL,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_batch_tracks_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id"),
+    CONSTRAINT "fk_product_batch_tracks_creator" FOREIGN KEY ("user_inst") REFERENCES "users"("id"),
+    CONSTRAINT "fk_product_batch_tracks_updater" FOREIGN KEY ("user_updt") REFERENCES "users"("id")
+);
+ALTER TABLE product_batch_tracks ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_product_batch_tracks_product_batch_id" ON "product_batch_tracks" ("product_batch_id");
+CREATE INDEX IF NOT EXISTS "idx_product_batch_tracks_tenant_id" ON "product_batch_tracks" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_batch_tracks_deleted_at" ON "product_batch_tracks" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS locations (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "user_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "address" text,
+    "phone_number" varchar(20),
+    "type" varchar(20) NOT NULL,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_locations_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_locations_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_locations_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL,
+    CONSTRAINT "chk_locations_type" CHECK (type IN ('gudang', 'reseller'))
+);
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS "idx_locations_tenant_id" ON "locations" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_locations_deleted_at" ON "locations" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_units (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "product_id" bigint NOT NULL,
+    "location_id" bigint NOT NULL,
+    "product_batch_id" bigint NOT NULL,
+    "name" text,
+    "quantity" decimal,
+    "unit_price" decimal,
+    "unit_price_retail" decimal,
+    "barcode" text,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_units_location" FOREIGN KEY ("location_id") REFERENCES "locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_units_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_units_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_units_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL,
+    CONSTRAINT "fk_product_units_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT
+);
+ALTER TABLE product_units ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE product_units ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS "idx_product_units_tenant_id" ON "product_units" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_units_deleted_at" ON "product_units" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_unit_tracks (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "product_unit_id" bigint NOT NULL,
+    "description" text NOT NULL,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_unit_tracks_product_unit" FOREIGN KEY ("product_unit_id") REFERENCES "product_units"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_unit_tracks_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_unit_tracks_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE product_unit_tracks ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_product_unit_tracks_tenant_id" ON "product_unit_tracks" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_unit_tracks_deleted_at" ON "product_unit_tracks" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS sub_locations (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "location_id" bigint NOT NULL,
+    "parent_id" bigint,
+    "level" varchar(10) NOT NULL,
+    "code" varchar(20) NOT NULL,
+    "path" varchar(100) NOT NULL,
+    "name" varchar(100),
+    "capacity" decimal,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sub_locations_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL,
+    CONSTRAINT "fk_sub_locations_location" FOREIGN KEY ("location_id") REFERENCES "locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_sub_locations_parent" FOREIGN KEY ("parent_id") REFERENCES "sub_locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_sub_locations_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "chk_sub_locations_level" CHECK (level IN ('zone', 'aisle', 'rack', 'bin'))
+);
+CREATE INDEX IF NOT EXISTS "idx_sub_locations_parent_id" ON "sub_locations" ("parent_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sub_locations_location_path" ON "sub_locations" ("location_id","path") WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_sub_locations_location_id" ON "sub_locations" ("location_id");
+CREATE INDEX IF NOT EXISTS "idx_sub_locations_tenant_id" ON "sub_locations" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_sub_locations_deleted_at" ON "sub_locations" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_stocks (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "product_batch_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "location_id" bigint NOT NULL,
+    "bin_id" bigint,
+    "quantity" decimal,
+    "status" varchar(20) NOT NULL DEFAULT 'available',
+    "status_reason" text,
+    "status_updated_at" timestamptz,
+    "status_updated_by" bigint,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_stocks_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL,
+    CONSTRAINT "fk_product_stocks_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stocks_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stocks_location" FOREIGN KEY ("location_id") REFERENCES "locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stocks_bin" FOREIGN KEY ("bin_id") REFERENCES "sub_locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stocks_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT
+);
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "bin_id" bigint;
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "status" varchar(20) NOT NULL DEFAULT 'available';
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "status_reason" text;
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "status_updated_at" timestamptz;
+ALTER TABLE product_stocks ADD COLUMN IF NOT EXISTS "status_updated_by" bigint;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_product_stocks_bin') THEN
+        ALTER TABLE product_stocks ADD CONSTRAINT "fk_product_stocks_bin" FOREIGN KEY ("bin_id") REFERENCES "sub_locations"("id") ON DELETE RESTRICT;
+    END IF;
+END $$;
+CREATE INDEX IF NOT EXISTS "idx_product_stocks_status" ON "product_stocks" ("status");
+CREATE INDEX IF NOT EXISTS "idx_product_stocks_bin_id" ON "product_stocks" ("bin_id");
+CREATE INDEX IF NOT EXISTS "idx_product_stocks_tenant_id" ON "product_stocks" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_stocks_deleted_at" ON "product_stocks" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_stock_tracks (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "product_stock_id" bigint NOT NULL,
+    "product_batch_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "date" timestamptz NOT NULL,
+    "quantity" decimal NOT NULL,
+    "operation" varchar(10) NOT NULL,
+    "stock" decimal NOT NULL,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_stock_tracks_product_stock" FOREIGN KEY ("product_stock_id") REFERENCES "product_stocks"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stock_tracks_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stock_tracks_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stock_tracks_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_stock_tracks_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE product_stock_tracks ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_product_stock_tracks_tenant_id" ON "product_stock_tracks" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_stock_tracks_deleted_at" ON "product_stock_tracks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_product_stock_tracks_date_id" ON "product_stock_tracks" ("date","id");
+
+CREATE TABLE IF NOT EXISTS product_items (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "product_stock_id" bigint NOT NULL,
+    "product_batch_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "stock_in" decimal,
+    "stock_out" decimal,
+    "quantity" decimal,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_items_product_stock" FOREIGN KEY ("product_stock_id") REFERENCES "product_stocks"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_items_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_items_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_items_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE product_items ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE product_items ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS "idx_product_items_tenant_id" ON "product_items" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_items_deleted_at" ON "product_items" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS product_item_tracks (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "product_stock_id" bigint NOT NULL,
+    "product_batch_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "date" timestamptz NOT NULL,
+    "quantity" decimal NOT NULL,
+    "operation" varchar(10) NOT NULL,
+    "stock" decimal NOT NULL,
+    "unit_price" text,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_product_item_tracks_product_stock" FOREIGN KEY ("product_stock_id") REFERENCES "product_stocks"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_item_tracks_product_batch" FOREIGN KEY ("product_batch_id") REFERENCES "product_batches"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_item_tracks_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_item_tracks_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_product_item_tracks_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+ALTER TABLE product_item_tracks ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_product_item_tracks_tenant_id" ON "product_item_tracks" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_product_item_tracks_deleted_at" ON "product_item_tracks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_product_item_tracks_date_id" ON "product_item_tracks" ("date","id");
+
+CREATE TABLE IF NOT EXISTS replenishment_rules (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "product_id" bigint NOT NULL,
+    "location_id" bigint,
+    "min_quantity" decimal NOT NULL DEFAULT 0,
+    "reorder_point" decimal NOT NULL,
+    "max_quantity" decimal NOT NULL,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_replenishment_rules_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL,
+    CONSTRAINT "fk_replenishment_rules_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_replenishment_rules_location" FOREIGN KEY ("location_id") REFERENCES "locations"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_replenishment_rules_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT
+);
+CREATE INDEX IF NOT EXISTS "idx_replenishment_rules_location_id" ON "replenishment_rules" ("location_id");
+CREATE INDEX IF NOT EXISTS "idx_replenishment_rules_product_id" ON "replenishment_rules" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_replenishment_rules_tenant_id" ON "replenishment_rules" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_replenishment_rules_deleted_at" ON "replenishment_rules" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS replenishment_alerts (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "tenant_id" bigint,
+    "replenishment_rule_id" bigint NOT NULL,
+    "product_id" bigint NOT NULL,
+    "location_id" bigint,
+    "priority" varchar(10) NOT NULL,
+    "status" varchar(10) NOT NULL DEFAULT 'open',
+    "on_hand" decimal NOT NULL,
+    "suggested_quantity" decimal NOT NULL,
+    "resolved_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_replenishment_alerts_replenishment_rule" FOREIGN KEY ("replenishment_rule_id") REFERENCES "replenishment_rules"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_replenishment_alerts_status" ON "replenishment_alerts" ("status");
+CREATE INDEX IF NOT EXISTS "idx_replenishment_alerts_replenishment_rule_id" ON "replenishment_alerts" ("replenishment_rule_id");
+CREATE INDEX IF NOT EXISTS "idx_replenishment_alerts_tenant_id" ON "replenishment_alerts" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "version" bigint NOT NULL DEFAULT 1,
+    "url" varchar(500) NOT NULL,
+    "events" text NOT NULL,
+    "secret" varchar(255) NOT NULL,
+    "is_active" boolean NOT NULL DEFAULT true,
+    "description" text,
+    "user_ins" bigint,
+    "user_updt" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_subscriptions_inserted_by" FOREIGN KEY ("user_ins") REFERENCES "users"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_webhook_subscriptions_updated_by" FOREIGN KEY ("user_updt") REFERENCES "users"("id") ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_tenant_id" ON "webhook_subscriptions" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_deleted_at" ON "webhook_subscriptions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "tenant_id" bigint,
+    "webhook_subscription_id" bigint NOT NULL,
+    "outbox_event_id" bigint,
+    "event" varchar(50) NOT NULL,
+    "resource_id" bigint,
+    "payload" text NOT NULL,
+    "status" varchar(10) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "last_attempt_at" timestamptz,
+    "response_status" bigint,
+    "last_error" text,
+    "delivered_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_webhook_subscription" FOREIGN KEY ("webhook_subscription_id") REFERENCES "webhook_subscriptions"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_resource_id" ON "webhook_deliveries" ("resource_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event" ON "webhook_deliveries" ("event");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_outbox_event_id" ON "webhook_deliveries" ("outbox_event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_subscription_id" ON "webhook_deliveries" ("webhook_subscription_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_tenant_id" ON "webhook_deliveries" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "tenant_id" bigint,
+    "event_type" varchar(50) NOT NULL,
+    "aggregate_type" varchar(30) NOT NULL,
+    "aggregate_id" bigint NOT NULL,
+    "payload" text NOT NULL,
+    "user_id" bigint,
+    "status" varchar(10) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "published_sinks" varchar(255) NOT NULL DEFAULT '',
+    "last_error" text,
+    "published_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_next_attempt_at" ON "outbox_events" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_status" ON "outbox_events" ("status");
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_aggregate" ON "outbox_events" ("aggregate_type","aggregate_id");
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_event_type" ON "outbox_events" ("event_type");
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_tenant_id" ON "outbox_events" ("tenant_id");
+
+-- The default tenant owns the seed data. When it is new, it also gets every existing row: the data of a deployment
+-- that predates multi-tenancy. Rows added later without a tenant, such as platform users, are left alone.
+DO $$
+DECLARE
+    default_tenant_id bigint;
+BEGIN
+    INSERT INTO tenants (code, name, is_active, created_at, updated_at)
+    VALUES ('default', 'Default', true, now(), now())
+    ON CONFLICT (code) DO NOTHING
+    RETURNING id INTO default_tenant_id;
+
+    IF default_tenant_id IS NOT NULL THEN
+        UPDATE users SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE roles SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE brands SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE categories SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE products SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_batches SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_batch_tracks SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_units SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_unit_tracks SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE locations SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE sub_locations SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_stocks SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_stock_tracks SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_items SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE product_item_tracks SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE replenishment_rules SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE replenishment_alerts SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE webhook_subscriptions SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE webhook_deliveries SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+        UPDATE outbox_events SET tenant_id = default_tenant_id WHERE tenant_id IS NULL;
+    END IF;
+END $$;
@@ -0,0 +1,6 @@
+-- pg_trgm stays installed, other database objects may use it
+DROP INDEX IF EXISTS idx_products_name_trgm;
+DROP INDEX IF EXISTS idx_brands_name_trgm;
+DROP INDEX IF EXISTS idx_categories_name_trgm;
+DROP INDEX IF EXISTS idx_product_batches_code_batch_trgm;
+DROP INDEX IF EXISTS idx_product_units_barcode_trgm;
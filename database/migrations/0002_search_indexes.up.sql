@@ -0,0 +1,8 @@
+-- Trigram indexes behind the fuzzy fallback of product search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_brands_name_trgm ON brands USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_categories_name_trgm ON categories USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_product_batches_code_batch_trgm ON product_batches USING gin (code_batch gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_product_units_barcode_trgm ON product_units USING gin (barcode gin_trgm_ops);
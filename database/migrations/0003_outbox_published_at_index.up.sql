@@ -0,0 +1,2 @@
+-- The outbox relay purges published events by age
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
@@ -0,0 +1,2 @@
+-- Replayed responses send the ETag of the first response again
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS "etag" varchar(100);
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS "version";
+ALTER TABLE tenants DROP COLUMN IF EXISTS "version";
+ALTER TABLE product_unit_tracks DROP COLUMN IF EXISTS "version";
+ALTER TABLE product_item_tracks DROP COLUMN IF EXISTS "version";
+ALTER TABLE product_stock_tracks DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,6 @@
+-- Tracks, tenants and users are versioned too, so their PUT and DELETE take If-Match
+ALTER TABLE product_stock_tracks ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE product_item_tracks ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE product_unit_tracks ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
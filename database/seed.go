@@ -1,10 +1,18 @@
 package database
 
 import (
+	"context"
 	"myapp/database/seeder"
+	"myapp/internal/tenant"
 )
 
-func Seed() error {
-	// Auto-run all seeders from seeder folder
-	return seeder.RunAllSeeders(DB)
+// Seed runs the seeders of env (seeder.EnvDev or seeder.EnvProd), only those named in only when it is not empty
+func Seed(env string, only []string) error {
+	// Seed data belongs to the default tenant
+	tenantID, err := DefaultTenantID()
+	if err != nil {
+		return err
+	}
+
+	return seeder.RunSeeders(DB.WithContext(tenant.WithID(context.Background(), tenantID)), env, only)
 }
\ No newline at end of file
@@ -10,22 +10,22 @@ import (
 func GetAllSeeders() *SeederRegistry {
 	registry := NewSeederRegistry()
 
-	// Register all seeders here in proper dependency order
-	// Add new seeders to this list to auto-run them
-	registry.Register(NewUserSeeder())         // Base users first
-	registry.Register(NewRoleSeeder())         // Roles
-	registry.Register(NewBrandSeeder())        // Product dependencies
-	registry.Register(NewCategorySeeder())     // Product dependencies
-	registry.Register(NewLocationSeeder())     // Location must be before ProductUnit
-	registry.Register(NewProductSeeder())      // Products
-	registry.Register(NewProductBatchSeeder()) // Product batches must be before ProductUnit
-	registry.Register(NewProductBatchTrackSeeder())
-	registry.Register(NewProductUnitSeeder()) // ProductUnit depends on Location & ProductBatch
-	registry.Register(NewProductUnitTrackSeeder())
-	registry.Register(NewProductStockSeeder())
-	registry.Register(NewProductStockTrackSeeder())
-	registry.Register(NewProductItemSeeder())
-	registry.Register(NewProductItemTrackSeeder())
+	// Register all seeders here in proper dependency order, with the environments they run in:
+	// sample data is for dev only, prod gets what a new deployment needs
+	registry.Register(NewUserSeeder(), EnvDev)          // Base users first
+	registry.Register(NewRoleSeeder(), EnvDev, EnvProd) // Roles
+	registry.Register(NewBrandSeeder(), EnvDev)         // Product dependencies
+	registry.Register(NewCategorySeeder(), EnvDev)      // Product dependencies
+	registry.Register(NewLocationSeeder(), EnvDev)      // Location must be before ProductUnit
+	registry.Register(NewProductSeeder(), EnvDev)       // Products
+	registry.Register(NewProductBatchSeeder(), EnvDev)  // Product batches must be before ProductUnit
+	registry.Register(NewProductBatchTrackSeeder(), EnvDev)
+	registry.Register(NewProductUnitSeeder(), EnvDev) // ProductUnit depends on Location & ProductBatch
+	registry.Register(NewProductUnitTrackSeeder(), EnvDev)
+	registry.Register(NewProductStockSeeder(), EnvDev)
+	registry.Register(NewProductStockTrackSeeder(), EnvDev)
+	registry.Register(NewProductItemSeeder(), EnvDev)
+	registry.Register(NewProductItemTrackSeeder(), EnvDev)
 	// registry.Register(NewWarehouseSeeder())
 
 	// Future seeders:
@@ -36,20 +36,24 @@ func GetAllSeeders() *SeederRegistry {
 	return registry
 }
 
-// RunAllSeeders executes all registered seeders
-func RunAllSeeders(db *gorm.DB) error {
-	log.Println("🌱 Starting database seeding...")
+// RunSeeders executes the seeders of env, only those named in only when it is not empty
+func RunSeeders(db *gorm.DB, env string, only []string) error {
+	log.Printf("🌱 Starting database seeding for %s...", env)
 
-	registry := GetAllSeeders()
-
-	log.Printf("📋 Found %d seeders to run", len(registry.GetSeeders()))
-
-	err := registry.RunAll(db)
+	seeders, err := GetAllSeeders().Select(env, only)
 	if err != nil {
-		log.Printf("❌ Seeding failed: %v", err)
 		return err
 	}
 
+	log.Printf("📋 Found %d seeders to run", len(seeders))
+
+	for _, seeder := range seeders {
+		if err := seeder.Seed(db); err != nil {
+			log.Printf("❌ Seeding failed: %v", err)
+			return err
+		}
+	}
+
 	log.Println("✅ Database seeding completed successfully!")
 	return nil
 }
@@ -40,5 +40,14 @@ func (s *RoleSeeder) Seed(db *gorm.DB) error {
 		}
 	}
 
+	// The seeded admin gets the Admin role, which is not limited to assigned locations
+	var adminRole model.Role
+	if err := db.Where("name = ?", model.RoleAdmin).First(&adminRole).Error; err == nil {
+		if err := db.Model(&model.User{}).Where("email = ? AND role_id IS NULL", "admin@wms.com").Update("role_id", adminRole.ID).Error; err != nil {
+			log.Printf("❌ Failed to assign Admin role: %v", err)
+			return err
+		}
+	}
+
 	return nil
 }
\ No newline at end of file
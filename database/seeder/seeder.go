@@ -1,6 +1,18 @@
 package seeder
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Environments seeders are registered for, chosen with seed --env
+const (
+	EnvDev  = "dev"
+	EnvProd = "prod"
+)
 
 // SeederInterface defines the contract for all seeders
 type SeederInterface interface {
@@ -11,18 +23,84 @@ type SeederInterface interface {
 // SeederRegistry holds all registered seeders
 type SeederRegistry struct {
 	seeders []SeederInterface
+	envs    map[string][]string
 }
 
 // NewSeederRegistry creates a new seeder registry
 func NewSeederRegistry() *SeederRegistry {
 	return &SeederRegistry{
 		seeders: make([]SeederInterface, 0),
+		envs:    make(map[string][]string),
 	}
 }
 
-// Register adds a seeder to the registry
-func (r *SeederRegistry) Register(seeder SeederInterface) {
+// Register adds a seeder to the registry, to run in the environments envs
+func (r *SeederRegistry) Register(seeder SeederInterface, envs ...string) {
 	r.seeders = append(r.seeders, seeder)
+	r.envs[seeder.GetName()] = envs
+}
+
+// Select returns the seeders of env in registration order, only those named in only when it is not empty
+func (r *SeederRegistry) Select(env string, only []string) ([]SeederInterface, error) {
+	wanted := make(map[string]bool, len(only))
+	for _, key := range only {
+		if r.find(key) == nil {
+			return nil, fmt.Errorf("unknown seeder %q, expected one of %s", key, strings.Join(r.Keys(), ", "))
+		}
+		wanted[key] = true
+	}
+
+	var selected []SeederInterface
+	for _, seeder := range r.seeders {
+		key := Key(seeder)
+		if len(only) > 0 && !wanted[key] {
+			continue
+		}
+		if !r.runsIn(seeder, env) {
+			if wanted[key] {
+				return nil, fmt.Errorf("seeder %q does not run in the %s environment", key, env)
+			}
+			continue
+		}
+		selected = append(selected, seeder)
+	}
+	return selected, nil
+}
+
+// Keys returns the keys of the registered seeders, as accepted by seed --only
+func (r *SeederRegistry) Keys() []string {
+	keys := make([]string, 0, len(r.seeders))
+	for _, seeder := range r.seeders {
+		keys = append(keys, Key(seeder))
+	}
+	return keys
+}
+
+func (r *SeederRegistry) find(key string) SeederInterface {
+	for _, seeder := range r.seeders {
+		if Key(seeder) == key {
+			return seeder
+		}
+	}
+	return nil
+}
+
+func (r *SeederRegistry) runsIn(seeder SeederInterface, env string) bool {
+	for _, seederEnv := range r.envs[seeder.GetName()] {
+		if seederEnv == env {
+			return true
+		}
+	}
+	return false
+}
+
+// wordBoundary finds the start of every word but the first in a CamelCase name
+var wordBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// Key names a seeder for seed --only: ProductBatchSeeder is product_batch
+func Key(seeder SeederInterface) string {
+	name := strings.TrimSuffix(seeder.GetName(), "Seeder")
+	return strings.ToLower(wordBoundary.ReplaceAllString(name, "${1}_${2}"))
 }
 
 // RunAll executes all registered seeders
@@ -1,6 +1,7 @@
 package seeder
 
 import (
+	"context"
 	"log"
 	"myapp/internal/model"
 
@@ -56,6 +57,17 @@ func (s *UserSeeder) Seed(db *gorm.DB) error {
 		return result.Error
 	}
 
-	log.Printf("✅ %s: Successfully created %d users", s.GetName(), len(users))
+	// Platform admin without a tenant, who manages tenants and picks one per request with X-Tenant-ID
+	platformAdmin := model.User{
+		Name:     "Platform Admin",
+		Email:    "platform@wms.com",
+		Password: string(hashedPassword),
+	}
+	if err := db.WithContext(context.Background()).Create(&platformAdmin).Error; err != nil {
+		log.Printf("❌ %s: Error creating platform admin: %v", s.GetName(), err)
+		return err
+	}
+
+	log.Printf("✅ %s: Successfully created %d users", s.GetName(), len(users)+1)
 	return nil
 }
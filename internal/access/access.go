@@ -0,0 +1,21 @@
+// Package access carries the location limits of a request through context.Context. Reseller users are limited to the
+// locations they are assigned to (Location.UserID); admins, platform users and background jobs are not limited.
+package access
+
+import "context"
+
+type contextKey struct{}
+
+// WithLocationOwner returns a copy of ctx limited to the locations assigned to userID
+func WithLocationOwner(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// LocationOwner returns the user whose locations ctx is limited to, if any
+func LocationOwner(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	userID, ok := ctx.Value(contextKey{}).(uint)
+	return userID, ok && userID != 0
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"myapp/internal/service"
+	"myapp/pkg/redis"
+)
+
+// cacheUsage describes the cache subcommands
+const cacheUsage = `usage: cache flush [--all]
+
+  flush          remove the cached brands and brand lists of every tenant
+  flush --all    empty the Redis database of REDIS_DB, outbox streams included; other databases on the
+                 server are left alone`
+
+// Cache runs a cache subcommand with its arguments; flush is the only one
+func Cache(args []string) error {
+	if len(args) == 0 || args[0] != "flush" {
+		if len(args) == 0 {
+			return &UsageError{Usage: cacheUsage}
+		}
+		return &UsageError{Problem: fmt.Sprintf("unknown cache command %q", args[0]), Usage: cacheUsage}
+	}
+
+	flags := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+	all := flags.Bool("all", false, "")
+	if err := parseFlags(flags, args[1:], cacheUsage); err != nil {
+		return err
+	}
+
+	if err := redis.InitRedis(); err != nil {
+		return err
+	}
+	if !redis.IsEnabled {
+		return errors.New("redis is disabled, set REDIS_ENABLED=true to flush it")
+	}
+	defer redis.Close()
+
+	cacheService := service.NewCacheService().WithContext(context.Background())
+	if *all {
+		if err := cacheService.FlushDB(); err != nil {
+			return err
+		}
+		fmt.Println("Flushed the Redis database of REDIS_DB")
+		return nil
+	}
+	if err := cacheService.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("Flushed the brand cache")
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package cli implements the subcommands of the binary besides serving the API: migrate, seed, user and cache.
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"myapp/database"
+	"strings"
+)
+
+// Usage lists the commands of the binary, printed for help and unknown commands
+const Usage = `usage: go-wms <command> [arguments]
+
+  serve                                    run the API server, the default without a command
+  migrate up|down|status|create            manage the database schema, see go-wms migrate
+  seed [--only=brand,product] [--env=dev]  insert the seed data of an environment into the default tenant
+  user create-admin --email=EMAIL          create an administrator, see go-wms user
+  cache flush [--all]                      empty the Redis cache`
+
+// UsageError is returned for a command called with wrong arguments; it is printed as is instead of logged
+type UsageError struct {
+	Problem string // What was wrong with the arguments, empty when only the usage was asked for
+	Usage   string // Usage of the command
+}
+
+func (e *UsageError) Error() string {
+	if e.Problem == "" {
+		return e.Usage
+	}
+	return e.Problem + "\n\n" + e.Usage
+}
+
+// parseFlags parses the flags of a command, turning -h and bad flags into a UsageError with usage
+func parseFlags(flags *flag.FlagSet, args []string, usage string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return &UsageError{Usage: usage}
+		}
+		return &UsageError{Problem: err.Error(), Usage: usage}
+	}
+	if flags.NArg() > 0 {
+		return &UsageError{Problem: "unexpected argument " + flags.Arg(0), Usage: usage}
+	}
+	return nil
+}
+
+// splitList splits a comma separated flag value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// connectDB connects to the database and refuses to work on a schema behind the migrations of this build
+func connectDB(ctx context.Context) error {
+	if err := database.ConnectDB(); err != nil {
+		return err
+	}
+	return database.CheckMigrations(ctx)
+}
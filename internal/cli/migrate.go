@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"myapp/database"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// migrateUsage lists the migrate subcommands
+const migrateUsage = `usage: migrate <command>
+
+  up [N]         apply the pending migrations, or only the next N
+  down [N]       revert the last applied migration, or the last N
+  status         list the migrations and when they were applied
+  create NAME    write empty up and down files for a new migration to ` + database.MigrationsDir
+
+// Migrate runs a migrate subcommand with its arguments, e.g. up or create add_bin_capacity
+func Migrate(args []string) error {
+	if len(args) == 0 {
+		return &UsageError{Usage: migrateUsage}
+	}
+	command, args := args[0], args[1:]
+
+	if command == "create" {
+		if len(args) != 1 {
+			return &UsageError{Usage: "usage: migrate create NAME"}
+		}
+		upPath, downPath, err := database.CreateMigration(database.MigrationsDir, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created", upPath)
+		fmt.Println("Created", downPath)
+		return nil
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		steps, err := stepsArg(args, 0)
+		if err != nil {
+			return err
+		}
+		if err := database.ConnectDB(); err != nil {
+			return err
+		}
+		return database.MigrateUp(ctx, steps)
+	case "down":
+		steps, err := stepsArg(args, 1)
+		if err != nil {
+			return err
+		}
+		if err := database.ConnectDB(); err != nil {
+			return err
+		}
+		return database.MigrateDown(ctx, steps)
+	case "status":
+		if err := database.ConnectDB(); err != nil {
+			return err
+		}
+		return printMigrationStatus(ctx)
+	}
+	return &UsageError{Problem: fmt.Sprintf("unknown migrate command %q", command), Usage: migrateUsage}
+}
+
+// stepsArg reads the optional migration count N
+func stepsArg(args []string, defaultSteps int) (int, error) {
+	if len(args) == 0 {
+		return defaultSteps, nil
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 || len(args) > 1 {
+		return 0, &UsageError{Problem: fmt.Sprintf("invalid migration count %q, expected a positive number", args[0]), Usage: migrateUsage}
+	}
+	return steps, nil
+}
+
+func printMigrationStatus(ctx context.Context) error {
+	statuses, err := database.MigrationStatuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
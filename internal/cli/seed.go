@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"myapp/database"
+	"myapp/database/seeder"
+	"os"
+	"strings"
+)
+
+// seedUsage describes the seed flags
+const seedUsage = `usage: seed [--only=brand,product] [--env=dev]
+
+  --only KEYS    run only these seeders, comma separated: %s
+  --env ENV      dev seeds sample data, prod only what production needs such as roles;
+                 defaults from APP_ENV, dev when it is not set`
+
+// Seed runs the seeders of an environment, or some of them, against the default tenant. Seeders skip data that
+// already exists, so seeding twice is safe.
+func Seed(args []string) error {
+	usage := fmt.Sprintf(seedUsage, strings.Join(seeder.GetAllSeeders().Keys(), ", "))
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	only := flags.String("only", "", "")
+	env := flags.String("env", "", "")
+	if err := parseFlags(flags, args, usage); err != nil {
+		return err
+	}
+
+	seedEnv, err := seedEnvironment(*env)
+	if err != nil {
+		return &UsageError{Problem: err.Error(), Usage: usage}
+	}
+	// Unknown seeders are reported before connecting
+	if _, err := seeder.GetAllSeeders().Select(seedEnv, splitList(*only)); err != nil {
+		return &UsageError{Problem: err.Error(), Usage: usage}
+	}
+
+	if err := connectDB(context.Background()); err != nil {
+		return err
+	}
+	return database.Seed(seedEnv, splitList(*only))
+}
+
+// seedEnvironment maps --env, or APP_ENV when it is empty, to the environment seeders are registered for
+func seedEnvironment(env string) (string, error) {
+	if env == "" {
+		env = os.Getenv("APP_ENV")
+	}
+	switch strings.ToLower(env) {
+	case "", seeder.EnvDev, "development", "local":
+		return seeder.EnvDev, nil
+	case seeder.EnvProd, "production":
+		return seeder.EnvProd, nil
+	}
+	return "", fmt.Errorf("unknown environment %q, use %s or %s", env, seeder.EnvDev, seeder.EnvProd)
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"myapp/internal/model"
+	"myapp/internal/service"
+	"myapp/internal/tenant"
+	"myapp/pkg/apperror"
+	"os"
+	"strings"
+)
+
+// minPasswordLength matches the minimum registration accepts
+const minPasswordLength = 6
+
+// userUsage describes the user subcommands
+const userUsage = `usage: user create-admin --email=EMAIL [--name=NAME] [--password=PASSWORD] [--tenant=CODE | --platform]
+
+  --email EMAIL      email the admin signs in with, required
+  --name NAME        display name, Admin by default
+  --password PASS    password of at least 6 characters; read from the first line of stdin when not set
+  --tenant CODE      code of the tenant the admin manages, ` + model.DefaultTenantCode + ` when not set; given the Admin role,
+                     which is created when the tenant has none
+  --platform         create a platform user instead, who has no tenant and manages all of them`
+
+// User runs a user subcommand with its arguments; create-admin is the only one
+func User(args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		if len(args) == 0 {
+			return &UsageError{Usage: userUsage}
+		}
+		return &UsageError{Problem: fmt.Sprintf("unknown user command %q", args[0]), Usage: userUsage}
+	}
+
+	flags := flag.NewFlagSet("user create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "")
+	name := flags.String("name", "Admin", "")
+	password := flags.String("password", "", "")
+	tenantCode := flags.String("tenant", "", "")
+	platform := flags.Bool("platform", false, "")
+	if err := parseFlags(flags, args[1:], userUsage); err != nil {
+		return err
+	}
+	if *email == "" {
+		return &UsageError{Problem: "--email is required", Usage: userUsage}
+	}
+	if *platform && *tenantCode != "" {
+		return &UsageError{Problem: "--tenant and --platform cannot be combined", Usage: userUsage}
+	}
+	if *tenantCode == "" {
+		*tenantCode = model.DefaultTenantCode
+	}
+
+	if *password == "" {
+		var err error
+		if *password, err = readPassword(os.Stdin); err != nil {
+			return err
+		}
+	}
+	if len(*password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	ctx := context.Background()
+	if err := connectDB(ctx); err != nil {
+		return err
+	}
+	if *platform {
+		user, err := service.NewUserService().WithContext(ctx).CreateUser(*name, *email, *password)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created platform user %s (ID %d)\n", user.Email, user.ID)
+		return nil
+	}
+	return createTenantAdmin(ctx, *tenantCode, *name, *email, *password)
+}
+
+// createTenantAdmin creates a user of the tenant with code and gives them the Admin role of the tenant
+func createTenantAdmin(ctx context.Context, code, name, email, password string) error {
+	found, err := service.NewTenantService().WithContext(ctx).GetTenantByCode(code)
+	if err != nil {
+		return err
+	}
+	ctx = tenant.WithID(ctx, found.ID)
+
+	userService := service.NewUserService().WithContext(ctx)
+	user, err := userService.CreateUser(name, email, password)
+	if err != nil {
+		return err
+	}
+
+	roleService := service.NewRoleService().WithContext(ctx)
+	role, err := roleService.GetRoleByName(model.RoleAdmin)
+	if appErr, ok := apperror.As(err); ok && appErr.Code == "role_not_found" {
+		// A tenant that was never seeded has no roles yet; the new admin is recorded as the creator
+		role, err = roleService.CreateRole(model.RoleAdmin, "Administrator with full access", user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := userService.AssignRole(user.ID, &role.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Created admin %s (ID %d) of tenant %s\n", user.Email, user.ID, found.Code)
+	return nil
+}
+
+// readPassword reads the password from the first line of r, prompting for it when r is a terminal
+func readPassword(r *os.File) (string, error) {
+	if info, err := r.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,92 @@
+// Package concurrency implements optimistic concurrency control. Versioned rows carry a version that every update
+// increments; a request that read version N sends it back in If-Match, and its update or delete only applies while the
+// row is still at version N, so two users editing the same row cannot silently overwrite each other.
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"myapp/pkg/apperror"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Column is the column holding the version of a row
+const Column = "version"
+
+// ErrVersionConflict is returned when a row changed since the version a request expected
+var ErrVersionConflict = apperror.PreconditionFailed("version_conflict", "resource was modified by another request, reload it and retry with the new ETag")
+
+// ErrInvalidETag is returned for an If-Match value that is not a version ETag
+var ErrInvalidETag = errors.New("If-Match must be the ETag of the resource, e.g. \"3\"")
+
+// precondition is the version a request expects the row of one model to be at; it applies to the first update or
+// delete of that model only, so the follow-up statements of a multi-step delete are not checked twice
+type precondition struct {
+	modelType reflect.Type
+	version   uint
+	applied   atomic.Bool
+}
+
+type contextKey struct{}
+
+// WithExpectedVersion returns a copy of ctx whose first update or delete of model only applies at version
+func WithExpectedVersion(ctx context.Context, model interface{}, version uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, &precondition{modelType: modelType(model), version: version})
+}
+
+// expectedVersion returns the precondition of ctx for model type t, if it has not been applied yet
+func expectedVersion(ctx context.Context, t reflect.Type) (*precondition, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	p, ok := ctx.Value(contextKey{}).(*precondition)
+	if !ok || p.modelType != t || p.applied.Load() {
+		return nil, false
+	}
+	return p, true
+}
+
+// ETag returns the ETag header value of a row at version
+func ETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// ParseIfMatch returns the version of an If-Match header; wildcard is true for "*", which matches every version
+func ParseIfMatch(header string) (version uint, wildcard bool, err error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, true, nil
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false, ErrInvalidETag
+	}
+	value, err := strconv.ParseUint(header[1:len(header)-1], 10, 32)
+	if err != nil || value == 0 {
+		return 0, false, ErrInvalidETag
+	}
+	return uint(value), false, nil
+}
+
+// VersionOf returns the Version field of v, a struct or a pointer to one
+func VersionOf(v interface{}) (uint, bool) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return 0, false
+	}
+	field := value.FieldByName("Version")
+	if !field.IsValid() || field.Kind() != reflect.Uint {
+		return 0, false
+	}
+	return uint(field.Uint()), true
+}
+
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
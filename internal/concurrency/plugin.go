@@ -0,0 +1,78 @@
+package concurrency
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// checkedSetting marks a statement that carries the version condition of its request
+const checkedSetting = "concurrency:checked"
+
+// Plugin versions GORM rows: every update of a model with a Version field increments it, and the first update or
+// delete of the model a request expects a version of (WithExpectedVersion) only applies to the row at that version,
+// failing with ErrVersionConflict when no row matched. Only map updates (Updates(map), Update(column, value)) are
+// incremented, which is how the repositories write; raw SQL is not versioned.
+type Plugin struct{}
+
+// NewPlugin returns the versioning plugin
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+func (p *Plugin) Name() string {
+	return "concurrency"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Update().Before("gorm:update").Register("concurrency:version", p.version); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:update").Register("concurrency:check", p.check); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("concurrency:version", p.condition); err != nil {
+		return err
+	}
+	return callback.Delete().After("gorm:delete").Register("concurrency:check", p.check)
+}
+
+// version increments the version of updated rows and adds the expected version condition
+func (p *Plugin) version(db *gorm.DB) {
+	statement := db.Statement
+	if db.Error != nil || statement.SQL.Len() > 0 || statement.Schema == nil || statement.Schema.LookUpField(Column) == nil {
+		return
+	}
+	switch statement.Dest.(type) {
+	case map[string]interface{}, []map[string]interface{}:
+		statement.SetColumn(Column, gorm.Expr(Column+" + 1"))
+	}
+	p.condition(db)
+}
+
+// condition adds "version = ?" to the first update or delete of the model the request expects a version of
+func (p *Plugin) condition(db *gorm.DB) {
+	statement := db.Statement
+	if db.Error != nil || statement.SQL.Len() > 0 || statement.Schema == nil || statement.Schema.LookUpField(Column) == nil {
+		return
+	}
+	expected, ok := expectedVersion(statement.Context, statement.Schema.ModelType)
+	if !ok || !expected.applied.CompareAndSwap(false, true) {
+		return
+	}
+
+	statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: Column}, Value: expected.version},
+	}})
+	statement.Settings.Store(checkedSetting, true)
+}
+
+// check fails a conditional statement that matched no row, meaning the row is at another version
+func (p *Plugin) check(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if _, checked := db.Statement.Settings.Load(checkedSetting); checked && db.RowsAffected == 0 {
+		db.AddError(ErrVersionConflict)
+	}
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Domain event types, recorded in the outbox in the same transaction as the change they describe
+const (
+	ProductCreated  = "product.created"
+	ProductUpdated  = "product.updated"
+	ProductDeleted  = "product.deleted"
+	ProductRestored = "product.restored"
+
+	BatchCreated       = "batch.created"
+	BatchUpdated       = "batch.updated"
+	BatchDeleted       = "batch.deleted"
+	BatchRestored      = "batch.restored"
+	BatchStatusChanged = "batch.status_changed"
+
+	StockCreated       = "stock.created"
+	StockUpdated       = "stock.updated"
+	StockMoved         = "stock.moved" // Stock in or out (product items and tracks) or a transfer to another location or bin
+	StockStatusChanged = "stock.status_changed"
+	StockDeleted       = "stock.deleted"
+
+	UnitCreated  = "unit.created"
+	UnitUpdated  = "unit.updated"
+	UnitDeleted  = "unit.deleted"
+	UnitRestored = "unit.restored"
+
+	ItemUpdated = "item.updated"
+	ItemDeleted = "item.deleted"
+)
+
+// Types lists every domain event type
+var Types = []string{
+	ProductCreated, ProductUpdated, ProductDeleted, ProductRestored,
+	BatchCreated, BatchUpdated, BatchDeleted, BatchRestored, BatchStatusChanged,
+	StockCreated, StockUpdated, StockMoved, StockStatusChanged, StockDeleted,
+	UnitCreated, UnitUpdated, UnitDeleted, UnitRestored,
+	ItemUpdated, ItemDeleted,
+}
+
+// Aggregate types, the kind of record an event is about
+const (
+	AggregateProduct      = "product"
+	AggregateProductBatch = "product_batch"
+	AggregateProductStock = "product_stock"
+	AggregateProductUnit  = "product_unit"
+	AggregateProductItem  = "product_item"
+)
+
+// Event is a domain event before it is stored
+type Event struct {
+	Type          string
+	AggregateType string
+	AggregateID   uint
+	UserID        *uint
+	Data          interface{}
+}
+
+// Envelope is a stored event as handed to sinks
+type Envelope struct {
+	ID            uint            `json:"id"` // Outbox ID, stable across retries
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregateType"`
+	AggregateID   uint            `json:"aggregateId"`
+	UserID        *uint           `json:"userId"`
+	TenantID      *uint           `json:"tenantId"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// StockMovement is the data of a stock.moved event; quantities are only set for stock in/out and track changes,
+// locations and bins only for transfers
+type StockMovement struct {
+	ProductStockID      uint        `json:"productStockId"`
+	ProductItemID       *uint       `json:"productItemId,omitempty"`
+	ProductStockTrackID *uint       `json:"productStockTrackId,omitempty"` // Set when a stock track was created, edited or deleted
+	ProductItemTrackID  *uint       `json:"productItemTrackId,omitempty"`  // Set when an item track was created, edited or deleted
+	ProductID           uint        `json:"productId"`
+	ProductBatchID      uint        `json:"productBatchId"`
+	StockIn             *float64    `json:"stockIn,omitempty"`
+	StockOut            *float64    `json:"stockOut,omitempty"`
+	FromLocationID      *uint       `json:"fromLocationId,omitempty"`
+	ToLocationID        *uint       `json:"toLocationId,omitempty"`
+	FromBinID           *uint       `json:"fromBinId,omitempty"`
+	ToBinID             *uint       `json:"toBinId,omitempty"`
+	Stock               interface{} `json:"stock,omitempty"` // The stock row after the move
+}
+
+// Changes is the data of an update event: the record before and after the change
+type Changes struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Deleted is the data of a delete event
+type Deleted struct {
+	ID uint `json:"id"`
+}
+
+// Sink receives relayed events; Publish must be safe to call again for the same envelope,
+// since an event is retried until every sink has accepted it
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, envelope Envelope) error
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"myapp/pkg/logger"
+	"myapp/pkg/redis"
+	"strconv"
+)
+
+var eventLog = logger.New("event")
+
+// LogSink writes every event to the application log
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, envelope Envelope) error {
+	eventLog.InfoContext(ctx, "Event", "event_id", envelope.ID, "type", envelope.Type, "aggregate_type", envelope.AggregateType,
+		"aggregate_id", envelope.AggregateID, "tenant_id", formatTenant(envelope.TenantID), "data", envelope.Data)
+	return nil
+}
+
+// DefaultRedisStream is the stream events are appended to when OUTBOX_REDIS_STREAM is not set
+const DefaultRedisStream = "inventory:events"
+
+// RedisStreamSink appends every event to a Redis stream, trimmed to about MaxLen entries (0 keeps everything)
+type RedisStreamSink struct {
+	Stream string
+	MaxLen int64
+}
+
+func (s RedisStreamSink) Name() string {
+	return "redis"
+}
+
+func (s RedisStreamSink) Publish(ctx context.Context, envelope Envelope) error {
+	if !redis.IsEnabled {
+		return errors.New("redis is not enabled")
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	_, err = redis.XAdd(ctx, s.Stream, s.MaxLen, map[string]interface{}{
+		"id":             strconv.FormatUint(uint64(envelope.ID), 10),
+		"tenant_id":      formatTenant(envelope.TenantID),
+		"type":           envelope.Type,
+		"aggregate_type": envelope.AggregateType,
+		"aggregate_id":   strconv.FormatUint(uint64(envelope.AggregateID), 10),
+		"event":          string(data),
+	})
+	return err
+}
+
+// formatTenant renders the tenant of an event, "-" for events without one
+func formatTenant(tenantID *uint) string {
+	if tenantID == nil {
+		return "-"
+	}
+	return strconv.FormatUint(uint64(*tenantID), 10)
+}
@@ -1,48 +1,19 @@
 package handler
 
 import (
-	"log"
-	"strings"
+	"strconv"
 
 	"myapp/internal/service"
+	"myapp/internal/tenant"
 	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var authUserService = service.NewUserService()
-
-// handleAuthError converts database errors to user-friendly messages for auth operations
-func handleAuthError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "email already exists" || errMsg == "user already exists" {
-		return 409, "Email already exists"
-	}
-
-	if errMsg == "invalid credentials" {
-		return 401, "Invalid email or password"
-	}
-
-	if errMsg == "user not found" {
-		return 404, "User not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") &&
-		(strings.Contains(errMsg, "users_email_key") || strings.Contains(errMsg, "uni_users_email")) {
-		return 409, "Email already exists"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var authLog = logger.New("auth")
 
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -56,9 +27,10 @@ type RegisterRequest struct {
 }
 
 type UserResponse struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	TenantID *uint  `json:"tenantId"`
 }
 
 type RegisterResponse struct {
@@ -67,104 +39,129 @@ type RegisterResponse struct {
 }
 
 func Login(c *fiber.Ctx) error {
-	log.Printf("[AUTH] Login request received from IP: %s", c.IP())
+	authLog.DebugContext(c.UserContext(), "Login request received")
 
 	var req LoginRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[AUTH] Login failed - Invalid request body for email: %s, error: %v", req.Email, err)
+		authLog.WarnContext(c.UserContext(), "Login failed: invalid request body", "email", req.Email, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		authLog.WarnContext(c.UserContext(), "Login failed: validation failed", "email", req.Email, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Use service instead of direct database access
 	user, err := authUserService.AuthenticateUser(req.Email, req.Password)
 	if err != nil {
-		log.Printf("[AUTH] Login failed - Authentication failed for email: %s, error: %v", req.Email, err)
-		return helper.Fail(c, 401, "Invalid credentials", err.Error())
+		authLog.WarnContext(c.UserContext(), "Login failed: authentication failed", "email", req.Email, "error", err)
+		return err
 	}
 
-	log.Printf("[AUTH] User authenticated successfully - ID: %d, Email: %s", user.ID, user.Email)
+	authLog.InfoContext(c.UserContext(), "User authenticated successfully", "id", user.ID, "email", user.Email)
+
+	// Users of an inactive tenant cannot sign in
+	if user.TenantID != nil {
+		active, err := tenantService.IsTenantActive(*user.TenantID)
+		if err != nil {
+			authLog.WarnContext(c.UserContext(), "Login failed: tenant lookup failed", "user_id", user.ID, "error", err)
+			return err
+		}
+		if !active {
+			authLog.WarnContext(c.UserContext(), "Login failed: tenant is inactive", "tenant_id", *user.TenantID, "user_id", user.ID)
+			return helper.Fail(c, 403, "Tenant is inactive", "tenant does not exist or is inactive")
+		}
+	}
 
 	// Generate JWT
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	token, err := utils.GenerateJWT(user.ID, user.Email, user.TenantID)
 	if err != nil {
-		log.Printf("[AUTH] Login failed - JWT generation error for user ID: %d, error: %v", user.ID, err)
+		authLog.WarnContext(c.UserContext(), "Login failed: JWT generation error", "user_id", user.ID, "error", err)
 		return helper.Fail(c, 500, "Failed to generate token", err.Error())
 	}
 
-	log.Printf("[AUTH] Login successful - User ID: %d, Email: %s, Token generated", user.ID, user.Email)
+	authLog.InfoContext(c.UserContext(), "Login successful: token generated", "user_id", user.ID, "email", user.Email)
 
-	return helper.Success(c, 200, "Login successful", fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-		},
+	return helper.Success(c, 200, "Login successful", RegisterResponse{
+		Token: token,
+		User:  UserResponse{ID: user.ID, Name: user.Name, Email: user.Email, TenantID: user.TenantID},
 	})
 }
 
 func Register(c *fiber.Ctx) error {
-	log.Printf("[AUTH] Register request received from IP: %s", c.IP())
+	authLog.DebugContext(c.UserContext(), "Register request received")
 
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[AUTH] Register failed - Invalid request body, error: %v", err)
+		authLog.WarnContext(c.UserContext(), "Register failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		authLog.WarnContext(c.UserContext(), "Register failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	// New users join the tenant chosen with the X-Tenant-ID header
+	tenantID, err := strconv.ParseUint(c.Get(tenant.HeaderName), 10, 32)
+	if err != nil || tenantID == 0 {
+		authLog.WarnContext(c.UserContext(), "Register failed: invalid or missing tenant header", "header", tenant.HeaderName, "value", c.Get(tenant.HeaderName))
+		return helper.Fail(c, 400, "Tenant required", "register with the "+tenant.HeaderName+" header of the tenant to join")
+	}
+
+	active, err := tenantService.IsTenantActive(uint(tenantID))
+	if err != nil {
+		authLog.WarnContext(c.UserContext(), "Register failed: tenant lookup failed", "tenant_id", tenantID, "error", err)
+		return err
+	}
+	if !active {
+		authLog.WarnContext(c.UserContext(), "Register failed: tenant does not exist or is inactive", "tenant_id", tenantID)
+		return helper.Fail(c, 400, "Invalid tenant", "tenant does not exist or is inactive")
+	}
 
 	// Use service instead of direct database access
-	user, err := authUserService.CreateUser(req.Name, req.Email, req.Password)
+	user, err := authUserService.WithContext(tenant.WithID(c.UserContext(), uint(tenantID))).CreateUser(req.Name, req.Email, req.Password)
 	if err != nil {
-		log.Printf("[AUTH] Register failed - User creation failed for email: %s, error: %v", req.Email, err)
-		statusCode, message := handleAuthError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		authLog.WarnContext(c.UserContext(), "Register failed: user creation failed", "email", req.Email, "error", err)
+		return err
 	}
 
-	log.Printf("[AUTH] User created successfully - ID: %d, Email: %s, Name: %s", user.ID, user.Email, user.Name)
+	authLog.InfoContext(c.UserContext(), "User created successfully", "id", user.ID, "email", user.Email, "name", user.Name)
 
 	// Generate JWT
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	token, err := utils.GenerateJWT(user.ID, user.Email, user.TenantID)
 	if err != nil {
-		log.Printf("[AUTH] Register failed - JWT generation error for user ID: %d, error: %v", user.ID, err)
+		authLog.WarnContext(c.UserContext(), "Register failed: JWT generation error", "user_id", user.ID, "error", err)
 		return helper.Fail(c, 500, "Failed to generate token", err.Error())
 	}
 
-	log.Printf("[AUTH] Registration successful - User ID: %d, Email: %s, Token generated", user.ID, user.Email)
+	authLog.InfoContext(c.UserContext(), "Registration successful: token generated", "user_id", user.ID, "email", user.Email)
 
-	return helper.Success(c, 201, "User created successfully", fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-		},
+	return helper.Success(c, 201, "User created successfully", RegisterResponse{
+		Token: token,
+		User:  UserResponse{ID: user.ID, Name: user.Name, Email: user.Email, TenantID: user.TenantID},
 	})
 }
 
 func GetProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	log.Printf("[AUTH] Profile request for user ID: %d from IP: %s", userID, c.IP())
+	authLog.DebugContext(c.UserContext(), "Profile request", "user_id", userID)
 
 	user, err := authUserService.GetUserByID(userID)
 	if err != nil {
-		log.Printf("[AUTH] Profile failed - User not found for ID: %d, error: %v", userID, err)
-		return helper.Fail(c, 404, "User not found", err.Error())
+		authLog.WarnContext(c.UserContext(), "Profile failed: user not found", "id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[AUTH] Profile retrieved successfully for user ID: %d, Email: %s", user.ID, user.Email)
+	authLog.InfoContext(c.UserContext(), "Profile retrieved successfully", "user_id", user.ID, "email", user.Email)
 
-	return helper.Success(c, 200, "Success", fiber.Map{
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": user.Email,
-	})
+	return helper.Success(c, 200, "Success", UserResponse{ID: user.ID, Name: user.Name, Email: user.Email, TenantID: user.TenantID})
 }
 
 // Future auth handlers
 func UpdateProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	log.Printf("[AUTH] Update profile request for user ID: %d from IP: %s", userID, c.IP())
-	log.Printf("[AUTH] Update profile - endpoint not yet implemented")
+	authLog.DebugContext(c.UserContext(), "Update profile request", "user_id", userID)
+	authLog.InfoContext(c.UserContext(), "Update profile: endpoint not yet implemented")
 
 	// Implement update profile
 	return helper.Success(c, 200, "Update profile endpoint", "Coming soon")
@@ -172,8 +169,8 @@ func UpdateProfile(c *fiber.Ctx) error {
 
 func Logout(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	log.Printf("[AUTH] Logout request for user ID: %d from IP: %s", userID, c.IP())
-	log.Printf("[AUTH] Logout successful for user ID: %d", userID)
+	authLog.DebugContext(c.UserContext(), "Logout request", "user_id", userID)
+	authLog.InfoContext(c.UserContext(), "Logout successful", "user_id", userID)
 
 	// Implement logout (blacklist token)
 	return helper.Success(c, 200, "Logout successful", "Token invalidated")
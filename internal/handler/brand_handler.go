@@ -1,217 +1,219 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var brandService = service.NewBrandService()
-
-// handleBrandError converts database errors to user-friendly messages for brand operations
-func handleBrandError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "brand already exists" || errMsg == "brand name already in use" {
-		return 409, "Brand name already exists"
-	}
-
-	if errMsg == "brand not found" {
-		return 404, "Brand not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") &&
-		strings.Contains(errMsg, "uni_brands_name") {
-		return 409, "Brand name already exists"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var brandLog = logger.New("brand")
 
 type CreateBrandRequest struct {
-	Name        string  `json:"name" validate:"required"`
+	Name        string  `json:"name" validate:"required,max=255"`
 	Description *string `json:"description"` // Nullable field
 }
 
 type UpdateBrandRequest struct {
-	Name        string  `json:"name" validate:"required"`
+	Name        string  `json:"name" validate:"required,max=255"`
 	Description *string `json:"description"` // Nullable field
 }
 
 func GetBrands(c *fiber.Ctx) error {
-	log.Printf("[BRAND] Get all brands request from IP: %s", c.IP())
+	brandLog.DebugContext(c.UserContext(), "Get all brands request")
 
-	brands, err := brandService.GetAllBrands()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[BRAND] Get all brands failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch brands", err.Error())
+		brandLog.WarnContext(c.UserContext(), "Get all brands failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[BRAND] Get all brands successful - Found %d brands", len(brands))
-	return helper.Success(c, 200, "Success", brands)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		brandLog.WarnContext(c.UserContext(), "Get all brands failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	brands, total, err := brandService.WithContext(c.UserContext()).GetAllBrands(query)
+	if err != nil {
+		brandLog.WarnContext(c.UserContext(), "Get all brands failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		brandLog.InfoContext(c.UserContext(), "Export brands", "format", format, "rows", total)
+		return sendListExport(c, format, "brands", brands)
+	}
+
+	brandLog.InfoContext(c.UserContext(), "Get all brands successful", "count", len(brands))
+	return helper.SuccessWithMeta(c, 200, "Success", brands, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetBrandByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[BRAND] Get brand by ID request - ID: %s from IP: %s", id, c.IP())
+	brandLog.DebugContext(c.UserContext(), "Get brand by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[BRAND] Get brand by ID failed - Invalid ID: %s, error: %v", id, err)
+		brandLog.WarnContext(c.UserContext(), "Get brand by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid brand ID", err.Error())
 	}
 
-	brand, err := brandService.GetBrandByID(uint(idUint))
+	brand, err := brandService.WithContext(c.UserContext()).GetBrandByID(uint(idUint))
 	if err != nil {
-		log.Printf("[BRAND] Get brand by ID failed - Brand ID: %d not found, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Brand not found", err.Error())
+		brandLog.WarnContext(c.UserContext(), "Get brand by ID failed: not found", "brand_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Get brand by ID successful - Brand ID: %d, Name: %s", brand.ID, brand.Name)
+	brandLog.InfoContext(c.UserContext(), "Get brand by ID successful", "brand_id", brand.ID, "name", brand.Name)
+	setETag(c, brand)
 	return helper.Success(c, 200, "Success", brand)
 }
 
 func CreateBrand(c *fiber.Ctx) error {
-	log.Printf("[BRAND] Create brand request from IP: %s", c.IP())
+	brandLog.DebugContext(c.UserContext(), "Create brand request")
 
 	var req CreateBrandRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[BRAND] Create brand failed - Invalid request body, error: %v", err)
+		brandLog.WarnContext(c.UserContext(), "Create brand failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		brandLog.WarnContext(c.UserContext(), "Create brand failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[BRAND] Create brand failed - User not authenticated")
+		brandLog.WarnContext(c.UserContext(), "Create brand failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[BRAND] Creating brand with audit - User ID: %d", userID)
+	brandLog.InfoContext(c.UserContext(), "Creating brand with audit", "user_id", userID)
 
-	brand, err := brandService.CreateBrand(req.Name, req.Description, userID)
+	brand, err := brandService.WithContext(c.UserContext()).CreateBrand(req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[BRAND] Create brand failed - Name: %s, User ID: %d, error: %v", req.Name, userID, err)
-		statusCode, message := handleBrandError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		brandLog.WarnContext(c.UserContext(), "Create brand failed", "name", req.Name, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Create brand successful - Brand ID: %d, Name: %s, Created by User ID: %d", brand.ID, brand.Name, userID)
+	brandLog.InfoContext(c.UserContext(), "Create brand successful", "brand_id", brand.ID, "name", brand.Name, "created_by_user_id", userID)
+	setETag(c, brand)
 	return helper.Success(c, 200, "Brand created successfully", brand)
 }
 
 func UpdateBrand(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[BRAND] Update brand request - ID: %s from IP: %s", id, c.IP())
+	brandLog.DebugContext(c.UserContext(), "Update brand request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[BRAND] Update brand failed - Invalid ID: %s, error: %v", id, err)
+		brandLog.WarnContext(c.UserContext(), "Update brand failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid brand ID", err.Error())
 	}
 
 	var req UpdateBrandRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[BRAND] Update brand failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		brandLog.WarnContext(c.UserContext(), "Update brand failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		brandLog.WarnContext(c.UserContext(), "Update brand failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[BRAND] Update brand failed - User not authenticated for Brand ID: %d", idUint)
+		brandLog.WarnContext(c.UserContext(), "Update brand failed: user not authenticated", "brand_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[BRAND] Updating brand with audit - Brand ID: %d, User ID: %d", idUint, userID)
+	brandLog.InfoContext(c.UserContext(), "Updating brand with audit", "brand_id", idUint, "user_id", userID)
 
-	brand, err := brandService.UpdateBrand(uint(idUint), req.Name, req.Description, userID)
+	brand, err := brandService.WithContext(c.UserContext()).UpdateBrand(uint(idUint), req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[BRAND] Update brand failed - Brand ID: %d, User ID: %d, error: %v", idUint, userID, err)
-		statusCode, message := handleBrandError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		brandLog.WarnContext(c.UserContext(), "Update brand failed", "brand_id", idUint, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Update brand successful - Brand ID: %d, Name: %s, Updated by User ID: %d", brand.ID, brand.Name, userID)
+	brandLog.InfoContext(c.UserContext(), "Update brand successful", "brand_id", brand.ID, "name", brand.Name, "updated_by_user_id", userID)
+	setETag(c, brand)
 	return helper.Success(c, 200, "Brand updated successfully", brand)
 }
 
 func DeleteBrand(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[BRAND] Delete brand request - ID: %s from IP: %s", id, c.IP())
+	brandLog.DebugContext(c.UserContext(), "Delete brand request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[BRAND] Delete brand failed - Invalid ID: %s, error: %v", id, err)
+		brandLog.WarnContext(c.UserContext(), "Delete brand failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid brand ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[BRAND] Delete brand failed - User not authenticated for Brand ID: %d", idUint)
+		brandLog.WarnContext(c.UserContext(), "Delete brand failed: user not authenticated", "brand_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = brandService.DeleteBrand(uint(idUint), userID)
+	err = brandService.WithContext(c.UserContext()).DeleteBrand(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[BRAND] Delete brand failed - Brand ID: %d, error: %v", idUint, err)
-		statusCode, message := handleBrandError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		brandLog.WarnContext(c.UserContext(), "Delete brand failed", "brand_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Delete brand successful - Brand ID: %d, Deleted by User ID: %d", idUint, userID)
+	brandLog.InfoContext(c.UserContext(), "Delete brand successful", "brand_id", idUint, "deleted_by_user_id", userID)
 	return helper.Success(c, 200, "Brand deleted successfully", nil)
 }
 
 func GetDeletedBrands(c *fiber.Ctx) error {
-	log.Printf("[BRAND] Get deleted brands request from IP: %s", c.IP())
+	brandLog.DebugContext(c.UserContext(), "Get deleted brands request")
 
-	brands, err := brandService.GetDeletedBrands()
+	brands, err := brandService.WithContext(c.UserContext()).GetDeletedBrands()
 	if err != nil {
-		log.Printf("[BRAND] Get deleted brands failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted brands", err.Error())
+		brandLog.WarnContext(c.UserContext(), "Get deleted brands failed", "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Get deleted brands successful - Found %d deleted brands", len(brands))
+	brandLog.InfoContext(c.UserContext(), "Get deleted brands successful", "count", len(brands))
 	return helper.Success(c, 200, "Success", brands)
 }
 
 func RestoreBrand(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[BRAND] Restore brand request - ID: %s from IP: %s", id, c.IP())
+	brandLog.DebugContext(c.UserContext(), "Restore brand request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[BRAND] Restore brand failed - Invalid ID: %s, error: %v", id, err)
+		brandLog.WarnContext(c.UserContext(), "Restore brand failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid brand ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[BRAND] Restore brand failed - User not authenticated for Brand ID: %d", idUint)
+		brandLog.WarnContext(c.UserContext(), "Restore brand failed: user not authenticated", "brand_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	brand, err := brandService.RestoreBrand(uint(idUint), userID)
+	brand, err := brandService.WithContext(c.UserContext()).RestoreBrand(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[BRAND] Restore brand failed - Brand ID: %d, error: %v", idUint, err)
-		statusCode, message := handleBrandError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		brandLog.WarnContext(c.UserContext(), "Restore brand failed", "brand_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[BRAND] Restore brand successful - Brand ID: %d, Name: %s, Restored by User ID: %d", brand.ID, brand.Name, userID)
+	brandLog.InfoContext(c.UserContext(), "Restore brand successful", "brand_id", brand.ID, "name", brand.Name, "restored_by_user_id", userID)
+	setETag(c, brand)
 	return helper.Success(c, 200, "Brand restored successfully", brand)
 }
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"myapp/internal/concurrency"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setETag sends the version of result as its ETag, which the next PUT or DELETE of the row sends back in If-Match
+func setETag(c *fiber.Ctx, result interface{}) {
+	if version, ok := concurrency.VersionOf(result); ok && version != 0 {
+		c.Set(fiber.HeaderETag, concurrency.ETag(version))
+	}
+}
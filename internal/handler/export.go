@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var exportLog = logger.New("export")
+
+const (
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimePDF  = "application/pdf"
+)
+
+// exportMimeTypes maps the formats accepted by ?format= to their content type
+var exportMimeTypes = map[string]string{
+	"csv":  mimeCSV,
+	"xlsx": mimeXLSX,
+	"pdf":  mimePDF,
+}
+
+// negotiateExportFormat returns the export format requested by ?format= (or, when absent, the Accept header).
+// An empty format means the regular JSON response.
+func negotiateExportFormat(c *fiber.Ctx) (string, error) {
+	c.Vary(fiber.HeaderAccept)
+
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		if format == "json" {
+			return "", nil
+		}
+		if _, ok := exportMimeTypes[format]; !ok {
+			return "", fmt.Errorf("unsupported format %q, expected json, csv, xlsx or pdf", format)
+		}
+		return format, nil
+	}
+
+	switch c.Accepts(fiber.MIMEApplicationJSON, mimeCSV, mimeXLSX, mimePDF) {
+	case mimeCSV:
+		return "csv", nil
+	case mimeXLSX:
+		return "xlsx", nil
+	case mimePDF:
+		return "pdf", nil
+	default:
+		return "", nil
+	}
+}
+
+// sendListExport renders the result of a list endpoint as a CSV, XLSX or PDF attachment named after the list
+func sendListExport(c *fiber.Ctx, format, name string, data interface{}) error {
+	headers, rows, err := utils.FlattenRows(data)
+	if err != nil {
+		exportLog.ErrorContext(c.UserContext(), "Export failed", "name", name, "error", err)
+		return helper.Fail(c, 500, "Export failed", err.Error())
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	c.Set(fiber.HeaderContentType, exportMimeTypes[format])
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	switch format {
+	case "csv":
+		ctx := c.UserContext()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			if err := utils.WriteCSV(w, headers, rows); err != nil {
+				exportLog.ErrorContext(ctx, "Export failed", "name", name, "format", format, "error", err)
+				return
+			}
+			if err := w.Flush(); err != nil {
+				exportLog.ErrorContext(ctx, "Export failed", "name", name, "format", format, "error", err)
+			}
+		})
+		return nil
+	case "xlsx":
+		err = utils.WriteXLSX(c.Response().BodyWriter(), name, headers, rows)
+	default:
+		err = utils.WritePDF(c.Response().BodyWriter(), exportTitle(name), headers, rows)
+	}
+
+	if err != nil {
+		exportLog.ErrorContext(c.UserContext(), "Export failed", "name", name, "format", format, "error", err)
+		c.Response().ResetBody()
+		c.Set(fiber.HeaderContentDisposition, "")
+		return helper.Fail(c, 500, "Export failed", err.Error())
+	}
+	return nil
+}
+
+// sendReportExport renders a multi-section report: one CSV block, XLSX sheet or PDF table per section
+func sendReportExport(c *fiber.Ctx, format, name, title string, summary []string, sections []utils.TableSection) error {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	c.Set(fiber.HeaderContentType, exportMimeTypes[format])
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var err error
+	switch format {
+	case "csv":
+		err = utils.WriteCSVSections(c.Response().BodyWriter(), sections)
+	case "xlsx":
+		err = utils.WriteXLSXSections(c.Response().BodyWriter(), sections)
+	default:
+		err = utils.WritePDFDocument(c.Response().BodyWriter(), title, summary, sections)
+	}
+
+	if err != nil {
+		exportLog.ErrorContext(c.UserContext(), "Export failed", "name", name, "format", format, "error", err)
+		c.Response().ResetBody()
+		c.Set(fiber.HeaderContentDisposition, "")
+		return helper.Fail(c, 500, "Export failed", err.Error())
+	}
+	return nil
+}
+
+// exportTitle turns a list name such as "product-stocks" into "Product Stocks"
+func exportTitle(name string) string {
+	words := strings.Split(name, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var healthService = service.NewHealthService()
+var healthLog = logger.New("health")
+
+// HealthResponse is the body of the health endpoints, which is not wrapped in helper.APIResponse so probes and
+// uptime checks can read it directly
+type HealthResponse struct {
+	Status  string                         `json:"status"`
+	Service string                         `json:"service"`
+	Checks  map[string]service.HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthLive answers as long as the process serves HTTP, without checking anything it depends on
+func HealthLive(c *fiber.Ctx) error {
+	return c.JSON(HealthResponse{Status: service.HealthOK, Service: "GO-WMS"})
+}
+
+// HealthReady pings Postgres and Redis. It answers 503 while Postgres is down; a Redis outage only degrades the
+// status, since the API serves from the database without its cache.
+func HealthReady(c *fiber.Ctx) error {
+	report := healthService.Check(c.UserContext())
+
+	status := fiber.StatusOK
+	if !report.Ready() {
+		status = fiber.StatusServiceUnavailable
+	}
+	if report.Status != service.HealthOK {
+		healthLog.WarnContext(c.UserContext(), "Health check failed", "status", report.Status, "checks", report.Checks)
+	}
+	return c.Status(status).JSON(HealthResponse{Status: report.Status, Service: "GO-WMS", Checks: report.Checks})
+}
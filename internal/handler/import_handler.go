@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var importService = service.NewImportService()
+var importLog = logger.New("import")
+
+func ImportRecords(c *fiber.Ctx) error {
+	entity := c.Params("entity")
+	dryRun := c.QueryBool("dry_run", false)
+	importLog.DebugContext(c.UserContext(), "Import request", "entity", entity, "dry_run", dryRun)
+
+	// Get user ID from JWT token
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		importLog.WarnContext(c.UserContext(), "Import failed: user not authenticated")
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		importLog.WarnContext(c.UserContext(), "Import failed: missing file", "error", err)
+		return helper.Fail(c, 400, "File is required", "multipart form field 'file' is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		importLog.WarnContext(c.UserContext(), "Import failed: cannot open file", "filename", fileHeader.Filename, "error", err)
+		return helper.Fail(c, 400, "Invalid import file", err.Error())
+	}
+	defer file.Close()
+
+	table, err := utils.ReadTable(fileHeader.Filename, file)
+	if err != nil {
+		importLog.WarnContext(c.UserContext(), "Import failed: cannot read file", "filename", fileHeader.Filename, "error", err)
+		return helper.Fail(c, 400, "Invalid import file", err.Error())
+	}
+
+	result, err := importService.WithContext(c.UserContext()).Import(entity, table, dryRun, userID)
+	if err != nil {
+		importLog.WarnContext(c.UserContext(), "Import failed", "entity", entity, "user_id", userID, "error", err)
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		importLog.WarnContext(c.UserContext(), "Import rejected", "entity", entity, "invalid_rows", len(result.Errors), "total_rows", result.TotalRows, "user_id", userID)
+		return helper.Fail(c, 422, "Import has invalid rows, nothing was saved", result)
+	}
+
+	if dryRun {
+		importLog.InfoContext(c.UserContext(), "Import dry run successful", "entity", entity, "rows_valid", result.Valid, "user_id", userID)
+		return helper.Success(c, 200, "Import validated successfully, nothing was saved (dry run)", result)
+	}
+
+	importLog.InfoContext(c.UserContext(), "Import successful", "entity", entity, "created_rows", result.Valid, "user_id", userID)
+	return helper.Success(c, 201, "Import completed successfully", result)
+}
@@ -1,16 +1,17 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var locationService = service.NewLocationService()
+var locationLog = logger.New("location")
 
 // Helper functions for logging
 func safeStringPtr(s *string) string {
@@ -20,264 +21,246 @@ func safeStringPtr(s *string) string {
 	return *s
 }
 
-// handleLocationError converts database errors to user-friendly messages for location operations
-func handleLocationError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "location name already exists for this user" {
-		return 409, "Location name already exists for this user"
-	}
-
-	if errMsg == "location not found" {
-		return 404, "Location not found"
-	}
-
-	if errMsg == "user not found" {
-		return 404, "User not found"
-	}
-
-	if errMsg == "invalid location type. Must be 'gudang' or 'reseller'" {
-		return 400, "Invalid location type. Must be 'gudang' or 'reseller'"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") {
-		return 409, "Location already exists"
-	}
-
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid user ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
-
 type CreateLocationRequest struct {
 	UserID      uint    `json:"userId" validate:"required"`
-	Name        string  `json:"name" validate:"required"`
+	Name        string  `json:"name" validate:"required,max=255"`
 	Address     *string `json:"address"`
 	PhoneNumber *string `json:"phoneNumber"`
 	Type        string  `json:"type" validate:"required,oneof=gudang reseller"`
 }
 
 type UpdateLocationRequest struct {
-	UserID      uint    `json:"userId"`
-	Name        *string `json:"name"`
+	UserID      uint    `json:"userId" validate:"omitempty,min=1"` // 0 keeps the user
+	Name        *string `json:"name" validate:"omitempty,min=1,max=255"`
 	Address     *string `json:"address"`
 	PhoneNumber *string `json:"phoneNumber"`
-	Type        *string `json:"type"`
+	Type        *string `json:"type" validate:"omitempty,oneof=gudang reseller"`
 }
 
 func GetLocations(c *fiber.Ctx) error {
-	log.Printf("[LOCATION] Get all locations request from IP: %s", c.IP())
+	locationLog.DebugContext(c.UserContext(), "Get all locations request")
 
-	locations, err := locationService.GetAllLocations()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[LOCATION] Get all locations failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch locations", err.Error())
+		locationLog.WarnContext(c.UserContext(), "Get all locations failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[LOCATION] Get all locations successful")
-	return helper.Success(c, 200, "Success", locations)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		locationLog.WarnContext(c.UserContext(), "Get all locations failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	locations, total, err := locationService.WithContext(c.UserContext()).GetAllLocations(query)
+	if err != nil {
+		locationLog.WarnContext(c.UserContext(), "Get all locations failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		locationLog.InfoContext(c.UserContext(), "Export locations", "format", format, "rows", total)
+		return sendListExport(c, format, "locations", locations)
+	}
+
+	locationLog.InfoContext(c.UserContext(), "Get all locations successful")
+	return helper.SuccessWithMeta(c, 200, "Success", locations, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetLocationsByUser(c *fiber.Ctx) error {
 	userID := c.Params("userId")
-	log.Printf("[LOCATION] Get locations by user request - User ID: %s from IP: %s", userID, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Get locations by user request", "user_id", userID)
 
 	userIDUint, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
-		log.Printf("[LOCATION] Get locations by user failed - Invalid User ID: %s, error: %v", userID, err)
+		locationLog.WarnContext(c.UserContext(), "Get locations by user failed: invalid User ID", "user_id", userID, "error", err)
 		return helper.Fail(c, 400, "Invalid user ID", err.Error())
 	}
 
-	locations, err := locationService.GetLocationsByUser(uint(userIDUint))
+	locations, err := locationService.WithContext(c.UserContext()).GetLocationsByUser(uint(userIDUint))
 	if err != nil {
-		log.Printf("[LOCATION] Get locations by user failed - User ID: %d, error: %v", userIDUint, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Get locations by user failed", "user_id", userIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Get locations by user successful - User ID: %d", userIDUint)
+	locationLog.InfoContext(c.UserContext(), "Get locations by user successful", "user_id", userIDUint)
 	return helper.Success(c, 200, "Success", locations)
 }
 
 func GetLocationsByType(c *fiber.Ctx) error {
 	locationType := c.Params("type")
-	log.Printf("[LOCATION] Get locations by type request - Type: %s from IP: %s", locationType, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Get locations by type request", "type", locationType)
 
-	locations, err := locationService.GetLocationsByType(locationType)
+	locations, err := locationService.WithContext(c.UserContext()).GetLocationsByType(locationType)
 	if err != nil {
-		log.Printf("[LOCATION] Get locations by type failed - Type: %s, error: %v", locationType, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Get locations by type failed", "type", locationType, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Get locations by type successful - Type: %s", locationType)
+	locationLog.InfoContext(c.UserContext(), "Get locations by type successful", "type", locationType)
 	return helper.Success(c, 200, "Success", locations)
 }
 
 func GetLocationByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[LOCATION] Get location by ID request - ID: %s from IP: %s", id, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Get location by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[LOCATION] Get location by ID failed - Invalid ID: %s, error: %v", id, err)
+		locationLog.WarnContext(c.UserContext(), "Get location by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid location ID", err.Error())
 	}
 
-	location, err := locationService.GetLocationByID(uint(idUint))
+	location, err := locationService.WithContext(c.UserContext()).GetLocationByID(uint(idUint))
 	if err != nil {
-		log.Printf("[LOCATION] Get location by ID failed - Location ID: %d, error: %v", idUint, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Get location by ID failed", "location_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Get location by ID successful - Location ID: %d", idUint)
+	locationLog.InfoContext(c.UserContext(), "Get location by ID successful", "location_id", idUint)
+	setETag(c, location)
 	return helper.Success(c, 200, "Success", location)
 }
 
 func CreateLocation(c *fiber.Ctx) error {
-	log.Printf("[LOCATION] Create location request from IP: %s", c.IP())
+	locationLog.DebugContext(c.UserContext(), "Create location request")
 
 	var req CreateLocationRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[LOCATION] Create location failed - Invalid request body, error: %v", err)
+		locationLog.WarnContext(c.UserContext(), "Create location failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		locationLog.WarnContext(c.UserContext(), "Create location failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token for audit trail
 	createdByUserID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[LOCATION] Create location failed - User not authenticated")
+		locationLog.WarnContext(c.UserContext(), "Create location failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[LOCATION] Creating location with audit - User ID: %d, Name: %s, Phone: %s, Type: %s", createdByUserID, req.Name, safeStringPtr(req.PhoneNumber), req.Type)
+	locationLog.InfoContext(c.UserContext(), "Creating location with audit", "user_id", createdByUserID, "name", req.Name, "phone", safeStringPtr(req.PhoneNumber), "type", req.Type)
 
-	location, err := locationService.CreateLocation(req.UserID, req.Name, req.Address, req.PhoneNumber, req.Type, createdByUserID)
+	location, err := locationService.WithContext(c.UserContext()).CreateLocation(req.UserID, req.Name, req.Address, req.PhoneNumber, req.Type, createdByUserID)
 	if err != nil {
-		log.Printf("[LOCATION] Create location failed - User ID: %d, Created by User ID: %d, error: %v", req.UserID, createdByUserID, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Create location failed", "user_id", req.UserID, "created_by_user_id", createdByUserID, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Create location successful - Created by User ID: %d", createdByUserID)
+	locationLog.InfoContext(c.UserContext(), "Create location successful", "created_by_user_id", createdByUserID)
+	setETag(c, location)
 	return helper.Success(c, 201, "Location created successfully", location)
 }
 
 func UpdateLocation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[LOCATION] Update location request - ID: %s from IP: %s", id, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Update location request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[LOCATION] Update location failed - Invalid ID: %s, error: %v", id, err)
+		locationLog.WarnContext(c.UserContext(), "Update location failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid location ID", err.Error())
 	}
 
 	var req UpdateLocationRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[LOCATION] Update location failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		locationLog.WarnContext(c.UserContext(), "Update location failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		locationLog.WarnContext(c.UserContext(), "Update location failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token for audit trail
 	updatedByUserID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[LOCATION] Update location failed - User not authenticated for Location ID: %d", idUint)
+		locationLog.WarnContext(c.UserContext(), "Update location failed: user not authenticated", "location_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[LOCATION] Updating location - ID: %d, Name: %s, Phone: %s, Type: %s, Updated by User ID: %d",
-		idUint,
-		safeStringPtr(req.Name),
-		safeStringPtr(req.PhoneNumber),
-		safeStringPtr(req.Type),
-		updatedByUserID)
+	locationLog.InfoContext(c.UserContext(), "Updating location", "id", idUint, "name", safeStringPtr(req.Name), "phone", safeStringPtr(req.PhoneNumber), "type", safeStringPtr(req.Type), "updated_by_user_id", updatedByUserID)
 
-	location, err := locationService.UpdateLocation(uint(idUint), req.UserID, req.Name, req.Address, req.PhoneNumber, req.Type, updatedByUserID)
+	location, err := locationService.WithContext(c.UserContext()).UpdateLocation(uint(idUint), req.UserID, req.Name, req.Address, req.PhoneNumber, req.Type, updatedByUserID)
 	if err != nil {
-		log.Printf("[LOCATION] Update location failed - Location ID: %d, Updated by User ID: %d, error: %v", idUint, updatedByUserID, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Update location failed", "location_id", idUint, "updated_by_user_id", updatedByUserID, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Update location successful - Updated by User ID: %d", updatedByUserID)
+	locationLog.InfoContext(c.UserContext(), "Update location successful", "updated_by_user_id", updatedByUserID)
+	setETag(c, location)
 	return helper.Success(c, 200, "Location updated successfully", location)
 }
 
 func DeleteLocation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[LOCATION] Delete location request - ID: %s from IP: %s", id, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Delete location request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[LOCATION] Delete location failed - Invalid ID: %s, error: %v", id, err)
+		locationLog.WarnContext(c.UserContext(), "Delete location failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid location ID", err.Error())
 	}
 
 	// Get user ID from JWT token for audit trail
 	deletedByUserID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[LOCATION] Delete location failed - User not authenticated for Location ID: %d", idUint)
+		locationLog.WarnContext(c.UserContext(), "Delete location failed: user not authenticated", "location_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = locationService.DeleteLocation(uint(idUint), deletedByUserID)
+	err = locationService.WithContext(c.UserContext()).DeleteLocation(uint(idUint), deletedByUserID)
 	if err != nil {
-		log.Printf("[LOCATION] Delete location failed - Location ID: %d, error: %v", idUint, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Delete location failed", "location_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Delete location successful - Location ID: %d", idUint)
+	locationLog.InfoContext(c.UserContext(), "Delete location successful", "location_id", idUint)
 	return helper.Success(c, 200, "Location deleted successfully", nil)
 }
 
 func GetDeletedLocations(c *fiber.Ctx) error {
-	log.Printf("[LOCATION] Get deleted locations request from IP: %s", c.IP())
+	locationLog.DebugContext(c.UserContext(), "Get deleted locations request")
 
-	locations, err := locationService.GetDeletedLocations()
+	locations, err := locationService.WithContext(c.UserContext()).GetDeletedLocations()
 	if err != nil {
-		log.Printf("[LOCATION] Get deleted locations failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted locations", err.Error())
+		locationLog.WarnContext(c.UserContext(), "Get deleted locations failed", "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Get deleted locations successful")
+	locationLog.InfoContext(c.UserContext(), "Get deleted locations successful")
 	return helper.Success(c, 200, "Success", locations)
 }
 
 func RestoreLocation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[LOCATION] Restore location request - ID: %s from IP: %s", id, c.IP())
+	locationLog.DebugContext(c.UserContext(), "Restore location request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[LOCATION] Restore location failed - Invalid ID: %s, error: %v", id, err)
+		locationLog.WarnContext(c.UserContext(), "Restore location failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid location ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[LOCATION] Restore location failed - User not authenticated for Location ID: %d", idUint)
+		locationLog.WarnContext(c.UserContext(), "Restore location failed: user not authenticated", "location_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	location, err := locationService.RestoreLocation(uint(idUint), userID)
+	location, err := locationService.WithContext(c.UserContext()).RestoreLocation(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[LOCATION] Restore location failed - Location ID: %d, error: %v", idUint, err)
-		statusCode, message := handleLocationError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		locationLog.WarnContext(c.UserContext(), "Restore location failed", "location_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[LOCATION] Restore location successful - Location ID: %d, Restored by User ID: %d", idUint, userID)
+	locationLog.InfoContext(c.UserContext(), "Restore location successful", "location_id", idUint, "restored_by_user_id", userID)
+	setETag(c, location)
 	return helper.Success(c, 200, "Location restored successfully", location)
 }
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"myapp/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamNDJSON streams rows produced by export as newline-delimited JSON without buffering the whole result.
+// The status is already sent when export runs, so failures midway are only logged and end the stream.
+func streamNDJSON(c *fiber.Ctx, filename string, exportLog *slog.Logger, export func(write func(row interface{}) error) error) error {
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	ctx := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		encoder := json.NewEncoder(w)
+		rows := 0
+
+		err := export(func(row interface{}) error {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+			rows++
+			if rows%utils.ExportBatchSize == 0 {
+				return w.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			exportLog.ErrorContext(ctx, "Export failed", "rows", rows, "error", err)
+			return
+		}
+
+		if err := w.Flush(); err != nil {
+			exportLog.ErrorContext(ctx, "Export failed", "rows", rows, "error", err)
+			return
+		}
+		exportLog.InfoContext(ctx, "Export successful", "rows", rows)
+	})
+
+	return nil
+}
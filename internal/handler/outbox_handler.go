@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var outboxService = service.NewOutboxService()
+var outboxLog = logger.New("outbox")
+
+// GetOutboxEvents returns the recorded domain events with their relay state
+func GetOutboxEvents(c *fiber.Ctx) error {
+	outboxLog.DebugContext(c.UserContext(), "Get all outbox events request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		outboxLog.WarnContext(c.UserContext(), "Get all outbox events failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	outboxEvents, total, err := outboxService.WithContext(c.UserContext()).GetAllOutboxEvents(query)
+	if err != nil {
+		outboxLog.WarnContext(c.UserContext(), "Get all outbox events failed", "error", err)
+		return err
+	}
+
+	outboxLog.InfoContext(c.UserContext(), "Get all outbox events successful")
+	return helper.SuccessWithMeta(c, 200, "Outbox events retrieved successfully", outboxEvents, helper.NewPagination(query.Page, query.PageSize, total))
+}
@@ -1,174 +1,242 @@
 package handler
 
 import (
-	"log"
-	"myapp/internal/repository"
+	"fmt"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productBatchService = service.NewProductBatchService()
-
-// handleProductBatchError converts database errors to user-friendly messages for product batch operations
-func handleProductBatchError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "product batch not found" {
-		return 404, "Product batch not found"
-	}
-
-	if errMsg == "product not found" {
-		return 404, "Product not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid product ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var productBatchLog = logger.New("product_batch")
 
 type CreateProductBatchRequest struct {
 	ProductID   uint     `json:"productId" validate:"required"`
-	CodeBatch   *string  `json:"codeBatch"` // Nullable field
-	UnitPrice   *float64 `json:"unitPrice"` // Nullable field
-	ExpDate     string   `json:"expDate" validate:"required"`
+	CodeBatch   *string  `json:"codeBatch"`                            // Nullable field
+	UnitPrice   *float64 `json:"unitPrice" validate:"omitempty,gte=0"` // Nullable field
+	ExpDate     string   `json:"expDate" validate:"required,datetime=2006-01-02"`
 	Description *string  `json:"description"` // Nullable field
 }
 
 type UpdateProductBatchRequest struct {
-	ProductID   uint     `json:"productId"`
-	CodeBatch   *string  `json:"codeBatch"` // Nullable field
-	UnitPrice   *float64 `json:"unitPrice"` // Nullable field
-	ExpDate     string   `json:"expDate"`
+	ProductID   uint     `json:"productId" validate:"omitempty,min=1"` // 0 keeps the product
+	CodeBatch   *string  `json:"codeBatch"`                            // Nullable field
+	UnitPrice   *float64 `json:"unitPrice" validate:"omitempty,gte=0"` // Nullable field
+	ExpDate     string   `json:"expDate" validate:"omitempty,datetime=2006-01-02"`
 	Description *string  `json:"description"` // Nullable field
 }
 
 func GetProductBatches(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_BATCH] Get all product batches request from IP: %s", c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Get all product batches request")
 
-	batches, err := productBatchService.GetAllProductBatches()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get all product batches failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch product batches", err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Get all product batches failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_BATCH] Get all product batches successful")
-	return helper.Success(c, 200, "Success", batches)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Get all product batches failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	batches, total, err := productBatchService.WithContext(c.UserContext()).GetAllProductBatches(query)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Get all product batches failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productBatchLog.InfoContext(c.UserContext(), "Export product-batches", "format", format, "rows", total)
+		return sendListExport(c, format, "product-batches", batches)
+	}
+
+	productBatchLog.InfoContext(c.UserContext(), "Get all product batches successful")
+	return helper.SuccessWithMeta(c, 200, "Success", batches, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductBatchesByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_BATCH] Get product batches by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Get product batches by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get product batches by product failed - Invalid Product ID: %s, error: %v", productID, err)
+		productBatchLog.WarnContext(c.UserContext(), "Get product batches by product failed: invalid Product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	batches, err := productBatchService.GetProductBatchesByProduct(uint(productIDUint))
+	batches, err := productBatchService.WithContext(c.UserContext()).GetProductBatchesByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get product batches by product failed - Product ID: %d, error: %v", productIDUint, err)
-		statusCode, message := handleProductBatchError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Get product batches by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Get product batches by product successful")
+	productBatchLog.InfoContext(c.UserContext(), "Get product batches by product successful")
 	return helper.Success(c, 200, "Success", batches)
 }
 
 func GetProductBatchByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_BATCH] Get product batch by ID request - ID: %s from IP: %s", id, c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Get product batch by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get product batch by ID failed - Invalid ID: %s, error: %v", id, err)
+		productBatchLog.WarnContext(c.UserContext(), "Get product batch by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
 	}
 
-	batchRepo := repository.NewProductBatchRepository()
-	batch, err := batchRepo.GetProductBatchByID(uint(idUint))
+	batch, err := productBatchService.WithContext(c.UserContext()).GetProductBatchByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get product batch by ID failed - Batch ID: %d not found, error: %v", idUint, err)
+		productBatchLog.WarnContext(c.UserContext(), "Get product batch by ID failed: not found", "batch_id", idUint, "error", err)
 		return helper.Fail(c, 404, "Product batch not found", err.Error())
 	}
 
-	log.Printf("[PRODUCT_BATCH] Get product batch by ID successful - Batch ID: %d, Product: %s", batch.ID, batch.ProductName)
+	productBatchLog.InfoContext(c.UserContext(), "Get product batch by ID successful", "batch_id", idUint)
+	setETag(c, batch)
 	return helper.Success(c, 200, "Success", batch)
 }
 
+func GetProductBatchTrace(c *fiber.Ctx) error {
+	id := c.Params("id")
+	productBatchLog.DebugContext(c.UserContext(), "Get product batch trace request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Get product batch trace failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
+	}
+
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Get product batch trace failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+
+	report, err := productBatchService.WithContext(c.UserContext()).GetBatchTrace(uint(idUint))
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Get product batch trace failed", "batch_id", idUint, "error", err)
+		return err
+	}
+
+	if format != "" {
+		productBatchLog.InfoContext(c.UserContext(), "Export product batch trace", "format", format, "batch_id", idUint)
+		return sendBatchTraceExport(c, format, report)
+	}
+
+	productBatchLog.InfoContext(c.UserContext(), "Get product batch trace successful", "batch_id", idUint, "locations", report.Summary.Locations, "movements", report.Summary.Movements)
+	return helper.Success(c, 200, "Product batch trace retrieved successfully", report)
+}
+
+// sendBatchTraceExport renders the trace report as a recall document (locations, movements and users sections)
+func sendBatchTraceExport(c *fiber.Ctx, format string, report *service.BatchTraceReport) error {
+	codeBatch := "-"
+	if report.Batch.CodeBatch != nil {
+		codeBatch = *report.Batch.CodeBatch
+	}
+
+	summary := []string{
+		fmt.Sprintf("Batch: %s (ID %d) - %s / %s / %s", codeBatch, report.Batch.ID, report.Batch.BrandName, report.Batch.CategoryName, report.Batch.ProductName),
+		fmt.Sprintf("Expiry date: %s", report.Batch.ExpDate.Format("2006-01-02")),
+		fmt.Sprintf("Quantity in: %g, quantity out: %g, remaining: %g across %d locations",
+			report.Summary.QuantityIn, report.Summary.QuantityOut, report.Summary.CurrentQuantity, report.Summary.Locations),
+		fmt.Sprintf("Generated %s", report.GeneratedAt.Format("2006-01-02 15:04")),
+	}
+
+	sections := []utils.TableSection{}
+	for _, part := range []struct {
+		title string
+		data  interface{}
+	}{
+		{"Locations", report.Locations},
+		{"Movements", report.Movements},
+		{"Users", report.Users},
+	} {
+		headers, rows, err := utils.FlattenRows(part.data)
+		if err != nil {
+			return helper.Fail(c, 500, "Export failed", err.Error())
+		}
+		sections = append(sections, utils.TableSection{Title: part.title, Headers: headers, Rows: rows})
+	}
+
+	name := fmt.Sprintf("batch-%d-recall", report.Batch.ID)
+	return sendReportExport(c, format, name, "Batch Recall Report", summary, sections)
+}
+
 func CreateProductBatch(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_BATCH] Create product batch request from IP: %s", c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Create product batch request")
 
 	var req CreateProductBatchRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_BATCH] Create product batch failed - Invalid request body, error: %v", err)
+		productBatchLog.WarnContext(c.UserContext(), "Create product batch failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Create product batch failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Parse expiry date
 	expDate, err := time.Parse("2006-01-02", req.ExpDate)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Create product batch failed - Invalid exp_date format: %s, error: %v", req.ExpDate, err)
+		productBatchLog.WarnContext(c.UserContext(), "Create product batch failed: invalid exp_date format", "exp_date_format", req.ExpDate, "error", err)
 		return helper.Fail(c, 400, "Invalid exp_date format, use YYYY-MM-DD", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_BATCH] Create product batch failed - User not authenticated")
+		productBatchLog.WarnContext(c.UserContext(), "Create product batch failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT_BATCH] Creating product batch with audit - User ID: %d, Product ID: %d", userID, req.ProductID)
+	productBatchLog.InfoContext(c.UserContext(), "Creating product batch with audit", "user_id", userID, "product_id", req.ProductID)
 
-	batch, err := productBatchService.CreateProductBatch(req.ProductID, req.CodeBatch, req.UnitPrice, expDate, req.Description, userID)
+	batch, err := productBatchService.WithContext(c.UserContext()).CreateProductBatch(req.ProductID, req.CodeBatch, req.UnitPrice, expDate, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Create product batch failed - Product ID: %d, User ID: %d, error: %v", req.ProductID, userID, err)
-		statusCode, message := handleProductBatchError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Create product batch failed", "product_id", req.ProductID, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Create product batch successful - Product ID: %d, Created by User ID: %d", req.ProductID, userID)
+	productBatchLog.InfoContext(c.UserContext(), "Create product batch successful", "product_id", req.ProductID, "created_by_user_id", userID)
+	setETag(c, batch)
 	return helper.Success(c, 201, "Product batch created successfully", batch)
 }
 
 func UpdateProductBatch(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_BATCH] Update product batch request - ID: %s from IP: %s", id, c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Update product batch request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Update product batch failed - Invalid ID: %s, error: %v", id, err)
+		productBatchLog.WarnContext(c.UserContext(), "Update product batch failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
 	}
 
 	var req UpdateProductBatchRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_BATCH] Update product batch failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		productBatchLog.WarnContext(c.UserContext(), "Update product batch failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Update product batch failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Parse expiry date if provided
 	var expDate time.Time
 	if req.ExpDate != "" {
 		expDate, err = time.Parse("2006-01-02", req.ExpDate)
 		if err != nil {
-			log.Printf("[PRODUCT_BATCH] Update product batch failed - Invalid exp_date format: %s, error: %v", req.ExpDate, err)
+			productBatchLog.WarnContext(c.UserContext(), "Update product batch failed: invalid exp_date format", "exp_date_format", req.ExpDate, "error", err)
 			return helper.Fail(c, 400, "Invalid exp_date format, use YYYY-MM-DD", err.Error())
 		}
 	}
@@ -176,87 +244,160 @@ func UpdateProductBatch(c *fiber.Ctx) error {
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_BATCH] Update product batch failed - User not authenticated for Batch ID: %d", idUint)
+		productBatchLog.WarnContext(c.UserContext(), "Update product batch failed: user not authenticated", "batch_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT_BATCH] Updating product batch with audit - Batch ID: %d, User ID: %d", idUint, userID)
+	productBatchLog.InfoContext(c.UserContext(), "Updating product batch with audit", "batch_id", idUint, "user_id", userID)
 
-	batch, err := productBatchService.UpdateProductBatch(uint(idUint), req.ProductID, req.CodeBatch, req.UnitPrice, expDate, req.Description, userID)
+	batch, err := productBatchService.WithContext(c.UserContext()).UpdateProductBatch(uint(idUint), req.ProductID, req.CodeBatch, req.UnitPrice, expDate, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Update product batch failed - Batch ID: %d, User ID: %d, error: %v", idUint, userID, err)
-		statusCode, message := handleProductBatchError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Update product batch failed", "batch_id", idUint, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Update product batch successful - Batch ID: %d, Updated by User ID: %d", idUint, userID)
+	productBatchLog.InfoContext(c.UserContext(), "Update product batch successful", "batch_id", idUint, "updated_by_user_id", userID)
+	setETag(c, batch)
 	return helper.Success(c, 200, "Product batch updated successfully", batch)
 }
 
 func DeleteProductBatch(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_BATCH] Delete product batch request - ID: %s from IP: %s", id, c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Delete product batch request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Delete product batch failed - Invalid ID: %s, error: %v", id, err)
+		productBatchLog.WarnContext(c.UserContext(), "Delete product batch failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_BATCH] Delete product batch failed - User not authenticated for Batch ID: %d", idUint)
+		productBatchLog.WarnContext(c.UserContext(), "Delete product batch failed: user not authenticated", "batch_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productBatchService.DeleteProductBatch(uint(idUint), userID)
+	err = productBatchService.WithContext(c.UserContext()).DeleteProductBatch(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Delete product batch failed - Batch ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductBatchError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Delete product batch failed", "batch_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Delete product batch successful - Batch ID: %d, Deleted by User ID: %d", idUint, userID)
+	productBatchLog.InfoContext(c.UserContext(), "Delete product batch successful", "batch_id", idUint, "deleted_by_user_id", userID)
 	return helper.Success(c, 200, "Product batch deleted successfully", nil)
 }
 
 func GetDeletedProductBatches(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_BATCH] Get deleted product batches request from IP: %s", c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Get deleted product batches request")
 
-	batches, err := productBatchService.GetDeletedProductBatches()
+	batches, err := productBatchService.WithContext(c.UserContext()).GetDeletedProductBatches()
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Get deleted product batches failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted product batches", err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Get deleted product batches failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Get deleted product batches successful")
+	productBatchLog.InfoContext(c.UserContext(), "Get deleted product batches successful")
 	return helper.Success(c, 200, "Success", batches)
 }
 
 func RestoreProductBatch(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_BATCH] Restore product batch request - ID: %s from IP: %s", id, c.IP())
+	productBatchLog.DebugContext(c.UserContext(), "Restore product batch request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Restore product batch failed - Invalid ID: %s, error: %v", id, err)
+		productBatchLog.WarnContext(c.UserContext(), "Restore product batch failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_BATCH] Restore product batch failed - User not authenticated for Batch ID: %d", idUint)
+		productBatchLog.WarnContext(c.UserContext(), "Restore product batch failed: user not authenticated", "batch_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	batch, err := productBatchService.RestoreProductBatch(uint(idUint), userID)
+	batch, err := productBatchService.WithContext(c.UserContext()).RestoreProductBatch(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_BATCH] Restore product batch failed - Batch ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductBatchError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productBatchLog.WarnContext(c.UserContext(), "Restore product batch failed", "batch_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_BATCH] Restore product batch successful - Batch ID: %d, Restored by User ID: %d", idUint, userID)
+	productBatchLog.InfoContext(c.UserContext(), "Restore product batch successful", "batch_id", idUint, "restored_by_user_id", userID)
+	setETag(c, batch)
 	return helper.Success(c, 200, "Product batch restored successfully", batch)
 }
+
+func HoldProductBatch(c *fiber.Ctx) error {
+	id := c.Params("id")
+	productBatchLog.DebugContext(c.UserContext(), "Hold product batch request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Hold product batch failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
+	}
+
+	var req HoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Hold product batch failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Hold product batch failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		productBatchLog.WarnContext(c.UserContext(), "Hold product batch failed: user not authenticated", "batch_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	result, err := productBatchService.WithContext(c.UserContext()).HoldProductBatch(uint(idUint), req.Status, req.Reason, userID)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Hold product batch failed", "batch_id", idUint, "error", err)
+		return err
+	}
+
+	productBatchLog.InfoContext(c.UserContext(), "Hold product batch successful", "batch_id", idUint, "status", req.Status, "by_user_id", userID)
+	setETag(c, result)
+	return helper.Success(c, 200, "Product batch placed on hold successfully", result)
+}
+
+func ReleaseProductBatch(c *fiber.Ctx) error {
+	id := c.Params("id")
+	productBatchLog.DebugContext(c.UserContext(), "Release product batch request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Release product batch failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid product batch ID", err.Error())
+	}
+
+	var req ReleaseHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Release product batch failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Release product batch failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		productBatchLog.WarnContext(c.UserContext(), "Release product batch failed: user not authenticated", "batch_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	result, err := productBatchService.WithContext(c.UserContext()).ReleaseProductBatch(uint(idUint), req.Reason, userID)
+	if err != nil {
+		productBatchLog.WarnContext(c.UserContext(), "Release product batch failed", "batch_id", idUint, "error", err)
+		return err
+	}
+
+	productBatchLog.InfoContext(c.UserContext(), "Release product batch successful", "batch_id", idUint, "by_user_id", userID)
+	setETag(c, result)
+	return helper.Success(c, 200, "Product batch released successfully", result)
+}
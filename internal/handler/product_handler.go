@@ -1,247 +1,241 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productService = service.NewProductService()
-
-// handleProductError converts database errors to user-friendly messages for product operations
-func handleProductError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "product already exists for this category" {
-		return 409, "Product name already exists for this category"
-	}
-
-	if errMsg == "product not found" {
-		return 404, "Product not found"
-	}
-
-	if errMsg == "category not found" {
-		return 404, "Category not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") {
-		return 409, "Product name already exists for this category"
-	}
-
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid category ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var productLog = logger.New("product")
 
 type CreateProductRequest struct {
 	CategoryID  uint    `json:"categoryId" validate:"required"`
-	Name        string  `json:"name" validate:"required"`
+	Name        string  `json:"name" validate:"required,max=255"`
 	Description *string `json:"description"` // Nullable field
 }
 
 type UpdateProductRequest struct {
-	CategoryID  uint    `json:"categoryId"`
-	Name        string  `json:"name" validate:"required"`
+	CategoryID  uint    `json:"categoryId" validate:"omitempty,min=1"` // 0 keeps the category
+	Name        string  `json:"name" validate:"required,max=255"`
 	Description *string `json:"description"` // Nullable field
 }
 
 func GetProducts(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT] Get all products request from IP: %s", c.IP())
+	productLog.DebugContext(c.UserContext(), "Get all products request")
 
-	products, err := productService.GetAllProducts()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT] Get all products failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch products", err.Error())
+		productLog.WarnContext(c.UserContext(), "Get all products failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT] Get all products successful")
-	return helper.Success(c, 200, "Success", products)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productLog.WarnContext(c.UserContext(), "Get all products failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	products, total, err := productService.WithContext(c.UserContext()).GetAllProducts(query)
+	if err != nil {
+		productLog.WarnContext(c.UserContext(), "Get all products failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productLog.InfoContext(c.UserContext(), "Export products", "format", format, "rows", total)
+		return sendListExport(c, format, "products", products)
+	}
+
+	productLog.InfoContext(c.UserContext(), "Get all products successful")
+	return helper.SuccessWithMeta(c, 200, "Success", products, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductsByCategory(c *fiber.Ctx) error {
 	categoryID := c.Params("categoryId")
-	log.Printf("[PRODUCT] Get products by category request - Category ID: %s from IP: %s", categoryID, c.IP())
+	productLog.DebugContext(c.UserContext(), "Get products by category request", "category_id", categoryID)
 
 	categoryIDUint, err := strconv.ParseUint(categoryID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT] Get products by category failed - Invalid Category ID: %s, error: %v", categoryID, err)
+		productLog.WarnContext(c.UserContext(), "Get products by category failed: invalid Category ID", "category_id", categoryID, "error", err)
 		return helper.Fail(c, 400, "Invalid category ID", err.Error())
 	}
 
-	products, err := productService.GetProductsByCategory(uint(categoryIDUint))
+	products, err := productService.WithContext(c.UserContext()).GetProductsByCategory(uint(categoryIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT] Get products by category failed - Category ID: %d, error: %v", categoryIDUint, err)
-		statusCode, message := handleProductError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productLog.WarnContext(c.UserContext(), "Get products by category failed", "category_id", categoryIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Get products by category successful")
+	productLog.InfoContext(c.UserContext(), "Get products by category successful")
 	return helper.Success(c, 200, "Success", products)
 }
 
 func GetProductByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT] Get product by ID request - ID: %s from IP: %s", id, c.IP())
+	productLog.DebugContext(c.UserContext(), "Get product by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT] Get product by ID failed - Invalid ID: %s, error: %v", id, err)
+		productLog.WarnContext(c.UserContext(), "Get product by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	product, err := productService.GetProductByID(uint(idUint))
+	product, err := productService.WithContext(c.UserContext()).GetProductByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT] Get product by ID failed - Product ID: %d not found, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product not found", err.Error())
+		productLog.WarnContext(c.UserContext(), "Get product by ID failed: not found", "product_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Get product by ID successful")
+	productLog.InfoContext(c.UserContext(), "Get product by ID successful")
+	setETag(c, product)
 	return helper.Success(c, 200, "Success", product)
 }
 
 func CreateProduct(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT] Create product request from IP: %s", c.IP())
+	productLog.DebugContext(c.UserContext(), "Create product request")
 
 	var req CreateProductRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT] Create product failed - Invalid request body, error: %v", err)
+		productLog.WarnContext(c.UserContext(), "Create product failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productLog.WarnContext(c.UserContext(), "Create product failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT] Create product failed - User not authenticated")
+		productLog.WarnContext(c.UserContext(), "Create product failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT] Creating product with audit - User ID: %d, Category ID: %d", userID, req.CategoryID)
+	productLog.InfoContext(c.UserContext(), "Creating product with audit", "user_id", userID, "category_id", req.CategoryID)
 
-	product, err := productService.CreateProduct(req.CategoryID, req.Name, req.Description, userID)
+	product, err := productService.WithContext(c.UserContext()).CreateProduct(req.CategoryID, req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT] Create product failed - Name: %s, Category ID: %d, User ID: %d, error: %v", req.Name, req.CategoryID, userID, err)
-		statusCode, message := handleProductError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productLog.WarnContext(c.UserContext(), "Create product failed", "name", req.Name, "category_id", req.CategoryID, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Create product successful - Name: %s, Category ID: %d, Created by User ID: %d", req.Name, req.CategoryID, userID)
+	productLog.InfoContext(c.UserContext(), "Create product successful", "name", req.Name, "category_id", req.CategoryID, "created_by_user_id", userID)
+	setETag(c, product)
 	return helper.Success(c, 201, "Product created successfully", product)
 }
 
 func UpdateProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT] Update product request - ID: %s from IP: %s", id, c.IP())
+	productLog.DebugContext(c.UserContext(), "Update product request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT] Update product failed - Invalid ID: %s, error: %v", id, err)
+		productLog.WarnContext(c.UserContext(), "Update product failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
 	var req UpdateProductRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT] Update product failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		productLog.WarnContext(c.UserContext(), "Update product failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productLog.WarnContext(c.UserContext(), "Update product failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT] Update product failed - User not authenticated for Product ID: %d", idUint)
+		productLog.WarnContext(c.UserContext(), "Update product failed: user not authenticated", "product_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT] Updating product with audit - Product ID: %d, User ID: %d", idUint, userID)
+	productLog.InfoContext(c.UserContext(), "Updating product with audit", "product_id", idUint, "user_id", userID)
 
-	product, err := productService.UpdateProduct(uint(idUint), req.CategoryID, req.Name, req.Description, userID)
+	product, err := productService.WithContext(c.UserContext()).UpdateProduct(uint(idUint), req.CategoryID, req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT] Update product failed - Product ID: %d, User ID: %d, error: %v", idUint, userID, err)
-		statusCode, message := handleProductError(err)
-		log.Printf("[PRODUCT] Update product failed - Error: %s", err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productLog.WarnContext(c.UserContext(), "Update product failed", "product_id", idUint, "user_id", userID, "error", err)
+		productLog.WarnContext(c.UserContext(), "Update product failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Update product successful - Product ID: %d, Updated by User ID: %d", idUint, userID)
+	productLog.InfoContext(c.UserContext(), "Update product successful", "product_id", idUint, "updated_by_user_id", userID)
+	setETag(c, product)
 	return helper.Success(c, 200, "Product updated successfully", product)
 }
 
 func DeleteProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT] Delete product request - ID: %s from IP: %s", id, c.IP())
+	productLog.DebugContext(c.UserContext(), "Delete product request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT] Delete product failed - Invalid ID: %s, error: %v", id, err)
+		productLog.WarnContext(c.UserContext(), "Delete product failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT] Delete product failed - User not authenticated for Product ID: %d", idUint)
+		productLog.WarnContext(c.UserContext(), "Delete product failed: user not authenticated", "product_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productService.DeleteProduct(uint(idUint), userID)
+	err = productService.WithContext(c.UserContext()).DeleteProduct(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT] Delete product failed - Product ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productLog.WarnContext(c.UserContext(), "Delete product failed", "product_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Delete product successful")
+	productLog.InfoContext(c.UserContext(), "Delete product successful")
 	return helper.Success(c, 200, "Product deleted successfully", nil)
 }
 
 func GetDeletedProducts(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT] Get deleted products request from IP: %s", c.IP())
+	productLog.DebugContext(c.UserContext(), "Get deleted products request")
 
-	products, err := productService.GetDeletedProducts()
+	products, err := productService.WithContext(c.UserContext()).GetDeletedProducts()
 	if err != nil {
-		log.Printf("[PRODUCT] Get deleted products failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted products", err.Error())
+		productLog.WarnContext(c.UserContext(), "Get deleted products failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Get deleted products successful")
+	productLog.InfoContext(c.UserContext(), "Get deleted products successful")
 	return helper.Success(c, 200, "Success", products)
 }
 
 func RestoreProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT] Restore product request - ID: %s from IP: %s", id, c.IP())
+	productLog.DebugContext(c.UserContext(), "Restore product request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT] Restore product failed - Invalid ID: %s, error: %v", id, err)
+		productLog.WarnContext(c.UserContext(), "Restore product failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT] Restore product failed - User not authenticated for Product ID: %d", idUint)
+		productLog.WarnContext(c.UserContext(), "Restore product failed: user not authenticated", "product_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	product, err := productService.RestoreProduct(uint(idUint), userID)
+	product, err := productService.WithContext(c.UserContext()).RestoreProduct(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT] Restore product failed - Product ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productLog.WarnContext(c.UserContext(), "Restore product failed", "product_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT] Restore product successful - Product ID: %d, Restored by User ID: %d", idUint, userID)
+	productLog.InfoContext(c.UserContext(), "Restore product successful", "product_id", idUint, "restored_by_user_id", userID)
+	setETag(c, product)
 	return helper.Success(c, 200, "Product restored successfully", product)
 }
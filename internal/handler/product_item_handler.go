@@ -1,16 +1,18 @@
 package handler
 
 import (
-	"log"
 	"strconv"
 
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productItemService = service.NewProductItemService()
+var productItemLog = logger.New("product_item")
 
 type CreateProductItemRequest struct {
 	ProductStockID uint     `json:"productStockId" validate:"required"`
@@ -32,193 +34,224 @@ type UpdateProductItemRequest struct {
 }
 
 func GetAllProductItems(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM] Get all product items request from IP: %s", c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get all product items request")
 
-	result, err := productItemService.GetAllProductItems()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get all failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve product items", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get all failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get all successful")
-	return helper.Success(c, 200, "Product items retrieved successfully", result)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productItemLog.WarnContext(c.UserContext(), "Get all failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	result, total, err := productItemService.WithContext(c.UserContext()).GetAllProductItems(query)
+	if err != nil {
+		productItemLog.WarnContext(c.UserContext(), "Get all failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productItemLog.InfoContext(c.UserContext(), "Export product-items", "format", format, "rows", total)
+		return sendListExport(c, format, "product-items", result)
+	}
+
+	productItemLog.InfoContext(c.UserContext(), "Get all successful")
+	return helper.SuccessWithMeta(c, 200, "Product items retrieved successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductItemsByStock(c *fiber.Ctx) error {
 	stockID := c.Params("stockId")
-	log.Printf("[PRODUCT_ITEM] Get items by stock request - Stock ID: %s from IP: %s", stockID, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get items by stock request", "stock_id", stockID)
 
 	stockIDUint, err := strconv.ParseUint(stockID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by stock failed - Invalid stock ID: %s, error: %v", stockID, err)
+		productItemLog.WarnContext(c.UserContext(), "Get items by stock failed: invalid stock ID", "stock_id", stockID, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
-	result, err := productItemService.GetProductItemsByStock(uint(stockIDUint))
+	result, err := productItemService.WithContext(c.UserContext()).GetProductItemsByStock(uint(stockIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by stock failed - Stock ID: %d, error: %v", stockIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product items", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get items by stock failed", "stock_id", stockIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get items by stock successful")
+	productItemLog.InfoContext(c.UserContext(), "Get items by stock successful")
 	return helper.Success(c, 200, "Product items retrieved successfully", result)
 }
 
 func GetProductItemsByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_ITEM] Get items by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get items by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by product failed - Invalid product ID: %s, error: %v", productID, err)
+		productItemLog.WarnContext(c.UserContext(), "Get items by product failed: invalid product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	result, err := productItemService.GetProductItemsByProduct(uint(productIDUint))
+	result, err := productItemService.WithContext(c.UserContext()).GetProductItemsByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by product failed - Product ID: %d, error: %v", productIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product items", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get items by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get items by product successful")
+	productItemLog.InfoContext(c.UserContext(), "Get items by product successful")
 	return helper.Success(c, 200, "Product items retrieved successfully", result)
 }
 
 func GetProductItemsByLocation(c *fiber.Ctx) error {
 	locationID := c.Params("locationId")
-	log.Printf("[PRODUCT_ITEM] Get items by location request - Location ID: %s from IP: %s", locationID, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get items by location request", "location_id", locationID)
 
 	locationIDUint, err := strconv.ParseUint(locationID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by location failed - Invalid location ID: %s, error: %v", locationID, err)
+		productItemLog.WarnContext(c.UserContext(), "Get items by location failed: invalid location ID", "location_id", locationID, "error", err)
 		return helper.Fail(c, 400, "Invalid location ID", err.Error())
 	}
 
-	result, err := productItemService.GetProductItemsByLocation(uint(locationIDUint))
+	result, err := productItemService.WithContext(c.UserContext()).GetProductItemsByLocation(uint(locationIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items by location failed - Location ID: %d, error: %v", locationIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product items", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get items by location failed", "location_id", locationIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get items by location successful")
+	productItemLog.InfoContext(c.UserContext(), "Get items by location successful")
 	return helper.Success(c, 200, "Product items retrieved successfully", result)
 }
 
 func GetItemsSummaryByProduct(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM] Get items summary request from IP: %s", c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get items summary request")
 
-	result, err := productItemService.GetAllProductItems()
+	result, _, err := productItemService.WithContext(c.UserContext()).GetAllProductItems(utils.ListQuery{})
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get items summary failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve items summary", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get items summary failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get items summary successful")
+	productItemLog.InfoContext(c.UserContext(), "Get items summary successful")
 	return helper.Success(c, 200, "Items summary retrieved successfully", result)
 }
 
 func GetProductItemByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM] Get item by ID request - ID: %s from IP: %s", id, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Get item by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get item by ID failed - Invalid ID: %s, error: %v", id, err)
+		productItemLog.WarnContext(c.UserContext(), "Get item by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid item ID", err.Error())
 	}
 
-	result, err := productItemService.GetProductItemByID(uint(idUint))
+	result, err := productItemService.WithContext(c.UserContext()).GetProductItemByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Get item by ID failed - ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product item not found", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Get item by ID failed", "id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Get item by ID successful")
+	productItemLog.InfoContext(c.UserContext(), "Get item by ID successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product item retrieved successfully", result)
 }
 
 func CreateProductItem(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM] Create product item request from IP: %s", c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Create product item request")
 
 	var req CreateProductItemRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_ITEM] Create failed - Invalid request body, error: %v", err)
+		productItemLog.WarnContext(c.UserContext(), "Create failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productItemLog.WarnContext(c.UserContext(), "Create failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM] Create failed - User not authenticated")
+		productItemLog.WarnContext(c.UserContext(), "Create failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productItemService.CreateProductItem(req.ProductStockID, req.ProductID, req.ProductBatchID, req.LocationID, req.StockIn, req.StockOut, req.Quantity, userID)
+	result, err := productItemService.WithContext(c.UserContext()).CreateProductItem(req.ProductStockID, req.ProductID, req.ProductBatchID, req.LocationID, req.StockIn, req.StockOut, req.Quantity, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Create failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to create product item", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Create failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Create successful")
+	productItemLog.InfoContext(c.UserContext(), "Create successful")
+	setETag(c, result)
 	return helper.Success(c, 201, "Product item created successfully", result)
 }
 
 func UpdateProductItem(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM] Update item request - ID: %s from IP: %s", id, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Update item request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Update failed - Invalid ID: %s, error: %v", id, err)
+		productItemLog.WarnContext(c.UserContext(), "Update failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid item ID", err.Error())
 	}
 
 	var req UpdateProductItemRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_ITEM] Update failed - Invalid request body, error: %v", err)
+		productItemLog.WarnContext(c.UserContext(), "Update failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productItemLog.WarnContext(c.UserContext(), "Update failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM] Update failed - User not authenticated")
+		productItemLog.WarnContext(c.UserContext(), "Update failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productItemService.UpdateProductItem(uint(idUint), req.ProductStockID, req.ProductID, req.LocationID, req.StockIn, req.StockOut, req.Quantity, userID)
+	result, err := productItemService.WithContext(c.UserContext()).UpdateProductItem(uint(idUint), req.ProductStockID, req.ProductID, req.LocationID, req.StockIn, req.StockOut, req.Quantity, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Update failed - Item ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to update product item", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Update failed", "item_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Update successful")
+	productItemLog.InfoContext(c.UserContext(), "Update successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product item updated successfully", result)
 }
 
 func DeleteProductItem(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM] Delete item request - ID: %s from IP: %s", id, c.IP())
+	productItemLog.DebugContext(c.UserContext(), "Delete item request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Delete failed - Invalid ID: %s, error: %v", id, err)
+		productItemLog.WarnContext(c.UserContext(), "Delete failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid item ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM] Delete failed - User not authenticated")
+		productItemLog.WarnContext(c.UserContext(), "Delete failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productItemService.DeleteProductItem(uint(idUint), userID)
+	err = productItemService.WithContext(c.UserContext()).DeleteProductItem(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM] Delete failed - Item ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to delete product item", err.Error())
+		productItemLog.WarnContext(c.UserContext(), "Delete failed", "item_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM] Delete successful")
+	productItemLog.InfoContext(c.UserContext(), "Delete successful")
 	return helper.Success(c, 200, "Product item deleted successfully", nil)
 }
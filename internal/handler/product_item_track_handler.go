@@ -1,24 +1,26 @@
 package handler
 
 import (
-	"log"
 	"strconv"
 	"time"
 
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productItemTrackService = service.NewProductItemTrackService()
+var productItemTrackLog = logger.New("product_item_track")
 
 type CreateProductItemTrackRequest struct {
 	ProductItemID  uint     `json:"product_item_id" validate:"required"`
 	ProductStockID *uint    `json:"product_stock_id,omitempty"`
 	ProductID      *uint    `json:"product_id,omitempty"`
 	ProductBatchID *uint    `json:"product_batch_id,omitempty"`
-	Date           string   `json:"date" validate:"required"` // Format: YYYY-MM-DD
+	Date           string   `json:"date" validate:"required,datetime=2006-01-02"`
 	UnitPrice      *string  `json:"unit_price"`
 	StockIn        *float64 `json:"stock_in" validate:"omitempty,gte=0"`
 	StockOut       *float64 `json:"stock_out" validate:"omitempty,gte=0"`
@@ -26,11 +28,11 @@ type CreateProductItemTrackRequest struct {
 	Operation      *string  `json:"operation" validate:"omitempty,oneof=In Out Plus Minus"`
 	Stock          *float64 `json:"stock" validate:"omitempty,gte=0"`
 	Description    *string  `json:"description,omitempty"`
-	Action         string   `json:"action,omitempty"` // CREATE, UPDATE, DELETE, STOCK_IN, STOCK_OUT
+	Action         string   `json:"action,omitempty" validate:"omitempty,oneof=CREATE UPDATE DELETE STOCK_IN STOCK_OUT"`
 }
 
 type UpdateProductItemTrackRequest struct {
-	Date        *string  `json:"date,omitempty"` // Format: YYYY-MM-DD
+	Date        *string  `json:"date,omitempty" validate:"omitempty,datetime=2006-01-02"`
 	UnitPrice   *string  `json:"unit_price,omitempty"`
 	Quantity    *float64 `json:"quantity,omitempty" validate:"omitempty,gt=0"`
 	Operation   *string  `json:"operation,omitempty" validate:"omitempty,oneof=In Out Plus Minus"`
@@ -39,222 +41,299 @@ type UpdateProductItemTrackRequest struct {
 }
 
 type DateRangeRequest struct {
-	StartDate time.Time `json:"start_date"`
-	EndDate   time.Time `json:"end_date"`
+	StartDate time.Time `json:"start_date" validate:"required"`
+	EndDate   time.Time `json:"end_date" validate:"required,gtefield=StartDate"`
 }
 
 func GetAllProductItemTracks(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM_TRACK] Get all product item tracks request from IP: %s", c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get all product item tracks request")
 
-	result, err := productItemTrackService.GetAllProductItemTracks()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get all failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get all failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get all successful")
-	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get all failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	result, total, err := productItemTrackService.WithContext(c.UserContext()).GetAllProductItemTracks(query)
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get all failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productItemTrackLog.InfoContext(c.UserContext(), "Export product-item-tracks", "format", format, "rows", total)
+		return sendListExport(c, format, "product-item-tracks", result)
+	}
+
+	productItemTrackLog.InfoContext(c.UserContext(), "Get all successful")
+	return helper.SuccessWithMeta(c, 200, "Product item tracks retrieved successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+func GetProductItemTracksByCursor(c *fiber.Ctx) error {
+	productItemTrackLog.DebugContext(c.UserContext(), "Get product item tracks by cursor request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get by cursor failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	cursor, err := utils.ParseCursorQuery(c.Queries())
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get by cursor failed: invalid cursor", "error", err)
+		return helper.Fail(c, 400, "Invalid cursor", err.Error())
+	}
+
+	result, nextCursor, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTracksByCursor(query, cursor)
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get by cursor failed", "error", err)
+		return err
+	}
+
+	productItemTrackLog.InfoContext(c.UserContext(), "Get by cursor successful")
+	return helper.SuccessWithMeta(c, 200, "Product item tracks retrieved successfully", result, helper.NewCursorPagination(cursor.Limit, nextCursor))
+}
+
+func ExportProductItemTracks(c *fiber.Ctx) error {
+	productItemTrackLog.DebugContext(c.UserContext(), "Export product item tracks request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Export failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	// The export runs in the stream writer, after the fiber context was reused, so it is scoped to the tenant here
+	svc := productItemTrackService.WithContext(c.UserContext())
+	return streamNDJSON(c, "product-item-tracks.ndjson", productItemTrackLog, func(write func(row interface{}) error) error {
+		return svc.ExportProductItemTracks(query, write)
+	})
 }
 
 func GetProductItemTracksByItem(c *fiber.Ctx) error {
 	itemID := c.Params("itemId")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by item request - Item ID: %s from IP: %s", itemID, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get tracks by item request", "item_id", itemID)
 
 	itemIDUint, err := strconv.ParseUint(itemID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by item failed - Invalid item ID: %s, error: %v", itemID, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by item failed: invalid item ID", "item_id", itemID, "error", err)
 		return helper.Fail(c, 400, "Invalid item ID", err.Error())
 	}
 
-	result, err := productItemTrackService.GetProductItemTracksByItem(uint(itemIDUint))
+	result, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTracksByItem(uint(itemIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by item failed - Item ID: %d, error: %v", itemIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by item failed", "item_id", itemIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by item successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get tracks by item successful")
 	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
 }
 
 func GetProductItemTracksByStock(c *fiber.Ctx) error {
 	stockID := c.Params("stockId")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by stock request - Stock ID: %s from IP: %s", stockID, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get tracks by stock request", "stock_id", stockID)
 
 	stockIDUint, err := strconv.ParseUint(stockID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by stock failed - Invalid stock ID: %s, error: %v", stockID, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by stock failed: invalid stock ID", "stock_id", stockID, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
-	result, err := productItemTrackService.GetProductItemTracksByStock(uint(stockIDUint))
+	result, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTracksByStock(uint(stockIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by stock failed - Stock ID: %d, error: %v", stockIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by stock failed", "stock_id", stockIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by stock successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get tracks by stock successful")
 	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
 }
 
 func GetProductItemTracksByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get tracks by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by product failed - Invalid product ID: %s, error: %v", productID, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by product failed: invalid product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	result, err := productItemTrackService.GetProductItemTracksByProduct(uint(productIDUint))
+	result, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTracksByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by product failed - Product ID: %d, error: %v", productIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by product successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get tracks by product successful")
 	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
 }
 
 func GetProductItemTracksByDateRange(c *fiber.Ctx) error {
 	startDateStr := c.Query("startDate")
 	endDateStr := c.Query("endDate")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range request - Start: %s, End: %s from IP: %s", startDateStr, endDateStr, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get tracks by date range request", "start", startDateStr, "end", endDateStr)
 
 	if startDateStr == "" || endDateStr == "" {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range failed - Missing date parameters")
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by date range failed: missing date parameters")
 		return helper.Fail(c, 400, "Invalid parameters", "startDate and endDate query parameters are required")
 	}
 
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range failed - Invalid start date: %s, error: %v", startDateStr, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by date range failed: invalid start date", "start_date", startDateStr, "error", err)
 		return helper.Fail(c, 400, "Invalid date format", "Invalid startDate format. Use YYYY-MM-DD")
 	}
 
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range failed - Invalid end date: %s, error: %v", endDateStr, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by date range failed: invalid end date", "end_date", endDateStr, "error", err)
 		return helper.Fail(c, 400, "Invalid date format", "Invalid endDate format. Use YYYY-MM-DD")
 	}
 
 	// Add end of day to end date
 	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
 
-	result, err := productItemTrackService.GetProductItemTracksByDateRange(startDate, endDate)
+	result, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTracksByDateRange(startDate, endDate)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by date range failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by date range successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get tracks by date range successful")
 	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
 }
 
 func GetTracksByOperation(c *fiber.Ctx) error {
 	operation := c.Params("operation")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by operation request - Operation: %s from IP: %s", operation, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get tracks by operation request", "operation", operation)
 
-	// For now, return all tracks (operation filtering can be implemented later)
-	result, err := productItemTrackService.GetAllProductItemTracks()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by operation failed - Operation: %s, error: %v", operation, err)
-		return helper.Fail(c, 400, "Failed to retrieve product item tracks", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by operation failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
+	query.Filters = append(query.Filters, utils.FilterField{Field: "operation", Operator: "eq", Value: operation})
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get tracks by operation successful")
-	return helper.Success(c, 200, "Product item tracks retrieved successfully", result)
+	result, total, err := productItemTrackService.WithContext(c.UserContext()).GetAllProductItemTracks(query)
+	if err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Get tracks by operation failed", "operation", operation, "error", err)
+		return err
+	}
+
+	productItemTrackLog.InfoContext(c.UserContext(), "Get tracks by operation successful")
+	return helper.SuccessWithMeta(c, 200, "Product item tracks retrieved successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetValueReportByProduct(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM_TRACK] Get value report request from IP: %s", c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get value report request")
 
 	// For now, return all tracks (value report can be implemented later)
-	result, err := productItemTrackService.GetAllProductItemTracks()
+	result, _, err := productItemTrackService.WithContext(c.UserContext()).GetAllProductItemTracks(utils.ListQuery{})
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get value report failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve value report", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get value report failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get value report successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get value report successful")
 	return helper.Success(c, 200, "Value report retrieved successfully", result)
 }
 
 func GetProductItemTrackByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM_TRACK] Get track by ID request - ID: %s from IP: %s", id, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Get track by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get track by ID failed - Invalid ID: %s, error: %v", id, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Get track by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
-	result, err := productItemTrackService.GetProductItemTrackByID(uint(idUint))
+	result, err := productItemTrackService.WithContext(c.UserContext()).GetProductItemTrackByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Get track by ID failed - ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product item track not found", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Get track by ID failed", "id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Get track by ID successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Get track by ID successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product item track retrieved successfully", result)
 }
 
 func CreateProductItemTrack(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_ITEM_TRACK] Create product item track request from IP: %s", c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Create product item track request")
 
 	var req CreateProductItemTrackRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Create failed - Invalid request body, error: %v", err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Create failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Create failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Parse date
 	parsedDate, err := time.Parse("2006-01-02", req.Date)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Create failed - Invalid date format: %s, error: %v", req.Date, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Create failed: invalid date format", "date_format", req.Date, "error", err)
 		return helper.Fail(c, 400, "Invalid date format", "Date must be in YYYY-MM-DD format")
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM_TRACK] Create failed - User not authenticated")
+		productItemTrackLog.WarnContext(c.UserContext(), "Create failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productItemTrackService.CreateProductItemTrack(req.ProductItemID, req.ProductStockID, req.ProductID, req.ProductBatchID, parsedDate, req.UnitPrice, req.StockIn, req.StockOut, req.Quantity, req.Operation, req.Stock, req.Description, req.Action, userID)
+	result, err := productItemTrackService.WithContext(c.UserContext()).CreateProductItemTrack(req.ProductItemID, req.ProductStockID, req.ProductID, req.ProductBatchID, parsedDate, req.UnitPrice, req.StockIn, req.StockOut, req.Quantity, req.Operation, req.Stock, req.Description, req.Action, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Create failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to create product item track", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Create failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Create successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Create successful")
+	setETag(c, result)
 	return helper.Success(c, 201, "Product item track created successfully", result)
 }
 
 func UpdateProductItemTrack(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM_TRACK] Update track request - ID: %s from IP: %s", id, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Update track request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Update failed - Invalid ID: %s, error: %v", id, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Update failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
 	var req UpdateProductItemTrackRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Update failed - Invalid request body, error: %v", err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Update failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productItemTrackLog.WarnContext(c.UserContext(), "Update failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Parse date if provided
 	var parsedDate *time.Time
 	if req.Date != nil && *req.Date != "" {
 		date, err := time.Parse("2006-01-02", *req.Date)
 		if err != nil {
-			log.Printf("[PRODUCT_ITEM_TRACK] Update failed - Invalid date format: %s, error: %v", *req.Date, err)
+			productItemTrackLog.WarnContext(c.UserContext(), "Update failed: invalid date format", "date_format", *req.Date, "error", err)
 			return helper.Fail(c, 400, "Invalid date format", "Date must be in YYYY-MM-DD format")
 		}
 		parsedDate = &date
@@ -263,43 +342,44 @@ func UpdateProductItemTrack(c *fiber.Ctx) error {
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM_TRACK] Update failed - User not authenticated")
+		productItemTrackLog.WarnContext(c.UserContext(), "Update failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productItemTrackService.UpdateProductItemTrack(uint(idUint), parsedDate, req.UnitPrice, req.Quantity, req.Operation, req.Stock, req.Description, userID)
+	result, err := productItemTrackService.WithContext(c.UserContext()).UpdateProductItemTrack(uint(idUint), parsedDate, req.UnitPrice, req.Quantity, req.Operation, req.Stock, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Update failed - Track ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to update product item track", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Update failed", "track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Update successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Update successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product item track updated successfully", result)
 }
 
 func DeleteProductItemTrack(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_ITEM_TRACK] Delete track request - ID: %s from IP: %s", id, c.IP())
+	productItemTrackLog.DebugContext(c.UserContext(), "Delete track request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Delete failed - Invalid ID: %s, error: %v", id, err)
+		productItemTrackLog.WarnContext(c.UserContext(), "Delete failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_ITEM_TRACK] Delete failed - User not authenticated")
+		productItemTrackLog.WarnContext(c.UserContext(), "Delete failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productItemTrackService.DeleteProductItemTrack(uint(idUint), userID)
+	err = productItemTrackService.WithContext(c.UserContext()).DeleteProductItemTrack(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_ITEM_TRACK] Delete failed - Track ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to delete product item track", err.Error())
+		productItemTrackLog.WarnContext(c.UserContext(), "Delete failed", "track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_ITEM_TRACK] Delete successful")
+	productItemTrackLog.InfoContext(c.UserContext(), "Delete successful")
 	return helper.Success(c, 200, "Product item track deleted successfully", nil)
 }
@@ -1,46 +1,23 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productStockService = service.NewProductStockService()
-
-// handleProductStockError converts database errors to user-friendly messages for product stock operations
-func handleProductStockError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "product batch not found" {
-		return 404, "Product batch not found"
-	}
-
-	if errMsg == "product not found" {
-		return 404, "Product not found"
-	}
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid product ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var productStockLog = logger.New("product_stock")
 
 type CreateProductStockRequest struct {
 	ProductBatchID uint     `json:"productBatchId" validate:"required"`
 	ProductID      uint     `json:"productId" validate:"required"`
 	LocationID     uint     `json:"locationId" validate:"required"`
+	BinID          *uint    `json:"binId"` // Optional bin inside a gudang location
 	Quantity       *float64 `json:"quantity" validate:"omitempty,gte=0"`
 }
 
@@ -48,146 +25,252 @@ type UpdateProductStockRequest struct {
 	ProductBatchID uint     `json:"productBatchId" validate:"required"`
 	ProductID      uint     `json:"productId,omitempty" validate:"omitempty,min=1"`
 	LocationID     uint     `json:"locationId,omitempty" validate:"omitempty,min=1"`
+	BinID          *uint    `json:"binId,omitempty"` // 0 removes the stock from its bin
 	Quantity       *float64 `json:"quantity,omitempty" validate:"omitempty,gte=0"`
 }
 
 func GetAllProductStocks(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_STOCK] Get all product stocks request from IP: %s", c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Get all product stocks request")
 
-	result, err := productStockService.GetAllProductStocks()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Get all failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve product stocks", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Get all failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_STOCK] Get all successful")
-	return helper.Success(c, 200, "Product stocks retrieved successfully", result)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Get all failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	result, total, err := productStockService.WithContext(c.UserContext()).GetAllProductStocks(query)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Get all failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productStockLog.InfoContext(c.UserContext(), "Export product-stocks", "format", format, "rows", total)
+		return sendListExport(c, format, "product-stocks", result)
+	}
+
+	productStockLog.InfoContext(c.UserContext(), "Get all successful")
+	return helper.SuccessWithMeta(c, 200, "Product stocks retrieved successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductStocksByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_STOCK] Get stocks by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Get stocks by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Get stocks by product failed - Invalid product ID: %s, error: %v", productID, err)
+		productStockLog.WarnContext(c.UserContext(), "Get stocks by product failed: invalid product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	result, err := productStockService.GetProductStocksByProduct(uint(productIDUint))
+	result, err := productStockService.WithContext(c.UserContext()).GetProductStocksByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Get stocks by product failed - Product ID: %d, error: %v", productIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product stocks", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Get stocks by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK] Get stocks by product successful")
+	productStockLog.InfoContext(c.UserContext(), "Get stocks by product successful")
 	return helper.Success(c, 200, "Product stocks retrieved successfully", result)
 }
 
 func GetProductStockByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK] Get stock by ID request - ID: %s from IP: %s", id, c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Get stock by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Get stock by ID failed - Invalid ID: %s, error: %v", id, err)
+		productStockLog.WarnContext(c.UserContext(), "Get stock by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
-	result, err := productStockService.GetProductStockByID(uint(idUint))
+	result, err := productStockService.WithContext(c.UserContext()).GetProductStockByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Get stock by ID failed - Stock ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product stock not found", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Get stock by ID failed", "stock_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK] Get stock by ID successful")
+	productStockLog.InfoContext(c.UserContext(), "Get stock by ID successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product stock retrieved successfully", result)
 }
 
 func CreateProductStock(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_STOCK] Create product stock request from IP: %s", c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Create product stock request")
 
 	var req CreateProductStockRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_STOCK] Create failed - Invalid request body, error: %v", err)
+		productStockLog.WarnContext(c.UserContext(), "Create failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Create failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK] Create failed - User not authenticated")
+		productStockLog.WarnContext(c.UserContext(), "Create failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	// result, err := productStockService.CreateProductStock(req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
-	result, err := productStockService.CreateProductStock(req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
+	// result, err := productStockService.WithContext(c.UserContext()).CreateProductStock(req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
+	result, err := productStockService.WithContext(c.UserContext()).CreateProductStock(req.ProductBatchID, req.ProductID, req.LocationID, req.BinID, req.Quantity, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Create failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to create product stock", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Create failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK] Create successful")
+	productStockLog.InfoContext(c.UserContext(), "Create successful")
+	setETag(c, result)
 	return helper.Success(c, 201, "Product stock created successfully", result)
 }
 
 func UpdateProductStock(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK] Update stock request - ID: %s from IP: %s", id, c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Update stock request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Update failed - Invalid ID: %s, error: %v", id, err)
+		productStockLog.WarnContext(c.UserContext(), "Update failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
 	var req UpdateProductStockRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_STOCK] Update failed - Invalid request body, error: %v", err)
+		productStockLog.WarnContext(c.UserContext(), "Update failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Update failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK] Update failed - User not authenticated")
+		productStockLog.WarnContext(c.UserContext(), "Update failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	// result, err := productStockService.UpdateProductStock(uint(idUint), req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
-	result, err := productStockService.UpdateProductStock(uint(idUint), req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
+	// result, err := productStockService.WithContext(c.UserContext()).UpdateProductStock(uint(idUint), req.ProductBatchID, req.ProductID, req.LocationID, req.Quantity, userID)
+	result, err := productStockService.WithContext(c.UserContext()).UpdateProductStock(uint(idUint), req.ProductBatchID, req.ProductID, req.LocationID, req.BinID, req.Quantity, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Update failed - Stock ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to update product stock", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Update failed", "stock_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK] Update successful")
+	productStockLog.InfoContext(c.UserContext(), "Update successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product stock updated successfully", result)
 }
 
 func DeleteProductStock(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK] Delete stock request - ID: %s from IP: %s", id, c.IP())
+	productStockLog.DebugContext(c.UserContext(), "Delete stock request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Delete failed - Invalid ID: %s, error: %v", id, err)
+		productStockLog.WarnContext(c.UserContext(), "Delete failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK] Delete failed - User not authenticated")
+		productStockLog.WarnContext(c.UserContext(), "Delete failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productStockService.DeleteProductStock(uint(idUint), userID)
+	err = productStockService.WithContext(c.UserContext()).DeleteProductStock(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK] Delete failed - Stock ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to delete product stock", err.Error())
+		productStockLog.WarnContext(c.UserContext(), "Delete failed", "stock_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK] Delete successful")
+	productStockLog.InfoContext(c.UserContext(), "Delete successful")
 	return helper.Success(c, 200, "Product stock deleted successfully", nil)
 }
+
+func HoldProductStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	productStockLog.DebugContext(c.UserContext(), "Hold product stock request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Hold product stock failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
+	}
+
+	var req HoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Hold product stock failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Hold product stock failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		productStockLog.WarnContext(c.UserContext(), "Hold product stock failed: user not authenticated", "stock_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	result, err := productStockService.WithContext(c.UserContext()).HoldProductStock(uint(idUint), req.Status, req.Reason, userID)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Hold product stock failed", "stock_id", idUint, "error", err)
+		return err
+	}
+
+	productStockLog.InfoContext(c.UserContext(), "Hold product stock successful", "stock_id", idUint, "status", req.Status, "by_user_id", userID)
+	setETag(c, result)
+	return helper.Success(c, 200, "Product stock placed on hold successfully", result)
+}
+
+func ReleaseProductStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	productStockLog.DebugContext(c.UserContext(), "Release product stock request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Release product stock failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
+	}
+
+	var req ReleaseHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Release product stock failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Release product stock failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		productStockLog.WarnContext(c.UserContext(), "Release product stock failed: user not authenticated", "stock_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	result, err := productStockService.WithContext(c.UserContext()).ReleaseProductStock(uint(idUint), req.Reason, userID)
+	if err != nil {
+		productStockLog.WarnContext(c.UserContext(), "Release product stock failed", "stock_id", idUint, "error", err)
+		return err
+	}
+
+	productStockLog.InfoContext(c.UserContext(), "Release product stock successful", "stock_id", idUint, "by_user_id", userID)
+	setETag(c, result)
+	return helper.Success(c, 200, "Product stock released successfully", result)
+}
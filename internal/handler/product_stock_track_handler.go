@@ -1,198 +1,245 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productStockTrackService = service.NewProductStockTrackService()
+var productStockTrackLog = logger.New("product_stock_track")
 
-// handleProductStockTrackError converts database errors to user-friendly messages for product stock track operations
-func handleProductStockTrackError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
+func GetAllProductStockTracks(c *fiber.Ctx) error {
+	productStockTrackLog.DebugContext(c.UserContext(), "Get all product stock tracks request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get all failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	errMsg := err.Error()
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get all failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
 
-	// Handle specific application errors first
-	if errMsg == "product stock track not found" {
-		return 404, "Product stock track not found"
+	result, total, err := productStockTrackService.WithContext(c.UserContext()).GetAllProductStockTracks(query)
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get all failed", "error", err)
+		return err
 	}
 
-	if errMsg == "product not found" {
-		return 404, "Product not found"
+	if format != "" {
+		productStockTrackLog.InfoContext(c.UserContext(), "Export product-stock-tracks", "format", format, "rows", total)
+		return sendListExport(c, format, "product-stock-tracks", result)
 	}
 
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid product ID"
+	productStockTrackLog.InfoContext(c.UserContext(), "Get all successful")
+	return helper.SuccessWithMeta(c, 200, "Product stock tracks retrieved successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+func GetProductStockTracksByCursor(c *fiber.Ctx) error {
+	productStockTrackLog.DebugContext(c.UserContext(), "Get product stock tracks by cursor request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get by cursor failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	// Default to 500 for other errors
-	return 500, "Internal server error"
+	cursor, err := utils.ParseCursorQuery(c.Queries())
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get by cursor failed: invalid cursor", "error", err)
+		return helper.Fail(c, 400, "Invalid cursor", err.Error())
+	}
+
+	result, nextCursor, err := productStockTrackService.WithContext(c.UserContext()).GetProductStockTracksByCursor(query, cursor)
+	if err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Get by cursor failed", "error", err)
+		return err
+	}
+
+	productStockTrackLog.InfoContext(c.UserContext(), "Get by cursor successful")
+	return helper.SuccessWithMeta(c, 200, "Product stock tracks retrieved successfully", result, helper.NewCursorPagination(cursor.Limit, nextCursor))
 }
 
-func GetAllProductStockTracks(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_STOCK_TRACK] Get all product stock tracks request from IP: %s", c.IP())
+func ExportProductStockTracks(c *fiber.Ctx) error {
+	productStockTrackLog.DebugContext(c.UserContext(), "Export product stock tracks request")
 
-	result, err := productStockTrackService.GetAllProductStockTracks()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get all failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to retrieve product stock tracks", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Export failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Get all successful")
-	return helper.Success(c, 200, "Product stock tracks retrieved successfully", result)
+	// The export runs in the stream writer, after the fiber context was reused, so it is scoped to the tenant here
+	svc := productStockTrackService.WithContext(c.UserContext())
+	return streamNDJSON(c, "product-stock-tracks.ndjson", productStockTrackLog, func(write func(row interface{}) error) error {
+		return svc.ExportProductStockTracks(query, write)
+	})
 }
 
 func GetProductStockTracksByStock(c *fiber.Ctx) error {
 	stockID := c.Params("stockId")
-	log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by stock request - Stock ID: %s from IP: %s", stockID, c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Get tracks by stock request", "stock_id", stockID)
 
 	stockIDUint, err := strconv.ParseUint(stockID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by stock failed - Invalid stock ID: %s, error: %v", stockID, err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Get tracks by stock failed: invalid stock ID", "stock_id", stockID, "error", err)
 		return helper.Fail(c, 400, "Invalid stock ID", err.Error())
 	}
 
-	result, err := productStockTrackService.GetProductStockTracksByStock(uint(stockIDUint))
+	result, err := productStockTrackService.WithContext(c.UserContext()).GetProductStockTracksByStock(uint(stockIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by stock failed - Stock ID: %d, error: %v", stockIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product stock tracks", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Get tracks by stock failed", "stock_id", stockIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by stock successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Get tracks by stock successful")
 	return helper.Success(c, 200, "Product stock tracks retrieved successfully", result)
 }
 
 func GetProductStockTracksByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Get tracks by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by product failed - Invalid product ID: %s, error: %v", productID, err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Get tracks by product failed: invalid product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	result, err := productStockTrackService.GetProductStockTracksByProduct(uint(productIDUint))
+	result, err := productStockTrackService.WithContext(c.UserContext()).GetProductStockTracksByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by product failed - Product ID: %d, error: %v", productIDUint, err)
-		return helper.Fail(c, 400, "Failed to retrieve product stock tracks", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Get tracks by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Get tracks by product successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Get tracks by product successful")
 	return helper.Success(c, 200, "Product stock tracks retrieved successfully", result)
 }
 
 func GetProductStockTrackByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK_TRACK] Get track by ID request - ID: %s from IP: %s", id, c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Get track by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get track by ID failed - Invalid ID: %s, error: %v", id, err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Get track by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
-	result, err := productStockTrackService.GetProductStockTrackByID(uint(idUint))
+	result, err := productStockTrackService.WithContext(c.UserContext()).GetProductStockTrackByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Get track by ID failed - ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product stock track not found", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Get track by ID failed", "id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Get track by ID successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Get track by ID successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product stock track retrieved successfully", result)
 }
 
 func CreateProductStockTrack(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_STOCK_TRACK] Create product stock track request from IP: %s", c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Create product stock track request")
 
 	var req service.CreateProductStockTrackRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Create failed - Invalid request body, error: %v", err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Create failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Create failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK_TRACK] Create failed - User not authenticated")
+		productStockTrackLog.WarnContext(c.UserContext(), "Create failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productStockTrackService.CreateProductStockTrack(req, userID)
+	result, err := productStockTrackService.WithContext(c.UserContext()).CreateProductStockTrack(req, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Create failed, error: %v", err)
-		return helper.Fail(c, 400, "Failed to create product stock track", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Create failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Create successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Create successful")
+	setETag(c, result)
 	return helper.Success(c, 201, "Product stock track created successfully", result)
 }
 
 func UpdateProductStockTrack(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK_TRACK] Update track request - ID: %s from IP: %s", id, c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Update track request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Update failed - Invalid ID: %s, error: %v", id, err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Update failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
 	var req service.UpdateProductStockTrackRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Update failed - Invalid request body, error: %v", err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Update failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productStockTrackLog.WarnContext(c.UserContext(), "Update failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK_TRACK] Update failed - User not authenticated")
+		productStockTrackLog.WarnContext(c.UserContext(), "Update failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	result, err := productStockTrackService.UpdateProductStockTrack(uint(idUint), req, userID)
+	result, err := productStockTrackService.WithContext(c.UserContext()).UpdateProductStockTrack(uint(idUint), req, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Update failed - Track ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to update product stock track", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Update failed", "track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Update successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Update successful")
+	setETag(c, result)
 	return helper.Success(c, 200, "Product stock track updated successfully", result)
 }
 
 func DeleteProductStockTrack(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_STOCK_TRACK] Delete track request - ID: %s from IP: %s", id, c.IP())
+	productStockTrackLog.DebugContext(c.UserContext(), "Delete track request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Delete failed - Invalid ID: %s, error: %v", id, err)
+		productStockTrackLog.WarnContext(c.UserContext(), "Delete failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid track ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_STOCK_TRACK] Delete failed - User not authenticated")
+		productStockTrackLog.WarnContext(c.UserContext(), "Delete failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productStockTrackService.DeleteProductStockTrack(uint(idUint), userID)
+	err = productStockTrackService.WithContext(c.UserContext()).DeleteProductStockTrack(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_STOCK_TRACK] Delete failed - Track ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 400, "Failed to delete product stock track", err.Error())
+		productStockTrackLog.WarnContext(c.UserContext(), "Delete failed", "track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_STOCK_TRACK] Delete successful")
+	productStockTrackLog.InfoContext(c.UserContext(), "Delete successful")
 	return helper.Success(c, 200, "Product stock track deleted successfully", nil)
 }
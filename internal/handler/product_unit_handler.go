@@ -1,90 +1,26 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productUnitService = service.NewProductUnitService()
-
-// handleProductUnitError converts database errors to user-friendly messages for product unit operations
-func handleProductUnitError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "product unit with this name already exists for this product" {
-		return 409, "Product unit name already exists for this product"
-	}
-
-	if errMsg == "barcode already exists for this product and location" {
-		return 409, "Barcode already exists for this product and location"
-	}
-
-	if errMsg == "barcode already exists for another product" {
-		return 409, "barcode already exists for another product"
-	}
-
-	if errMsg == "product unit with same name and location already exists" {
-		return 409, "product unit with same name and location already exists"
-	}
-
-	if errMsg == "product unit name already in use for this product and location" {
-		return 409, "product unit name already in use for this product and location"
-	}
-
-	if errMsg == "product unit with this name already exists for this product and location" {
-		return 409, "product unit with this name already exists for this product and location"
-	}
-
-	if errMsg == "product unit barcode already in use for this product" {
-		return 409, "Product unit barcode already in use for this product"
-	}
-
-	if errMsg == "product unit not found" {
-		return 404, "Product unit not found"
-	}
-
-	if errMsg == "product not found" {
-		return 404, "Product not found"
-	}
-
-	if errMsg == "invalid location ID" {
-		return 400, "Invalid location ID"
-	}
-	if errMsg == "location ID is required" {
-		return 400, "Invalid location ID"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") {
-		return 409, "Product unit already exists"
-	}
-
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid product ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var productUnitLog = logger.New("product_unit")
 
 type CreateProductUnitRequest struct {
 	ProductID       uint     `json:"productId" validate:"required"`
 	LocationID      uint     `json:"locationId" validate:"required"`
 	ProductBatchID  uint     `json:"productBatchId" validate:"required"`
 	Name            *string  `json:"name"`
-	Quantity        *float64 `json:"quantity"`
-	UnitPrice       *float64 `json:"unitPrice"`
-	UnitPriceRetail *float64 `json:"unitPriceRetail"`
+	Quantity        *float64 `json:"quantity" validate:"omitempty,gte=0"`
+	UnitPrice       *float64 `json:"unitPrice" validate:"omitempty,gte=0"`
+	UnitPriceRetail *float64 `json:"unitPriceRetail" validate:"omitempty,gte=0"`
 	Barcode         *string  `json:"barcode"`
 	Description     *string  `json:"description"`
 }
@@ -94,196 +30,223 @@ type UpdateProductUnitRequest struct {
 	LocationID      uint     `json:"locationId" validate:"required"`
 	ProductBatchID  uint     `json:"productBatchId" validate:"required"`
 	Name            *string  `json:"name"`
-	Quantity        *float64 `json:"quantity"`
-	UnitPrice       *float64 `json:"unitPrice"`
-	UnitPriceRetail *float64 `json:"unitPriceRetail"`
+	Quantity        *float64 `json:"quantity" validate:"omitempty,gte=0"`
+	UnitPrice       *float64 `json:"unitPrice" validate:"omitempty,gte=0"`
+	UnitPriceRetail *float64 `json:"unitPriceRetail" validate:"omitempty,gte=0"`
 	Barcode         *string  `json:"barcode"`
 	Description     *string  `json:"description"`
 }
 
 func GetProductUnits(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_UNIT] Get all product units request from IP: %s", c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Get all product units request")
 
-	productUnits, err := productUnitService.GetAllProductUnits()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get all product units failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch product units", err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Get all product units failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_UNIT] Get all product units successful")
-	return helper.Success(c, 200, "Success", productUnits)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productUnitLog.WarnContext(c.UserContext(), "Get all product units failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	productUnits, total, err := productUnitService.WithContext(c.UserContext()).GetAllProductUnits(query)
+	if err != nil {
+		productUnitLog.WarnContext(c.UserContext(), "Get all product units failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productUnitLog.InfoContext(c.UserContext(), "Export product-units", "format", format, "rows", total)
+		return sendListExport(c, format, "product-units", productUnits)
+	}
+
+	productUnitLog.InfoContext(c.UserContext(), "Get all product units successful")
+	return helper.SuccessWithMeta(c, 200, "Success", productUnits, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductUnitsByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_UNIT] Get product units by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Get product units by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get product units by product failed - Invalid Product ID: %s, error: %v", productID, err)
+		productUnitLog.WarnContext(c.UserContext(), "Get product units by product failed: invalid Product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	productUnits, err := productUnitService.GetProductUnitsByProduct(uint(productIDUint))
+	productUnits, err := productUnitService.WithContext(c.UserContext()).GetProductUnitsByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get product units by product failed - Product ID: %d, error: %v", productIDUint, err)
-		statusCode, message := handleProductUnitError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Get product units by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Get product units by product successful - Product ID: %d", productIDUint)
+	productUnitLog.InfoContext(c.UserContext(), "Get product units by product successful", "product_id", productIDUint)
 	return helper.Success(c, 200, "Success", productUnits)
 }
 
 func GetProductUnitByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT] Get product unit by ID request - ID: %s from IP: %s", id, c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Get product unit by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get product unit by ID failed - Invalid ID: %s, error: %v", id, err)
+		productUnitLog.WarnContext(c.UserContext(), "Get product unit by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit ID", err.Error())
 	}
 
-	productUnit, err := productUnitService.GetProductUnitByID(uint(idUint))
+	productUnit, err := productUnitService.WithContext(c.UserContext()).GetProductUnitByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get product unit by ID failed - Product Unit ID: %d not found, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Product unit not found", err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Get product unit by ID failed: not found", "product_unit_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Get product unit by ID successful - Product Unit ID: %d", idUint)
+	productUnitLog.InfoContext(c.UserContext(), "Get product unit by ID successful", "product_unit_id", idUint)
+	setETag(c, productUnit)
 	return helper.Success(c, 200, "Success", productUnit)
 }
 
 func CreateProductUnit(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_UNIT] Create product unit request from IP: %s", c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Create product unit request")
 
 	var req CreateProductUnitRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_UNIT] Create product unit failed - Invalid request body, error: %v", err)
+		productUnitLog.WarnContext(c.UserContext(), "Create product unit failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productUnitLog.WarnContext(c.UserContext(), "Create product unit failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT] Create product unit failed - User not authenticated")
+		productUnitLog.WarnContext(c.UserContext(), "Create product unit failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT_UNIT] Creating product unit with audit - User ID: %d, Product ID: %d, Product Batch ID: %d", userID, req.ProductID, req.ProductBatchID)
+	productUnitLog.InfoContext(c.UserContext(), "Creating product unit with audit", "user_id", userID, "product_id", req.ProductID, "product_batch_id", req.ProductBatchID)
 
-	productUnit, err := productUnitService.CreateProductUnit(req.ProductID, req.LocationID, req.ProductBatchID, req.Name, req.Quantity, req.UnitPrice, req.UnitPriceRetail, req.Barcode, req.Description, userID)
+	productUnit, err := productUnitService.WithContext(c.UserContext()).CreateProductUnit(req.ProductID, req.LocationID, req.ProductBatchID, req.Name, req.Quantity, req.UnitPrice, req.UnitPriceRetail, req.Barcode, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Create product unit failed - Product ID: %d, User ID: %d, error: %v", req.ProductID, userID, err)
-		statusCode, message := handleProductUnitError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Create product unit failed", "product_id", req.ProductID, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Create product unit successful - Product Unit: %v, Created by User ID: %d", productUnit, userID)
+	productUnitLog.InfoContext(c.UserContext(), "Create product unit successful", "product_unit", productUnit, "created_by_user_id", userID)
+	setETag(c, productUnit)
 	return helper.Success(c, 201, "Product unit created successfully", productUnit)
 }
 
 func UpdateProductUnit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT] Update product unit request - ID: %s from IP: %s", id, c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Update product unit request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Update product unit failed - Invalid ID: %s, error: %v", id, err)
+		productUnitLog.WarnContext(c.UserContext(), "Update product unit failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit ID", err.Error())
 	}
 
 	var req UpdateProductUnitRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_UNIT] Update product unit failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		productUnitLog.WarnContext(c.UserContext(), "Update product unit failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productUnitLog.WarnContext(c.UserContext(), "Update product unit failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT] Update product unit failed - User not authenticated for Product Unit ID: %d", idUint)
+		productUnitLog.WarnContext(c.UserContext(), "Update product unit failed: user not authenticated", "product_unit_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT_UNIT] Updating product unit with audit - Product Unit ID: %d, User ID: %d, Product Batch ID: %d", idUint, userID, req.ProductBatchID)
+	productUnitLog.InfoContext(c.UserContext(), "Updating product unit with audit", "product_unit_id", idUint, "user_id", userID, "product_batch_id", req.ProductBatchID)
 
-	productUnit, err := productUnitService.UpdateProductUnit(uint(idUint), req.ProductID, req.LocationID, req.ProductBatchID, req.Name, req.Quantity, req.UnitPrice, req.UnitPriceRetail, req.Barcode, req.Description, userID)
+	productUnit, err := productUnitService.WithContext(c.UserContext()).UpdateProductUnit(uint(idUint), req.ProductID, req.LocationID, req.ProductBatchID, req.Name, req.Quantity, req.UnitPrice, req.UnitPriceRetail, req.Barcode, req.Description, userID)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Update product unit failed - Product Unit ID: %d, User ID: %d, error: %v", idUint, userID, err)
-		statusCode, message := handleProductUnitError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Update product unit failed", "product_unit_id", idUint, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Update product unit successful - Product Unit: %v, Updated by User ID: %d", productUnit, userID)
+	productUnitLog.InfoContext(c.UserContext(), "Update product unit successful", "product_unit", productUnit, "updated_by_user_id", userID)
+	setETag(c, productUnit)
 	return helper.Success(c, 200, "Product unit updated successfully", productUnit)
 }
 
 func DeleteProductUnit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT] Delete product unit request - ID: %s from IP: %s", id, c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Delete product unit request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Delete product unit failed - Invalid ID: %s, error: %v", id, err)
+		productUnitLog.WarnContext(c.UserContext(), "Delete product unit failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT] Delete product unit failed - User not authenticated for Product Unit ID: %d", idUint)
+		productUnitLog.WarnContext(c.UserContext(), "Delete product unit failed: user not authenticated", "product_unit_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productUnitService.DeleteProductUnit(uint(idUint), userID)
+	err = productUnitService.WithContext(c.UserContext()).DeleteProductUnit(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Delete product unit failed - Product Unit ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductUnitError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Delete product unit failed", "product_unit_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Delete product unit successful - Product Unit ID: %d", idUint)
+	productUnitLog.InfoContext(c.UserContext(), "Delete product unit successful", "product_unit_id", idUint)
 	return helper.Success(c, 200, "Product unit deleted successfully", nil)
 }
 
 func GetDeletedProductUnits(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_UNIT] Get deleted product units request from IP: %s", c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Get deleted product units request")
 
-	units, err := productUnitService.GetDeletedProductUnits()
+	units, err := productUnitService.WithContext(c.UserContext()).GetDeletedProductUnits()
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Get deleted product units failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted product units", err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Get deleted product units failed", "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Get deleted product units successful")
+	productUnitLog.InfoContext(c.UserContext(), "Get deleted product units successful")
 	return helper.Success(c, 200, "Success", units)
 }
 
 func RestoreProductUnit(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT] Restore product unit request - ID: %s from IP: %s", id, c.IP())
+	productUnitLog.DebugContext(c.UserContext(), "Restore product unit request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Restore product unit failed - Invalid ID: %s, error: %v", id, err)
+		productUnitLog.WarnContext(c.UserContext(), "Restore product unit failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT] Restore product unit failed - User not authenticated for Product Unit ID: %d", idUint)
+		productUnitLog.WarnContext(c.UserContext(), "Restore product unit failed: user not authenticated", "product_unit_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	unit, err := productUnitService.RestoreProductUnit(uint(idUint), userID)
+	unit, err := productUnitService.WithContext(c.UserContext()).RestoreProductUnit(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT] Restore product unit failed - Product Unit ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductUnitError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitLog.WarnContext(c.UserContext(), "Restore product unit failed", "product_unit_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT] Restore product unit successful - Product Unit ID: %d, Restored by User ID: %d", idUint, userID)
+	productUnitLog.InfoContext(c.UserContext(), "Restore product unit successful", "product_unit_id", idUint, "restored_by_user_id", userID)
+	setETag(c, unit)
 	return helper.Success(c, 200, "Product unit restored successfully", unit)
 }
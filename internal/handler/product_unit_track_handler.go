@@ -1,50 +1,17 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var productUnitTrackService = service.NewProductUnitTrackService()
-
-// handleProductUnitTrackError converts database errors to user-friendly messages for product unit track operations
-func handleProductUnitTrackError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "product unit track not found" {
-		return 404, "Product unit track not found"
-	}
-
-	if errMsg == "product unit not found" {
-		return 404, "Product unit not found"
-	}
-
-	if errMsg == "product not found" {
-		return 404, "Product not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") {
-		return 409, "Product unit track already exists"
-	}
-
-	if strings.Contains(errMsg, "foreign key constraint") {
-		return 400, "Invalid product unit ID"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var productUnitTrackLog = logger.New("product_unit_track")
 
 type CreateProductUnitTrackRequest struct {
 	ProductUnitID uint    `json:"productUnitId" validate:"required"`
@@ -52,103 +19,125 @@ type CreateProductUnitTrackRequest struct {
 }
 
 type UpdateProductUnitTrackRequest struct {
-	ProductUnitID uint    `json:"productUnitId"`
+	ProductUnitID uint    `json:"productUnitId" validate:"omitempty,min=1"`
 	Description   *string `json:"description"`
 }
 
 func GetProductUnitTracks(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_UNIT_TRACK] Get all product unit tracks request from IP: %s", c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Get all product unit tracks request")
 
-	tracks, err := productUnitTrackService.GetAllProductUnitTracks()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get all product unit tracks failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch product unit tracks", err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get all product unit tracks failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Get all product unit tracks successful")
-	return helper.Success(c, 200, "Success", tracks)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get all product unit tracks failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	tracks, total, err := productUnitTrackService.WithContext(c.UserContext()).GetAllProductUnitTracks(query)
+	if err != nil {
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get all product unit tracks failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		productUnitTrackLog.InfoContext(c.UserContext(), "Export product-unit-tracks", "format", format, "rows", total)
+		return sendListExport(c, format, "product-unit-tracks", tracks)
+	}
+
+	productUnitTrackLog.InfoContext(c.UserContext(), "Get all product unit tracks successful")
+	return helper.SuccessWithMeta(c, 200, "Success", tracks, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetProductUnitTracksByProduct(c *fiber.Ctx) error {
 	productID := c.Params("productId")
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product request - Product ID: %s from IP: %s", productID, c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Get product unit tracks by product request", "product_id", productID)
 
 	productIDUint, err := strconv.ParseUint(productID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product failed - Invalid Product ID: %s, error: %v", productID, err)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit tracks by product failed: invalid Product ID", "product_id", productID, "error", err)
 		return helper.Fail(c, 400, "Invalid product ID", err.Error())
 	}
 
-	tracks, err := productUnitTrackService.GetProductUnitTracksByProduct(uint(productIDUint))
+	tracks, err := productUnitTrackService.WithContext(c.UserContext()).GetProductUnitTracksByProduct(uint(productIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product failed - Product ID: %d, error: %v", productIDUint, err)
-		statusCode, message := handleProductUnitTrackError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit tracks by product failed", "product_id", productIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product successful - Product ID: %d", productIDUint)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Get product unit tracks by product successful", "product_id", productIDUint)
 	return helper.Success(c, 200, "Success", tracks)
 }
 
 func GetProductUnitTracksByProductUnit(c *fiber.Ctx) error {
 	productUnitID := c.Params("productUnitId")
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product unit request - Product Unit ID: %s from IP: %s", productUnitID, c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Get product unit tracks by product unit request", "product_unit_id", productUnitID)
 
 	productUnitIDUint, err := strconv.ParseUint(productUnitID, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product unit failed - Invalid Product Unit ID: %s, error: %v", productUnitID, err)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit tracks by product unit failed: invalid Product Unit ID", "product_unit_id", productUnitID, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit ID", err.Error())
 	}
 
-	tracks, err := productUnitTrackService.GetProductUnitTracksByProductUnit(uint(productUnitIDUint))
+	tracks, err := productUnitTrackService.WithContext(c.UserContext()).GetProductUnitTracksByProductUnit(uint(productUnitIDUint))
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product unit failed - Product Unit ID: %d, error: %v", productUnitIDUint, err)
-		statusCode, message := handleProductUnitTrackError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit tracks by product unit failed", "product_unit_id", productUnitIDUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit tracks by product unit successful - Product Unit ID: %d", productUnitIDUint)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Get product unit tracks by product unit successful", "product_unit_id", productUnitIDUint)
 	return helper.Success(c, 200, "Success", tracks)
 }
 
 func GetProductUnitTrackByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit track by ID request - ID: %s from IP: %s", id, c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Get product unit track by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit track by ID failed - Invalid ID: %s, error: %v", id, err)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit track by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit track ID", err.Error())
 	}
 
-	track, err := productUnitTrackService.GetProductUnitTrackByID(uint(idUint))
+	track, err := productUnitTrackService.WithContext(c.UserContext()).GetProductUnitTrackByID(uint(idUint))
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Get product unit track by ID failed - Product Unit Track ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductUnitTrackError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Get product unit track by ID failed", "product_unit_track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Get product unit track by ID successful - Product Unit Track ID: %d", idUint)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Get product unit track by ID successful", "product_unit_track_id", idUint)
+	setETag(c, track)
 	return helper.Success(c, 200, "Success", track)
 }
 
 func CreateProductUnitTrack(c *fiber.Ctx) error {
-	log.Printf("[PRODUCT_UNIT_TRACK] Create product unit track request from IP: %s", c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Create product unit track request")
 
 	var req CreateProductUnitTrackRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Create product unit track failed - Invalid request body, error: %v", err)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Create product unit track failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		productUnitTrackLog.WarnContext(c.UserContext(), "Create product unit track failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT_TRACK] Create product unit track failed - User not authenticated")
+		productUnitTrackLog.WarnContext(c.UserContext(), "Create product unit track failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Creating product unit track with audit - User ID: %d, Product Unit ID: %d", userID, req.ProductUnitID)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Creating product unit track with audit", "user_id", userID, "product_unit_id", req.ProductUnitID)
 
 	// Convert *string to string for description
 	description := ""
@@ -156,45 +145,44 @@ func CreateProductUnitTrack(c *fiber.Ctx) error {
 		description = *req.Description
 	}
 
-	track, err := productUnitTrackService.CreateProductUnitTrack(
+	track, err := productUnitTrackService.WithContext(c.UserContext()).CreateProductUnitTrack(
 		req.ProductUnitID,
 		description,
 		userID,
 	)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Create product unit track failed - Product Unit ID: %d, User ID: %d, error: %v", req.ProductUnitID, userID, err)
-		statusCode, message := handleProductUnitTrackError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Create product unit track failed", "product_unit_id", req.ProductUnitID, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Create product unit track successful - Track: %v, Created by User ID: %d", track, userID)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Create product unit track successful", "track", track, "created_by_user_id", userID)
+	setETag(c, track)
 	return helper.Success(c, 201, "Product unit track created successfully", track)
 }
 
 func DeleteProductUnitTrack(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[PRODUCT_UNIT_TRACK] Delete product unit track request - ID: %s from IP: %s", id, c.IP())
+	productUnitTrackLog.DebugContext(c.UserContext(), "Delete product unit track request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Delete product unit track failed - Invalid ID: %s, error: %v", id, err)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Delete product unit track failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid product unit track ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[PRODUCT_UNIT_TRACK] Delete product unit track failed - User not authenticated for Track ID: %d", idUint)
+		productUnitTrackLog.WarnContext(c.UserContext(), "Delete product unit track failed: user not authenticated", "track_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = productUnitTrackService.DeleteProductUnitTrack(uint(idUint), userID)
+	err = productUnitTrackService.WithContext(c.UserContext()).DeleteProductUnitTrack(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[PRODUCT_UNIT_TRACK] Delete product unit track failed - Track ID: %d, error: %v", idUint, err)
-		statusCode, message := handleProductUnitTrackError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		productUnitTrackLog.WarnContext(c.UserContext(), "Delete product unit track failed", "track_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[PRODUCT_UNIT_TRACK] Delete product unit track successful - Track ID: %d", idUint)
+	productUnitTrackLog.InfoContext(c.UserContext(), "Delete product unit track successful", "track_id", idUint)
 	return helper.Success(c, 200, "Product unit track deleted successfully", nil)
 }
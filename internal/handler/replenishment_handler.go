@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var replenishmentService = service.NewReplenishmentService()
+var replenishmentLog = logger.New("replenishment")
+
+type CreateReplenishmentRuleRequest struct {
+	ProductID    uint    `json:"productId" validate:"required"`
+	LocationID   *uint   `json:"locationId"` // Omit for a product-wide rule
+	MinQuantity  float64 `json:"minQuantity" validate:"gte=0"`
+	ReorderPoint float64 `json:"reorderPoint" validate:"gtefield=MinQuantity"`
+	MaxQuantity  float64 `json:"maxQuantity" validate:"gtfield=ReorderPoint"`
+}
+
+type UpdateReplenishmentRuleRequest struct {
+	MinQuantity  *float64 `json:"minQuantity" validate:"omitempty,gte=0"`
+	ReorderPoint *float64 `json:"reorderPoint" validate:"omitempty,gte=0"`
+	MaxQuantity  *float64 `json:"maxQuantity" validate:"omitempty,gt=0"`
+}
+
+// GetReplenishmentSuggestions lists what to order or transfer, optionally for one ?product_id= and/or ?location_id=
+func GetReplenishmentSuggestions(c *fiber.Ctx) error {
+	replenishmentLog.DebugContext(c.UserContext(), "Get replenishment suggestions request")
+
+	productID, err := parseOptionalID(c, "product_id")
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get suggestions failed: invalid product_id", "product_id", c.Query("product_id"))
+		return helper.Fail(c, 400, "Invalid query parameters", "product_id must be a positive integer")
+	}
+
+	locationID, err := parseOptionalID(c, "location_id")
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get suggestions failed: invalid location_id", "location_id", c.Query("location_id"))
+		return helper.Fail(c, 400, "Invalid query parameters", "location_id must be a positive integer")
+	}
+
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get suggestions failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+
+	suggestions, err := replenishmentService.WithContext(c.UserContext()).GetReplenishmentSuggestions(productID, locationID)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get suggestions failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		replenishmentLog.InfoContext(c.UserContext(), "Export replenishment suggestions", "format", format, "rows", len(suggestions))
+		return sendListExport(c, format, "replenishment-suggestions", suggestions)
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Get suggestions successful", "suggestions", len(suggestions))
+	return helper.Success(c, 200, "Replenishment suggestions retrieved successfully", suggestions)
+}
+
+func GetReplenishmentRules(c *fiber.Ctx) error {
+	replenishmentLog.DebugContext(c.UserContext(), "Get all replenishment rules request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get all rules failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get all rules failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	rules, total, err := replenishmentService.WithContext(c.UserContext()).GetAllReplenishmentRules(query)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get all rules failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		replenishmentLog.InfoContext(c.UserContext(), "Export replenishment rules", "format", format, "rows", total)
+		return sendListExport(c, format, "replenishment-rules", rules)
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Get all rules successful")
+	return helper.SuccessWithMeta(c, 200, "Replenishment rules retrieved successfully", rules, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+func GetReplenishmentRuleByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	replenishmentLog.DebugContext(c.UserContext(), "Get replenishment rule request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get rule failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid replenishment rule ID", err.Error())
+	}
+
+	rule, err := replenishmentService.WithContext(c.UserContext()).GetReplenishmentRuleByID(uint(idUint))
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get rule failed", "rule_id", idUint, "error", err)
+		return err
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Get rule successful", "rule_id", idUint)
+	setETag(c, rule)
+	return helper.Success(c, 200, "Replenishment rule retrieved successfully", rule)
+}
+
+func CreateReplenishmentRule(c *fiber.Ctx) error {
+	replenishmentLog.DebugContext(c.UserContext(), "Create replenishment rule request")
+
+	var req CreateReplenishmentRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Create rule failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Create rule failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		replenishmentLog.WarnContext(c.UserContext(), "Create rule failed: user not authenticated")
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	rule, err := replenishmentService.WithContext(c.UserContext()).CreateReplenishmentRule(req.ProductID, req.LocationID, req.MinQuantity, req.ReorderPoint, req.MaxQuantity, userID)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Create rule failed", "product_id", req.ProductID, "error", err)
+		return err
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Create rule successful", "product_id", req.ProductID, "created_by_user_id", userID)
+	setETag(c, rule)
+	return helper.Success(c, 201, "Replenishment rule created successfully", rule)
+}
+
+func UpdateReplenishmentRule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	replenishmentLog.DebugContext(c.UserContext(), "Update replenishment rule request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Update rule failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid replenishment rule ID", err.Error())
+	}
+
+	var req UpdateReplenishmentRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Update rule failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Update rule failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		replenishmentLog.WarnContext(c.UserContext(), "Update rule failed: user not authenticated", "rule_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	rule, err := replenishmentService.WithContext(c.UserContext()).UpdateReplenishmentRule(uint(idUint), req.MinQuantity, req.ReorderPoint, req.MaxQuantity, userID)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Update rule failed", "rule_id", idUint, "error", err)
+		return err
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Update rule successful", "rule_id", idUint, "updated_by_user_id", userID)
+	setETag(c, rule)
+	return helper.Success(c, 200, "Replenishment rule updated successfully", rule)
+}
+
+func DeleteReplenishmentRule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	replenishmentLog.DebugContext(c.UserContext(), "Delete replenishment rule request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Delete rule failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid replenishment rule ID", err.Error())
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		replenishmentLog.WarnContext(c.UserContext(), "Delete rule failed: user not authenticated", "rule_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	err = replenishmentService.WithContext(c.UserContext()).DeleteReplenishmentRule(uint(idUint), userID)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Delete rule failed", "rule_id", idUint, "error", err)
+		return err
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Delete rule successful", "rule_id", idUint, "deleted_by_user_id", userID)
+	return helper.Success(c, 200, "Replenishment rule deleted successfully", nil)
+}
+
+func GetReplenishmentAlerts(c *fiber.Ctx) error {
+	replenishmentLog.DebugContext(c.UserContext(), "Get replenishment alerts request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get alerts failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get alerts failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	alerts, total, err := replenishmentService.WithContext(c.UserContext()).GetAllReplenishmentAlerts(query)
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Get alerts failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		replenishmentLog.InfoContext(c.UserContext(), "Export replenishment alerts", "format", format, "rows", total)
+		return sendListExport(c, format, "replenishment-alerts", alerts)
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Get alerts successful")
+	return helper.SuccessWithMeta(c, 200, "Replenishment alerts retrieved successfully", alerts, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+// RunReplenishmentCheck runs the replenishment job immediately instead of waiting for its next interval
+func RunReplenishmentCheck(c *fiber.Ctx) error {
+	replenishmentLog.DebugContext(c.UserContext(), "Run replenishment check request")
+
+	result, err := replenishmentService.WithContext(c.UserContext()).RunReplenishmentCheck()
+	if err != nil {
+		replenishmentLog.WarnContext(c.UserContext(), "Run check failed", "error", err)
+		return err
+	}
+
+	replenishmentLog.InfoContext(c.UserContext(), "Run check successful", "rules", result.Rules, "opened", result.Opened, "updated", result.Updated, "resolved", result.Resolved)
+	return helper.Success(c, 200, "Replenishment check completed", result)
+}
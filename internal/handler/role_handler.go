@@ -1,216 +1,218 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var roleService = service.NewRoleService()
-
-// handleRoleError converts database errors to user-friendly messages for role operations
-func handleRoleError(err error) (int, string) {
-	if err == nil {
-		return 200, ""
-	}
-
-	errMsg := err.Error()
-
-	// Handle specific application errors first
-	if errMsg == "role already exists" || errMsg == "role name already in use" {
-		return 409, "Role name already exists"
-	}
-
-	if errMsg == "role not found" {
-		return 404, "Role not found"
-	}
-
-	// Handle PostgreSQL constraint errors as backup
-	if strings.Contains(errMsg, "duplicate key value violates unique constraint") &&
-		strings.Contains(errMsg, "uni_roles_name") {
-		return 409, "Role name already exists"
-	}
-
-	// Default to 500 for other errors
-	return 500, "Internal server error"
-}
+var roleLog = logger.New("role")
 
 type CreateRoleRequest struct {
-	Name        string `json:"name" validate:"required"`
+	Name        string `json:"name" validate:"required,max=255"`
 	Description string `json:"description"`
 }
 
 type UpdateRoleRequest struct {
-	Name        string `json:"name" validate:"required"`
+	Name        string `json:"name" validate:"required,max=255"`
 	Description string `json:"description"`
 }
 
 func GetRoles(c *fiber.Ctx) error {
-	log.Printf("[ROLE] Get all roles request from IP: %s", c.IP())
+	roleLog.DebugContext(c.UserContext(), "Get all roles request")
 
-	roles, err := roleService.GetAllRoles()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[ROLE] Get all roles failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch roles", err.Error())
+		roleLog.WarnContext(c.UserContext(), "Get all roles failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[ROLE] Get all roles successful - Found %d roles", len(roles))
-	return helper.Success(c, 200, "Success", roles)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		roleLog.WarnContext(c.UserContext(), "Get all roles failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	roles, total, err := roleService.WithContext(c.UserContext()).GetAllRoles(query)
+	if err != nil {
+		roleLog.WarnContext(c.UserContext(), "Get all roles failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		roleLog.InfoContext(c.UserContext(), "Export roles", "format", format, "rows", total)
+		return sendListExport(c, format, "roles", roles)
+	}
+
+	roleLog.InfoContext(c.UserContext(), "Get all roles successful", "count", len(roles))
+	return helper.SuccessWithMeta(c, 200, "Success", roles, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetRoleByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[ROLE] Get role by ID request - ID: %s from IP: %s", id, c.IP())
+	roleLog.DebugContext(c.UserContext(), "Get role by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[ROLE] Get role by ID failed - Invalid ID: %s, error: %v", id, err)
+		roleLog.WarnContext(c.UserContext(), "Get role by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid role ID", err.Error())
 	}
 
-	role, err := roleService.GetRoleByID(uint(idUint))
+	role, err := roleService.WithContext(c.UserContext()).GetRoleByID(uint(idUint))
 	if err != nil {
-		log.Printf("[ROLE] Get role by ID failed - Role ID: %d not found, error: %v", idUint, err)
-		return helper.Fail(c, 404, "Role not found", err.Error())
+		roleLog.WarnContext(c.UserContext(), "Get role by ID failed: not found", "role_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Get role by ID successful - Role ID: %d, Name: %s", role.ID, role.Name)
+	roleLog.InfoContext(c.UserContext(), "Get role by ID successful", "role_id", role.ID, "name", role.Name)
+	setETag(c, role)
 	return helper.Success(c, 200, "Success", role)
 }
 
 func CreateRole(c *fiber.Ctx) error {
-	log.Printf("[ROLE] Create role request from IP: %s", c.IP())
+	roleLog.DebugContext(c.UserContext(), "Create role request")
 
 	var req CreateRoleRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[ROLE] Create role failed - Invalid request body, error: %v", err)
+		roleLog.WarnContext(c.UserContext(), "Create role failed: invalid request body", "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		roleLog.WarnContext(c.UserContext(), "Create role failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[ROLE] Create role failed - User not authenticated")
+		roleLog.WarnContext(c.UserContext(), "Create role failed: user not authenticated")
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[ROLE] Creating role with audit - User ID: %d", userID)
+	roleLog.InfoContext(c.UserContext(), "Creating role with audit", "user_id", userID)
 
-	role, err := roleService.CreateRole(req.Name, req.Description, userID)
+	role, err := roleService.WithContext(c.UserContext()).CreateRole(req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[ROLE] Create role failed - Name: %s, User ID: %d, error: %v", req.Name, userID, err)
-		statusCode, message := handleRoleError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		roleLog.WarnContext(c.UserContext(), "Create role failed", "name", req.Name, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Create role successful - Role ID: %d, Name: %s, Created by User ID: %d", role.ID, role.Name, userID)
+	roleLog.InfoContext(c.UserContext(), "Create role successful", "role_id", role.ID, "name", role.Name, "created_by_user_id", userID)
+	setETag(c, role)
 	return helper.Success(c, 201, "Role created successfully", role)
 }
 
 func UpdateRole(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[ROLE] Update role request - ID: %s from IP: %s", id, c.IP())
+	roleLog.DebugContext(c.UserContext(), "Update role request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[ROLE] Update role failed - Invalid ID: %s, error: %v", id, err)
+		roleLog.WarnContext(c.UserContext(), "Update role failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid role ID", err.Error())
 	}
 
 	var req UpdateRoleRequest
 	if err := c.BodyParser(&req); err != nil {
-		log.Printf("[ROLE] Update role failed - Invalid request body for ID: %d, error: %v", idUint, err)
+		roleLog.WarnContext(c.UserContext(), "Update role failed: invalid request body", "id", idUint, "error", err)
 		return helper.Fail(c, 400, "Invalid request body", err.Error())
 	}
+	if err := helper.Validate(&req); err != nil {
+		roleLog.WarnContext(c.UserContext(), "Update role failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[ROLE] Update role failed - User not authenticated for Role ID: %d", idUint)
+		roleLog.WarnContext(c.UserContext(), "Update role failed: user not authenticated", "role_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	log.Printf("[ROLE] Updating role with audit - Role ID: %d, User ID: %d", idUint, userID)
+	roleLog.InfoContext(c.UserContext(), "Updating role with audit", "role_id", idUint, "user_id", userID)
 
-	role, err := roleService.UpdateRole(uint(idUint), req.Name, req.Description, userID)
+	role, err := roleService.WithContext(c.UserContext()).UpdateRole(uint(idUint), req.Name, req.Description, userID)
 	if err != nil {
-		log.Printf("[ROLE] Update role failed - Role ID: %d, User ID: %d, error: %v", idUint, userID, err)
-		statusCode, message := handleRoleError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		roleLog.WarnContext(c.UserContext(), "Update role failed", "role_id", idUint, "user_id", userID, "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Update role successful - Role ID: %d, Name: %s, Updated by User ID: %d", role.ID, role.Name, userID)
+	roleLog.InfoContext(c.UserContext(), "Update role successful", "role_id", role.ID, "name", role.Name, "updated_by_user_id", userID)
+	setETag(c, role)
 	return helper.Success(c, 200, "Role updated successfully", role)
 }
 
 func DeleteRole(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[ROLE] Delete role request - ID: %s from IP: %s", id, c.IP())
+	roleLog.DebugContext(c.UserContext(), "Delete role request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[ROLE] Delete role failed - Invalid ID: %s, error: %v", id, err)
+		roleLog.WarnContext(c.UserContext(), "Delete role failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid role ID", err.Error())
 	}
 
 	// Get user ID from JWT token
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[ROLE] Delete role failed - User not authenticated for Role ID: %d", idUint)
+		roleLog.WarnContext(c.UserContext(), "Delete role failed: user not authenticated", "role_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	err = roleService.DeleteRole(uint(idUint), userID)
+	err = roleService.WithContext(c.UserContext()).DeleteRole(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[ROLE] Delete role failed - Role ID: %d, error: %v", idUint, err)
-		statusCode, message := handleRoleError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		roleLog.WarnContext(c.UserContext(), "Delete role failed", "role_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Delete role successful - Role ID: %d, Deleted by User ID: %d", idUint, userID)
+	roleLog.InfoContext(c.UserContext(), "Delete role successful", "role_id", idUint, "deleted_by_user_id", userID)
 	return helper.Success(c, 200, "Role deleted successfully", nil)
 }
 
 func GetDeletedRoles(c *fiber.Ctx) error {
-	log.Printf("[ROLE] Get deleted roles request from IP: %s", c.IP())
+	roleLog.DebugContext(c.UserContext(), "Get deleted roles request")
 
-	roles, err := roleService.GetDeletedRoles()
+	roles, err := roleService.WithContext(c.UserContext()).GetDeletedRoles()
 	if err != nil {
-		log.Printf("[ROLE] Get deleted roles failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted roles", err.Error())
+		roleLog.WarnContext(c.UserContext(), "Get deleted roles failed", "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Get deleted roles successful - Found %d deleted roles", len(roles))
+	roleLog.InfoContext(c.UserContext(), "Get deleted roles successful", "count", len(roles))
 	return helper.Success(c, 200, "Success", roles)
 }
 
 func RestoreRole(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[ROLE] Restore role request - ID: %s from IP: %s", id, c.IP())
+	roleLog.DebugContext(c.UserContext(), "Restore role request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[ROLE] Restore role failed - Invalid ID: %s, error: %v", id, err)
+		roleLog.WarnContext(c.UserContext(), "Restore role failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid role ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[ROLE] Restore role failed - User not authenticated for Role ID: %d", idUint)
+		roleLog.WarnContext(c.UserContext(), "Restore role failed: user not authenticated", "role_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	role, err := roleService.RestoreRole(uint(idUint), userID)
+	role, err := roleService.WithContext(c.UserContext()).RestoreRole(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[ROLE] Restore role failed - Role ID: %d, error: %v", idUint, err)
-		statusCode, message := handleRoleError(err)
-		return helper.Fail(c, statusCode, message, err.Error())
+		roleLog.WarnContext(c.UserContext(), "Restore role failed", "role_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[ROLE] Restore role successful - Role ID: %d, Restored by User ID: %d", idUint, userID)
+	roleLog.InfoContext(c.UserContext(), "Restore role successful", "role_id", idUint, "restored_by_user_id", userID)
+	setETag(c, role)
 	return helper.Success(c, 200, "Role restored successfully", role)
 }
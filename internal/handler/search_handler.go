@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var searchService = service.NewSearchService()
+var searchLog = logger.New("search")
+
+// parseOptionalID reads an optional numeric query parameter, returning nil when it is absent
+func parseOptionalID(c *fiber.Ctx, key string) (*uint, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	result := uint(id)
+	return &result, nil
+}
+
+func SearchProducts(c *fiber.Ctx) error {
+	keyword := c.Query("q")
+	searchLog.DebugContext(c.UserContext(), "Search products request", "keyword", keyword)
+
+	if keyword == "" {
+		searchLog.WarnContext(c.UserContext(), "Search products failed: missing keyword")
+		return helper.Fail(c, 400, "Search keyword is required", "query parameter q is required")
+	}
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		searchLog.WarnContext(c.UserContext(), "Search products failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	brandID, err := parseOptionalID(c, "brand_id")
+	if err != nil {
+		searchLog.WarnContext(c.UserContext(), "Search products failed: invalid brand ID", "brand_id", c.Query("brand_id"), "error", err)
+		return helper.Fail(c, 400, "Invalid brand ID", err.Error())
+	}
+
+	categoryID, err := parseOptionalID(c, "category_id")
+	if err != nil {
+		searchLog.WarnContext(c.UserContext(), "Search products failed: invalid category ID", "category_id", c.Query("category_id"), "error", err)
+		return helper.Fail(c, 400, "Invalid category ID", err.Error())
+	}
+
+	result, total, err := searchService.WithContext(c.UserContext()).SearchProducts(keyword, brandID, categoryID, query)
+	if err != nil {
+		searchLog.WarnContext(c.UserContext(), "Search products failed", "keyword", keyword, "error", err)
+		return err
+	}
+
+	searchLog.InfoContext(c.UserContext(), "Search products successful", "keyword", keyword, "count", total)
+	return helper.SuccessWithMeta(c, 200, "Products found successfully", result, helper.NewPagination(query.Page, query.PageSize, total))
+}
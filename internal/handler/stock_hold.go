@@ -0,0 +1,12 @@
+package handler
+
+// HoldRequest places a product batch or product stock on hold
+type HoldRequest struct {
+	Status string `json:"status" validate:"required,oneof=quarantined on-hold damaged expired"`
+	Reason string `json:"reason" validate:"required"`
+}
+
+// ReleaseHoldRequest makes a held product batch or product stock available again
+type ReleaseHoldRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"myapp/internal/access"
+	"myapp/internal/realtime"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+var stockStreamLog = logger.New("stock_stream")
+
+// stockStreamHeartbeat keeps idle connections open through proxies and detects clients that went away
+const stockStreamHeartbeat = 15 * time.Second
+
+// StreamStockUpdates streams stock quantity changes as Server-Sent Events, optionally filtered by ?locationId= and ?productId=
+func StreamStockUpdates(c *fiber.Ctx) error {
+	stockStreamLog.DebugContext(c.UserContext(), "Stream request")
+
+	locationID, err := parseOptionalID(c, "locationId")
+	if err != nil {
+		stockStreamLog.WarnContext(c.UserContext(), "Stream failed: invalid location ID", "location_id", c.Query("locationId"), "error", err)
+		return helper.Fail(c, 400, "Invalid location ID", err.Error())
+	}
+
+	productID, err := parseOptionalID(c, "productId")
+	if err != nil {
+		stockStreamLog.WarnContext(c.UserContext(), "Stream failed: invalid product ID", "product_id", c.Query("productId"), "error", err)
+		return helper.Fail(c, 400, "Invalid product ID", err.Error())
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	tenantID, ok := c.Locals("tenant_id").(uint)
+	if !ok {
+		stockStreamLog.WarnContext(c.UserContext(), "Stream failed: no tenant", "user_id", userID)
+		return helper.Fail(c, 400, "Tenant required", "No tenant selected for this stream")
+	}
+	filter := realtime.Filter{TenantID: &tenantID, LocationID: locationID, ProductID: productID}
+
+	// Reseller users only receive the updates of their own locations
+	if ownerID, limited := access.LocationOwner(c.UserContext()); limited {
+		filter.LocationIDs, err = locationService.WithContext(c.UserContext()).GetLocationIDsByUser(ownerID)
+		if err != nil {
+			stockStreamLog.WarnContext(c.UserContext(), "Stream failed: load assigned locations", "user_id", ownerID, "error", err)
+			return err
+		}
+		if filter.LocationIDs == nil {
+			filter.LocationIDs = []uint{}
+		}
+	}
+
+	subscription := realtime.StockHub.Subscribe(filter)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+
+	// The writer runs after the handler returned and the fiber context was reused, so it only keeps ctx
+	ctx := c.UserContext()
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer realtime.StockHub.Unsubscribe(subscription)
+		stockStreamLog.InfoContext(ctx, "Client connected", "user_id", userID)
+
+		heartbeat := time.NewTicker(stockStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 3000\n: connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case update, ok := <-subscription.Updates:
+				if !ok {
+					stockStreamLog.InfoContext(ctx, "Subscription closed, for falling behind or on shutdown", "user_id", userID)
+					return
+				}
+				data, err := json.Marshal(update)
+				if err != nil {
+					stockStreamLog.WarnContext(ctx, "Encode update failed", "error", err)
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", update.EventID, update.Event, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				stockStreamLog.InfoContext(ctx, "Client disconnected", "user_id", userID)
+				return
+			}
+		}
+	}))
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var subLocationService = service.NewSubLocationService()
+var subLocationLog = logger.New("sub_location")
+
+type CreateSubLocationRequest struct {
+	ParentID    *uint    `json:"parentId"` // Required for everything but zones
+	Level       string   `json:"level" validate:"required,oneof=zone aisle rack bin"`
+	Code        string   `json:"code" validate:"required,max=50"`
+	Name        *string  `json:"name"`
+	Capacity    *float64 `json:"capacity" validate:"omitempty,gte=0"` // Bins only
+	Description *string  `json:"description"`
+}
+
+type UpdateSubLocationRequest struct {
+	Name        *string  `json:"name"`
+	Capacity    *float64 `json:"capacity" validate:"omitempty,gte=0"`
+	Description *string  `json:"description"`
+}
+
+// parseSubLocationParams reads the :id (location) and :subLocationId route parameters
+func parseSubLocationParams(c *fiber.Ctx) (uint, uint, error) {
+	locationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	subLocationID, err := strconv.ParseUint(c.Params("subLocationId"), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(locationID), uint(subLocationID), nil
+}
+
+func GetLocationLayout(c *fiber.Ctx) error {
+	id := c.Params("id")
+	subLocationLog.DebugContext(c.UserContext(), "Get location layout request", "location_id", id)
+
+	locationID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get location layout failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid location ID", err.Error())
+	}
+
+	layout, err := subLocationService.WithContext(c.UserContext()).GetLocationLayout(uint(locationID))
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get location layout failed", "location_id", locationID, "error", err)
+		return err
+	}
+
+	subLocationLog.InfoContext(c.UserContext(), "Get location layout successful", "location_id", locationID)
+	return helper.Success(c, 200, "Location layout retrieved successfully", layout)
+}
+
+func CreateSubLocation(c *fiber.Ctx) error {
+	id := c.Params("id")
+	subLocationLog.DebugContext(c.UserContext(), "Create sub-location request", "location_id", id)
+
+	locationID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Create sub-location failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid location ID", err.Error())
+	}
+
+	var req CreateSubLocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Create sub-location failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Create sub-location failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		subLocationLog.WarnContext(c.UserContext(), "Create sub-location failed: user not authenticated")
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	subLocation, err := subLocationService.WithContext(c.UserContext()).CreateSubLocation(uint(locationID), req.ParentID, req.Level, req.Code, req.Name, req.Capacity, req.Description, userID)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Create sub-location failed", "location_id", locationID, "code", req.Code, "error", err)
+		return err
+	}
+
+	subLocationLog.InfoContext(c.UserContext(), "Create sub-location successful", "location_id", locationID, "code", req.Code, "created_by_user_id", userID)
+	setETag(c, subLocation)
+	return helper.Success(c, 201, "Sub-location created successfully", subLocation)
+}
+
+func UpdateSubLocation(c *fiber.Ctx) error {
+	subLocationLog.DebugContext(c.UserContext(), "Update sub-location request", "location_id", c.Params("id"), "sub_location_id", c.Params("subLocationId"))
+
+	locationID, subLocationID, err := parseSubLocationParams(c)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Update sub-location failed: invalid ID", "error", err)
+		return helper.Fail(c, 400, "Invalid sub-location ID", err.Error())
+	}
+
+	var req UpdateSubLocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Update sub-location failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Update sub-location failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		subLocationLog.WarnContext(c.UserContext(), "Update sub-location failed: user not authenticated", "sub_location_id", subLocationID)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	subLocation, err := subLocationService.WithContext(c.UserContext()).UpdateSubLocation(locationID, subLocationID, req.Name, req.Capacity, req.Description, userID)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Update sub-location failed", "sub_location_id", subLocationID, "error", err)
+		return err
+	}
+
+	subLocationLog.InfoContext(c.UserContext(), "Update sub-location successful", "sub_location_id", subLocationID, "updated_by_user_id", userID)
+	setETag(c, subLocation)
+	return helper.Success(c, 200, "Sub-location updated successfully", subLocation)
+}
+
+func DeleteSubLocation(c *fiber.Ctx) error {
+	subLocationLog.DebugContext(c.UserContext(), "Delete sub-location request", "location_id", c.Params("id"), "sub_location_id", c.Params("subLocationId"))
+
+	locationID, subLocationID, err := parseSubLocationParams(c)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Delete sub-location failed: invalid ID", "error", err)
+		return helper.Fail(c, 400, "Invalid sub-location ID", err.Error())
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		subLocationLog.WarnContext(c.UserContext(), "Delete sub-location failed: user not authenticated", "sub_location_id", subLocationID)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	err = subLocationService.WithContext(c.UserContext()).DeleteSubLocation(locationID, subLocationID, userID)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Delete sub-location failed", "sub_location_id", subLocationID, "error", err)
+		return err
+	}
+
+	subLocationLog.InfoContext(c.UserContext(), "Delete sub-location successful", "sub_location_id", subLocationID, "deleted_by_user_id", userID)
+	return helper.Success(c, 200, "Sub-location deleted successfully", nil)
+}
+
+// GetPutawaySuggestions suggests bins for receiving ?quantity= of ?product_id= into a location (?limit=, default 5)
+func GetPutawaySuggestions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	subLocationLog.DebugContext(c.UserContext(), "Get putaway suggestions request", "location_id", id)
+
+	locationID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get putaway suggestions failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid location ID", err.Error())
+	}
+
+	productID, err := strconv.ParseUint(c.Query("product_id"), 10, 32)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get putaway suggestions failed: invalid product_id", "product_id", c.Query("product_id"), "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", "product_id must be a positive integer")
+	}
+
+	quantity, err := strconv.ParseFloat(c.Query("quantity"), 64)
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get putaway suggestions failed: invalid quantity", "quantity", c.Query("quantity"), "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", "quantity must be a number")
+	}
+
+	suggestions, err := subLocationService.WithContext(c.UserContext()).SuggestPutaway(uint(locationID), uint(productID), quantity, c.QueryInt("limit", service.DefaultPutawaySuggestions))
+	if err != nil {
+		subLocationLog.WarnContext(c.UserContext(), "Get putaway suggestions failed", "location_id", locationID, "error", err)
+		return err
+	}
+
+	subLocationLog.InfoContext(c.UserContext(), "Get putaway suggestions successful", "location_id", locationID, "product_id", productID, "quantity", quantity)
+	return helper.Success(c, 200, "Putaway suggestions retrieved successfully", suggestions)
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var tenantService = service.NewTenantService()
+var tenantLog = logger.New("tenant")
+
+type CreateTenantRequest struct {
+	Code string `json:"code" validate:"required,min=2,max=50"` // Lowercase slug, e.g. acme-retail
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type UpdateTenantRequest struct {
+	Code     *string `json:"code" validate:"omitempty,min=2,max=50"`
+	Name     *string `json:"name" validate:"omitempty,min=1,max=255"`
+	IsActive *bool   `json:"isActive"`
+}
+
+func GetTenants(c *fiber.Ctx) error {
+	tenantLog.DebugContext(c.UserContext(), "Get all tenants request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Get all tenants failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	tenants, total, err := tenantService.WithContext(c.UserContext()).GetAllTenants(query)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Get all tenants failed", "error", err)
+		return err
+	}
+
+	tenantLog.InfoContext(c.UserContext(), "Get all tenants successful", "count", len(tenants))
+	return helper.SuccessWithMeta(c, 200, "Tenants retrieved successfully", tenants, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+func GetTenantByID(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantLog.DebugContext(c.UserContext(), "Get tenant by ID request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Get tenant by ID failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid tenant ID", err.Error())
+	}
+
+	tenant, err := tenantService.WithContext(c.UserContext()).GetTenantByID(uint(idUint))
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Get tenant by ID failed", "tenant_id", idUint, "error", err)
+		return err
+	}
+
+	tenantLog.InfoContext(c.UserContext(), "Get tenant by ID successful", "tenant_id", tenant.ID, "code", tenant.Code)
+	setETag(c, tenant)
+	return helper.Success(c, 200, "Tenant retrieved successfully", tenant)
+}
+
+func CreateTenant(c *fiber.Ctx) error {
+	tenantLog.DebugContext(c.UserContext(), "Create tenant request")
+
+	var req CreateTenantRequest
+	if err := c.BodyParser(&req); err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Create tenant failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Create tenant failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		tenantLog.WarnContext(c.UserContext(), "Create tenant failed: user not authenticated")
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	tenant, err := tenantService.WithContext(c.UserContext()).CreateTenant(req.Code, req.Name, userID)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Create tenant failed", "code", req.Code, "error", err)
+		return err
+	}
+
+	tenantLog.InfoContext(c.UserContext(), "Create tenant successful", "tenant_id", tenant.ID, "code", tenant.Code, "created_by_user_id", userID)
+	setETag(c, tenant)
+	return helper.Success(c, 201, "Tenant created successfully", tenant)
+}
+
+func UpdateTenant(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantLog.DebugContext(c.UserContext(), "Update tenant request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Update tenant failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid tenant ID", err.Error())
+	}
+
+	var req UpdateTenantRequest
+	if err := c.BodyParser(&req); err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Update tenant failed: invalid request body", "id", idUint, "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Update tenant failed: validation failed", "id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		tenantLog.WarnContext(c.UserContext(), "Update tenant failed: user not authenticated", "tenant_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	tenant, err := tenantService.WithContext(c.UserContext()).UpdateTenant(uint(idUint), req.Code, req.Name, req.IsActive, userID)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Update tenant failed", "tenant_id", idUint, "error", err)
+		return err
+	}
+
+	tenantLog.InfoContext(c.UserContext(), "Update tenant successful", "tenant_id", tenant.ID, "updated_by_user_id", userID)
+	setETag(c, tenant)
+	return helper.Success(c, 200, "Tenant updated successfully", tenant)
+}
+
+func DeleteTenant(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantLog.DebugContext(c.UserContext(), "Delete tenant request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Delete tenant failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid tenant ID", err.Error())
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		tenantLog.WarnContext(c.UserContext(), "Delete tenant failed: user not authenticated", "tenant_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	err = tenantService.WithContext(c.UserContext()).DeleteTenant(uint(idUint), userID)
+	if err != nil {
+		tenantLog.WarnContext(c.UserContext(), "Delete tenant failed", "tenant_id", idUint, "error", err)
+		return err
+	}
+
+	tenantLog.InfoContext(c.UserContext(), "Delete tenant successful", "tenant_id", idUint, "deleted_by_user_id", userID)
+	return helper.Success(c, 200, "Tenant deleted successfully", nil)
+}
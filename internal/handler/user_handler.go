@@ -1,85 +1,108 @@
 package handler
 
 import (
-	"log"
 	"myapp/internal/service"
+	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 var userService = service.NewUserService()
+var userLog = logger.New("user")
 
 func GetUsers(c *fiber.Ctx) error {
-	log.Printf("[USER] Get all users request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get all users request")
 
-	users, err := userService.GetAllUsers()
+	query, err := utils.ParseListQuery(c.Queries())
 	if err != nil {
-		log.Printf("[USER] Get all users failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch users", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get all users failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
 	}
 
-	log.Printf("[USER] Get all users successful - Found %d users", len(users))
-	return helper.Success(c, 200, "Success", users)
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		userLog.WarnContext(c.UserContext(), "Get all users failed: unsupported export format", "error", err)
+		return helper.Fail(c, 406, "Unsupported export format", err.Error())
+	}
+	if format != "" {
+		query = query.ForExport()
+	}
+
+	users, total, err := userService.WithContext(c.UserContext()).GetAllUsers(query)
+	if err != nil {
+		userLog.WarnContext(c.UserContext(), "Get all users failed", "error", err)
+		return err
+	}
+
+	if format != "" {
+		userLog.InfoContext(c.UserContext(), "Export users", "format", format, "rows", total)
+		return sendListExport(c, format, "users", users)
+	}
+
+	userLog.InfoContext(c.UserContext(), "Get all users successful", "count", len(users))
+	return helper.SuccessWithMeta(c, 200, "Success", users, helper.NewPagination(query.Page, query.PageSize, total))
 }
 
 func GetUsersMinimal(c *fiber.Ctx) error {
-	log.Printf("[USER] Get users minimal request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get users minimal request")
 
-	users, err := userService.GetUsersMinimal()
+	users, err := userService.WithContext(c.UserContext()).GetUsersMinimal()
 	if err != nil {
-		log.Printf("[USER] Get users minimal failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch users", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get users minimal failed", "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get users minimal successful - Found %d users", len(users))
+	userLog.InfoContext(c.UserContext(), "Get users minimal successful", "count", len(users))
 	return helper.Success(c, 200, "Success", users)
 }
 
 func GetUsersRaw(c *fiber.Ctx) error {
-	log.Printf("[USER] Get users with stats request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get users with stats request")
 
-	users, err := userService.GetUsersWithStats()
+	users, err := userService.WithContext(c.UserContext()).GetUsersWithStats()
 	if err != nil {
-		log.Printf("[USER] Get users with stats failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch users", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get users with stats failed", "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get users with stats successful - Found %d users", len(users))
+	userLog.InfoContext(c.UserContext(), "Get users with stats successful", "count", len(users))
 	return helper.Success(c, 200, "Success", users)
 }
 
 func GetUserByIDRaw(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[USER] Get user by ID request - ID: %s from IP: %s", id, c.IP())
+	userLog.DebugContext(c.UserContext(), "Get user by ID request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[USER] Get user by ID failed - Invalid ID: %s, error: %v", id, err)
+		userLog.WarnContext(c.UserContext(), "Get user by ID failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid user ID", err.Error())
 	}
 
-	user, err := userService.GetUserByID(uint(idUint))
+	user, err := userService.WithContext(c.UserContext()).GetUserByID(uint(idUint))
 	if err != nil {
-		log.Printf("[USER] Get user by ID failed - User ID: %d not found, error: %v", idUint, err)
-		return helper.Fail(c, 404, "User not found", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get user by ID failed: not found", "user_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get user by ID successful - User ID: %d, Email: %s", user.ID, user.Email)
+	userLog.InfoContext(c.UserContext(), "Get user by ID successful", "user_id", user.ID, "email", user.Email)
+	setETag(c, user)
 	return helper.Success(c, 200, "Success", user)
 }
 
 func GetUsersFromRepository(c *fiber.Ctx) error {
-	log.Printf("[USER] Get users from repository request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get users from repository request")
 
-	users, err := userService.GetUsersWithRawSQL()
+	users, err := userService.WithContext(c.UserContext()).GetUsersWithRawSQL()
 	if err != nil {
-		log.Printf("[USER] Get users from repository failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch users", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get users from repository failed", "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get users from repository successful - Found %d users", len(users))
+	userLog.InfoContext(c.UserContext(), "Get users from repository successful", "count", len(users))
 	return helper.Success(c, 200, "Success", users)
 }
 
@@ -88,69 +111,111 @@ func SearchUsers(c *fiber.Ctx) error {
 	limitStr := c.Query("limit", "10")
 	offsetStr := c.Query("offset", "0")
 
-	log.Printf("[USER] Search users request - keyword: '%s', limit: %s, offset: %s from IP: %s", keyword, limitStr, offsetStr, c.IP())
+	userLog.DebugContext(c.UserContext(), "Search users request", "keyword", keyword, "limit", limitStr, "offset", offsetStr)
 
 	limit, _ := strconv.Atoi(limitStr)
 	offset, _ := strconv.Atoi(offsetStr)
 
-	users, err := userService.SearchUsers(keyword, limit, offset)
+	users, err := userService.WithContext(c.UserContext()).SearchUsers(keyword, limit, offset)
 	if err != nil {
-		log.Printf("[USER] Search users failed - keyword: '%s', error: %v", keyword, err)
-		return helper.Fail(c, 500, "Search failed", err.Error())
+		userLog.WarnContext(c.UserContext(), "Search users failed", "keyword", keyword, "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Search users successful - keyword: '%s', found %d users", keyword, len(users))
+	userLog.InfoContext(c.UserContext(), "Search users successful", "keyword", keyword, "count", len(users))
 	return helper.Success(c, 200, "Success", users)
 }
 
 func GetUserStats(c *fiber.Ctx) error {
-	log.Printf("[USER] Get user stats request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get user stats request")
 
-	stats, err := userService.GetUsersStats()
+	stats, err := userService.WithContext(c.UserContext()).GetUsersStats()
 	if err != nil {
-		log.Printf("[USER] Get user stats failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch stats", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get user stats failed", "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get user stats successful - Total: %d, Active: %d, Deleted: %d", stats.TotalUsers, stats.ActiveUsers, stats.DeletedUsers)
+	userLog.InfoContext(c.UserContext(), "Get user stats successful", "total", stats.TotalUsers, "active", stats.ActiveUsers, "deleted", stats.DeletedUsers)
 	return helper.Success(c, 200, "Success", stats)
 }
 
 func GetDeletedUsers(c *fiber.Ctx) error {
-	log.Printf("[USER] Get deleted users request from IP: %s", c.IP())
+	userLog.DebugContext(c.UserContext(), "Get deleted users request")
 
-	users, err := userService.GetDeletedUsers()
+	users, err := userService.WithContext(c.UserContext()).GetDeletedUsers()
 	if err != nil {
-		log.Printf("[USER] Get deleted users failed - error: %v", err)
-		return helper.Fail(c, 500, "Failed to fetch deleted users", err.Error())
+		userLog.WarnContext(c.UserContext(), "Get deleted users failed", "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Get deleted users successful - Found %d deleted users", len(users))
+	userLog.InfoContext(c.UserContext(), "Get deleted users successful", "count", len(users))
 	return helper.Success(c, 200, "Success", users)
 }
 
 func RestoreUser(c *fiber.Ctx) error {
 	id := c.Params("id")
-	log.Printf("[USER] Restore user request - ID: %s from IP: %s", id, c.IP())
+	userLog.DebugContext(c.UserContext(), "Restore user request", "id", id)
 
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
-		log.Printf("[USER] Restore user failed - Invalid ID: %s, error: %v", id, err)
+		userLog.WarnContext(c.UserContext(), "Restore user failed: invalid ID", "id", id, "error", err)
 		return helper.Fail(c, 400, "Invalid user ID", err.Error())
 	}
 
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
-		log.Printf("[USER] Restore user failed - User not authenticated for User ID: %d", idUint)
+		userLog.WarnContext(c.UserContext(), "Restore user failed: user not authenticated", "user_id", idUint)
 		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
 	}
 
-	user, err := userService.RestoreUser(uint(idUint), userID)
+	user, err := userService.WithContext(c.UserContext()).RestoreUser(uint(idUint), userID)
 	if err != nil {
-		log.Printf("[USER] Restore user failed - User ID: %d, error: %v", idUint, err)
-		return helper.Fail(c, 500, "Failed to restore user", err.Error())
+		userLog.WarnContext(c.UserContext(), "Restore user failed", "user_id", idUint, "error", err)
+		return err
 	}
 
-	log.Printf("[USER] Restore user successful - User ID: %d, Restored by User ID: %d", idUint, userID)
+	userLog.InfoContext(c.UserContext(), "Restore user successful", "user_id", idUint, "restored_by_user_id", userID)
+	setETag(c, user)
 	return helper.Success(c, 200, "User restored successfully", user)
 }
+
+type AssignUserRoleRequest struct {
+	RoleID *uint `json:"roleId" validate:"omitempty,min=1"` // null removes the role
+}
+
+func AssignUserRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userLog.DebugContext(c.UserContext(), "Assign role request", "id", id)
+
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		userLog.WarnContext(c.UserContext(), "Assign role failed: invalid ID", "id", id, "error", err)
+		return helper.Fail(c, 400, "Invalid user ID", err.Error())
+	}
+
+	var req AssignUserRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		userLog.WarnContext(c.UserContext(), "Assign role failed: invalid request body", "user_id", idUint, "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		userLog.WarnContext(c.UserContext(), "Assign role failed: validation failed", "user_id", idUint, "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		userLog.WarnContext(c.UserContext(), "Assign role failed: user not authenticated", "user_id", idUint)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	user, err := userService.WithContext(c.UserContext()).AssignRole(uint(idUint), req.RoleID)
+	if err != nil {
+		userLog.WarnContext(c.UserContext(), "Assign role failed", "user_id", idUint, "error", err)
+		return err
+	}
+
+	userLog.InfoContext(c.UserContext(), "Assign role successful", "user_id", idUint, "role_id", req.RoleID, "assigned_by_user_id", userID)
+	setETag(c, user)
+	return helper.Success(c, 200, "Role assigned successfully", user)
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"myapp/internal/service"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var webhookService = service.NewWebhookService()
+var webhookLog = logger.New("webhook")
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Events      []string `json:"events" validate:"required,min=1,dive,required"` // stock.low, batch.expiring, a domain event such as stock.moved, or *
+	Secret      *string  `json:"secret" validate:"omitempty,min=16"`             // Generated when omitted
+	Description *string  `json:"description"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url" validate:"omitempty,url"`
+	Events      []string `json:"events" validate:"omitempty,min=1,dive,required"`
+	IsActive    *bool    `json:"isActive"`
+	Secret      *string  `json:"secret"` // "" rotates to a generated secret
+	Description *string  `json:"description"`
+}
+
+// parseWebhookID reads the :id route parameter
+func parseWebhookID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	return uint(id), err
+}
+
+func GetWebhooks(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Get all webhooks request")
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get all webhooks failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	subscriptions, total, err := webhookService.WithContext(c.UserContext()).GetAllWebhookSubscriptions(query)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get all webhooks failed", "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Get all webhooks successful")
+	return helper.SuccessWithMeta(c, 200, "Webhooks retrieved successfully", subscriptions, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+func GetWebhookByID(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Get webhook request", "id", c.Params("id"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get webhook failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	subscription, err := webhookService.WithContext(c.UserContext()).GetWebhookSubscriptionByID(id)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get webhook failed", "webhook_id", id, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Get webhook successful", "webhook_id", id)
+	setETag(c, subscription)
+	return helper.Success(c, 200, "Webhook retrieved successfully", subscription)
+}
+
+func CreateWebhook(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Create webhook request")
+
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Create webhook failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Create webhook failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		webhookLog.WarnContext(c.UserContext(), "Create webhook failed: user not authenticated")
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	subscription, err := webhookService.WithContext(c.UserContext()).CreateWebhookSubscription(req.URL, req.Events, req.Secret, req.Description, userID)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Create webhook failed", "url", req.URL, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Create webhook successful", "url", req.URL, "created_by_user_id", userID)
+	setETag(c, subscription)
+	return helper.Success(c, 201, "Webhook created successfully", subscription)
+}
+
+func UpdateWebhook(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Update webhook request", "id", c.Params("id"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Update webhook failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Update webhook failed: invalid request body", "error", err)
+		return helper.Fail(c, 400, "Invalid request body", err.Error())
+	}
+	if err := helper.Validate(&req); err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Update webhook failed: validation failed", "error", err)
+		return helper.ValidationFail(c, err)
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		webhookLog.WarnContext(c.UserContext(), "Update webhook failed: user not authenticated", "webhook_id", id)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	subscription, err := webhookService.WithContext(c.UserContext()).UpdateWebhookSubscription(id, req.URL, req.Events, req.IsActive, req.Secret, req.Description, userID)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Update webhook failed", "webhook_id", id, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Update webhook successful", "webhook_id", id, "updated_by_user_id", userID)
+	setETag(c, subscription)
+	return helper.Success(c, 200, "Webhook updated successfully", subscription)
+}
+
+func DeleteWebhook(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Delete webhook request", "id", c.Params("id"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Delete webhook failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		webhookLog.WarnContext(c.UserContext(), "Delete webhook failed: user not authenticated", "webhook_id", id)
+		return helper.Fail(c, 401, "User not authenticated", "Failed to get user ID from token")
+	}
+
+	err = webhookService.WithContext(c.UserContext()).DeleteWebhookSubscription(id, userID)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Delete webhook failed", "webhook_id", id, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Delete webhook successful", "webhook_id", id, "deleted_by_user_id", userID)
+	return helper.Success(c, 200, "Webhook deleted successfully", nil)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook, newest first
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Get webhook deliveries request", "id", c.Params("id"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get deliveries failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	query, err := utils.ParseListQuery(c.Queries())
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get deliveries failed: invalid query parameters", "error", err)
+		return helper.Fail(c, 400, "Invalid query parameters", err.Error())
+	}
+
+	deliveries, total, err := webhookService.WithContext(c.UserContext()).GetWebhookDeliveries(id, query)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Get deliveries failed", "webhook_id", id, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Get deliveries successful", "webhook_id", id)
+	return helper.SuccessWithMeta(c, 200, "Webhook deliveries retrieved successfully", deliveries, helper.NewPagination(query.Page, query.PageSize, total))
+}
+
+// TestWebhook sends a signed ping event to the webhook right away
+func TestWebhook(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Test webhook request", "id", c.Params("id"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Test webhook failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	delivery, err := webhookService.WithContext(c.UserContext()).SendTestWebhook(id)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Test webhook failed", "webhook_id", id, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Test webhook sent", "webhook_id", id)
+	return helper.Success(c, 200, "Test webhook sent", delivery)
+}
+
+// RedeliverWebhook queues a past delivery again
+func RedeliverWebhook(c *fiber.Ctx) error {
+	webhookLog.DebugContext(c.UserContext(), "Redeliver request", "id", c.Params("id"), "delivery_id", c.Params("deliveryId"))
+
+	id, err := parseWebhookID(c)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Redeliver failed: invalid ID", "id", c.Params("id"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook subscription ID", err.Error())
+	}
+
+	deliveryID, err := strconv.ParseUint(c.Params("deliveryId"), 10, 32)
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Redeliver failed: invalid delivery ID", "delivery_id", c.Params("deliveryId"), "error", err)
+		return helper.Fail(c, 400, "Invalid webhook delivery ID", err.Error())
+	}
+
+	delivery, err := webhookService.WithContext(c.UserContext()).RedeliverWebhookDelivery(id, uint(deliveryID))
+	if err != nil {
+		webhookLog.WarnContext(c.UserContext(), "Redeliver failed", "delivery_id", deliveryID, "error", err)
+		return err
+	}
+
+	webhookLog.InfoContext(c.UserContext(), "Redeliver queued", "webhook_id", id, "delivery_id", deliveryID)
+	return helper.Success(c, 202, "Webhook delivery queued", delivery)
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+	"time"
+)
+
+var idempotencyJobLog = logger.New("idempotency_job")
+
+// DefaultIdempotencyCleanupInterval is used when IDEMPOTENCY_CLEANUP_INTERVAL is not set
+const DefaultIdempotencyCleanupInterval = time.Hour
+
+// StartIdempotencyCleanup removes expired idempotency keys every IDEMPOTENCY_CLEANUP_INTERVAL; 0 disables it
+func StartIdempotencyCleanup(workers *Manager) {
+	interval := intervalFromEnv("IDEMPOTENCY_CLEANUP_INTERVAL", DefaultIdempotencyCleanupInterval)
+	if interval == 0 {
+		idempotencyJobLog.Info("Disabled", "reason", "IDEMPOTENCY_CLEANUP_INTERVAL=0")
+		return
+	}
+
+	idempotencyService := service.NewIdempotencyService()
+	workers.Every(idempotencyJobLog, interval, func(ctx context.Context) {
+		removed, err := idempotencyService.DeleteExpiredKeys()
+		if err != nil {
+			idempotencyJobLog.ErrorContext(ctx, "Cleanup failed", "error", err)
+			return
+		}
+		if removed > 0 {
+			idempotencyJobLog.InfoContext(ctx, "Removed expired keys", "keys", removed)
+		}
+	})
+}
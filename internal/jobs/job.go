@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"myapp/pkg/logger"
+	"os"
+	"strconv"
+	"time"
+)
+
+var jobsLog = logger.New("jobs")
+
+// intervalFromEnv reads a Go duration (such as 15m) from key; 0 disables the job
+func intervalFromEnv(key string, defaultInterval time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		jobsLog.Warn("Invalid interval, using the default", "key", key, "value", value, "default", defaultInterval.String())
+		return defaultInterval
+	}
+	return interval
+}
+
+// intFromEnv reads a non-negative integer from key
+func intFromEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		jobsLog.Warn("Invalid number, using the default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return number
+}
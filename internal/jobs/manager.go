@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Manager runs the scheduled jobs until its context is done and lets main wait for the runs in progress on shutdown
+type Manager struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+// NewManager returns a manager whose jobs stop once ctx is done, such as on SIGTERM
+func NewManager(ctx context.Context) *Manager {
+	return &Manager{ctx: ctx}
+}
+
+// Every calls run once right away and then on every interval until the context of the manager is done. A run in
+// progress is not cut off; run gets the context so it can stop between batches.
+func (m *Manager) Every(jobLog *slog.Logger, interval time.Duration, run func(ctx context.Context)) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(m.ctx)
+
+			select {
+			case <-m.ctx.Done():
+				jobLog.Info("Stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	jobLog.Info("Started", "interval", interval.String())
+}
+
+// Wait blocks until every job has stopped, or returns the error of ctx when it is done first
+func (m *Manager) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"context"
+	"myapp/internal/events"
+	"myapp/internal/realtime"
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+	"myapp/pkg/redis"
+	"os"
+	"strings"
+	"time"
+)
+
+// Defaults used when the matching environment variables are not set
+const (
+	DefaultOutboxRelayInterval = 2 * time.Second
+	DefaultOutboxSinks         = "webhook,realtime"
+	DefaultOutboxRetention     = 7 * 24 * time.Hour
+)
+
+// outboxPurgeInterval is how often published events older than OUTBOX_RETENTION are removed
+const outboxPurgeInterval = time.Hour
+
+var outboxJobLog = logger.New("outbox_job")
+
+// StartOutboxRelay publishes recorded domain events every OUTBOX_RELAY_INTERVAL (0 disables it) to the sinks
+// listed in OUTBOX_SINKS: log, redis (stream OUTBOX_REDIS_STREAM, trimmed to OUTBOX_REDIS_MAXLEN), webhook and realtime.
+// Published events are removed once they are older than OUTBOX_RETENTION (0 keeps them).
+func StartOutboxRelay(workers *Manager) {
+	interval := intervalFromEnv("OUTBOX_RELAY_INTERVAL", DefaultOutboxRelayInterval)
+	if interval == 0 {
+		outboxJobLog.Info("Disabled", "reason", "OUTBOX_RELAY_INTERVAL=0")
+		return
+	}
+
+	sinks := outboxSinksFromEnv()
+	if len(sinks) == 0 {
+		outboxJobLog.Info("Disabled", "reason", "no usable OUTBOX_SINKS")
+		return
+	}
+
+	outboxService := service.NewOutboxService()
+	workers.Every(outboxJobLog, interval, func(ctx context.Context) {
+		relayOutbox(ctx, outboxService, sinks)
+	})
+
+	retention := intervalFromEnv("OUTBOX_RETENTION", DefaultOutboxRetention)
+	if retention == 0 {
+		outboxJobLog.Info("Keeping published events", "reason", "OUTBOX_RETENTION=0")
+		return
+	}
+	workers.Every(outboxJobLog, outboxPurgeInterval, func(ctx context.Context) {
+		removed, err := outboxService.PurgePublishedEvents(ctx, retention)
+		if err != nil {
+			outboxJobLog.ErrorContext(ctx, "Purge failed", "error", err)
+		}
+		if removed > 0 {
+			outboxJobLog.InfoContext(ctx, "Removed published events", "events", removed)
+		}
+	})
+}
+
+// outboxSinksFromEnv builds the sinks named in OUTBOX_SINKS, skipping unknown names and redis when it is not enabled
+func outboxSinksFromEnv() []events.Sink {
+	names := os.Getenv("OUTBOX_SINKS")
+	if names == "" {
+		names = DefaultOutboxSinks
+	}
+
+	var sinks []events.Sink
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "log":
+			sinks = append(sinks, events.LogSink{})
+		case "redis":
+			if !redis.IsEnabled {
+				outboxJobLog.Warn("Redis is not enabled, skipping redis sink")
+				continue
+			}
+			stream := os.Getenv("OUTBOX_REDIS_STREAM")
+			if stream == "" {
+				stream = events.DefaultRedisStream
+			}
+			sinks = append(sinks, events.RedisStreamSink{
+				Stream: stream,
+				MaxLen: int64(intFromEnv("OUTBOX_REDIS_MAXLEN", 0)),
+			})
+		case "webhook":
+			sinks = append(sinks, service.NewWebhookSink())
+		case "realtime":
+			sinks = append(sinks, service.NewRealtimeSink(realtime.StockHub))
+		default:
+			outboxJobLog.Warn("Unknown sink in OUTBOX_SINKS, skipping", "sink", name)
+		}
+	}
+	return sinks
+}
+
+// relayOutbox keeps relaying while full batches of pending events come back, until ctx is done. A batch in progress
+// is finished on shutdown rather than leaving events published to some sinks only.
+func relayOutbox(ctx context.Context, outboxService *service.OutboxService, sinks []events.Sink) {
+	for {
+		relayed, err := outboxService.Relay(context.WithoutCancel(ctx), sinks)
+		if err != nil {
+			outboxJobLog.ErrorContext(ctx, "Relay failed", "error", err)
+			return
+		}
+		if relayed > 0 {
+			outboxJobLog.InfoContext(ctx, "Relayed events", "events", relayed)
+		}
+		if relayed < service.OutboxRelayBatch || ctx.Err() != nil {
+			return
+		}
+	}
+}
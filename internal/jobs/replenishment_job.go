@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+	"time"
+)
+
+var replenishmentJobLog = logger.New("replenishment_job")
+
+// DefaultReplenishmentInterval is how often the replenishment check runs when REPLENISHMENT_CHECK_INTERVAL is not set
+const DefaultReplenishmentInterval = time.Hour
+
+// StartReplenishmentJob runs the replenishment check once at startup and then on every interval until workers stop
+func StartReplenishmentJob(workers *Manager) {
+	interval := intervalFromEnv("REPLENISHMENT_CHECK_INTERVAL", DefaultReplenishmentInterval)
+	if interval == 0 {
+		replenishmentJobLog.Info("Disabled", "reason", "REPLENISHMENT_CHECK_INTERVAL=0")
+		return
+	}
+
+	replenishmentService := service.NewReplenishmentService()
+	workers.Every(replenishmentJobLog, interval, func(ctx context.Context) {
+		runReplenishmentCheck(ctx, replenishmentService)
+	})
+}
+
+func runReplenishmentCheck(ctx context.Context, replenishmentService *service.ReplenishmentService) {
+	result, err := replenishmentService.RunReplenishmentCheck()
+	if err != nil {
+		replenishmentJobLog.ErrorContext(ctx, "Check failed", "error", err)
+		return
+	}
+	replenishmentJobLog.InfoContext(ctx, "Check done", "rules", result.Rules, "opened", result.Opened, "updated", result.Updated, "resolved", result.Resolved)
+}
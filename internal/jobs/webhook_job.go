@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+	"time"
+)
+
+// Defaults used when the matching environment variables are not set
+const (
+	DefaultWebhookDeliveryInterval = 10 * time.Second
+	DefaultBatchExpiryInterval     = time.Hour
+	DefaultBatchExpiryWarningDays  = 30
+)
+
+var (
+	webhookJobLog     = logger.New("webhook_job")
+	batchExpiryJobLog = logger.New("batch_expiry_job")
+)
+
+// StartWebhookJobs starts the delivery worker (WEBHOOK_DELIVERY_INTERVAL) and the batch expiry scan
+// (BATCH_EXPIRY_CHECK_INTERVAL, warning BATCH_EXPIRY_WARNING_DAYS ahead); either is disabled with an interval of 0
+func StartWebhookJobs(workers *Manager) {
+	webhookService := service.NewWebhookService()
+
+	deliveryInterval := intervalFromEnv("WEBHOOK_DELIVERY_INTERVAL", DefaultWebhookDeliveryInterval)
+	if deliveryInterval == 0 {
+		webhookJobLog.Info("Disabled", "reason", "WEBHOOK_DELIVERY_INTERVAL=0")
+	} else {
+		workers.Every(webhookJobLog, deliveryInterval, func(ctx context.Context) {
+			deliverWebhooks(ctx, webhookService)
+		})
+	}
+
+	expiryInterval := intervalFromEnv("BATCH_EXPIRY_CHECK_INTERVAL", DefaultBatchExpiryInterval)
+	if expiryInterval == 0 {
+		batchExpiryJobLog.Info("Disabled", "reason", "BATCH_EXPIRY_CHECK_INTERVAL=0")
+		return
+	}
+	warningDays := intFromEnv("BATCH_EXPIRY_WARNING_DAYS", DefaultBatchExpiryWarningDays)
+	workers.Every(batchExpiryJobLog, expiryInterval, func(ctx context.Context) {
+		notifyExpiringBatches(ctx, webhookService, warningDays)
+	})
+}
+
+// deliverWebhooks keeps sending while full batches of due deliveries come back, until ctx is done
+func deliverWebhooks(ctx context.Context, webhookService *service.WebhookService) {
+	for {
+		sent, err := webhookService.WithContext(ctx).DeliverDueWebhooks()
+		if err != nil {
+			webhookJobLog.ErrorContext(ctx, "Delivery failed", "error", err)
+			return
+		}
+		if sent > 0 {
+			webhookJobLog.InfoContext(ctx, "Attempted deliveries", "deliveries", sent)
+		}
+		if sent < service.WebhookDeliveryBatch || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func notifyExpiringBatches(ctx context.Context, webhookService *service.WebhookService, warningDays int) {
+	count, err := webhookService.NotifyExpiringBatches(warningDays)
+	if err != nil {
+		batchExpiryJobLog.ErrorContext(ctx, "Check failed", "error", err)
+		return
+	}
+	batchExpiryJobLog.InfoContext(ctx, "Check done", "expiring_batches", count, "warning_days", warningDays)
+}
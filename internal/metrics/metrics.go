@@ -0,0 +1,130 @@
+// Package metrics exposes the Prometheus metrics of the API: request latency and status per route, the database
+// connection pool, Redis cache hits and misses, and inventory gauges read from the database on every scrape.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"myapp/internal/service"
+	"myapp/pkg/logger"
+	"myapp/pkg/redis"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// InventoryTimeout bounds the queries behind the inventory gauges, so a slow database does not hang the scrape
+const InventoryTimeout = 5 * time.Second
+
+// UnmatchedRoute is the route label of requests that matched no route, which keeps unknown paths out of the labels
+const UnmatchedRoute = "unmatched"
+
+var metricsLog = logger.New("metrics")
+
+// Registry holds every metric of the API, served by Handler
+var Registry = prometheus.NewRegistry()
+
+var requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "http_request_duration_seconds",
+	Help:    "Latency of HTTP requests by method, route and status.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestDuration,
+		newInventoryCollector(service.NewMetricsService()),
+	)
+	Registry.MustRegister(redis.Collectors()...)
+}
+
+// ObserveRequest records a finished request; route is the route pattern, such as /api/v1/brands/:id
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// RegisterDB adds the connection pool statistics of db, once it is connected
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// Handler serves the metrics in the Prometheus text format. When METRICS_TOKEN is set, scrapers must send it as a
+// bearer token.
+func Handler() fiber.Handler {
+	token := os.Getenv("METRICS_TOKEN")
+	serve := adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			sent := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return c.SendStatus(fiber.StatusUnauthorized)
+			}
+		}
+		return serve(c)
+	}
+}
+
+// inventoryCollector reads the inventory gauges from the database when metrics are scraped
+type inventoryCollector struct {
+	metricsService *service.MetricsService
+	stockValue     *prometheus.Desc
+	expiredBatches *prometheus.Desc
+	up             *prometheus.Desc
+}
+
+func newInventoryCollector(metricsService *service.MetricsService) *inventoryCollector {
+	return &inventoryCollector{
+		metricsService: metricsService,
+		stockValue: prometheus.NewDesc("inventory_stock_value",
+			"Value of the stock on hand, quantity times the unit price of its batch.", []string{"tenant_id"}, nil),
+		expiredBatches: prometheus.NewDesc("inventory_expired_batches",
+			"Batches past their expiry date that still have stock on hand.", []string{"tenant_id"}, nil),
+		up: prometheus.NewDesc("inventory_metrics_up",
+			"Whether the inventory gauges could be read from the database.", nil, nil),
+	}
+}
+
+func (c *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.stockValue
+	ch <- c.expiredBatches
+	ch <- c.up
+}
+
+func (c *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), InventoryTimeout)
+	defer cancel()
+
+	totals, err := c.metricsService.WithContext(ctx).GetInventoryTotals()
+	if err != nil {
+		metricsLog.ErrorContext(ctx, "Reading inventory gauges failed", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+
+	for _, total := range totals.StockValue {
+		ch <- prometheus.MustNewConstMetric(c.stockValue, prometheus.GaugeValue, total.Total, tenantLabel(total.TenantID))
+	}
+	for _, total := range totals.ExpiredBatches {
+		ch <- prometheus.MustNewConstMetric(c.expiredBatches, prometheus.GaugeValue, total.Total, tenantLabel(total.TenantID))
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+}
+
+// tenantLabel is the tenant_id label of a total, empty for rows without a tenant
+func tenantLabel(tenantID *uint) string {
+	if tenantID == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*tenantID), 10)
+}
@@ -1,13 +1,22 @@
 package middleware
 
 import (
+	"errors"
+	"myapp/internal/access"
+	"myapp/internal/service"
+	"myapp/internal/tenant"
 	"myapp/internal/utils"
 	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+var tenantService = service.NewTenantService()
+var userService = service.NewUserService()
+
 func JWTMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
@@ -26,16 +35,132 @@ func JWTMiddleware() fiber.Handler {
 			return helper.Fail(c, 401, "Unauthorized", "Token is empty")
 		}
 
-		// Validate token
-		claims, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			return helper.Fail(c, 401, "Invalid token", err.Error())
+		return authenticate(c, tokenString, true)
+	}
+}
+
+// JWTAccountMiddleware is JWTMiddleware for routes about the signed in user's own account, which platform users
+// can call without choosing a tenant
+func JWTAccountMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		if tokenString == "" {
+			return helper.Fail(c, 401, "Unauthorized", "Bearer token required")
+		}
+
+		return authenticate(c, tokenString, false)
+	}
+}
+
+// PlatformMiddleware only lets platform users through; it runs after JWTAccountMiddleware
+func PlatformMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if platform, _ := c.Locals("platform").(bool); !platform {
+			return helper.Fail(c, 403, "Forbidden", "Only platform users can manage tenants")
+		}
+		return c.Next()
+	}
+}
+
+// AdminMiddleware only lets admins through, users with the Admin role and platform users; it runs after JWTMiddleware
+func AdminMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if admin, _ := c.Locals("admin").(bool); !admin {
+			return helper.Fail(c, 403, "Forbidden", "Only admins can do this")
+		}
+		return c.Next()
+	}
+}
+
+// JWTStreamMiddleware is JWTMiddleware for event streams: browsers cannot set headers on an EventSource,
+// so the token may also be passed as the access_token query parameter
+func JWTStreamMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString = c.Query("access_token")
 		}
+		if tokenString == "" {
+			return helper.Fail(c, 401, "Unauthorized", "Authorization header or access_token required")
+		}
+
+		return authenticate(c, tokenString, true)
+	}
+}
+
+// authenticate validates tokenString, stores the user it belongs to in the context and scopes the request to the tenant
+// of the user. Platform users choose the tenant with the X-Tenant-ID header; requireTenant rejects them when they do not.
+func authenticate(c *fiber.Ctx, tokenString string, requireTenant bool) error {
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil {
+		return helper.Fail(c, 401, "Invalid token", err.Error())
+	}
+	if claims.TenantID == nil && !claims.Platform {
+		return helper.Fail(c, 401, "Invalid token", "token has no tenant, please sign in again")
+	}
 
-		// Store user info in context
-		c.Locals("user_id", claims.UserID)
-		c.Locals("email", claims.Email)
+	// Store user info in context
+	c.Locals("user_id", claims.UserID)
+	c.Locals("email", claims.Email)
+	c.Locals("platform", claims.Platform)
+	c.SetUserContext(logger.With(c.UserContext(), "user_id", claims.UserID))
 
+	tenantID, err := requestTenant(c, claims)
+	if err != nil {
+		return helper.Fail(c, 403, "Forbidden", err.Error())
+	}
+	if tenantID == 0 {
+		if requireTenant {
+			return helper.Fail(c, 400, "Tenant required", "platform users must choose a tenant with the "+tenant.HeaderName+" header")
+		}
 		return c.Next()
 	}
+
+	active, err := tenantService.IsTenantActive(tenantID)
+	if err != nil {
+		return helper.Fail(c, 500, "Failed to resolve tenant", err.Error())
+	}
+	if !active {
+		return helper.Fail(c, 403, "Forbidden", "tenant does not exist or is inactive")
+	}
+
+	c.Locals("tenant_id", tenantID)
+	ctx := tenant.WithID(logger.With(c.UserContext(), "tenant_id", tenantID), tenantID)
+
+	// Reseller users only reach the stock of the locations they are assigned to; admins and platform users reach everything
+	c.Locals("admin", claims.Platform)
+	if !claims.Platform {
+		userAccess, err := userService.WithContext(ctx).GetUserAccess(claims.UserID)
+		if err != nil {
+			return helper.Fail(c, 500, "Failed to resolve user access", err.Error())
+		}
+		c.Locals("admin", userAccess.IsAdmin)
+		if userAccess.IsReseller && !userAccess.IsAdmin {
+			ctx = access.WithLocationOwner(ctx, claims.UserID)
+		}
+	}
+
+	c.SetUserContext(ctx)
+	return c.Next()
+}
+
+// requestTenant returns the tenant of the user, or the one chosen with the X-Tenant-ID header by a platform user.
+// Tenant users may send the header too, but only with their own tenant.
+func requestTenant(c *fiber.Ctx, claims *utils.Claims) (uint, error) {
+	header := c.Get(tenant.HeaderName)
+	if claims.TenantID != nil {
+		if header != "" && header != strconv.FormatUint(uint64(*claims.TenantID), 10) {
+			return 0, errors.New("users can only access their own tenant")
+		}
+		return *claims.TenantID, nil
+	}
+
+	if header == "" {
+		return 0, nil
+	}
+	tenantID, err := strconv.ParseUint(header, 10, 32)
+	if err != nil || tenantID == 0 {
+		return 0, errors.New("invalid " + tenant.HeaderName + " header")
+	}
+	return uint(tenantID), nil
 }
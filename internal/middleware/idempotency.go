@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"myapp/internal/service"
+	"myapp/internal/tenant"
+	"myapp/internal/utils"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IdempotencyHeader is the request header clients set to make a mutating request safe to retry
+const IdempotencyHeader = "Idempotency-Key"
+
+var idempotencyService = service.NewIdempotencyService()
+var idempotencyLog = logger.New("idempotency")
+
+// IdempotencyMiddleware makes POST, PUT, PATCH and DELETE requests sent with an Idempotency-Key header safe to retry.
+// The first request runs and its response is stored; a retry with the same key and body gets the stored response
+// back with Idempotent-Replayed: true instead of running again. Keys belong to the user of the token, or the client
+// address without one, and the X-Tenant-ID header, and are kept for IDEMPOTENCY_KEY_TTL (default 24h). Routes whose
+// responses carry credentials, such as /auth, are registered before it so they are never stored.
+func IdempotencyMiddleware() fiber.Handler {
+	ttl := idempotencyKeyTTL()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyHeader)
+		if key == "" || !isMutatingMethod(c.Method()) {
+			return c.Next()
+		}
+		if len(key) > service.IdempotencyKeyMaxLength {
+			return helper.Fail(c, 400, "Invalid idempotency key", "Idempotency-Key must be at most 255 characters")
+		}
+
+		record, started, err := idempotencyService.Begin(idempotencyScope(c), key, c.Method(), c.Path(), requestFingerprint(c), ttl)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			idempotencyLog.WarnContext(c.UserContext(), "Key reused with a different request", "key", key)
+			return helper.Fail(c, 422, "Idempotency key reused", err.Error())
+		case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+			idempotencyLog.WarnContext(c.UserContext(), "Key still in progress", "key", key)
+			return helper.Fail(c, 409, "Request in progress", err.Error())
+		case err != nil:
+			idempotencyLog.ErrorContext(c.UserContext(), "Check failed", "key", key, "error", err)
+			return helper.Fail(c, 500, "Failed to check idempotency key", err.Error())
+		}
+
+		if !started {
+			idempotencyLog.InfoContext(c.UserContext(), "Replaying response", "key", key, "status", record.ResponseStatus)
+			c.Set("Idempotent-Replayed", "true")
+			if record.ContentType != "" {
+				c.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			if record.ETag != "" {
+				c.Set(fiber.HeaderETag, record.ETag)
+			}
+			return c.Status(record.ResponseStatus).Send(record.ResponseBody)
+		}
+
+		stopKeepAlive := keepAlive(c, record.ID, key)
+		err = c.Next()
+		stopKeepAlive()
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = helper.ErrorStatus(err)
+		}
+
+		// Server errors are not stored, so the client can retry them with the same key; their errors are passed along
+		// to be answered by RequestLogger
+		if status >= fiber.StatusInternalServerError {
+			if releaseErr := idempotencyService.Release(record.ID); releaseErr != nil {
+				idempotencyLog.ErrorContext(c.UserContext(), "Release failed", "key", key, "error", releaseErr)
+			}
+			return err
+		}
+		// A 4xx error is stored like any other response, so it is answered here where its body can be read
+		if err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		etag := string(c.Response().Header.Peek(fiber.HeaderETag))
+		if err := idempotencyService.Complete(record.ID, status, string(c.Response().Header.ContentType()), etag, body); err != nil {
+			idempotencyLog.ErrorContext(c.UserContext(), "Store response failed", "key", key, "error", err)
+		}
+		return nil
+	}
+}
+
+// keepAlive touches the key of a running request every third of the lock timeout until the returned stop is called,
+// so a request running longer than the timeout, such as a large import, is not taken over by a retry and run twice
+func keepAlive(c *fiber.Ctx, id uint, key string) (stop func()) {
+	ctx := c.UserContext()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(service.IdempotencyLockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := idempotencyService.Touch(id); err != nil {
+					idempotencyLog.ErrorContext(ctx, "Keep alive failed", "key", key, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyScope names who sent a key: the user of a valid token, or the client address without one, and the tenant
+// header, since the middleware runs before authentication
+func idempotencyScope(c *fiber.Ctx) string {
+	user := "anonymous@" + c.IP()
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); token != "" {
+		if claims, err := utils.ValidateJWT(token); err == nil {
+			user = strconv.FormatUint(uint64(claims.UserID), 10)
+		}
+	}
+
+	tenantID := ""
+	if id, err := strconv.ParseUint(c.Get(tenant.HeaderName), 10, 32); err == nil {
+		tenantID = strconv.FormatUint(id, 10)
+	}
+	return "user:" + user + ":tenant:" + tenantID
+}
+
+// requestFingerprint hashes what makes two requests the same: method, path, query string and body
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + "\n" + c.Path() + "\n"))
+	hash.Write(c.Request().URI().QueryString())
+	hash.Write([]byte("\n"))
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyKeyTTL reads IDEMPOTENCY_KEY_TTL, a Go duration such as 24h
+func idempotencyKeyTTL() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_KEY_TTL")
+	if value == "" {
+		return service.IdempotencyKeyTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		idempotencyLog.Warn("Invalid IDEMPOTENCY_KEY_TTL, using the default", "value", value, "default", service.IdempotencyKeyTTL.String())
+		return service.IdempotencyKeyTTL
+	}
+	return ttl
+}
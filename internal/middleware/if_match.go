@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"myapp/internal/concurrency"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var concurrencyLog = logger.New("concurrency")
+
+// IfMatchMiddleware requires the PUT or DELETE of a row of model to send the ETag it read in If-Match. The change
+// then only applies while the row is still at that version; when someone else changed it first the handler gets
+// concurrency.ErrVersionConflict and answers 412 Precondition Failed instead of overwriting their change.
+// "If-Match: *" skips the check.
+func IfMatchMiddleware(model interface{}) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderIfMatch)
+		if header == "" {
+			concurrencyLog.WarnContext(c.UserContext(), "Missing If-Match", "method", c.Method(), "path", c.Path())
+			return helper.Fail(c, 428, "Precondition required", "If-Match header with the ETag of the resource is required")
+		}
+
+		version, wildcard, err := concurrency.ParseIfMatch(header)
+		if err != nil {
+			concurrencyLog.WarnContext(c.UserContext(), "Invalid If-Match", "if_match", header, "method", c.Method(), "path", c.Path())
+			return helper.Fail(c, 400, "Invalid If-Match header", err.Error())
+		}
+		if !wildcard {
+			c.SetUserContext(concurrency.WithExpectedVersion(c.UserContext(), model, version))
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"myapp/internal/metrics"
+	"myapp/pkg/helper"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics records the latency and status of every request by the route it matched
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		route := ""
+
+		// Errors are answered by RequestLogger, the status they will be answered with is recorded
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The router answers requests no route matches with a fiber 404
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+				route = metrics.UnmatchedRoute
+			}
+			status = helper.ErrorStatus(err)
+		}
+
+		// Requests stopped by middleware, such as a missing token, count under the prefix the middleware is mounted on
+		if route == "" {
+			route = c.Route().Path
+		}
+		metrics.ObserveRequest(c.Method(), route, status, time.Since(start))
+		return err
+	}
+}
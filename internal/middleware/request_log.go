@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"log/slog"
+	"myapp/pkg/logger"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var requestLog = logger.New("http")
+
+// maxRequestIDLength bounds the X-Request-ID a client may send, longer or unprintable IDs are replaced
+const maxRequestIDLength = 128
+
+// RequestLogger gives every request an ID, taken from the X-Request-ID header or generated, and sends it back in
+// X-Request-ID. Every line logged with the request context carries the request ID and route, and one access line
+// is logged when the request is done.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(requestID) {
+			requestID = utils.UUIDv4()
+		}
+		c.Set(fiber.HeaderXRequestID, requestID)
+		c.Locals("request_id", requestID)
+
+		route := &routeValue{c: c}
+		c.SetUserContext(logger.With(c.UserContext(), "request_id", requestID, "route", route))
+
+		// Errors are answered here, by the outermost middleware, so the access line has the status they were answered
+		// with; the middleware inside passes them along
+		err := c.Next()
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+		route.done()
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []interface{}{
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+		}
+		// Reading the body of a streamed response, such as SSE or an NDJSON export, would buffer the whole stream
+		if !c.Response().IsBodyStream() {
+			attrs = append(attrs, "bytes", len(c.Response().Body()))
+		}
+		requestLog.Log(c.UserContext(), level, "Request completed", attrs...)
+		return nil
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// routeValue logs the route the request matched, such as /api/v1/brands/:id. The route is only known once the
+// router reached the handler, so it is read when a line is logged, and kept when the request is done since the
+// fiber context is reused by the next request.
+type routeValue struct {
+	mu   sync.Mutex
+	c    *fiber.Ctx
+	path string
+}
+
+func (r *routeValue) LogValue() slog.Value {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.c != nil {
+		return slog.StringValue(r.c.Route().Path)
+	}
+	return slog.StringValue(r.path)
+}
+
+func (r *routeValue) done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path = r.c.Route().Path
+	r.c = nil
+}
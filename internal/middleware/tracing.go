@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"myapp/internal/metrics"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
+	"myapp/pkg/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, continuing the trace of a traceparent header, and hands it to
+// handlers through the user context; service, GORM and Redis spans are its children. Log lines of the request carry
+// its trace_id.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// fasthttp reuses the memory of these strings once the request is answered, after the span is exported
+		method := utils.CopyString(c.Method())
+
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c: c})
+		ctx, span := tracing.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(utils.CopyString(c.Path())),
+			semconv.ClientAddress(utils.CopyString(c.IP())),
+		))
+		defer span.End()
+
+		if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+			ctx = logger.With(ctx, "trace_id", spanContext.TraceID().String())
+		}
+		c.SetUserContext(ctx)
+
+		// Errors are answered by RequestLogger, the span records the status they will be answered with
+		route := ""
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+				route = metrics.UnmatchedRoute
+			}
+			status = helper.ErrorStatus(err)
+		}
+
+		if route == "" {
+			route = c.Route().Path
+		}
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		// Client errors are answers, only server errors fail the span
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, utils.StatusMessage(status))
+		}
+		return err
+	}
+}
+
+// headerCarrier reads and writes trace headers of a request for the propagator
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -12,6 +12,12 @@ type Category struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
 	// Foreign Key to Brand
 	BrandID     uint    `gorm:"not null" json:"brand_id"`
 	Name        string  `gorm:"not null" json:"name"`
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// IdempotencyKey is a mutating request sent with an Idempotency-Key header and the response it got,
+// replayed when the client retries the request with the same key
+type IdempotencyKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Request
+	Scope       string `gorm:"type:varchar(100);not null;uniqueIndex:idx_idempotency_keys_scope_key" json:"scope"` // Who sent the key: user and tenant
+	Key         string `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_scope_key" json:"key"`
+	Method      string `gorm:"type:varchar(10);not null" json:"method"`
+	Path        string `gorm:"type:varchar(255);not null" json:"path"`
+	Fingerprint string `gorm:"type:varchar(64);not null" json:"fingerprint"` // SHA-256 of method, path and body
+
+	// Response
+	Status         string    `gorm:"type:varchar(10);not null;default:processing" json:"status"` // processing, completed
+	ResponseStatus int       `json:"response_status"`
+	ContentType    string    `gorm:"type:varchar(100)" json:"content_type"`
+	ETag           string    `gorm:"type:varchar(100)" json:"etag"`
+	ResponseBody   []byte    `gorm:"type:bytea" json:"-"`
+	ExpiresAt      time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// Idempotency key statuses
+const (
+	IdempotencyKeyProcessing = "processing"
+	IdempotencyKeyCompleted  = "completed"
+)
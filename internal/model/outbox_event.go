@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// OutboxEvent is a domain event written in the same transaction as the change it describes,
+// then published to the configured sinks by the outbox relay
+type OutboxEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Event Information
+	EventType     string `gorm:"type:varchar(50);not null;index" json:"event_type"`
+	AggregateType string `gorm:"type:varchar(30);not null;index:idx_outbox_events_aggregate" json:"aggregate_type"`
+	AggregateID   uint   `gorm:"not null;index:idx_outbox_events_aggregate" json:"aggregate_id"`
+	Payload       string `gorm:"type:text;not null" json:"payload"`
+	UserID        *uint  `json:"user_id"`
+
+	// Relay State
+	Status         string     `gorm:"type:varchar(10);not null;default:pending;index" json:"status"` // pending, published, failed
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	PublishedSinks string     `gorm:"type:varchar(255);not null;default:''" json:"published_sinks"` // Comma separated sinks that already accepted the event
+	LastError      *string    `gorm:"type:text" json:"last_error"`
+	PublishedAt    *time.Time `gorm:"index" json:"published_at"`
+}
+
+// Outbox event statuses
+const (
+	OutboxEventPending   = "pending"
+	OutboxEventPublished = "published"
+	OutboxEventFailed    = "failed"
+)
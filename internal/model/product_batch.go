@@ -12,6 +12,12 @@ type ProductBatch struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
 	// Foreign Key to Product
 	ProductID   uint      `gorm:"not null" json:"product_id"`
 	CodeBatch   *string   `json:"code_batch"`  // Nullable code batch
@@ -19,6 +25,12 @@ type ProductBatch struct {
 	ExpDate     time.Time `json:"exp_date"`    // Expiry date
 	Description *string   `json:"description"` // Nullable description
 
+	// Hold Status
+	Status          string     `gorm:"type:varchar(20);not null;default:available;index" json:"status"` // available, quarantined, on-hold, damaged, expired
+	StatusReason    *string    `gorm:"type:text" json:"status_reason"`                                  // Reason of the last hold or release
+	StatusUpdatedAt *time.Time `json:"status_updated_at"`
+	StatusUpdatedBy *uint      `json:"status_updated_by,omitempty"`
+
 	// Audit Trail Fields
 	UserIns  *uint `json:"user_ins,omitempty"`  // Pointer untuk allow null
 	UserUpdt *uint `json:"user_updt,omitempty"` // Pointer untuk allow null
@@ -28,3 +40,21 @@ type ProductBatch struct {
 	InsertedBy *User   `gorm:"foreignKey:UserIns;constraint:OnDelete:RESTRICT" json:"inserted_by,omitempty"`
 	UpdatedBy  *User   `gorm:"foreignKey:UserUpdt;constraint:OnDelete:SET NULL" json:"updated_by,omitempty"`
 }
+
+// Stock status constants shared by product batches and product stocks; anything but available blocks stock-out
+const (
+	StockStatusAvailable   = "available"
+	StockStatusQuarantined = "quarantined"
+	StockStatusOnHold      = "on-hold"
+	StockStatusDamaged     = "damaged"
+	StockStatusExpired     = "expired"
+)
+
+// IsHoldStatus reports whether status is one of the statuses a batch or stock can be held with
+func IsHoldStatus(status string) bool {
+	switch status {
+	case StockStatusQuarantined, StockStatusOnHold, StockStatusDamaged, StockStatusExpired:
+		return true
+	}
+	return false
+}
@@ -12,6 +12,9 @@ type ProductBatchTrack struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
 	ProductBatchID uint   `json:"product_batch_id" gorm:"not null;index"`
 	Description    string `json:"description" gorm:"type:text;not null"` // Description of what changed
 	UserInst       uint   `json:"user_inst" gorm:"not null"`             // User who made the change
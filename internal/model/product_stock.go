@@ -12,14 +12,27 @@ type ProductStock struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
 	// Foreign Keys
-	ProductBatchID uint `gorm:"not null" json:"product_batch_id"`
-	ProductID      uint `gorm:"not null" json:"product_id"`
-	LocationID     uint `gorm:"not null" json:"location_id"`
+	ProductBatchID uint  `gorm:"not null" json:"product_batch_id"`
+	ProductID      uint  `gorm:"not null" json:"product_id"`
+	LocationID     uint  `gorm:"not null" json:"location_id"`
+	BinID          *uint `gorm:"index" json:"bin_id"` // Optional bin (sub-location) inside a gudang location
 
 	// Stock Information
 	Quantity *float64 `json:"quantity"`
 
+	// Hold Status
+	Status          string     `gorm:"type:varchar(20);not null;default:available;index" json:"status"` // available, quarantined, on-hold, damaged, expired
+	StatusReason    *string    `gorm:"type:text" json:"status_reason"`                                  // Reason of the last hold or release
+	StatusUpdatedAt *time.Time `json:"status_updated_at"`
+	StatusUpdatedBy *uint      `json:"status_updated_by,omitempty"`
+
 	// Audit Trail Fields
 	UserIns  *uint `json:"user_ins,omitempty"`
 	UserUpdt *uint `json:"user_updt,omitempty"`
@@ -28,6 +41,7 @@ type ProductStock struct {
 	ProductBatch ProductBatch `gorm:"foreignKey:ProductBatchID;constraint:OnDelete:RESTRICT" json:"product_batch"`
 	Product      Product      `gorm:"foreignKey:ProductID;constraint:OnDelete:RESTRICT" json:"product"`
 	Location     *Location    `gorm:"foreignKey:LocationID;constraint:OnDelete:RESTRICT" json:"location,omitempty"`
+	Bin          *SubLocation `gorm:"foreignKey:BinID;constraint:OnDelete:RESTRICT" json:"bin,omitempty"`
 	InsertedBy   *User        `gorm:"foreignKey:UserIns;constraint:OnDelete:RESTRICT" json:"inserted_by,omitempty"`
 	UpdatedBy    *User        `gorm:"foreignKey:UserUpdt;constraint:OnDelete:SET NULL" json:"updated_by,omitempty"`
 }
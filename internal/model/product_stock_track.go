@@ -7,18 +7,24 @@ import (
 )
 
 type ProductStockTrack struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
+	ID        uint           `gorm:"primarykey;index:idx_product_stock_tracks_date_id,priority:2" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
 	// Foreign Keys
 	ProductStockID uint `gorm:"not null" json:"product_stock_id"`
 	ProductBatchID uint `gorm:"not null" json:"product_batch_id"`
 	ProductID      uint `gorm:"not null" json:"product_id"`
 
 	// Track Information
-	Date        time.Time `gorm:"not null" json:"date"`
+	Date        time.Time `gorm:"not null;index:idx_product_stock_tracks_date_id,priority:1" json:"date"` // Keyset pagination on (date, id)
 	Quantity    float64   `gorm:"not null" json:"quantity"`
 	Operation   string    `gorm:"type:varchar(10);not null" json:"operation"` // Plus, Minus
 	Stock       float64   `gorm:"not null" json:"stock"`
@@ -12,6 +12,12 @@ type ProductUnit struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
 	// Foreign Key to Product
 	ProductID       uint     `gorm:"not null" json:"product_id"`
 	LocationID      uint     `gorm:"not null" json:"location_id"`
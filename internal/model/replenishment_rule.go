@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplenishmentRule holds the stock levels wanted for a product, either at one location or (LocationID null) across all locations.
+// When the available quantity drops to ReorderPoint or below, stock should be brought back up to MaxQuantity.
+type ReplenishmentRule struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
+	// Foreign Keys
+	ProductID  uint  `gorm:"not null;index" json:"product_id"`
+	LocationID *uint `gorm:"index" json:"location_id"` // Null for a product-wide rule
+
+	// Stock Levels
+	MinQuantity  float64 `gorm:"not null;default:0" json:"min_quantity"` // Safety stock; below it the alert is critical
+	ReorderPoint float64 `gorm:"not null" json:"reorder_point"`
+	MaxQuantity  float64 `gorm:"not null" json:"max_quantity"` // Level to refill up to
+
+	// Audit Trail Fields
+	UserIns  *uint `json:"user_ins,omitempty"`
+	UserUpdt *uint `json:"user_updt,omitempty"`
+
+	// Relationships
+	Product    Product   `gorm:"foreignKey:ProductID;constraint:OnDelete:RESTRICT" json:"product"`
+	Location   *Location `gorm:"foreignKey:LocationID;constraint:OnDelete:RESTRICT" json:"location,omitempty"`
+	InsertedBy *User     `gorm:"foreignKey:UserIns;constraint:OnDelete:RESTRICT" json:"inserted_by,omitempty"`
+	UpdatedBy  *User     `gorm:"foreignKey:UserUpdt;constraint:OnDelete:SET NULL" json:"updated_by,omitempty"`
+}
+
+// ReplenishmentAlert is raised by the replenishment job when a rule reaches its reorder point and resolved once stock is back above it
+type ReplenishmentAlert struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Foreign Keys
+	ReplenishmentRuleID uint  `gorm:"not null;index" json:"replenishment_rule_id"`
+	ProductID           uint  `gorm:"not null" json:"product_id"`
+	LocationID          *uint `json:"location_id"`
+
+	// Alert Information
+	Priority          string     `gorm:"type:varchar(10);not null" json:"priority"`                  // critical, reorder
+	Status            string     `gorm:"type:varchar(10);not null;default:open;index" json:"status"` // open, resolved
+	OnHand            float64    `gorm:"not null" json:"on_hand"`
+	SuggestedQuantity float64    `gorm:"not null" json:"suggested_quantity"`
+	ResolvedAt        *time.Time `json:"resolved_at"`
+
+	// Relationships
+	ReplenishmentRule ReplenishmentRule `gorm:"foreignKey:ReplenishmentRuleID;constraint:OnDelete:CASCADE" json:"replenishment_rule"`
+}
+
+// Replenishment alert priorities and statuses
+const (
+	ReplenishmentPriorityCritical = "critical"
+	ReplenishmentPriorityReorder  = "reorder"
+
+	ReplenishmentAlertOpen     = "open"
+	ReplenishmentAlertResolved = "resolved"
+)
@@ -6,13 +6,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// RoleAdmin is the role whose users bypass location-scoped access
+const RoleAdmin = "Admin"
+
 type Role struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
-	Name        string         `gorm:"unique;not null" json:"name"`
-	Description string         `json:"description"`
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Tenant
+	TenantID *uint `gorm:"index;uniqueIndex:idx_roles_tenant_name" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
+	Name        string `gorm:"not null;uniqueIndex:idx_roles_tenant_name" json:"name"` // Unique per tenant
+	Description string `json:"description"`
 
 	// Audit Trail Fields
 	UserIns  *uint `json:"user_ins,omitempty"`  // Pointer untuk allow null
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubLocation is one node of the storage layout of a gudang location: zone > aisle > rack > bin
+type SubLocation struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
+	// Foreign Keys
+	LocationID uint  `gorm:"not null;index;uniqueIndex:idx_sub_locations_location_path,where:deleted_at IS NULL" json:"location_id"`
+	ParentID   *uint `gorm:"index" json:"parent_id"` // Null for zones
+
+	// Layout Information
+	Level       string   `gorm:"type:varchar(10);not null;check:level IN ('zone', 'aisle', 'rack', 'bin')" json:"level"`
+	Code        string   `gorm:"type:varchar(20);not null" json:"code"`
+	Path        string   `gorm:"type:varchar(100);not null;uniqueIndex:idx_sub_locations_location_path,where:deleted_at IS NULL" json:"path"` // Codes from the zone down, e.g. A-01-03-B2
+	Name        *string  `gorm:"type:varchar(100)" json:"name"`
+	Capacity    *float64 `json:"capacity"` // Maximum quantity a bin can hold, null for unlimited
+	Description *string  `gorm:"type:text" json:"description"`
+
+	// Audit Trail Fields
+	UserIns  *uint `json:"user_ins,omitempty"`
+	UserUpdt *uint `json:"user_updt,omitempty"`
+
+	// Relationships
+	Location   Location     `gorm:"foreignKey:LocationID;constraint:OnDelete:RESTRICT" json:"location"`
+	Parent     *SubLocation `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"parent,omitempty"`
+	InsertedBy *User        `gorm:"foreignKey:UserIns;constraint:OnDelete:RESTRICT" json:"inserted_by,omitempty"`
+	UpdatedBy  *User        `gorm:"foreignKey:UserUpdt;constraint:OnDelete:SET NULL" json:"updated_by,omitempty"`
+}
+
+// Sub-location levels, from the outermost to the innermost
+const (
+	SubLocationLevelZone  = "zone"
+	SubLocationLevelAisle = "aisle"
+	SubLocationLevelRack  = "rack"
+	SubLocationLevelBin   = "bin"
+)
+
+// SubLocationParentLevel maps each level to the level its parent must have; zones have no parent
+var SubLocationParentLevel = map[string]string{
+	SubLocationLevelZone:  "",
+	SubLocationLevelAisle: SubLocationLevelZone,
+	SubLocationLevelRack:  SubLocationLevelAisle,
+	SubLocationLevelBin:   SubLocationLevelRack,
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tenant is a company whose data is isolated from the other tenants; every other model has a TenantID
+type Tenant struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
+	// Tenant Information
+	Code     string `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"`
+	Name     string `gorm:"type:varchar(255);not null" json:"name"`
+	IsActive bool   `gorm:"not null;default:true" json:"is_active"`
+
+	// Audit Trail Fields
+	UserIns  *uint `json:"user_ins,omitempty"`
+	UserUpdt *uint `json:"user_updt,omitempty"`
+}
+
+// DefaultTenantCode is the tenant existing data is assigned to when multi-tenancy is enabled
+const DefaultTenantCode = "default"
@@ -8,6 +8,9 @@ import (
 
 type User struct {
 	gorm.Model
+	Version  uint   `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+	TenantID *uint  `gorm:"index" json:"tenant_id"`            // Null for platform users, who choose a tenant per request with X-Tenant-ID
+	RoleID   *uint  `gorm:"index" json:"role_id"`              // Users with the Admin role are not limited to their assigned locations
 	Name     string `json:"name"`
 	Email    string `json:"email" gorm:"unique"`
 	Password string `json:"-"` // "-" means don't include in JSON response
@@ -15,6 +18,6 @@ type User struct {
 
 // SafeLogString returns a safe string representation of User without password
 func (u *User) SafeLogString() string {
-	return fmt.Sprintf("User{ID: %d, Name: %s, Email: %s, CreatedAt: %v, UpdatedAt: %v}",
-		u.ID, u.Name, u.Email, u.CreatedAt, u.UpdatedAt)
+	return fmt.Sprintf("User{ID: %d, TenantID: %v, RoleID: %v, Name: %s, Email: %s, CreatedAt: %v, UpdatedAt: %v}",
+		u.ID, u.TenantID, u.RoleID, u.Name, u.Email, u.CreatedAt, u.UpdatedAt)
 }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookSubscription sends the events it subscribes to as signed POST requests to URL
+type WebhookSubscription struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Optimistic Concurrency
+	Version uint `gorm:"not null;default:1" json:"version"` // Incremented by every update, sent as the ETag
+
+	// Subscription Information
+	URL         string  `gorm:"type:varchar(500);not null" json:"url"`
+	Events      string  `gorm:"type:text;not null" json:"events"`    // Comma separated event names, * for every event
+	Secret      string  `gorm:"type:varchar(255);not null" json:"-"` // HMAC-SHA256 key for X-Webhook-Signature
+	IsActive    bool    `gorm:"not null;default:true" json:"is_active"`
+	Description *string `gorm:"type:text" json:"description"`
+
+	// Audit Trail Fields
+	UserIns  *uint `json:"user_ins,omitempty"`
+	UserUpdt *uint `json:"user_updt,omitempty"`
+
+	// Relationships
+	InsertedBy *User `gorm:"foreignKey:UserIns;constraint:OnDelete:RESTRICT" json:"inserted_by,omitempty"`
+	UpdatedBy  *User `gorm:"foreignKey:UserUpdt;constraint:OnDelete:SET NULL" json:"updated_by,omitempty"`
+}
+
+// WebhookDelivery is one event queued for one subscription, retried with exponential backoff until it succeeds or runs out of attempts
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Tenant
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Foreign Keys
+	WebhookSubscriptionID uint  `gorm:"not null;index" json:"webhook_subscription_id"`
+	OutboxEventID         *uint `gorm:"index" json:"outbox_event_id"` // Set for domain events relayed from the outbox
+
+	// Event Information
+	Event      string `gorm:"type:varchar(50);not null;index" json:"event"`
+	ResourceID *uint  `gorm:"index" json:"resource_id"` // ID of the product, batch or alert the event is about
+	Payload    string `gorm:"type:text;not null" json:"payload"`
+
+	// Delivery State
+	Status         string     `gorm:"type:varchar(10);not null;default:pending;index" json:"status"` // pending, succeeded, failed
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int       `json:"response_status"`
+	LastError      *string    `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+
+	// Relationships
+	WebhookSubscription WebhookSubscription `gorm:"foreignKey:WebhookSubscriptionID;constraint:OnDelete:CASCADE" json:"webhook_subscription"`
+}
+
+// Webhook events raised by background jobs; domain events relayed from the outbox can be subscribed to as well
+const (
+	WebhookEventStockLow      = "stock.low"
+	WebhookEventBatchExpiring = "batch.expiring"
+	WebhookEventPing          = "ping"
+
+	WebhookEventAll = "*"
+)
+
+// WebhookEvents lists the job events a subscription can subscribe to
+var WebhookEvents = []string{WebhookEventStockLow, WebhookEventBatchExpiring}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	_ "embed"
+	"myapp/pkg/logger"
+	"net/http"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+var openAPILog = logger.New("openapi")
+
+//go:embed docs.html
+var docsPage []byte
+
+// SpecHandler serves the document of cfg as JSON. It is built on the first request, once every route is registered.
+func SpecHandler(cfg Config) fiber.Handler {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			doc = Build(c.App(), cfg)
+			openAPILog.InfoContext(c.UserContext(), "Built document", "paths", len(doc.Paths))
+		})
+		return c.JSON(doc)
+	}
+}
+
+// DocsHandler serves a Swagger UI page reading the document from openapi.json next to it and its scripts and styles
+// from the assets of AssetsHandler
+func DocsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(docsPage)
+	}
+}
+
+// AssetsHandler serves the Swagger UI files the docs page loads. They are built into the binary from the release
+// pinned in go.mod, so the page works offline and does not change between builds. Register it with Use under
+// docs/assets, next to DocsHandler.
+func AssetsHandler() fiber.Handler {
+	return filesystem.New(filesystem.Config{Root: http.FS(swaggerFiles.FS)})
+}
@@ -0,0 +1,446 @@
+// Package openapi generates the OpenAPI 3 document of the API from the routes registered on the Fiber app. Paths,
+// methods, path parameters, authentication and If-Match/Idempotency-Key headers come from the route registrations;
+// summaries, request bodies and responses come from the Endpoint each route is documented with, whose request and
+// response structs are turned into JSON schemas by reflection.
+package openapi
+
+import (
+	"fmt"
+	"myapp/pkg/helper"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Endpoint documents one route. Its key in Config.Endpoints is the method and the path below Config.Prefix as
+// registered, e.g. "GET /brands/:id".
+type Endpoint struct {
+	Summary     string      // Defaults to the handler name in words, GetBrandByID becomes "Get brand by ID"
+	Description string      // Optional longer description
+	Request     interface{} // Value of the JSON body, e.g. handler.CreateBrandRequest{}
+	Response    interface{} // Value of data in a successful response, e.g. model.Brand{} or []model.Brand{}
+	Status      int         // Status of a successful response, 200 when zero
+	Meta        interface{} // Value of meta in a successful response, helper.Pagination{} for lists
+	List        bool        // Paginated list accepting page, page_size, sort and filters
+	Export      bool        // List that can be exported as CSV, XLSX or PDF with ?format= or Accept
+	Upload      bool        // Body is a multipart form with a file field instead of JSON
+	ContentType string      // Content type of a successful response not wrapped in {code, message, data}, e.g. text/event-stream
+	Query       []Parameter // Other query parameters
+}
+
+// Config describes the API and how its routes are documented
+type Config struct {
+	Title       string
+	Version     string
+	Description string
+	Prefix      string              // Only routes below Prefix are documented, e.g. /api/v1
+	Endpoints   map[string]Endpoint // Documentation of each route, keyed by "METHOD /path"
+
+	// Middleware are recognised by the name of the function that built them, e.g. JWTMiddleware
+	Authenticators []string // Middleware that require a bearer token
+	Preconditions  []string // Middleware that require If-Match
+	Idempotency    []string // Middleware that accept Idempotency-Key on mutating requests
+}
+
+// Build returns the document of the routes of app below cfg.Prefix that have an Endpoint. Routes without one are
+// left out, which the route coverage test catches.
+func Build(app *fiber.App, cfg Config) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: cfg.Title, Version: cfg.Version, Description: cfg.Description},
+		Servers: []Server{{URL: cfg.Prefix}},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	schemas := newSchemaGenerator(doc.Components.Schemas)
+	doc.Components.Schemas["Error"] = errorSchema()
+
+	operationIDs := map[string]bool{}
+	for _, route := range Routes(app, cfg.Prefix) {
+		endpoint, ok := cfg.Endpoints[route.Key()]
+		if !ok {
+			continue
+		}
+
+		op := buildOperation(route, endpoint, cfg, schemas)
+		// A handler served on two paths gets the second path in its operation ID
+		if operationIDs[op.OperationID] {
+			op.OperationID += upperFirst(route.Path[strings.LastIndex(route.Path, "/")+1:])
+		}
+		operationIDs[op.OperationID] = true
+
+		path := route.OpenAPIPath()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+// Route is an endpoint registered on the app, with the middleware that run before it
+type Route struct {
+	Method     string
+	Path       string   // Path below the prefix as registered, e.g. /brands/:id
+	Params     []string // Path parameters
+	Handler    string   // Name of the handler, e.g. GetBrandByID, empty for inline handlers
+	Middleware []string // Names of the functions that built its middleware, e.g. JWTMiddleware
+}
+
+// Key is the key of the route in Config.Endpoints
+func (r Route) Key() string {
+	return r.Method + " " + r.Path
+}
+
+var pathParamPattern = regexp.MustCompile(`:(\w+)\??`)
+
+// OpenAPIPath writes path parameters the OpenAPI way: /brands/:id becomes /brands/{id}
+func (r Route) OpenAPIPath() string {
+	return pathParamPattern.ReplaceAllString(r.Path, "{$1}")
+}
+
+// Routes lists the endpoints of app below prefix, in registration order. HEAD routes Fiber adds for every GET are
+// skipped. Middleware registered with Use only apply to the routes registered after them, as in Fiber itself.
+func Routes(app *fiber.App, prefix string) []Route {
+	// GetRoutes(true) leaves out the middleware registered with Use, which the raw stack does not tell apart
+	endpoints := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		endpoints[routeID(route.Method, route.Path, route.Handlers)] = true
+	}
+
+	var routes []Route
+	for _, stack := range app.Stack() {
+		var uses []*fiber.Route
+		for _, route := range stack {
+			if !endpoints[routeID(route.Method, route.Path, route.Handlers)] {
+				uses = append(uses, route)
+				continue
+			}
+			if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, prefix) {
+				continue
+			}
+
+			var middleware []string
+			for _, use := range uses {
+				if usePrefixMatches(use.Path, route.Path) {
+					middleware = append(middleware, funcNames(use.Handlers)...)
+				}
+			}
+			last := len(route.Handlers) - 1
+			middleware = append(middleware, funcNames(route.Handlers[:last])...)
+			handler, closure := funcName(route.Handlers[last])
+			if closure {
+				handler = ""
+			}
+
+			routes = append(routes, Route{
+				Method:     route.Method,
+				Path:       normalizePath(strings.TrimPrefix(route.Path, prefix)),
+				Params:     route.Params,
+				Handler:    handler,
+				Middleware: middleware,
+			})
+		}
+	}
+	return routes
+}
+
+// routeID identifies a registered route by its method, path and handlers
+func routeID(method, path string, handlers []fiber.Handler) string {
+	id := method + " " + path
+	for _, h := range handlers {
+		id += fmt.Sprintf(" %x", reflect.ValueOf(h).Pointer())
+	}
+	return id
+}
+
+// usePrefixMatches reports whether a middleware registered with Use on prefix runs for path
+func usePrefixMatches(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// normalizePath drops the trailing slash of group roots: /brands/ and /brands are the same route
+func normalizePath(path string) string {
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// funcNames names the functions that built handlers: middleware.JWTMiddleware.func1 becomes JWTMiddleware and
+// handler.GetBrandByID stays GetBrandByID
+func funcNames(handlers []fiber.Handler) []string {
+	names := make([]string, len(handlers))
+	for i, h := range handlers {
+		names[i], _ = funcName(h)
+	}
+	return names
+}
+
+// funcName names the function that built h and reports whether h is a closure it returned
+func funcName(h fiber.Handler) (string, bool) {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	parts := strings.Split(name[strings.LastIndex(name, "/")+1:], ".")
+	if len(parts) < 2 {
+		return name, false
+	}
+	return parts[1], len(parts) > 2
+}
+
+func buildOperation(route Route, endpoint Endpoint, cfg Config, schemas *schemaGenerator) *Operation {
+	op := &Operation{
+		Tags:        []string{tagOf(route.Path)},
+		Summary:     endpoint.Summary,
+		Description: endpoint.Description,
+		OperationID: lowerFirst(route.Handler),
+		Responses:   map[string]Response{},
+	}
+	if op.Summary == "" {
+		op.Summary = words(route.Handler)
+	}
+	if route.Handler == "" {
+		// Inline handlers have no name to go by, their summary is required
+		op.OperationID = camelCase(op.Summary)
+	}
+
+	for _, param := range route.Params {
+		op.Parameters = append(op.Parameters, Parameter{Name: param, In: "path", Required: true, Schema: pathParamSchema(param)})
+	}
+	if endpoint.List {
+		op.Parameters = append(op.Parameters, listParameters()...)
+	}
+	if endpoint.Export {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: "format", In: "query", Description: "Export the list as a file instead of returning JSON",
+			Schema: &Schema{Type: "string", Enum: []interface{}{"json", "csv", "xlsx", "pdf"}},
+		})
+	}
+	op.Parameters = append(op.Parameters, endpoint.Query...)
+
+	authenticated := hasAny(route.Middleware, cfg.Authenticators)
+	if authenticated {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		op.Responses["401"] = errorResponse("Missing or invalid token")
+	}
+	if hasAny(route.Middleware, cfg.Preconditions) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: fiber.HeaderIfMatch, In: "header", Required: true,
+			Description: `ETag of the resource as last read, e.g. "3", or * to skip the check`,
+			Schema:      &Schema{Type: "string"},
+		})
+		op.Responses["412"] = errorResponse("The resource changed since the ETag in If-Match")
+		op.Responses["428"] = errorResponse("If-Match is missing")
+	}
+	if hasAny(route.Middleware, cfg.Idempotency) && isMutating(route.Method) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: "Idempotency-Key", In: "header",
+			Description: "Retries with the same key and body get the first response back instead of running again",
+			Schema:      &Schema{Type: "string", MaxLength: intPtr(255)},
+		})
+	}
+
+	switch {
+	case endpoint.Upload:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			fiber.MIMEMultipartForm: {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+				Required:   []string{"file"},
+			}},
+		}}
+	case endpoint.Request != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			fiber.MIMEApplicationJSON: {Schema: schemas.schemaOf(reflect.TypeOf(endpoint.Request))},
+		}}
+		op.Responses["422"] = errorResponse("The body failed validation or a business rule")
+	}
+
+	if len(route.Params) > 0 {
+		op.Responses["404"] = errorResponse("Not found")
+	}
+	op.Responses["400"] = errorResponse("Invalid request")
+	op.Responses["500"] = errorResponse("Internal server error")
+
+	status := endpoint.Status
+	if status == 0 {
+		status = fiber.StatusOK
+	}
+	op.Responses[fmt.Sprint(status)] = successResponse(endpoint, schemas)
+	return op
+}
+
+func successResponse(endpoint Endpoint, schemas *schemaGenerator) Response {
+	// Responses that are not wrapped in helper.APIResponse, such as this document or an event stream
+	if endpoint.ContentType != "" {
+		schema := &Schema{Type: "string"}
+		if endpoint.ContentType == fiber.MIMEApplicationJSON {
+			schema = &Schema{Type: "object"}
+		}
+		return Response{Description: "Success", Content: map[string]MediaType{endpoint.ContentType: {Schema: schema}}}
+	}
+
+	envelope := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer"},
+			"message": {Type: "string"},
+		},
+	}
+	if endpoint.Response != nil {
+		envelope.Properties["data"] = schemas.schemaOf(reflect.TypeOf(endpoint.Response))
+	}
+	meta := endpoint.Meta
+	if meta == nil && endpoint.List {
+		meta = helper.Pagination{}
+	}
+	if meta != nil {
+		envelope.Properties["meta"] = schemas.schemaOf(reflect.TypeOf(meta))
+	}
+	return Response{Description: "Success", Content: map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: envelope}}}
+}
+
+func errorResponse(description string) Response {
+	return Response{Description: description, Content: map[string]MediaType{
+		fiber.MIMEApplicationJSON: {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+	}}
+}
+
+// errorSchema is helper.APIResponse as sent by helper.Fail
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer"},
+			"message": {Type: "string"},
+			"error":   {Description: "The typed error code and details, the failed validation rules or a message"},
+		},
+		Required: []string{"code", "message"},
+	}
+}
+
+func listParameters() []Parameter {
+	return []Parameter{
+		{Name: "page", In: "query", Schema: &Schema{Type: "integer", Minimum: floatPtr(1)}},
+		{Name: "page_size", In: "query", Description: "At most 100", Schema: &Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(100)}},
+		{Name: "sort", In: "query", Description: "Comma separated fields, - for descending, e.g. -created_at,name. Filter with field=value or field[op]=value", Schema: &Schema{Type: "string"}},
+	}
+}
+
+func pathParamSchema(name string) *Schema {
+	if strings.HasSuffix(strings.ToLower(name), "id") {
+		return &Schema{Type: "integer", Minimum: floatPtr(1)}
+	}
+	return &Schema{Type: "string"}
+}
+
+// tagOf groups operations by their first path segment: /product-stocks/:id is tagged Product Stocks
+func tagOf(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	parts := strings.Split(segment, "-")
+	for i, part := range parts {
+		parts[i] = upperFirst(part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// words turns a handler name into a summary: GetBrandByID becomes "Get brand by ID"
+func words(name string) string {
+	runes := []rune(name)
+	var parts []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || (unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))) {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	for i := 1; i < len(parts); i++ {
+		if strings.ToUpper(parts[i]) != parts[i] {
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// camelCase turns a summary into an operation ID: API v1 health check becomes apiV1HealthCheck
+func camelCase(summary string) string {
+	fields := strings.FieldsFunc(summary, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, field := range fields {
+		if i == 0 {
+			fields[i] = strings.ToLower(field)
+		} else {
+			fields[i] = upperFirst(strings.ToLower(field))
+		}
+	}
+	return strings.Join(fields, "")
+}
+
+func hasAny(names, wanted []string) bool {
+	for _, name := range names {
+		for _, w := range wanted {
+			if name == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// SortedKeys returns the keys of m in order, for stable output of documents and test failures
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator turns Go types into schemas the way encoding/json writes them. Named structs are added to the
+// components once and referenced, which also ends the recursion of models that point back at each other.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator(schemas map[string]*Schema) *schemaGenerator {
+	return &schemaGenerator{schemas: schemas, names: map[reflect.Type]string{}}
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schemaOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.componentName(t)}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: floatPtr(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+	// interface{} and anything else JSON cannot describe up front
+	return &Schema{}
+}
+
+// componentName adds the schema of a named struct to the components and returns its name there. Structs of
+// different packages with the same name are told apart by their package: service.X and handler.X.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = upperFirst(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // Placeholder while the fields are generated, they may refer back to t
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the JSON fields of struct t, including those of embedded structs, to schema
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type)
+		if applyRules(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyRules adds the `validate` rules of a field to its schema and reports whether the field is required. Rules
+// after dive apply to the items of a slice, whose schema is left as it is.
+func applyRules(schema *Schema, rules string) bool {
+	if rules == "" {
+		return false
+	}
+
+	required := false
+	target := schema
+	if schema.Ref != "" {
+		target = &Schema{} // A referenced schema cannot be narrowed in place
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, value)
+			}
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "datetime":
+			if param == "2006-01-02" {
+				target.Format = "date"
+			}
+		case "min", "gte", "gt":
+			setBound(target, param, true, key == "gt")
+		case "max", "lte", "lt":
+			setBound(target, param, false, key == "lt")
+		case "len":
+			setBound(target, param, true, false)
+			setBound(target, param, false, false)
+		}
+	}
+	return required
+}
+
+// setBound sets a min or max rule as the length of strings and slices, or the value of numbers
+func setBound(schema *Schema, param string, lower, exclusive bool) {
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch schema.Type {
+	case "string":
+		n := int(value)
+		if lower {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case "array":
+		n := int(value)
+		if lower {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	case "integer", "number":
+		if lower {
+			schema.Minimum = &value
+			schema.ExclusiveMinimum = exclusive
+		} else {
+			schema.Maximum = &value
+			schema.ExclusiveMaximum = exclusive
+		}
+	}
+}
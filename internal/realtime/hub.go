@@ -0,0 +1,214 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"myapp/pkg/logger"
+	"myapp/pkg/redis"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var realtimeLog = logger.New("realtime")
+
+// DefaultStockChannel is the Redis pub/sub channel used when REALTIME_REDIS_CHANNEL is not set
+const DefaultStockChannel = "inventory:stock-updates"
+
+// ClientBuffer is how many updates a subscriber may fall behind before it is disconnected
+const ClientBuffer = 64
+
+// StockUpdate is the state of a stock row after a change, as streamed to dashboards
+type StockUpdate struct {
+	EventID        uint      `json:"eventId"` // Outbox event ID, usable to drop duplicates
+	TenantID       *uint     `json:"tenantId"`
+	Event          string    `json:"event"`
+	ProductStockID uint      `json:"productStockId"`
+	ProductID      uint      `json:"productId"`
+	ProductBatchID uint      `json:"productBatchId"`
+	LocationID     uint      `json:"locationId"`
+	BinID          *uint     `json:"binId"`
+	FromLocationID *uint     `json:"fromLocationId,omitempty"` // Previous location when the stock was moved
+	Quantity       *float64  `json:"quantity"`
+	StockIn        *float64  `json:"stockIn,omitempty"`
+	StockOut       *float64  `json:"stockOut,omitempty"`
+	Status         string    `json:"status"`
+	Deleted        bool      `json:"deleted"`
+	OccurredAt     time.Time `json:"occurredAt"`
+}
+
+// Filter limits a subscription to one tenant and optionally one location and/or one product; nil fields match everything
+type Filter struct {
+	TenantID    *uint
+	LocationIDs []uint // Locations a reseller user is assigned to; nil allows every location
+	LocationID  *uint
+	ProductID   *uint
+}
+
+// Matches reports whether update belongs to the filter; a move matches both its old and new location
+func (f Filter) Matches(update StockUpdate) bool {
+	if f.TenantID != nil && (update.TenantID == nil || *f.TenantID != *update.TenantID) {
+		return false
+	}
+	if f.LocationIDs != nil && !slices.Contains(f.LocationIDs, update.LocationID) &&
+		(update.FromLocationID == nil || !slices.Contains(f.LocationIDs, *update.FromLocationID)) {
+		return false
+	}
+	if f.ProductID != nil && *f.ProductID != update.ProductID {
+		return false
+	}
+	if f.LocationID != nil && *f.LocationID != update.LocationID &&
+		(update.FromLocationID == nil || *f.LocationID != *update.FromLocationID) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives the updates matching its filter on Updates, which is closed when it is removed
+type Subscription struct {
+	Updates chan StockUpdate
+	filter  Filter
+}
+
+// Hub fans stock updates out to the subscribers of every API instance. With Redis enabled updates go through
+// a pub/sub channel that every instance listens to; otherwise they are only delivered in this process.
+type Hub struct {
+	channel       string
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewHub(channel string) *Hub {
+	return &Hub{
+		channel:       channel,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// StockHub is the hub shared by the stock stream endpoint and the outbox relay; its channel is REALTIME_REDIS_CHANNEL
+var StockHub = NewHub("")
+
+// redisChannel resolves the channel on use, since the hub is created before the environment is loaded
+func (h *Hub) redisChannel() string {
+	if h.channel != "" {
+		return h.channel
+	}
+	if channel := os.Getenv("REALTIME_REDIS_CHANNEL"); channel != "" {
+		return channel
+	}
+	return DefaultStockChannel
+}
+
+// Subscribe registers a subscriber for the updates matching filter
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	subscription := &Subscription{
+		Updates: make(chan StockUpdate, ClientBuffer),
+		filter:  filter,
+	}
+
+	h.mu.Lock()
+	h.subscriptions[subscription] = struct{}{}
+	h.mu.Unlock()
+
+	return subscription
+}
+
+// Unsubscribe removes subscription and closes its Updates channel; calling it twice is safe
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[subscription]; ok {
+		delete(h.subscriptions, subscription)
+		close(subscription.Updates)
+	}
+}
+
+// Close removes every subscriber, which ends their streams so the server can shut down without waiting for them
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscription := range h.subscriptions {
+		delete(h.subscriptions, subscription)
+		close(subscription.Updates)
+	}
+}
+
+// Publish sends update to the subscribers of every instance
+func (h *Hub) Publish(ctx context.Context, update StockUpdate) error {
+	if !redis.IsEnabled {
+		h.broadcast(update)
+		return nil
+	}
+
+	message, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return redis.Publish(ctx, h.redisChannel(), message)
+}
+
+// Run relays updates published by any instance to the local subscribers until ctx is done; without Redis it does nothing
+func (h *Hub) Run(ctx context.Context) {
+	if !redis.IsEnabled {
+		realtimeLog.Warn("Redis is not enabled, stock updates are only delivered within this instance")
+		return
+	}
+
+	channel := h.redisChannel()
+	pubsub, err := redis.Subscribe(ctx, channel)
+	if err != nil {
+		realtimeLog.Error("Subscribe failed, stock updates are not streamed", "channel", channel, "error", err)
+		return
+	}
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				realtimeLog.Info("Stopped")
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var update StockUpdate
+				if err := json.Unmarshal([]byte(message.Payload), &update); err != nil {
+					realtimeLog.Warn("Invalid message", "channel", channel, "error", err)
+					continue
+				}
+				h.broadcast(update)
+			}
+		}
+	}()
+
+	realtimeLog.Info("Listening for stock updates", "channel", channel)
+}
+
+// broadcast hands update to the matching local subscribers, disconnecting those that fell too far behind
+func (h *Hub) broadcast(update StockUpdate) {
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for subscription := range h.subscriptions {
+		if !subscription.filter.Matches(update) {
+			continue
+		}
+		select {
+		case subscription.Updates <- update:
+		default:
+			slow = append(slow, subscription)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, subscription := range slow {
+		realtimeLog.Warn("Subscriber fell behind, disconnecting")
+		h.Unsubscribe(subscription)
+	}
+}
@@ -1,77 +1,119 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"myapp/database"
 	"myapp/internal/model"
+	"myapp/internal/tenant"
+	"myapp/internal/utils"
+	"myapp/pkg/logger"
 	"myapp/pkg/redis"
+
+	"gorm.io/gorm"
 )
 
-type BrandRepository struct{}
+var cacheLog = logger.New("cache")
+
+type BrandRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 func NewBrandRepository() *BrandRepository {
 	return &BrandRepository{}
 }
 
-func (r *BrandRepository) GetAllBrands() ([]model.Brand, error) {
-	cacheKey := "brands:all"
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *BrandRepository) WithTx(tx *gorm.DB) *BrandRepository {
+	return &BrandRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *BrandRepository) WithContext(ctx context.Context) *BrandRepository {
+	return &BrandRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *BrandRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// cacheKey prefixes key with the tenant of the repository
+func (r *BrandRepository) cacheKey(key string) string {
+	tenantID, _ := tenant.FromContext(r.ctx)
+	return redis.TenantKey(tenantID, key)
+}
+
+// brandListColumns are the fields accepted by ?sort= and filters on the brand list
+var brandListColumns = utils.ListColumns{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// brandListCache is the value cached for every distinct list query
+type brandListCache struct {
+	Brands []model.Brand `json:"brands"`
+	Total  int64         `json:"total"`
+}
+
+func (r *BrandRepository) GetAllBrands(query utils.ListQuery) ([]model.Brand, int64, error) {
+	cacheKey := r.cacheKey("brands:list:" + query.CacheKey())
 
 	// Try to get from cache first
-	if cached, err := redis.Get(cacheKey); err == nil {
-		var brands []model.Brand
-		if err := json.Unmarshal([]byte(cached), &brands); err == nil {
-			log.Printf("[REDIS] Cache hit for %s", cacheKey)
-			log.Printf("[REDIS] Cached brands data: %+v", brands)
-			return brands, nil
+	if cached, err := redis.Get(r.ctx, cacheKey); err == nil {
+		var page brandListCache
+		if err := json.Unmarshal([]byte(cached), &page); err == nil {
+			cacheLog.DebugContext(r.ctx, "Cache hit", "key", cacheKey)
+			return page.Brands, page.Total, nil
 		}
 	}
 
 	// Cache miss, get from database
 	var brands []model.Brand
-	result := database.DB.Find(&brands)
-	if result.Error != nil {
-		return brands, result.Error
+	total, err := utils.FindPage(r.db().Model(&model.Brand{}), query, brandListColumns, "id ASC", &brands)
+	if err != nil {
+		return brands, total, err
 	}
 
 	// Store in cache
-	if data, err := json.Marshal(brands); err == nil {
-		if err := redis.Set(cacheKey, string(data)); err != nil {
-			log.Printf("[REDIS] Failed to cache %s: %v", cacheKey, err)
+	if data, err := json.Marshal(brandListCache{Brands: brands, Total: total}); err == nil {
+		if err := redis.Set(r.ctx, cacheKey, string(data)); err != nil {
+			cacheLog.WarnContext(r.ctx, "Caching failed", "key", cacheKey, "error", err)
 		} else {
-			log.Printf("[REDIS] Cached %s", cacheKey)
+			cacheLog.DebugContext(r.ctx, "Cached", "key", cacheKey)
 		}
 	}
 
-	return brands, result.Error
+	return brands, total, nil
 }
 
 func (r *BrandRepository) GetBrandByID(id uint) (model.Brand, error) {
-	cacheKey := fmt.Sprintf("brand:id:%d", id)
+	cacheKey := r.cacheKey(fmt.Sprintf("brand:id:%d", id))
 
 	// Try to get from cache first
-	if cached, err := redis.Get(cacheKey); err == nil {
+	if cached, err := redis.Get(r.ctx, cacheKey); err == nil {
 		var brand model.Brand
 		if err := json.Unmarshal([]byte(cached), &brand); err == nil {
-			log.Printf("[REDIS] Cache hit for %s", cacheKey)
+			cacheLog.DebugContext(r.ctx, "Cache hit", "key", cacheKey)
 			return brand, nil
 		}
 	}
 
 	// Cache miss, get from database
 	var brand model.Brand
-	result := database.DB.First(&brand, id)
+	result := r.db().First(&brand, id)
 	if result.Error != nil {
 		return brand, result.Error
 	}
 
 	// Store in cache
 	if data, err := json.Marshal(brand); err == nil {
-		if err := redis.Set(cacheKey, string(data)); err != nil {
-			log.Printf("[REDIS] Failed to cache %s: %v", cacheKey, err)
+		if err := redis.Set(r.ctx, cacheKey, string(data)); err != nil {
+			cacheLog.WarnContext(r.ctx, "Caching failed", "key", cacheKey, "error", err)
 		} else {
-			log.Printf("[REDIS] Cached %s", cacheKey)
+			cacheLog.DebugContext(r.ctx, "Cached", "key", cacheKey)
 		}
 	}
 
@@ -79,24 +121,24 @@ func (r *BrandRepository) GetBrandByID(id uint) (model.Brand, error) {
 }
 
 func (r *BrandRepository) CreateBrand(brand *model.Brand) error {
-	err := database.DB.Create(brand).Error
+	err := r.db().Create(brand).Error
 	if err != nil {
 		return err
 	}
 
-	// Write-Through: Update cache instead of invalidate
-	r.updateBrandCache()
+	// List pages are keyed by query, so invalidate them all
+	r.invalidateBrandListCache()
 	return nil
 }
 
 func (r *BrandRepository) UpdateBrand(id uint, updateData map[string]interface{}) error {
-	err := database.DB.Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
-	// Update both all brands cache and specific brand cache
-	r.updateBrandCache()
+	// Invalidate list pages and refresh the specific brand cache
+	r.invalidateBrandListCache()
 	r.updateSpecificBrandCache(id)
 	return nil
 }
@@ -108,73 +150,80 @@ func (r *BrandRepository) DeleteBrandWithAudit(id uint, userID uint) error {
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	err = database.DB.Delete(&model.Brand{}, id).Error
+	err = r.db().Delete(&model.Brand{}, id).Error
 	if err != nil {
 		return err
 	}
 
 	// Update cache after delete
-	r.updateBrandCache()
+	r.invalidateBrandListCache()
 	r.invalidateSpecificBrandCache(id) // This one we can invalidate since it's deleted
 	return nil
 }
 
-// updateBrandCache refreshes the all brands cache
-func (r *BrandRepository) updateBrandCache() {
-	var brands []model.Brand
-	result := database.DB.Find(&brands)
-	if result.Error != nil {
-		log.Printf("[REDIS] Failed to fetch brands for cache update: %v", result.Error)
-		return
-	}
+// InvalidateListCache drops every cached brand list page; used after bulk changes committed in a transaction
+func (r *BrandRepository) InvalidateListCache() {
+	r.invalidateBrandListCache()
+}
 
-	if data, err := json.Marshal(brands); err == nil {
-		if err := redis.Set("brands:all", string(data)); err != nil {
-			log.Printf("[REDIS] Failed to update brands:all cache: %v", err)
-		} else {
-			log.Printf("[REDIS] Updated brands:all cache with %d brands", len(brands))
+// FlushCache drops every cached brand and brand list page of every tenant
+func (r *BrandRepository) FlushCache() error {
+	for _, pattern := range []string{"brands:list:*", "brand:id:*"} {
+		for _, key := range []string{pattern, redis.AnyTenantKey(pattern)} {
+			if err := redis.DeletePattern(r.ctx, key); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// invalidateBrandListCache drops every cached brand list page; each filter/sort/page
+// combination is cached separately so they cannot be refreshed in place
+func (r *BrandRepository) invalidateBrandListCache() {
+	if err := redis.DeletePattern(r.ctx, r.cacheKey("brands:list:*")); err != nil {
+		cacheLog.WarnContext(r.ctx, "Invalidating cache failed", "key", "brands:list", "error", err)
+	}
 }
 
 // updateSpecificBrandCache refreshes specific brand cache
 func (r *BrandRepository) updateSpecificBrandCache(brandID uint) {
 	var brand model.Brand
-	result := database.DB.First(&brand, brandID)
+	result := r.db().First(&brand, brandID)
 	if result.Error != nil {
-		log.Printf("[REDIS] Failed to fetch brand %d for cache update: %v", brandID, result.Error)
+		cacheLog.WarnContext(r.ctx, "Fetching brand for cache update failed", "brand_id", brandID, "error", result.Error)
 		return
 	}
 
-	cacheKey := fmt.Sprintf("brand:id:%d", brandID)
+	cacheKey := r.cacheKey(fmt.Sprintf("brand:id:%d", brandID))
 	if data, err := json.Marshal(brand); err == nil {
-		if err := redis.Set(cacheKey, string(data)); err != nil {
-			log.Printf("[REDIS] Failed to update %s cache: %v", cacheKey, err)
+		if err := redis.Set(r.ctx, cacheKey, string(data)); err != nil {
+			cacheLog.WarnContext(r.ctx, "Updating cache failed", "key", cacheKey, "error", err)
 		} else {
-			log.Printf("[REDIS] Updated %s cache", cacheKey)
+			cacheLog.DebugContext(r.ctx, "Updated cache", "key", cacheKey)
 		}
 	}
 }
 
 // invalidateSpecificBrandCache removes specific brand cache
 func (r *BrandRepository) invalidateSpecificBrandCache(brandID uint) {
-	cacheKey := fmt.Sprintf("brand:id:%d", brandID)
-	if err := redis.Delete(cacheKey); err != nil {
-		log.Printf("[REDIS] Failed to invalidate %s cache: %v", cacheKey, err)
+	cacheKey := r.cacheKey(fmt.Sprintf("brand:id:%d", brandID))
+	if err := redis.Delete(r.ctx, cacheKey); err != nil {
+		cacheLog.WarnContext(r.ctx, "Invalidating cache failed", "key", cacheKey, "error", err)
 	} else {
-		log.Printf("[REDIS] Invalidated %s cache", cacheKey)
+		cacheLog.DebugContext(r.ctx, "Invalidated cache", "key", cacheKey)
 	}
 }
 
 func (r *BrandRepository) CheckBrandExists(name string) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.Brand{}).Unscoped().Where("name ILIKE ?", name)
+	query := r.db().Model(&model.Brand{}).Unscoped().Where("name ILIKE ?", name)
 
 	result := query.Count(&count)
 	return count > 0, result.Error
@@ -183,7 +232,7 @@ func (r *BrandRepository) CheckBrandExists(name string) (bool, error) {
 // GetDeletedBrands returns all soft deleted brands
 func (r *BrandRepository) GetDeletedBrands() ([]model.Brand, error) {
 	var brands []model.Brand
-	result := database.DB.Unscoped().Where("deleted_at IS NOT NULL").Find(&brands)
+	result := r.db().Unscoped().Where("deleted_at IS NOT NULL").Find(&brands)
 	return brands, result.Error
 }
 
@@ -195,12 +244,12 @@ func (r *BrandRepository) RestoreBrand(id uint, userID uint) error {
 		"deleted_at": nil,
 	}
 
-	err := database.DB.Unscoped().Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Unscoped().Model(&model.Brand{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Update cache after restore
-	r.updateBrandCache()
+	r.invalidateBrandListCache()
 	return nil
 }
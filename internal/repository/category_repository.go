@@ -1,11 +1,17 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type CategoryRepository struct{}
+type CategoryRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // categoryWithBrandResponse struct untuk response dengan brand name
 type categoryWithBrandResponse struct {
@@ -14,30 +20,54 @@ type categoryWithBrandResponse struct {
 	BrandName   string  `json:"brandName"`
 	Name        string  `json:"name"`
 	Description *string `json:"description"`
+	Version     uint    `json:"version"`
 }
 
 func NewCategoryRepository() *CategoryRepository {
 	return &CategoryRepository{}
 }
 
-func (r *CategoryRepository) GetAllCategories() ([]categoryWithBrandResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *CategoryRepository) WithTx(tx *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *CategoryRepository) WithContext(ctx context.Context) *CategoryRepository {
+	return &CategoryRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *CategoryRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// categoryListColumns are the fields accepted by ?sort= and filters on the category list
+var categoryListColumns = utils.ListColumns{
+	"id":         "c.id",
+	"brand_id":   "c.brand_id",
+	"brand_name": "b.name",
+	"name":       "c.name",
+	"created_at": "c.created_at",
+	"updated_at": "c.updated_at",
+}
+
+func (r *CategoryRepository) GetAllCategories(query utils.ListQuery) ([]categoryWithBrandResponse, int64, error) {
 	var categories []categoryWithBrandResponse
 
-	result := database.DB.Debug().Table("categories c").
-		Select("c.id, c.brand_id, c.name, c.description, b.name as brand_name").
+	db := r.db().Table("categories c").
+		Select("c.id, c.brand_id, c.name, c.description, c.version, b.name as brand_name").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
-		Where("c.deleted_at IS NULL").
-		Order("c.name ASC").
-		Find(&categories)
+		Where("c.deleted_at IS NULL")
 
-	return categories, result.Error
+	total, err := utils.FindPage(db, query, categoryListColumns, "c.name ASC", &categories)
+	return categories, total, err
 }
 
 func (r *CategoryRepository) GetCategoriesByBrand(brandID uint) ([]categoryWithBrandResponse, error) {
 	var categories []categoryWithBrandResponse
 
-	result := database.DB.Table("categories c").
-		Select("c.id, c.brand_id, c.name, c.description, b.name as brand_name").
+	result := r.db().Table("categories c").
+		Select("c.id, c.brand_id, c.name, c.description, c.version, b.name as brand_name").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
 		Where("c.brand_id = ? AND c.deleted_at IS NULL", brandID).
 		Order("c.name ASC").
@@ -49,8 +79,8 @@ func (r *CategoryRepository) GetCategoriesByBrand(brandID uint) ([]categoryWithB
 func (r *CategoryRepository) GetCategoryByID(id uint) (categoryWithBrandResponse, error) {
 	var category categoryWithBrandResponse
 
-	result := database.DB.Table("categories c").
-		Select("c.id, c.brand_id, c.name, c.description, b.name as brand_name").
+	result := r.db().Table("categories c").
+		Select("c.id, c.brand_id, c.name, c.description, c.version, b.name as brand_name").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
 		Where("c.id = ? AND c.deleted_at IS NULL", id).
 		First(&category)
@@ -61,16 +91,16 @@ func (r *CategoryRepository) GetCategoryByID(id uint) (categoryWithBrandResponse
 // GetCategoryModelByID returns model.Category for service operations
 func (r *CategoryRepository) GetCategoryModelByID(id uint) (model.Category, error) {
 	var category model.Category
-	result := database.DB.Preload("Brand").Where("id = ?", id).First(&category)
+	result := r.db().Preload("Brand").Where("id = ?", id).First(&category)
 	return category, result.Error
 }
 
 func (r *CategoryRepository) CreateCategory(category *model.Category) error {
-	return database.DB.Create(category).Error
+	return r.db().Create(category).Error
 }
 
 func (r *CategoryRepository) UpdateCategory(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *CategoryRepository) DeleteCategoryWithAudit(id uint, userID uint) error {
@@ -80,18 +110,18 @@ func (r *CategoryRepository) DeleteCategoryWithAudit(id uint, userID uint) error
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.Category{}, id).Error
+	return r.db().Delete(&model.Category{}, id).Error
 }
 
 func (r *CategoryRepository) CheckCategoryExists(name string, brandID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.Category{}).Unscoped().Where("name ILIKE ? AND brand_id = ?", name, brandID)
+	query := r.db().Model(&model.Category{}).Unscoped().Where("name ILIKE ? AND brand_id = ?", name, brandID)
 
 	result := query.Count(&count)
 	return count > 0, result.Error
@@ -99,7 +129,7 @@ func (r *CategoryRepository) CheckCategoryExists(name string, brandID uint) (boo
 
 func (r *CategoryRepository) CheckBrandExists(brandID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.Brand{}).Where("id = ?", brandID).Count(&count)
+	result := r.db().Model(&model.Brand{}).Where("id = ?", brandID).Count(&count)
 	return count > 0, result.Error
 }
 
@@ -107,8 +137,8 @@ func (r *CategoryRepository) CheckBrandExists(brandID uint) (bool, error) {
 func (r *CategoryRepository) GetDeletedCategories() ([]categoryWithBrandResponse, error) {
 	var categories []categoryWithBrandResponse
 
-	result := database.DB.Unscoped().Table("categories c").
-		Select("c.id, c.brand_id, c.name, c.description, b.name as brand_name").
+	result := r.db().Unscoped().Table("categories c").
+		Select("c.id, c.brand_id, c.name, c.description, c.version, b.name as brand_name").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id").
 		Where("c.deleted_at IS NOT NULL").
 		Order("c.deleted_at DESC").
@@ -125,5 +155,5 @@ func (r *CategoryRepository) RestoreCategory(id uint, userID uint) error {
 		"deleted_at": nil,
 	}
 
-	return database.DB.Unscoped().Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.Category{}).Where("id = ?", id).Updates(updateData).Error
 }
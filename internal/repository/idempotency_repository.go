@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *IdempotencyRepository) WithTx(tx *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *IdempotencyRepository) WithContext(ctx context.Context) *IdempotencyRepository {
+	return &IdempotencyRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *IdempotencyRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// CreateIdempotencyKey inserts key unless the scope already has it, and reports whether it was inserted
+func (r *IdempotencyRepository) CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	result := r.db().Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *IdempotencyRepository) GetIdempotencyKey(scope, key string) (model.IdempotencyKey, error) {
+	var idempotencyKey model.IdempotencyKey
+	result := r.db().Where("scope = ? AND key = ?", scope, key).First(&idempotencyKey)
+	return idempotencyKey, result.Error
+}
+
+func (r *IdempotencyRepository) UpdateIdempotencyKey(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.IdempotencyKey{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+func (r *IdempotencyRepository) DeleteIdempotencyKey(id uint) error {
+	return r.db().Delete(&model.IdempotencyKey{}, id).Error
+}
+
+// DeleteStaleIdempotencyKey removes a key only while it still has updatedAt, so two retries taking over the same
+// expired or abandoned key do not remove each other's row
+func (r *IdempotencyRepository) DeleteStaleIdempotencyKey(id uint, updatedAt time.Time) error {
+	return r.db().Where("id = ? AND updated_at = ?", id, updatedAt).Delete(&model.IdempotencyKey{}).Error
+}
+
+// DeleteExpiredIdempotencyKeys removes the keys that expired before now and returns how many were removed
+func (r *IdempotencyRepository) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	result := r.db().Where("expires_at < ?", now).Delete(&model.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// ImportRepository resolves the names used in import files to IDs of existing (or just imported) records
+type ImportRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+func NewImportRepository() *ImportRepository {
+	return &ImportRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ImportRepository) WithTx(tx *gorm.DB) *ImportRepository {
+	return &ImportRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ImportRepository) WithContext(ctx context.Context) *ImportRepository {
+	return &ImportRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ImportRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// findID returns the id of the first live row of table matching where, or 0 when there is none
+func (r *ImportRepository) findID(table string, where string, args ...interface{}) (uint, error) {
+	var ids []uint
+	result := r.db().Table(table).
+		Where("deleted_at IS NULL").
+		Where(where, args...).
+		Order("id ASC").
+		Limit(1).
+		Pluck("id", &ids)
+	if result.Error != nil || len(ids) == 0 {
+		return 0, result.Error
+	}
+	return ids[0], nil
+}
+
+func (r *ImportRepository) FindBrandID(name string) (uint, error) {
+	return r.findID("brands", "name ILIKE ?", name)
+}
+
+func (r *ImportRepository) FindCategoryID(brandID uint, name string) (uint, error) {
+	return r.findID("categories", "brand_id = ? AND name ILIKE ?", brandID, name)
+}
+
+func (r *ImportRepository) FindProductID(categoryID uint, name string) (uint, error) {
+	return r.findID("products", "category_id = ? AND name ILIKE ?", categoryID, name)
+}
+
+func (r *ImportRepository) FindProductBatchID(productID uint, codeBatch string) (uint, error) {
+	return r.findID("product_batches", "product_id = ? AND code_batch = ?", productID, codeBatch)
+}
+
+func (r *ImportRepository) FindLocationID(name string) (uint, error) {
+	return r.findID("locations", "name ILIKE ?", name)
+}
+
+func (r *ImportRepository) FindBinID(locationID uint, path string) (uint, error) {
+	return r.findID("sub_locations", "location_id = ? AND level = 'bin' AND path = UPPER(?)", locationID, path)
+}
@@ -1,11 +1,17 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type LocationRepository struct{}
+type LocationRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // locationWithUserResponse struct untuk response dengan user name
 type locationWithUserResponse struct {
@@ -16,30 +22,55 @@ type locationWithUserResponse struct {
 	Address     *string `json:"address"`
 	PhoneNumber *string `json:"phoneNumber"`
 	Type        string  `json:"type"`
+	Version     uint    `json:"version"`
 }
 
 func NewLocationRepository() *LocationRepository {
 	return &LocationRepository{}
 }
 
-func (r *LocationRepository) GetAllLocations() ([]locationWithUserResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *LocationRepository) WithTx(tx *gorm.DB) *LocationRepository {
+	return &LocationRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *LocationRepository) WithContext(ctx context.Context) *LocationRepository {
+	return &LocationRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *LocationRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// locationListColumns are the fields accepted by ?sort= and filters on the location list
+var locationListColumns = utils.ListColumns{
+	"id":         "l.id",
+	"user_id":    "l.user_id",
+	"user_name":  "u.name",
+	"name":       "l.name",
+	"type":       "l.type",
+	"created_at": "l.created_at",
+	"updated_at": "l.updated_at",
+}
+
+func (r *LocationRepository) GetAllLocations(query utils.ListQuery) ([]locationWithUserResponse, int64, error) {
 	var locations []locationWithUserResponse
 
-	result := database.DB.Table("locations l").
-		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type").
+	db := r.db().Table("locations l").
+		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type, l.version").
 		Joins("INNER JOIN users u ON l.user_id = u.id AND u.deleted_at IS NULL").
-		Where("l.deleted_at IS NULL").
-		Order("l.name ASC").
-		Find(&locations)
+		Where("l.deleted_at IS NULL")
 
-	return locations, result.Error
+	total, err := utils.FindPage(db, query, locationListColumns, "l.name ASC", &locations)
+	return locations, total, err
 }
 
 func (r *LocationRepository) GetLocationsByUser(userID uint) ([]locationWithUserResponse, error) {
 	var locations []locationWithUserResponse
 
-	result := database.DB.Table("locations l").
-		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type").
+	result := r.db().Table("locations l").
+		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type, l.version").
 		Joins("INNER JOIN users u ON l.user_id = u.id AND u.deleted_at IS NULL").
 		Where("l.user_id = ? AND l.deleted_at IS NULL", userID).
 		Order("l.name ASC").
@@ -48,11 +79,18 @@ func (r *LocationRepository) GetLocationsByUser(userID uint) ([]locationWithUser
 	return locations, result.Error
 }
 
+// GetLocationIDsByUser returns the IDs of the locations assigned to a user
+func (r *LocationRepository) GetLocationIDsByUser(userID uint) ([]uint, error) {
+	var ids []uint
+	result := r.db().Model(&model.Location{}).Where("user_id = ?", userID).Order("id ASC").Pluck("id", &ids)
+	return ids, result.Error
+}
+
 func (r *LocationRepository) GetLocationsByType(locationType string) ([]locationWithUserResponse, error) {
 	var locations []locationWithUserResponse
 
-	result := database.DB.Table("locations l").
-		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type").
+	result := r.db().Table("locations l").
+		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type, l.version").
 		Joins("INNER JOIN users u ON l.user_id = u.id AND u.deleted_at IS NULL").
 		Where("l.type = ? AND l.deleted_at IS NULL", locationType).
 		Order("l.name ASC").
@@ -64,8 +102,8 @@ func (r *LocationRepository) GetLocationsByType(locationType string) ([]location
 func (r *LocationRepository) GetLocationByID(id uint) (locationWithUserResponse, error) {
 	var location locationWithUserResponse
 
-	result := database.DB.Table("locations l").
-		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type").
+	result := r.db().Table("locations l").
+		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type, l.version").
 		Joins("INNER JOIN users u ON l.user_id = u.id AND u.deleted_at IS NULL").
 		Where("l.id = ? AND l.deleted_at IS NULL", id).
 		First(&location)
@@ -76,16 +114,16 @@ func (r *LocationRepository) GetLocationByID(id uint) (locationWithUserResponse,
 // GetLocationModelByID returns model.Location for service operations
 func (r *LocationRepository) GetLocationModelByID(id uint) (model.Location, error) {
 	var location model.Location
-	result := database.DB.Where("id = ?", id).First(&location)
+	result := r.db().Where("id = ?", id).First(&location)
 	return location, result.Error
 }
 
 func (r *LocationRepository) CreateLocation(location *model.Location) error {
-	return database.DB.Create(location).Error
+	return r.db().Create(location).Error
 }
 
 func (r *LocationRepository) UpdateLocation(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *LocationRepository) DeleteLocationWithAudit(id uint, userID uint) error {
@@ -95,24 +133,24 @@ func (r *LocationRepository) DeleteLocationWithAudit(id uint, userID uint) error
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.Location{}, id).Error
+	return r.db().Delete(&model.Location{}, id).Error
 }
 
 func (r *LocationRepository) CheckUserExists(userID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.User{}).Where("id = ?", userID).Count(&count)
+	result := r.db().Model(&model.User{}).Where("id = ?", userID).Count(&count)
 	return count > 0, result.Error
 }
 
 func (r *LocationRepository) CheckLocationNameExists(userID uint, name string, excludeID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.Location{}).
+	query := r.db().Model(&model.Location{}).
 		Where("user_id = ? AND name = ?", userID, name)
 
 	if excludeID > 0 {
@@ -127,8 +165,8 @@ func (r *LocationRepository) CheckLocationNameExists(userID uint, name string, e
 func (r *LocationRepository) GetDeletedLocations() ([]locationWithUserResponse, error) {
 	var locations []locationWithUserResponse
 
-	result := database.DB.Table("locations l").
-		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type").
+	result := r.db().Table("locations l").
+		Select("l.id, l.user_id, u.name as user_name, l.name, l.address, l.phone_number, l.type, l.version").
 		Joins("INNER JOIN users u ON l.user_id = u.id").
 		Where("l.deleted_at IS NOT NULL").
 		Order("l.deleted_at DESC").
@@ -143,5 +181,5 @@ func (r *LocationRepository) RestoreLocation(id uint, userID uint) error {
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.Location{}).Where("id = ?", id).Updates(updateData).Error
 }
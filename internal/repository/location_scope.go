@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/access"
+	"myapp/internal/model"
+	"myapp/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+// ErrLocationNotAssigned is returned when a reseller user writes to a location they are not assigned to
+var ErrLocationNotAssigned = apperror.Forbidden("location_not_assigned", "location is not assigned to this user")
+
+// assignedLocationsSQL selects the locations assigned to a user
+const assignedLocationsSQL = "SELECT id FROM locations WHERE user_id = ? AND deleted_at IS NULL"
+
+// locationScope limits a query to rows whose column is one of the locations the reseller user of ctx is assigned to;
+// queries without a location owner in ctx (admins, background jobs) are left alone
+func locationScope(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		ownerID, ok := access.LocationOwner(ctx)
+		if !ok {
+			return db
+		}
+		return db.Where(column+" IN ("+assignedLocationsSQL+")", ownerID)
+	}
+}
+
+// stockLocationScope is locationScope for rows that point at a product stock instead of a location
+func stockLocationScope(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		ownerID, ok := access.LocationOwner(ctx)
+		if !ok {
+			return db
+		}
+		return db.Where(column+" IN (SELECT id FROM product_stocks WHERE location_id IN ("+assignedLocationsSQL+"))", ownerID)
+	}
+}
+
+// unitLocationScope is locationScope for rows that point at a product unit instead of a location
+func unitLocationScope(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		ownerID, ok := access.LocationOwner(ctx)
+		if !ok {
+			return db
+		}
+		return db.Where(column+" IN (SELECT id FROM product_units WHERE location_id IN ("+assignedLocationsSQL+"))", ownerID)
+	}
+}
+
+// locationCondition is locationScope for raw queries with named arguments: it returns a condition limiting column to
+// the locations of ctx, adding the @location_owner argument it needs to args, or TRUE when ctx is not limited
+func locationCondition(ctx context.Context, column string, args map[string]interface{}) string {
+	ownerID, ok := access.LocationOwner(ctx)
+	if !ok {
+		return "TRUE"
+	}
+	args["location_owner"] = ownerID
+	return column + " IN (SELECT id FROM locations WHERE user_id = @location_owner AND deleted_at IS NULL)"
+}
+
+// checkLocationAssigned returns ErrLocationNotAssigned when ctx is limited to locations that do not include locationID
+func checkLocationAssigned(db *gorm.DB, ctx context.Context, locationID uint) error {
+	ownerID, ok := access.LocationOwner(ctx)
+	if !ok {
+		return nil
+	}
+
+	var count int64
+	err := db.Model(&model.Location{}).Where("id = ? AND user_id = ?", locationID, ownerID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLocationNotAssigned
+	}
+	return nil
+}
+
+// checkUpdatedLocation applies checkLocationAssigned to the location_id of updateData, when it is being changed
+func checkUpdatedLocation(db *gorm.DB, ctx context.Context, updateData map[string]interface{}) error {
+	switch locationID := updateData["location_id"].(type) {
+	case uint:
+		return checkLocationAssigned(db, ctx, locationID)
+	case *uint:
+		if locationID != nil {
+			return checkLocationAssigned(db, ctx, *locationID)
+		}
+	}
+	return nil
+}
+
+// checkStockAssigned is checkLocationAssigned for the location of a product stock
+func checkStockAssigned(db *gorm.DB, ctx context.Context, stockID uint) error {
+	if _, ok := access.LocationOwner(ctx); !ok {
+		return nil
+	}
+
+	var stock model.ProductStock
+	if err := db.Select("id, location_id").Where("id = ?", stockID).First(&stock).Error; err != nil {
+		return err
+	}
+	return checkLocationAssigned(db, ctx, stock.LocationID)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MetricsRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+// TenantTotal is an inventory total of one tenant, TenantID is nil for rows without a tenant
+type TenantTotal struct {
+	TenantID *uint
+	Total    float64
+}
+
+func NewMetricsRepository() *MetricsRepository {
+	return &MetricsRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *MetricsRepository) WithTx(tx *gorm.DB) *MetricsRepository {
+	return &MetricsRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *MetricsRepository) WithContext(ctx context.Context) *MetricsRepository {
+	return &MetricsRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *MetricsRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// GetStockValueByTenant returns the value of the stock on hand, quantity times the unit price of its batch, per tenant.
+// Stock of batches without a unit price counts as 0.
+func (r *MetricsRepository) GetStockValueByTenant() ([]TenantTotal, error) {
+	var totals []TenantTotal
+
+	result := r.db().Model(&model.ProductStock{}).
+		Select("product_stocks.tenant_id, COALESCE(SUM(product_stocks.quantity * COALESCE(pb.unit_price, 0)), 0) as total").
+		Joins("INNER JOIN product_batches pb ON pb.id = product_stocks.product_batch_id AND pb.deleted_at IS NULL").
+		Group("product_stocks.tenant_id").
+		Scan(&totals)
+
+	return totals, result.Error
+}
+
+// CountExpiredBatchesByTenant returns per tenant how many batches are past their expiry date on today and still
+// have stock on hand
+func (r *MetricsRepository) CountExpiredBatchesByTenant(today time.Time) ([]TenantTotal, error) {
+	var totals []TenantTotal
+
+	result := r.db().Model(&model.ProductBatch{}).
+		Select("product_batches.tenant_id, COUNT(*) as total").
+		Where("product_batches.exp_date < ?", today).
+		Where("EXISTS (SELECT 1 FROM product_stocks ps WHERE ps.product_batch_id = product_batches.id AND ps.deleted_at IS NULL AND ps.quantity > 0)").
+		Group("product_batches.tenant_id").
+		Scan(&totals)
+
+	return totals, result.Error
+}
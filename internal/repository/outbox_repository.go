@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *OutboxRepository) WithTx(tx *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *OutboxRepository) WithContext(ctx context.Context) *OutboxRepository {
+	return &OutboxRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *OutboxRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+func (r *OutboxRepository) CreateOutboxEvent(event *model.OutboxEvent) error {
+	return r.db().Create(event).Error
+}
+
+// ClaimPendingOutboxEvents locks up to limit due events in the order they were written and pushes their next attempt
+// back by lease, so another relay does not publish them again while they are in flight
+func (r *OutboxRepository) ClaimPendingOutboxEvents(now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&model.OutboxEvent{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.OutboxEventPending, now).
+			Order("id ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		err = tx.Model(&model.OutboxEvent{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("id IN ?", ids).Order("id ASC").Find(&events).Error
+	})
+
+	return events, err
+}
+
+func (r *OutboxRepository) UpdateOutboxEvent(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.OutboxEvent{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+// DeletePublishedOutboxEvents removes up to limit events published before cutoff and returns how many were removed
+func (r *OutboxRepository) DeletePublishedOutboxEvents(cutoff time.Time, limit int) (int64, error) {
+	ids := r.db().Model(&model.OutboxEvent{}).Select("id").
+		Where("status = ? AND published_at < ?", model.OutboxEventPublished, cutoff).
+		Order("id ASC").
+		Limit(limit)
+	result := r.db().Where("id IN (?)", ids).Delete(&model.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// outboxEventListColumns are the fields accepted by ?sort= and filters on the outbox list
+var outboxEventListColumns = utils.ListColumns{
+	"id":             "id",
+	"event_type":     "event_type",
+	"aggregate_type": "aggregate_type",
+	"aggregate_id":   "aggregate_id",
+	"status":         "status",
+	"attempts":       "attempts",
+	"created_at":     "created_at",
+	"published_at":   "published_at",
+}
+
+// outboxEventResponse struct untuk response outbox list
+type outboxEventResponse struct {
+	ID             uint       `json:"id"`
+	EventType      string     `json:"eventType"`
+	AggregateType  string     `json:"aggregateType"`
+	AggregateID    uint       `json:"aggregateId"`
+	Payload        string     `json:"payload"`
+	UserID         *uint      `json:"userId"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt"`
+	PublishedSinks string     `json:"publishedSinks"`
+	LastError      *string    `json:"lastError"`
+	PublishedAt    *time.Time `json:"publishedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func (r *OutboxRepository) GetAllOutboxEvents(query utils.ListQuery) ([]outboxEventResponse, int64, error) {
+	var events []outboxEventResponse
+
+	db := r.db().Table("outbox_events").
+		Select("id, event_type, aggregate_type, aggregate_id, payload, user_id, status, attempts, next_attempt_at, " +
+			"published_sinks, last_error, published_at, created_at")
+
+	total, err := utils.FindPage(db, query, outboxEventListColumns, "id DESC", &events)
+	return events, total, err
+}
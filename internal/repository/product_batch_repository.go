@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductBatchRepository struct{}
+type ProductBatchRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productBatchWithDetailsResponse struct untuk response dengan product, category, dan brand name
 type productBatchWithDetailsResponse struct {
@@ -21,32 +27,63 @@ type productBatchWithDetailsResponse struct {
 	CodeBatch    string    `json:"codeBatch"`
 	ExpDate      time.Time `json:"expDate"` // Hidden dari JSON
 	Description  *string   `json:"description"`
+	Status       string    `json:"status"`
+	StatusReason *string   `json:"statusReason"`
+	Version      uint      `json:"version"`
 }
 
 func NewProductBatchRepository() *ProductBatchRepository {
 	return &ProductBatchRepository{}
 }
 
-func (r *ProductBatchRepository) GetAllProductBatches() ([]productBatchWithDetailsResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductBatchRepository) WithTx(tx *gorm.DB) *ProductBatchRepository {
+	return &ProductBatchRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductBatchRepository) WithContext(ctx context.Context) *ProductBatchRepository {
+	return &ProductBatchRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductBatchRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productBatchListColumns are the fields accepted by ?sort= and filters on the product batch list
+var productBatchListColumns = utils.ListColumns{
+	"id":           "pb.id",
+	"product_id":   "pb.product_id",
+	"product_name": "p.name",
+	"category_id":  "c.id",
+	"brand_id":     "b.id",
+	"code_batch":   "pb.code_batch",
+	"unit_price":   "pb.unit_price",
+	"exp_date":     "pb.exp_date",
+	"status":       "pb.status",
+	"created_at":   "pb.created_at",
+	"updated_at":   "pb.updated_at",
+}
+
+func (r *ProductBatchRepository) GetAllProductBatches(query utils.ListQuery) ([]productBatchWithDetailsResponse, int64, error) {
 	var batches []productBatchWithDetailsResponse
 
-	result := database.DB.Table("product_batches pb").
-		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name, pb.unit_price,pb.code_batch, pb.exp_date, pb.description").
+	db := r.db().Table("product_batches pb").
+		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name, pb.unit_price,pb.code_batch, pb.exp_date, pb.description, pb.status, pb.status_reason, pb.version").
 		Joins("LEFT JOIN products p ON pb.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
-		Where("pb.deleted_at IS NULL").
-		Order("pb.code_batch ASC").
-		Find(&batches)
+		Where("pb.deleted_at IS NULL")
 
-	return batches, result.Error
+	total, err := utils.FindPage(db, query, productBatchListColumns, "pb.code_batch ASC", &batches)
+	return batches, total, err
 }
 
 func (r *ProductBatchRepository) GetProductBatchesByProduct(productID uint) ([]productBatchWithDetailsResponse, error) {
 	var batches []productBatchWithDetailsResponse
 
-	result := database.DB.Table("product_batches pb").
-		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description").
+	result := r.db().Table("product_batches pb").
+		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description, pb.status, pb.status_reason, pb.version").
 		Joins("INNER JOIN products p ON pb.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
@@ -60,8 +97,8 @@ func (r *ProductBatchRepository) GetProductBatchesByProduct(productID uint) ([]p
 func (r *ProductBatchRepository) GetProductBatchByID(id uint) (productBatchWithDetailsResponse, error) {
 	var batch productBatchWithDetailsResponse
 
-	result := database.DB.Table("product_batches pb").
-		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description").
+	result := r.db().Table("product_batches pb").
+		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description, pb.status, pb.status_reason, pb.version").
 		Joins("INNER JOIN products p ON pb.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
@@ -74,16 +111,16 @@ func (r *ProductBatchRepository) GetProductBatchByID(id uint) (productBatchWithD
 // GetProductBatchModelByID returns model.ProductBatch for service operations
 func (r *ProductBatchRepository) GetProductBatchModelByID(id uint) (model.ProductBatch, error) {
 	var batch model.ProductBatch
-	result := database.DB.Where("id = ?", id).First(&batch)
+	result := r.db().Where("id = ?", id).First(&batch)
 	return batch, result.Error
 }
 
 func (r *ProductBatchRepository) CreateProductBatch(batch *model.ProductBatch) error {
-	return database.DB.Create(batch).Error
+	return r.db().Create(batch).Error
 }
 
 func (r *ProductBatchRepository) UpdateProductBatch(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductBatchRepository) DeleteProductBatchWithAudit(id uint, userID uint) error {
@@ -93,18 +130,18 @@ func (r *ProductBatchRepository) DeleteProductBatchWithAudit(id uint, userID uin
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductBatch{}, id).Error
+	return r.db().Delete(&model.ProductBatch{}, id).Error
 }
 
 func (r *ProductBatchRepository) CheckProductExists(productID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.Product{}).Where("id = ?", productID).Count(&count)
+	result := r.db().Model(&model.Product{}).Where("id = ?", productID).Count(&count)
 	return count > 0, result.Error
 }
 
@@ -112,8 +149,8 @@ func (r *ProductBatchRepository) CheckProductExists(productID uint) (bool, error
 func (r *ProductBatchRepository) GetDeletedProductBatches() ([]productBatchWithDetailsResponse, error) {
 	var batches []productBatchWithDetailsResponse
 
-	result := database.DB.Table("product_batches pb").
-		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description").
+	result := r.db().Table("product_batches pb").
+		Select("pb.id, pb.product_id, p.name as product_name, c.id as category_id, c.name as category_name, b.id as brand_id, b.name as brand_name,pb.unit_price, pb.code_batch, pb.exp_date, pb.description, pb.status, pb.status_reason, pb.version").
 		Joins("LEFT JOIN products p ON pb.product_id = p.id").
 		Joins("LEFT JOIN categories c ON p.category_id = c.id").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id").
@@ -130,5 +167,164 @@ func (r *ProductBatchRepository) RestoreProductBatch(id uint, userID uint) error
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.ProductBatch{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+// BatchTraceHeader identifies the traced batch, including soft deleted batches so recalls can still be reported
+type BatchTraceHeader struct {
+	ID           uint       `json:"id"`
+	CodeBatch    *string    `json:"codeBatch"`
+	ProductID    uint       `json:"productId"`
+	ProductName  string     `json:"productName"`
+	CategoryName string     `json:"categoryName"`
+	BrandName    string     `json:"brandName"`
+	UnitPrice    *float64   `json:"unitPrice"`
+	ExpDate      time.Time  `json:"expDate"`
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
+}
+
+// BatchTraceLocation is one product stock (batch at a location) with the quantities moved through it
+type BatchTraceLocation struct {
+	ProductStockID  uint       `json:"productStockId"`
+	LocationID      uint       `json:"locationId"`
+	LocationName    string     `json:"locationName"`
+	LocationType    string     `json:"locationType"`
+	QuantityIn      float64    `json:"quantityIn"`
+	QuantityOut     float64    `json:"quantityOut"`
+	ItemsIn         float64    `json:"itemsIn"`
+	ItemsOut        float64    `json:"itemsOut"`
+	CurrentQuantity float64    `json:"currentQuantity"`
+	FirstMovement   *time.Time `json:"firstMovement"`
+	LastMovement    *time.Time `json:"lastMovement"`
+	Deleted         bool       `json:"deleted"`
+}
+
+// BatchTraceMovement is a stock track or item track row of the batch
+type BatchTraceMovement struct {
+	Source         string    `json:"source"` // stock, item
+	ID             uint      `json:"id"`
+	ProductStockID uint      `json:"productStockId"`
+	LocationName   string    `json:"locationName"`
+	Date           time.Time `json:"date"`
+	Operation      string    `json:"operation"`
+	Quantity       float64   `json:"quantity"`
+	Stock          float64   `json:"stock"`
+	UserID         *uint     `json:"userId"`
+	UserName       *string   `json:"userName"`
+}
+
+// BatchTraceUser is a user who created or changed any stock, item or track row of the batch
+type BatchTraceUser struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Actions int64  `json:"actions"`
+}
+
+func (r *ProductBatchRepository) GetBatchTraceHeader(batchID uint) (BatchTraceHeader, error) {
+	var header BatchTraceHeader
+
+	result := r.db().Table("product_batches pb").
+		Select("pb.id, pb.code_batch, pb.product_id, p.name as product_name, c.name as category_name, b.name as brand_name, pb.unit_price, pb.exp_date, pb.deleted_at").
+		Joins("LEFT JOIN products p ON pb.product_id = p.id").
+		Joins("LEFT JOIN categories c ON p.category_id = c.id").
+		Joins("LEFT JOIN brands b ON c.brand_id = b.id").
+		Where("pb.id = ?", batchID).
+		Take(&header)
+
+	return header, result.Error
+}
+
+// GetBatchTraceLocations returns every product stock that ever held the batch, deleted ones included, in the locations
+// ctx is limited to
+func (r *ProductBatchRepository) GetBatchTraceLocations(batchID uint) ([]BatchTraceLocation, error) {
+	var locations []BatchTraceLocation
+
+	args := map[string]interface{}{"batch": batchID}
+	query := `
+		SELECT ps.id AS product_stock_id, ps.location_id, COALESCE(l.name, '') AS location_name, COALESCE(l.type, '') AS location_type,
+			COALESCE(st.quantity_in, 0) AS quantity_in, COALESCE(st.quantity_out, 0) AS quantity_out,
+			COALESCE(it.items_in, 0) AS items_in, COALESCE(it.items_out, 0) AS items_out,
+			COALESCE(ps.quantity, 0) AS current_quantity,
+			st.first_movement, st.last_movement,
+			ps.deleted_at IS NOT NULL AS deleted
+		FROM product_stocks ps
+		LEFT JOIN locations l ON ps.location_id = l.id
+		LEFT JOIN (
+			SELECT product_stock_id,
+				SUM(CASE WHEN operation IN ('Plus', 'In') THEN quantity ELSE 0 END) AS quantity_in,
+				SUM(CASE WHEN operation IN ('Minus', 'Out') THEN quantity ELSE 0 END) AS quantity_out,
+				MIN(date) AS first_movement, MAX(date) AS last_movement
+			FROM product_stock_tracks
+			WHERE product_batch_id = @batch AND deleted_at IS NULL
+			GROUP BY product_stock_id
+		) st ON st.product_stock_id = ps.id
+		LEFT JOIN (
+			SELECT product_stock_id, SUM(COALESCE(stock_in, 0)) AS items_in, SUM(COALESCE(stock_out, 0)) AS items_out
+			FROM product_items
+			WHERE product_batch_id = @batch AND deleted_at IS NULL
+			GROUP BY product_stock_id
+		) it ON it.product_stock_id = ps.id
+		WHERE ps.product_batch_id = @batch AND ` + locationCondition(r.ctx, "ps.location_id", args) + `
+		ORDER BY location_name ASC, ps.id ASC
+	`
+
+	result := r.db().Raw(query, args).Scan(&locations)
+	return locations, result.Error
+}
+
+// GetBatchTraceMovements returns the stock and item tracks of the batch in chronological order, in the locations ctx is
+// limited to
+func (r *ProductBatchRepository) GetBatchTraceMovements(batchID uint) ([]BatchTraceMovement, error) {
+	var movements []BatchTraceMovement
+
+	args := map[string]interface{}{"batch": batchID}
+	inLocations := locationCondition(r.ctx, "ps.location_id", args)
+	query := `
+		SELECT 'stock' AS source, pst.id, pst.product_stock_id, COALESCE(l.name, '') AS location_name,
+			pst.date, pst.operation, pst.quantity, pst.stock, u.id AS user_id, u.name AS user_name
+		FROM product_stock_tracks pst
+		LEFT JOIN product_stocks ps ON pst.product_stock_id = ps.id
+		LEFT JOIN locations l ON ps.location_id = l.id
+		LEFT JOIN users u ON u.id = COALESCE(pst.user_updt, pst.user_ins)
+		WHERE pst.product_batch_id = @batch AND pst.deleted_at IS NULL AND ` + inLocations + `
+		UNION ALL
+		SELECT 'item' AS source, pit.id, pit.product_stock_id, COALESCE(l.name, '') AS location_name,
+			pit.date, pit.operation, pit.quantity, pit.stock, u.id AS user_id, u.name AS user_name
+		FROM product_item_tracks pit
+		LEFT JOIN product_stocks ps ON pit.product_stock_id = ps.id
+		LEFT JOIN locations l ON ps.location_id = l.id
+		LEFT JOIN users u ON u.id = COALESCE(pit.user_updt, pit.user_ins)
+		WHERE pit.product_batch_id = @batch AND pit.deleted_at IS NULL AND ` + inLocations + `
+		ORDER BY date ASC, source ASC, id ASC
+	`
+
+	result := r.db().Raw(query, args).Scan(&movements)
+	return movements, result.Error
+}
+
+// GetBatchTraceUsers returns every user recorded in the audit fields of the batch's stocks, items and tracks
+func (r *ProductBatchRepository) GetBatchTraceUsers(batchID uint) ([]BatchTraceUser, error) {
+	var users []BatchTraceUser
+
+	query := `
+		SELECT u.id, u.name, u.email, COUNT(*) AS actions
+		FROM (
+			SELECT user_ins AS user_id FROM product_stocks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_updt FROM product_stocks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_ins FROM product_stock_tracks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_updt FROM product_stock_tracks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_ins FROM product_items WHERE product_batch_id = @batch
+			UNION ALL SELECT user_updt FROM product_items WHERE product_batch_id = @batch
+			UNION ALL SELECT user_ins FROM product_item_tracks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_updt FROM product_item_tracks WHERE product_batch_id = @batch
+			UNION ALL SELECT user_inst FROM product_batch_tracks WHERE product_batch_id = @batch
+		) touched
+		INNER JOIN users u ON u.id = touched.user_id
+		GROUP BY u.id, u.name, u.email
+		ORDER BY actions DESC, u.name ASC
+	`
+
+	result := r.db().Raw(query, map[string]interface{}{"batch": batchID}).Scan(&users)
+	return users, result.Error
 }
@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductItemRepository struct{}
+type ProductItemRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productItemResponse struct untuk response dengan relasi detail
 type productItemResponse struct {
@@ -21,37 +27,68 @@ type productItemResponse struct {
 	StockIn          *float64 `json:"stockIn"`
 	StockOut         *float64 `json:"stockOut"`
 	Quantity         *float64 `json:"quantity"`
+	Version          uint     `json:"version"`
 }
 
 func NewProductItemRepository() *ProductItemRepository {
 	return &ProductItemRepository{}
 }
 
-func (r *ProductItemRepository) GetAllProductItems() ([]productItemResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductItemRepository) WithTx(tx *gorm.DB) *ProductItemRepository {
+	return &ProductItemRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductItemRepository) WithContext(ctx context.Context) *ProductItemRepository {
+	return &ProductItemRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductItemRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productItemListColumns are the fields accepted by ?sort= and filters on the product item list
+var productItemListColumns = utils.ListColumns{
+	"id":               "pi.id",
+	"product_stock_id": "pi.product_stock_id",
+	"product_id":       "pi.product_id",
+	"product_name":     "p.name",
+	"product_batch_id": "pi.product_batch_id",
+	"location_id":      "ps.location_id",
+	"stock_in":         "pi.stock_in",
+	"stock_out":        "pi.stock_out",
+	"quantity":         "pi.quantity",
+	"created_at":       "pi.created_at",
+	"updated_at":       "pi.updated_at",
+}
+
+func (r *ProductItemRepository) GetAllProductItems(query utils.ListQuery) ([]productItemResponse, int64, error) {
 	var items []productItemResponse
 
-	result := database.DB.Table("product_items pi").
-		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity").
+	db := r.db().Table("product_items pi").
+		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity, pi.version").
 		Joins("INNER JOIN product_stocks ps ON pi.product_stock_id = ps.id AND ps.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pi.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
-		Where("pi.deleted_at IS NULL").
-		Order("pi.created_at DESC").
-		Find(&items)
+		Scopes(locationScope(r.ctx, "ps.location_id")).
+		Where("pi.deleted_at IS NULL")
 
-	return items, result.Error
+	total, err := utils.FindPage(db, query, productItemListColumns, "pi.created_at DESC", &items)
+	return items, total, err
 }
 
 func (r *ProductItemRepository) GetProductItemsByStock(stockID uint) ([]productItemResponse, error) {
 	var items []productItemResponse
 
-	result := database.DB.Table("product_items pi").
-		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity").
+	result := r.db().Table("product_items pi").
+		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity, pi.version").
 		Joins("INNER JOIN product_stocks ps ON pi.product_stock_id = ps.id AND ps.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pi.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("pi.deleted_at IS NULL AND pi.product_stock_id = ?", stockID).
 		Order("pi.created_at DESC").
 		Find(&items)
@@ -62,12 +99,13 @@ func (r *ProductItemRepository) GetProductItemsByStock(stockID uint) ([]productI
 func (r *ProductItemRepository) GetProductItemsByProduct(productID uint) ([]productItemResponse, error) {
 	var items []productItemResponse
 
-	result := database.DB.Table("product_items pi").
-		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity").
+	result := r.db().Table("product_items pi").
+		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity, pi.version").
 		Joins("INNER JOIN product_stocks ps ON pi.product_stock_id = ps.id AND ps.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pi.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("pi.deleted_at IS NULL AND pi.product_id = ?", productID).
 		Order("pi.created_at DESC").
 		Find(&items)
@@ -78,12 +116,13 @@ func (r *ProductItemRepository) GetProductItemsByProduct(productID uint) ([]prod
 func (r *ProductItemRepository) GetProductItemsByLocation(locationID uint) ([]productItemResponse, error) {
 	var items []productItemResponse
 
-	result := database.DB.Table("product_items pi").
-		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity").
+	result := r.db().Table("product_items pi").
+		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity, pi.version").
 		Joins("INNER JOIN product_stocks ps ON pi.product_stock_id = ps.id AND ps.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pi.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("pi.deleted_at IS NULL AND pi.location_id = ?", locationID).
 		Order("pi.created_at DESC").
 		Find(&items)
@@ -94,12 +133,13 @@ func (r *ProductItemRepository) GetProductItemsByLocation(locationID uint) ([]pr
 func (r *ProductItemRepository) GetProductItemByID(id uint) (productItemResponse, error) {
 	var item productItemResponse
 
-	result := database.DB.Table("product_items pi").
-		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity").
+	result := r.db().Table("product_items pi").
+		Select("pi.id, pi.product_stock_id, pi.product_id, p.name as product_name, pi.product_batch_id, pb.code_batch as product_batch_code, ps.location_id, l.name as location_name, pi.stock_in, pi.stock_out, pi.quantity, pi.version").
 		Joins("INNER JOIN product_stocks ps ON pi.product_stock_id = ps.id AND ps.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pi.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("pi.deleted_at IS NULL AND pi.id = ?", id).
 		First(&item)
 
@@ -109,16 +149,19 @@ func (r *ProductItemRepository) GetProductItemByID(id uint) (productItemResponse
 // GetProductItemModelByID returns model.ProductItem for service operations
 func (r *ProductItemRepository) GetProductItemModelByID(id uint) (model.ProductItem, error) {
 	var item model.ProductItem
-	result := database.DB.Where("id = ?", id).First(&item)
+	result := r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).First(&item)
 	return item, result.Error
 }
 
 func (r *ProductItemRepository) CreateProductItem(item *model.ProductItem) error {
-	return database.DB.Create(item).Error
+	if err := checkStockAssigned(r.db(), r.ctx, item.ProductStockID); err != nil {
+		return err
+	}
+	return r.db().Create(item).Error
 }
 
 func (r *ProductItemRepository) UpdateProductItem(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductItem{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.ProductItem{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductItemRepository) DeleteProductItemWithAudit(id uint, userID uint) error {
@@ -129,18 +172,18 @@ func (r *ProductItemRepository) DeleteProductItemWithAudit(id uint, userID uint)
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductItem{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductItem{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductItem{}, id).Error
+	return r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Delete(&model.ProductItem{}, id).Error
 }
 
 func (r *ProductItemRepository) CheckProductStockExists(stockID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductStock{}).Where("id = ?", stockID).Count(&count)
+	result := r.db().Model(&model.ProductStock{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", stockID).Count(&count)
 	return count > 0, result.Error
 }
 
@@ -148,9 +191,10 @@ func (r *ProductItemRepository) CheckProductStockExists(stockID uint) (bool, err
 func (r *ProductItemRepository) GetItemsSummaryByProduct() ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
-	rows, err := database.DB.Table("product_items pi").
+	rows, err := r.db().Table("product_items pi").
 		Select("pi.product_id, p.name as product_name, SUM(pi.stock_in) as total_stock_in, SUM(pi.stock_out) as total_stock_out, SUM(pi.quantity) as total_quantity").
 		Joins("INNER JOIN products p ON pi.product_id = p.id AND p.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pi.product_stock_id")).
 		Where("pi.deleted_at IS NULL").
 		Group("pi.product_id, p.name").
 		Order("p.name ASC").
@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductItemTrackRepository struct{}
+type ProductItemTrackRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productItemTrackResponse struct untuk response dengan relasi detail
 type productItemTrackResponse struct {
@@ -22,22 +28,68 @@ type productItemTrackResponse struct {
 	Quantity         *float64  `json:"quantity"`
 	Operation        string    `json:"operation"`
 	Stock            *float64  `json:"stock"`
+	Version          uint      `json:"version"`
 }
 
 func NewProductItemTrackRepository() *ProductItemTrackRepository {
 	return &ProductItemTrackRepository{}
 }
 
-func (r *ProductItemTrackRepository) GetAllProductItemTracks() ([]productItemTrackResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductItemTrackRepository) WithTx(tx *gorm.DB) *ProductItemTrackRepository {
+	return &ProductItemTrackRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductItemTrackRepository) WithContext(ctx context.Context) *ProductItemTrackRepository {
+	return &ProductItemTrackRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductItemTrackRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productItemTrackListColumns are the fields accepted by ?sort= and filters on the product item track list
+var productItemTrackListColumns = utils.ListColumns{
+	"id":               "pit.id",
+	"product_stock_id": "pit.product_stock_id",
+	"product_id":       "pit.product_id",
+	"product_name":     "p.name",
+	"product_batch_id": "pit.product_batch_id",
+	"date":             "pit.date",
+	"quantity":         "pit.quantity",
+	"operation":        "pit.operation",
+	"stock":            "pit.stock",
+	"created_at":       "pit.created_at",
+}
+
+func (r *ProductItemTrackRepository) GetAllProductItemTracks(query utils.ListQuery) ([]productItemTrackResponse, int64, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	db := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
-		Where("pit.deleted_at IS NULL").
-		Order("pit.date_track DESC").
-		Find(&tracks)
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
+		Where("pit.deleted_at IS NULL")
+
+	total, err := utils.FindPage(db, query, productItemTrackListColumns, "pit.date DESC", &tracks)
+	return tracks, total, err
+}
+
+// GetProductItemTracksByCursor returns up to limit tracks after cursor in (date, id) order, newest first unless ascending
+func (r *ProductItemTrackRepository) GetProductItemTracksByCursor(query utils.ListQuery, cursor *utils.TrackCursor, limit int, ascending bool) ([]productItemTrackResponse, error) {
+	var tracks []productItemTrackResponse
+
+	db := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
+		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
+		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
+		Where("pit.deleted_at IS NULL")
+
+	db = query.ApplyFilters(db, productItemTrackListColumns)
+	result := utils.ApplyKeyset(db, "pit.date", "pit.id", cursor, limit, ascending).Find(&tracks)
 
 	return tracks, result.Error
 }
@@ -45,12 +97,13 @@ func (r *ProductItemTrackRepository) GetAllProductItemTracks() ([]productItemTra
 func (r *ProductItemTrackRepository) GetProductItemTracksByItem(itemID uint) ([]productItemTrackResponse, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.product_item_id = ?", itemID).
-		Order("pit.date_track DESC").
+		Order("pit.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -59,12 +112,13 @@ func (r *ProductItemTrackRepository) GetProductItemTracksByItem(itemID uint) ([]
 func (r *ProductItemTrackRepository) GetProductItemTracksByStock(stockID uint) ([]productItemTrackResponse, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.product_stock_id = ?", stockID).
-		Order("pit.date_track DESC").
+		Order("pit.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -73,12 +127,13 @@ func (r *ProductItemTrackRepository) GetProductItemTracksByStock(stockID uint) (
 func (r *ProductItemTrackRepository) GetProductItemTracksByProduct(productID uint) ([]productItemTrackResponse, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.product_id = ?", productID).
-		Order("pit.date_track DESC").
+		Order("pit.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -87,12 +142,13 @@ func (r *ProductItemTrackRepository) GetProductItemTracksByProduct(productID uin
 func (r *ProductItemTrackRepository) GetProductItemTracksByDateRange(startDate, endDate time.Time) ([]productItemTrackResponse, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
-		Where("pit.deleted_at IS NULL AND pit.date_track BETWEEN ? AND ?", startDate, endDate).
-		Order("pit.date_track DESC").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
+		Where("pit.deleted_at IS NULL AND pit.date BETWEEN ? AND ?", startDate, endDate).
+		Order("pit.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -101,10 +157,11 @@ func (r *ProductItemTrackRepository) GetProductItemTracksByDateRange(startDate,
 func (r *ProductItemTrackRepository) GetProductItemTrackByID(id uint) (productItemTrackResponse, error) {
 	var track productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.id = ?", id).
 		First(&track)
 
@@ -114,16 +171,19 @@ func (r *ProductItemTrackRepository) GetProductItemTrackByID(id uint) (productIt
 // GetProductItemTrackModelByID returns model.ProductItemTrack for service operations
 func (r *ProductItemTrackRepository) GetProductItemTrackModelByID(id uint) (model.ProductItemTrack, error) {
 	var track model.ProductItemTrack
-	result := database.DB.Where("id = ?", id).First(&track)
+	result := r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).First(&track)
 	return track, result.Error
 }
 
 func (r *ProductItemTrackRepository) CreateProductItemTrack(track *model.ProductItemTrack) error {
-	return database.DB.Create(track).Error
+	if err := checkStockAssigned(r.db(), r.ctx, track.ProductStockID); err != nil {
+		return err
+	}
+	return r.db().Create(track).Error
 }
 
 func (r *ProductItemTrackRepository) UpdateProductItemTrack(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductItemTrack{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.ProductItemTrack{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductItemTrackRepository) DeleteProductItemTrackWithAudit(id uint, userID uint) error {
@@ -134,18 +194,18 @@ func (r *ProductItemTrackRepository) DeleteProductItemTrackWithAudit(id uint, us
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductItemTrack{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductItemTrack{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductItemTrack{}, id).Error
+	return r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Delete(&model.ProductItemTrack{}, id).Error
 }
 
 func (r *ProductItemTrackRepository) CheckProductItemExists(itemID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductItem{}).Where("id = ?", itemID).Count(&count)
+	result := r.db().Model(&model.ProductItem{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", itemID).Count(&count)
 	return count > 0, result.Error
 }
 
@@ -153,12 +213,13 @@ func (r *ProductItemTrackRepository) CheckProductItemExists(itemID uint) (bool,
 func (r *ProductItemTrackRepository) GetTracksByOperation(operation string) ([]productItemTrackResponse, error) {
 	var tracks []productItemTrackResponse
 
-	result := database.DB.Table("product_item_tracks pit").
-		Select("pit.id, pit.product_item_id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date_track as date, pit.unit_price, pit.quantity, pit.operation, pit.stock").
+	result := r.db().Table("product_item_tracks pit").
+		Select("pit.id, pit.product_stock_id, pit.product_id, p.name as product_name, pit.product_batch_id, pb.code_batch as product_batch_code, pit.date, pit.unit_price, pit.quantity, pit.operation, pit.stock, pit.version").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pit.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.operation = ?", operation).
-		Order("pit.date_track DESC").
+		Order("pit.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -168,9 +229,10 @@ func (r *ProductItemTrackRepository) GetTracksByOperation(operation string) ([]p
 func (r *ProductItemTrackRepository) GetValueReportByProduct() ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
-	rows, err := database.DB.Table("product_item_tracks pit").
+	rows, err := r.db().Table("product_item_tracks pit").
 		Select("pit.product_id, p.name as product_name, COUNT(*) as total_transactions, SUM(pit.quantity * pit.unit_price) as total_value, AVG(pit.unit_price) as avg_unit_price").
 		Joins("INNER JOIN products p ON pit.product_id = p.id AND p.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pit.product_stock_id")).
 		Where("pit.deleted_at IS NULL AND pit.unit_price IS NOT NULL AND pit.quantity IS NOT NULL").
 		Group("pit.product_id, p.name").
 		Order("total_value DESC").
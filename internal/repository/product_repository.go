@@ -1,11 +1,17 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type ProductRepository struct{}
+type ProductRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productWithBrandCategoryResponse struct untuk response dengan brand dan category name
 type productWithBrandCategoryResponse struct {
@@ -16,31 +22,57 @@ type productWithBrandCategoryResponse struct {
 	CategoryName string  `json:"categoryName"`
 	Name         string  `json:"name"`
 	Description  *string `json:"description"`
+	Version      uint    `json:"version"`
 }
 
 func NewProductRepository() *ProductRepository {
 	return &ProductRepository{}
 }
 
-func (r *ProductRepository) GetAllProducts() ([]productWithBrandCategoryResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductRepository) WithTx(tx *gorm.DB) *ProductRepository {
+	return &ProductRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductRepository) WithContext(ctx context.Context) *ProductRepository {
+	return &ProductRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productListColumns are the fields accepted by ?sort= and filters on the product list
+var productListColumns = utils.ListColumns{
+	"id":            "p.id",
+	"brand_id":      "c.brand_id",
+	"brand_name":    "b.name",
+	"category_id":   "p.category_id",
+	"category_name": "c.name",
+	"name":          "p.name",
+	"created_at":    "p.created_at",
+	"updated_at":    "p.updated_at",
+}
+
+func (r *ProductRepository) GetAllProducts(query utils.ListQuery) ([]productWithBrandCategoryResponse, int64, error) {
 	var products []productWithBrandCategoryResponse
 
-	result := database.DB.Table("products p").
-		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description").
+	db := r.db().Table("products p").
+		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description, p.version").
 		Joins("LEFT JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
-		Where("p.deleted_at IS NULL").
-		Order("p.name ASC").
-		Find(&products)
+		Where("p.deleted_at IS NULL")
 
-	return products, result.Error
+	total, err := utils.FindPage(db, query, productListColumns, "p.name ASC", &products)
+	return products, total, err
 }
 
 func (r *ProductRepository) GetProductsByCategory(categoryID uint) ([]productWithBrandCategoryResponse, error) {
 	var products []productWithBrandCategoryResponse
 
-	result := database.DB.Table("products p").
-		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description").
+	result := r.db().Table("products p").
+		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description, p.version").
 		Joins("INNER JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
 		Where("p.category_id = ? AND p.deleted_at IS NULL", categoryID).
@@ -53,8 +85,8 @@ func (r *ProductRepository) GetProductsByCategory(categoryID uint) ([]productWit
 func (r *ProductRepository) GetProductByID(id uint) (productWithBrandCategoryResponse, error) {
 	var product productWithBrandCategoryResponse
 
-	result := database.DB.Table("products p").
-		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description").
+	result := r.db().Table("products p").
+		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description, p.version").
 		Joins("INNER JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL").
 		Joins("INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL").
 		Where("p.id = ? AND p.deleted_at IS NULL", id).
@@ -66,16 +98,16 @@ func (r *ProductRepository) GetProductByID(id uint) (productWithBrandCategoryRes
 // GetProductModelByID returns model.Product for service operations
 func (r *ProductRepository) GetProductModelByID(id uint) (model.Product, error) {
 	var product model.Product
-	result := database.DB.Where("id = ?", id).First(&product)
+	result := r.db().Where("id = ?", id).First(&product)
 	return product, result.Error
 }
 
 func (r *ProductRepository) CreateProduct(product *model.Product) error {
-	return database.DB.Create(product).Error
+	return r.db().Create(product).Error
 }
 
 func (r *ProductRepository) UpdateProduct(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductRepository) DeleteProductWithAudit(id uint, userID uint) error {
@@ -85,25 +117,25 @@ func (r *ProductRepository) DeleteProductWithAudit(id uint, userID uint) error {
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.Product{}, id).Error
+	return r.db().Delete(&model.Product{}, id).Error
 }
 
 func (r *ProductRepository) CheckProductExists(name string, categoryID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.Product{}).Unscoped().Where("name ILIKE ? AND category_id = ?", name, categoryID)
+	query := r.db().Model(&model.Product{}).Unscoped().Where("name ILIKE ? AND category_id = ?", name, categoryID)
 	result := query.Count(&count)
 	return count > 0, result.Error
 }
 
 func (r *ProductRepository) CheckCategoryExists(categoryID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.Category{}).Where("id = ?", categoryID).Count(&count)
+	result := r.db().Model(&model.Category{}).Where("id = ?", categoryID).Count(&count)
 	return count > 0, result.Error
 }
 
@@ -111,8 +143,8 @@ func (r *ProductRepository) CheckCategoryExists(categoryID uint) (bool, error) {
 func (r *ProductRepository) GetDeletedProducts() ([]productWithBrandCategoryResponse, error) {
 	var products []productWithBrandCategoryResponse
 
-	result := database.DB.Table("products p").
-		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description").
+	result := r.db().Table("products p").
+		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description, p.version").
 		Joins("LEFT JOIN categories c ON p.category_id = c.id").
 		Joins("LEFT JOIN brands b ON c.brand_id = b.id").
 		Where("p.deleted_at IS NOT NULL").
@@ -128,14 +160,14 @@ func (r *ProductRepository) RestoreProduct(id uint, userID uint) error {
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 // // GetDeletedProducts returns all soft deleted products
 // func (r *ProductRepository) GetDeletedProducts() ([]productWithBrandCategoryResponse, error) {
 // 	var products []productWithBrandCategoryResponse
 
-// 	result := database.DB.Table("products p").
+// 	result := r.db().Table("products p").
 // 		Select("p.id, c.brand_id, p.category_id, b.name as brand_name, c.name as category_name, p.name, p.description").
 // 		Joins("LEFT JOIN categories c ON p.category_id = c.id").
 // 		Joins("LEFT JOIN brands b ON c.brand_id = b.id").
@@ -152,5 +184,5 @@ func (r *ProductRepository) RestoreProduct(id uint, userID uint) error {
 // 		"user_updt":  userID,
 // 		"deleted_at": nil,
 // 	}
-// 	return database.DB.Unscoped().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
+// 	return r.db().Unscoped().Model(&model.Product{}).Where("id = ?", id).Updates(updateData).Error
 // }
@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductStockRepository struct{}
+type ProductStockRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productStockResponse struct untuk response dengan product, batch, dan location name
 type productStockResponse struct {
@@ -17,36 +23,76 @@ type productStockResponse struct {
 	ProductName      string   `json:"productName"`
 	LocationID       *uint    `json:"locationId"`
 	LocationName     *string  `json:"locationName"`
+	BinID            *uint    `json:"binId"`
+	BinPath          *string  `json:"binPath"`
 	Quantity         *float64 `json:"quantity"`
+	Status           string   `json:"status"`
+	StatusReason     *string  `json:"statusReason"`
+	BatchStatus      string   `json:"batchStatus"`
+	Version          uint     `json:"version"`
 }
 
 func NewProductStockRepository() *ProductStockRepository {
 	return &ProductStockRepository{}
 }
 
-func (r *ProductStockRepository) GetAllProductStocks() ([]productStockResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductStockRepository) WithTx(tx *gorm.DB) *ProductStockRepository {
+	return &ProductStockRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductStockRepository) WithContext(ctx context.Context) *ProductStockRepository {
+	return &ProductStockRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductStockRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productStockListColumns are the fields accepted by ?sort= and filters on the product stock list
+var productStockListColumns = utils.ListColumns{
+	"id":               "ps.id",
+	"product_batch_id": "ps.product_batch_id",
+	"product_id":       "ps.product_id",
+	"product_name":     "p.name",
+	"location_id":      "ps.location_id",
+	"location_name":    "l.name",
+	"bin_id":           "ps.bin_id",
+	"bin_path":         "sl.path",
+	"quantity":         "ps.quantity",
+	"status":           "ps.status",
+	"batch_status":     "pb.status",
+	"created_at":       "ps.created_at",
+	"updated_at":       "ps.updated_at",
+}
+
+func (r *ProductStockRepository) GetAllProductStocks(query utils.ListQuery) ([]productStockResponse, int64, error) {
 	var stocks []productStockResponse
 
-	result := database.DB.Table("product_stocks ps").
-		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.quantity").
+	db := r.db().Table("product_stocks ps").
+		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.bin_id, sl.path as bin_path, ps.quantity, ps.status, ps.status_reason, pb.status as batch_status, ps.version").
 		Joins("INNER JOIN product_batches pb ON ps.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON ps.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
-		Where("ps.deleted_at IS NULL").
-		Order("ps.created_at DESC").
-		Find(&stocks)
+		Joins("LEFT JOIN sub_locations sl ON ps.bin_id = sl.id").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
+		Where("ps.deleted_at IS NULL")
 
-	return stocks, result.Error
+	total, err := utils.FindPage(db, query, productStockListColumns, "ps.created_at DESC", &stocks)
+	return stocks, total, err
 }
 
 func (r *ProductStockRepository) GetProductStocksByProduct(productID uint) ([]productStockResponse, error) {
 	var stocks []productStockResponse
 
-	result := database.DB.Table("product_stocks ps").
-		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.quantity").
+	result := r.db().Table("product_stocks ps").
+		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.bin_id, sl.path as bin_path, ps.quantity, ps.status, ps.status_reason, pb.status as batch_status, ps.version").
 		Joins("INNER JOIN product_batches pb ON ps.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON ps.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Joins("LEFT JOIN sub_locations sl ON ps.bin_id = sl.id").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("ps.deleted_at IS NULL AND ps.product_id = ?", productID).
 		Order("ps.created_at DESC").
 		Find(&stocks)
@@ -57,11 +103,13 @@ func (r *ProductStockRepository) GetProductStocksByProduct(productID uint) ([]pr
 func (r *ProductStockRepository) GetProductStockByID(id uint) (productStockResponse, error) {
 	var stock productStockResponse
 
-	result := database.DB.Table("product_stocks ps").
-		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.quantity").
+	result := r.db().Table("product_stocks ps").
+		Select("ps.id, ps.product_batch_id, pb.code_batch as product_batch_code, ps.product_id, p.name as product_name, ps.location_id, l.name as location_name, ps.bin_id, sl.path as bin_path, ps.quantity, ps.status, ps.status_reason, pb.status as batch_status, ps.version").
 		Joins("INNER JOIN product_batches pb ON ps.product_batch_id = pb.id AND pb.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON ps.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Joins("LEFT JOIN sub_locations sl ON ps.bin_id = sl.id").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
 		Where("ps.deleted_at IS NULL AND ps.id = ?", id).
 		First(&stock)
 
@@ -71,16 +119,29 @@ func (r *ProductStockRepository) GetProductStockByID(id uint) (productStockRespo
 // GetProductStockModelByID returns model.ProductStock for service operations
 func (r *ProductStockRepository) GetProductStockModelByID(id uint) (model.ProductStock, error) {
 	var stock model.ProductStock
-	result := database.DB.Where("id = ?", id).First(&stock)
+	result := r.db().Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).First(&stock)
+	return stock, result.Error
+}
+
+// GetProductStockModelByIDUnscoped returns model.ProductStock including soft deleted rows
+func (r *ProductStockRepository) GetProductStockModelByIDUnscoped(id uint) (model.ProductStock, error) {
+	var stock model.ProductStock
+	result := r.db().Unscoped().Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).First(&stock)
 	return stock, result.Error
 }
 
 func (r *ProductStockRepository) CreateProductStock(stock *model.ProductStock) error {
-	return database.DB.Create(stock).Error
+	if err := checkLocationAssigned(r.db(), r.ctx, stock.LocationID); err != nil {
+		return err
+	}
+	return r.db().Create(stock).Error
 }
 
 func (r *ProductStockRepository) UpdateProductStock(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductStock{}).Where("id = ?", id).Updates(updateData).Error
+	if err := checkUpdatedLocation(r.db(), r.ctx, updateData); err != nil {
+		return err
+	}
+	return r.db().Model(&model.ProductStock{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductStockRepository) DeleteProductStockWithAudit(id uint, userID uint) error {
@@ -91,23 +152,45 @@ func (r *ProductStockRepository) DeleteProductStockWithAudit(id uint, userID uin
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductStock{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductStock{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductStock{}, id).Error
+	return r.db().Scopes(locationScope(r.ctx, "location_id")).Delete(&model.ProductStock{}, id).Error
 }
 
 func (r *ProductStockRepository) CheckProductExists(productID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.Product{}).Where("id = ?", productID).Count(&count)
+	result := r.db().Model(&model.Product{}).Where("id = ?", productID).Count(&count)
 	return count > 0, result.Error
 }
 
 func (r *ProductStockRepository) CheckProductBatchExists(batchID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductBatch{}).Where("id = ?", batchID).Count(&count)
+	result := r.db().Model(&model.ProductBatch{}).Where("id = ?", batchID).Count(&count)
 	return count > 0, result.Error
 }
+
+// GetProductBatchStatus returns the status of a batch
+func (r *ProductStockRepository) GetProductBatchStatus(batchID uint) (string, error) {
+	var batch model.ProductBatch
+	result := r.db().Select("id, status").Where("id = ?", batchID).Take(&batch)
+	return batch.Status, result.Error
+}
+
+// GetProductStockStatus returns the status of a stock row and of its batch; either one being held blocks stock-out
+func (r *ProductStockRepository) GetProductStockStatus(stockID uint) (string, string, error) {
+	var status struct {
+		StockStatus string
+		BatchStatus string
+	}
+	result := r.db().Table("product_stocks ps").
+		Select("ps.status as stock_status, pb.status as batch_status").
+		Joins("INNER JOIN product_batches pb ON ps.product_batch_id = pb.id").
+		Scopes(locationScope(r.ctx, "ps.location_id")).
+		Where("ps.id = ? AND ps.deleted_at IS NULL", stockID).
+		Take(&status)
+	return status.StockStatus, status.BatchStatus, result.Error
+}
@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductStockTrackRepository struct{}
+type ProductStockTrackRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productStockTrackResponse struct untuk response dengan relasi detail
 type productStockTrackResponse struct {
@@ -20,22 +26,68 @@ type productStockTrackResponse struct {
 	Quantity         *float64  `json:"quantity"`
 	Operation        string    `json:"operation"`
 	Stock            *float64  `json:"stock"`
+	Version          uint      `json:"version"`
 }
 
 func NewProductStockTrackRepository() *ProductStockTrackRepository {
 	return &ProductStockTrackRepository{}
 }
 
-func (r *ProductStockTrackRepository) GetAllProductStockTracks() ([]productStockTrackResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductStockTrackRepository) WithTx(tx *gorm.DB) *ProductStockTrackRepository {
+	return &ProductStockTrackRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductStockTrackRepository) WithContext(ctx context.Context) *ProductStockTrackRepository {
+	return &ProductStockTrackRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductStockTrackRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productStockTrackListColumns are the fields accepted by ?sort= and filters on the product stock track list
+var productStockTrackListColumns = utils.ListColumns{
+	"id":               "pst.id",
+	"product_stock_id": "pst.product_stock_id",
+	"product_id":       "pst.product_id",
+	"product_name":     "p.name",
+	"product_batch_id": "pst.product_batch_id",
+	"date":             "pst.date",
+	"quantity":         "pst.quantity",
+	"operation":        "pst.operation",
+	"stock":            "pst.stock",
+	"created_at":       "pst.created_at",
+}
+
+func (r *ProductStockTrackRepository) GetAllProductStockTracks(query utils.ListQuery) ([]productStockTrackResponse, int64, error) {
 	var tracks []productStockTrackResponse
 
-	result := database.DB.Table("product_stock_tracks pst").
-		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date_track, pst.quantity, pst.operation, pst.stock").
+	db := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
 		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
-		Where("pst.deleted_at IS NULL").
-		Order("pst.date_track DESC").
-		Find(&tracks)
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
+		Where("pst.deleted_at IS NULL")
+
+	total, err := utils.FindPage(db, query, productStockTrackListColumns, "pst.date DESC", &tracks)
+	return tracks, total, err
+}
+
+// GetProductStockTracksByCursor returns up to limit tracks after cursor in (date, id) order, newest first unless ascending
+func (r *ProductStockTrackRepository) GetProductStockTracksByCursor(query utils.ListQuery, cursor *utils.TrackCursor, limit int, ascending bool) ([]productStockTrackResponse, error) {
+	var tracks []productStockTrackResponse
+
+	db := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
+		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
+		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
+		Where("pst.deleted_at IS NULL")
+
+	db = query.ApplyFilters(db, productStockTrackListColumns)
+	result := utils.ApplyKeyset(db, "pst.date", "pst.id", cursor, limit, ascending).Find(&tracks)
 
 	return tracks, result.Error
 }
@@ -43,12 +95,13 @@ func (r *ProductStockTrackRepository) GetAllProductStockTracks() ([]productStock
 func (r *ProductStockTrackRepository) GetProductStockTracksByStock(stockID uint) ([]productStockTrackResponse, error) {
 	var tracks []productStockTrackResponse
 
-	result := database.DB.Table("product_stock_tracks pst").
-		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date_track, pst.quantity, pst.operation, pst.stock").
+	result := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
 		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
 		Where("pst.deleted_at IS NULL AND pst.product_stock_id = ?", stockID).
-		Order("pst.date_track DESC").
+		Order("pst.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -57,12 +110,13 @@ func (r *ProductStockTrackRepository) GetProductStockTracksByStock(stockID uint)
 func (r *ProductStockTrackRepository) GetProductStockTracksByProduct(productID uint) ([]productStockTrackResponse, error) {
 	var tracks []productStockTrackResponse
 
-	result := database.DB.Table("product_stock_tracks pst").
-		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date_track, pst.quantity, pst.operation, pst.stock").
+	result := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
 		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
 		Where("pst.deleted_at IS NULL AND pst.product_id = ?", productID).
-		Order("pst.date_track DESC").
+		Order("pst.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -71,12 +125,13 @@ func (r *ProductStockTrackRepository) GetProductStockTracksByProduct(productID u
 func (r *ProductStockTrackRepository) GetProductStockTracksByDateRange(startDate, endDate time.Time) ([]productStockTrackResponse, error) {
 	var tracks []productStockTrackResponse
 
-	result := database.DB.Table("product_stock_tracks pst").
-		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date_track, pst.quantity, pst.operation, pst.stock").
+	result := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
 		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
-		Where("pst.deleted_at IS NULL AND pst.date_track BETWEEN ? AND ?", startDate, endDate).
-		Order("pst.date_track DESC").
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
+		Where("pst.deleted_at IS NULL AND pst.date BETWEEN ? AND ?", startDate, endDate).
+		Order("pst.date DESC").
 		Find(&tracks)
 
 	return tracks, result.Error
@@ -85,10 +140,11 @@ func (r *ProductStockTrackRepository) GetProductStockTracksByDateRange(startDate
 func (r *ProductStockTrackRepository) GetProductStockTrackByID(id uint) (productStockTrackResponse, error) {
 	var track productStockTrackResponse
 
-	result := database.DB.Table("product_stock_tracks pst").
-		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date_track, pst.quantity, pst.operation, pst.stock").
+	result := r.db().Table("product_stock_tracks pst").
+		Select("pst.id, pst.product_stock_id, pst.product_id, p.name as product_name, pst.product_batch_id, pb.code_batch as product_batch_code, pst.date as date_track, pst.quantity, pst.operation, pst.stock, pst.version").
 		Joins("INNER JOIN products p ON pst.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("INNER JOIN product_batches pb ON pst.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(stockLocationScope(r.ctx, "pst.product_stock_id")).
 		Where("pst.deleted_at IS NULL AND pst.id = ?", id).
 		First(&track)
 
@@ -98,16 +154,19 @@ func (r *ProductStockTrackRepository) GetProductStockTrackByID(id uint) (product
 // GetProductStockTrackModelByID returns model.ProductStockTrack for service operations
 func (r *ProductStockTrackRepository) GetProductStockTrackModelByID(id uint) (model.ProductStockTrack, error) {
 	var track model.ProductStockTrack
-	result := database.DB.Where("id = ?", id).First(&track)
+	result := r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).First(&track)
 	return track, result.Error
 }
 
 func (r *ProductStockTrackRepository) CreateProductStockTrack(track *model.ProductStockTrack) error {
-	return database.DB.Create(track).Error
+	if err := checkStockAssigned(r.db(), r.ctx, track.ProductStockID); err != nil {
+		return err
+	}
+	return r.db().Create(track).Error
 }
 
 func (r *ProductStockTrackRepository) UpdateProductStockTrack(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductStockTrack{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.ProductStockTrack{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductStockTrackRepository) DeleteProductStockTrackWithAudit(id uint, userID uint) error {
@@ -118,17 +177,17 @@ func (r *ProductStockTrackRepository) DeleteProductStockTrackWithAudit(id uint,
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductStockTrack{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductStockTrack{}).Scopes(stockLocationScope(r.ctx, "product_stock_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductStockTrack{}, id).Error
+	return r.db().Scopes(stockLocationScope(r.ctx, "product_stock_id")).Delete(&model.ProductStockTrack{}, id).Error
 }
 
 func (r *ProductStockTrackRepository) CheckProductStockExists(stockID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductStock{}).Where("id = ?", stockID).Count(&count)
+	result := r.db().Model(&model.ProductStock{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", stockID).Count(&count)
 	return count > 0, result.Error
-}
\ No newline at end of file
+}
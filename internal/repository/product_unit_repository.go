@@ -1,12 +1,18 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type ProductUnitRepository struct{}
+type ProductUnitRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productUnitResponse struct untuk response product unit
 type productUnitResponse struct {
@@ -23,6 +29,7 @@ type productUnitResponse struct {
 	UnitPriceRetail  *string  `json:"unitPriceRetail"`
 	Barcode          *string  `json:"barcode"`
 	Description      *string  `json:"description"`
+	Version          uint     `json:"version"`
 }
 
 type productUnitOriResponse struct {
@@ -35,32 +42,64 @@ type productUnitOriResponse struct {
 	UnitPriceRetail *float64 `json:"unit_price_retail"`
 	Barcode         *string  `json:"barcode"`
 	Description     *string  `json:"description"`
+	Version         uint     `json:"version"`
 }
 
 func NewProductUnitRepository() *ProductUnitRepository {
 	return &ProductUnitRepository{}
 }
 
-func (r *ProductUnitRepository) GetAllProductUnits() ([]productUnitResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductUnitRepository) WithTx(tx *gorm.DB) *ProductUnitRepository {
+	return &ProductUnitRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductUnitRepository) WithContext(ctx context.Context) *ProductUnitRepository {
+	return &ProductUnitRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductUnitRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productUnitListColumns are the fields accepted by ?sort= and filters on the product unit list
+var productUnitListColumns = utils.ListColumns{
+	"id":                "pu.id",
+	"product_id":        "pu.product_id",
+	"product_name":      "p.name",
+	"location_id":       "pu.location_id",
+	"product_batch_id":  "pu.product_batch_id",
+	"name":              "pu.name",
+	"barcode":           "pu.barcode",
+	"quantity":          "pu.quantity",
+	"unit_price":        "pu.unit_price",
+	"unit_price_retail": "pu.unit_price_retail",
+	"created_at":        "pu.created_at",
+	"updated_at":        "pu.updated_at",
+}
+
+func (r *ProductUnitRepository) GetAllProductUnits(query utils.ListQuery) ([]productUnitResponse, int64, error) {
 	var units []productUnitResponse
-	result := database.DB.Table("product_units pu").
-		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description").
+	db := r.db().Table("product_units pu").
+		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description, pu.version").
 		Joins("LEFT JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON pu.location_id = l.id AND l.deleted_at IS NULL").
 		Joins("LEFT JOIN product_batches pb ON pu.product_batch_id = pb.id AND pb.deleted_at IS NULL").
-		Where("pu.deleted_at IS NULL").
-		Order("pu.created_at DESC").
-		Find(&units)
-	return units, result.Error
+		Scopes(locationScope(r.ctx, "pu.location_id")).
+		Where("pu.deleted_at IS NULL")
+	total, err := utils.FindPage(db, query, productUnitListColumns, "pu.created_at DESC", &units)
+	return units, total, err
 }
 
 func (r *ProductUnitRepository) GetProductUnitsByProduct(productID uint) ([]productUnitResponse, error) {
 	var units []productUnitResponse
-	result := database.DB.Table("product_units pu").
-		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description").
+	result := r.db().Table("product_units pu").
+		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description, pu.version").
 		Joins("LEFT JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON pu.location_id = l.id AND l.deleted_at IS NULL").
 		Joins("LEFT JOIN product_batches pb ON pu.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "pu.location_id")).
 		Where("pu.deleted_at IS NULL AND pu.product_id = ?", productID).
 		Order("pu.created_at DESC").
 		Find(&units)
@@ -69,11 +108,12 @@ func (r *ProductUnitRepository) GetProductUnitsByProduct(productID uint) ([]prod
 
 func (r *ProductUnitRepository) GetProductUnitByID(id uint) (productUnitResponse, error) {
 	var unit productUnitResponse
-	result := database.DB.Table("product_units pu").
-		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description").
+	result := r.db().Table("product_units pu").
+		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description, pu.version").
 		Joins("LEFT JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
 		Joins("LEFT JOIN locations l ON pu.location_id = l.id AND l.deleted_at IS NULL").
 		Joins("LEFT JOIN product_batches pb ON pu.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Scopes(locationScope(r.ctx, "pu.location_id")).
 		Where("pu.deleted_at IS NULL AND pu.id = ?", id).
 		First(&unit)
 	return unit, result.Error
@@ -82,25 +122,25 @@ func (r *ProductUnitRepository) GetProductUnitByID(id uint) (productUnitResponse
 // GetProductUnitById return model.ProductUnit for internal use (not for response)
 func (r *ProductUnitRepository) GetProductUnitByIDModel(id uint) (model.ProductUnit, error) {
 	var unit model.ProductUnit
-	result := database.DB.Where("deleted_at IS NULL AND id = ?", id).First(&unit)
+	result := r.db().Scopes(locationScope(r.ctx, "location_id")).Where("deleted_at IS NULL AND id = ?", id).First(&unit)
 	return unit, result.Error
 }
 
 func (r *ProductUnitRepository) CheckProductExists(productID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.Product{}).Where("id = ? AND deleted_at IS NULL", productID).Count(&count)
+	result := r.db().Model(&model.Product{}).Where("id = ? AND deleted_at IS NULL", productID).Count(&count)
 	return count > 0, result.Error
 }
 
 func (r *ProductUnitRepository) CheckProductBatchExists(productBatchID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductBatch{}).Where("id = ? AND deleted_at IS NULL", productBatchID).Count(&count)
+	result := r.db().Model(&model.ProductBatch{}).Where("id = ? AND deleted_at IS NULL", productBatchID).Count(&count)
 	return count > 0, result.Error
 }
 
 func (r *ProductUnitRepository) CheckProductUnitExists(productID uint, locationID uint, name string, excludeID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.ProductUnit{}).Where("product_id = ? AND name = ? AND deleted_at IS NULL", productID, name)
+	query := r.db().Model(&model.ProductUnit{}).Where("product_id = ? AND name = ? AND deleted_at IS NULL", productID, name)
 
 	if locationID != 0 {
 		query = query.Where("location_id = ?", locationID)
@@ -117,7 +157,7 @@ func (r *ProductUnitRepository) CheckProductUnitExists(productID uint, locationI
 
 func (r *ProductUnitRepository) CheckBarcodeProductUnitExists(productID uint, locationID uint, barcode string, excludeID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.ProductUnit{}).Where("product_id = ? AND barcode = ? AND deleted_at IS NULL", productID, barcode)
+	query := r.db().Model(&model.ProductUnit{}).Where("product_id = ? AND barcode = ? AND deleted_at IS NULL", productID, barcode)
 
 	if locationID != 0 {
 		query = query.Where("location_id = ?", locationID)
@@ -133,11 +173,17 @@ func (r *ProductUnitRepository) CheckBarcodeProductUnitExists(productID uint, lo
 }
 
 func (r *ProductUnitRepository) CreateProductUnit(unit *model.ProductUnit) error {
-	return database.DB.Create(unit).Error
+	if err := checkLocationAssigned(r.db(), r.ctx, unit.LocationID); err != nil {
+		return err
+	}
+	return r.db().Create(unit).Error
 }
 
 func (r *ProductUnitRepository) UpdateProductUnit(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductUnit{}).Where("id = ?", id).Updates(updateData).Error
+	if err := checkUpdatedLocation(r.db(), r.ctx, updateData); err != nil {
+		return err
+	}
+	return r.db().Model(&model.ProductUnit{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Updates(updateData).Error
 }
 func (r *ProductUnitRepository) DeleteProductUnitWithAudit(id uint, userID uint) error {
 	// First update the user_updt field to track who deleted the unit
@@ -146,17 +192,17 @@ func (r *ProductUnitRepository) DeleteProductUnitWithAudit(id uint, userID uint)
 		"updated_at": time.Now(),
 	}
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductUnit{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductUnit{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 	// Then soft delete the unit
-	return database.DB.Model(&model.ProductUnit{}).Where("id = ?", id).Update("deleted_at", time.Now()).Error
+	return r.db().Model(&model.ProductUnit{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Update("deleted_at", time.Now()).Error
 }
 
 func (r *ProductUnitRepository) CheckBarcodeExists(barcode string) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.ProductUnit{}).Where("barcode = ? AND deleted_at IS NULL", barcode)
+	query := r.db().Model(&model.ProductUnit{}).Where("barcode = ? AND deleted_at IS NULL", barcode)
 	result := query.Count(&count)
 	return count > 0, result.Error
 }
@@ -164,8 +210,9 @@ func (r *ProductUnitRepository) CheckBarcodeExists(barcode string) (bool, error)
 // GetProductUnitByBarcode returns ProductUnit data based on barcode
 func (r *ProductUnitRepository) GetProductUnitByBarcode(barcode string) (productUnitOriResponse, error) {
 	var unit productUnitOriResponse
-	result := database.DB.Table("product_units pu").
-		Select("pu.id, pu.product_id, pu.location_id, pu.product_batch_id, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description").
+	result := r.db().Table("product_units pu").
+		Select("pu.id, pu.product_id, pu.location_id, pu.product_batch_id, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description, pu.version").
+		Scopes(locationScope(r.ctx, "pu.location_id")).
 		Where("pu.barcode = ? AND pu.deleted_at IS NULL", barcode).
 		First(&unit)
 	return unit, result.Error
@@ -175,11 +222,12 @@ func (r *ProductUnitRepository) GetProductUnitByBarcode(barcode string) (product
 func (r *ProductUnitRepository) GetDeletedProductUnits() ([]productUnitResponse, error) {
 	var units []productUnitResponse
 
-	result := database.DB.Table("product_units pu").
-		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description").
+	result := r.db().Table("product_units pu").
+		Select("pu.id, pu.product_id, p.name as product_name, pu.location_id, l.name as location_name, pu.product_batch_id, pb.code_batch as product_batch_name, pu.name, pu.quantity, pu.unit_price, pu.unit_price_retail, pu.barcode, pu.description, pu.version").
 		Joins("LEFT JOIN products p ON pu.product_id = p.id").
 		Joins("LEFT JOIN locations l ON pu.location_id = l.id").
 		Joins("LEFT JOIN product_batches pb ON pu.product_batch_id = pb.id").
+		Scopes(locationScope(r.ctx, "pu.location_id")).
 		Where("pu.deleted_at IS NOT NULL").
 		Order("pu.deleted_at DESC").
 		Find(&units)
@@ -193,5 +241,5 @@ func (r *ProductUnitRepository) RestoreProductUnit(id uint, userID uint) error {
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.ProductUnit{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.ProductUnit{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", id).Updates(updateData).Error
 }
@@ -1,11 +1,17 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type ProductUnitTrackRepository struct{}
+type ProductUnitTrackRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 // productUnitTrackWithDetailsResponse struct untuk response dengan product unit dan product name
 type productUnitTrackWithDetailsResponse struct {
@@ -16,33 +22,58 @@ type productUnitTrackWithDetailsResponse struct {
 	UnitName      *string `json:"unitName"`
 	UnitType      *string `json:"unitType"`
 	Description   *string `json:"description"`
+	Version       uint    `json:"version"`
 }
 
 func NewProductUnitTrackRepository() *ProductUnitTrackRepository {
 	return &ProductUnitTrackRepository{}
 }
 
-func (r *ProductUnitTrackRepository) GetAllProductUnitTracks() ([]productUnitTrackWithDetailsResponse, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ProductUnitTrackRepository) WithTx(tx *gorm.DB) *ProductUnitTrackRepository {
+	return &ProductUnitTrackRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ProductUnitTrackRepository) WithContext(ctx context.Context) *ProductUnitTrackRepository {
+	return &ProductUnitTrackRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ProductUnitTrackRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productUnitTrackListColumns are the fields accepted by ?sort= and filters on the product unit track list
+var productUnitTrackListColumns = utils.ListColumns{
+	"id":              "put.id",
+	"product_unit_id": "put.product_unit_id",
+	"product_id":      "pu.product_id",
+	"product_name":    "p.name",
+	"created_at":      "put.created_at",
+}
+
+func (r *ProductUnitTrackRepository) GetAllProductUnitTracks(query utils.ListQuery) ([]productUnitTrackWithDetailsResponse, int64, error) {
 	var tracks []productUnitTrackWithDetailsResponse
 
-	result := database.DB.Table("product_unit_tracks put").
-		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description").
+	db := r.db().Table("product_unit_tracks put").
+		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description, put.version").
 		Joins("LEFT JOIN product_units pu ON put.product_unit_id = pu.id AND pu.deleted_at IS NULL").
 		Joins("LEFT JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
-		Where("put.deleted_at IS NULL").
-		Order("put.created_at DESC").
-		Find(&tracks)
+		Scopes(unitLocationScope(r.ctx, "put.product_unit_id")).
+		Where("put.deleted_at IS NULL")
 
-	return tracks, result.Error
+	total, err := utils.FindPage(db, query, productUnitTrackListColumns, "put.created_at DESC", &tracks)
+	return tracks, total, err
 }
 
 func (r *ProductUnitTrackRepository) GetProductUnitTracksByProductUnit(productUnitID uint) ([]productUnitTrackWithDetailsResponse, error) {
 	var tracks []productUnitTrackWithDetailsResponse
 
-	result := database.DB.Table("product_unit_tracks put").
-		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description").
+	result := r.db().Table("product_unit_tracks put").
+		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description, put.version").
 		Joins("INNER JOIN product_units pu ON put.product_unit_id = pu.id AND pu.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
+		Scopes(unitLocationScope(r.ctx, "put.product_unit_id")).
 		Where("put.product_unit_id = ? AND put.deleted_at IS NULL", productUnitID).
 		Order("put.created_at DESC").
 		Find(&tracks)
@@ -53,10 +84,11 @@ func (r *ProductUnitTrackRepository) GetProductUnitTracksByProductUnit(productUn
 func (r *ProductUnitTrackRepository) GetProductUnitTracksByProduct(productID uint) ([]productUnitTrackWithDetailsResponse, error) {
 	var tracks []productUnitTrackWithDetailsResponse
 
-	result := database.DB.Table("product_unit_tracks put").
-		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description").
+	result := r.db().Table("product_unit_tracks put").
+		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description, put.version").
 		Joins("INNER JOIN product_units pu ON put.product_unit_id = pu.id AND pu.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
+		Scopes(unitLocationScope(r.ctx, "put.product_unit_id")).
 		Where("pu.product_id = ? AND put.deleted_at IS NULL", productID).
 		Order("put.created_at DESC").
 		Find(&tracks)
@@ -67,10 +99,11 @@ func (r *ProductUnitTrackRepository) GetProductUnitTracksByProduct(productID uin
 func (r *ProductUnitTrackRepository) GetProductUnitTrackByID(id uint) (productUnitTrackWithDetailsResponse, error) {
 	var track productUnitTrackWithDetailsResponse
 
-	result := database.DB.Table("product_unit_tracks put").
-		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description").
+	result := r.db().Table("product_unit_tracks put").
+		Select("put.id, put.product_unit_id, pu.product_id, p.name as product_name, pu.name as unit_name, pu.unit_price, pu.barcode, put.description, put.version").
 		Joins("INNER JOIN product_units pu ON put.product_unit_id = pu.id AND pu.deleted_at IS NULL").
 		Joins("INNER JOIN products p ON pu.product_id = p.id AND p.deleted_at IS NULL").
+		Scopes(unitLocationScope(r.ctx, "put.product_unit_id")).
 		Where("put.id = ? AND put.deleted_at IS NULL", id).
 		First(&track)
 
@@ -80,16 +113,16 @@ func (r *ProductUnitTrackRepository) GetProductUnitTrackByID(id uint) (productUn
 // GetProductUnitTrackModelByID returns model.ProductUnitTrack for service operations
 func (r *ProductUnitTrackRepository) GetProductUnitTrackModelByID(id uint) (model.ProductUnitTrack, error) {
 	var track model.ProductUnitTrack
-	result := database.DB.Where("id = ?", id).First(&track)
+	result := r.db().Scopes(unitLocationScope(r.ctx, "product_unit_id")).Where("id = ?", id).First(&track)
 	return track, result.Error
 }
 
 func (r *ProductUnitTrackRepository) CreateProductUnitTrack(track *model.ProductUnitTrack) error {
-	return database.DB.Create(track).Error
+	return r.db().Create(track).Error
 }
 
 func (r *ProductUnitTrackRepository) UpdateProductUnitTrack(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.ProductUnitTrack{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.ProductUnitTrack{}).Scopes(unitLocationScope(r.ctx, "product_unit_id")).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *ProductUnitTrackRepository) DeleteProductUnitTrackWithAudit(id uint, userID uint) error {
@@ -99,17 +132,17 @@ func (r *ProductUnitTrackRepository) DeleteProductUnitTrackWithAudit(id uint, us
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.ProductUnitTrack{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.ProductUnitTrack{}).Scopes(unitLocationScope(r.ctx, "product_unit_id")).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.ProductUnitTrack{}, id).Error
+	return r.db().Scopes(unitLocationScope(r.ctx, "product_unit_id")).Delete(&model.ProductUnitTrack{}, id).Error
 }
 
 func (r *ProductUnitTrackRepository) CheckProductUnitExists(productUnitID uint) (bool, error) {
 	var count int64
-	result := database.DB.Model(&model.ProductUnit{}).Where("id = ?", productUnitID).Count(&count)
+	result := r.db().Model(&model.ProductUnit{}).Scopes(locationScope(r.ctx, "location_id")).Where("id = ?", productUnitID).Count(&count)
 	return count > 0, result.Error
 }
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReplenishmentRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+// ReplenishmentRuleLevel is a rule with the quantity currently available for it
+// (at its location, or across every location for a product-wide rule)
+type ReplenishmentRuleLevel struct {
+	ID           uint    `json:"id"`
+	ProductID    uint    `json:"productId"`
+	ProductName  string  `json:"productName"`
+	LocationID   *uint   `json:"locationId"`
+	LocationName *string `json:"locationName"`
+	LocationType *string `json:"locationType"`
+	MinQuantity  float64 `json:"minQuantity"`
+	ReorderPoint float64 `json:"reorderPoint"`
+	MaxQuantity  float64 `json:"maxQuantity"`
+	OnHand       float64 `json:"onHand"`
+	Version      uint    `json:"version"`
+	TenantID     *uint   `json:"-"` // For the replenishment job, which checks every tenant at once
+}
+
+// WarehouseStock is the available quantity of a product in one gudang location
+type WarehouseStock struct {
+	ProductID    uint    `json:"productId"`
+	LocationID   uint    `json:"locationId"`
+	LocationName string  `json:"locationName"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// replenishmentAlertResponse struct untuk response dengan product dan location name
+type replenishmentAlertResponse struct {
+	ID                  uint       `json:"id"`
+	ReplenishmentRuleID uint       `json:"replenishmentRuleId"`
+	ProductID           uint       `json:"productId"`
+	ProductName         string     `json:"productName"`
+	LocationID          *uint      `json:"locationId"`
+	LocationName        *string    `json:"locationName"`
+	Priority            string     `json:"priority"`
+	Status              string     `json:"status"`
+	OnHand              float64    `json:"onHand"`
+	SuggestedQuantity   float64    `json:"suggestedQuantity"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+	ResolvedAt          *time.Time `json:"resolvedAt"`
+}
+
+func NewReplenishmentRepository() *ReplenishmentRepository {
+	return &ReplenishmentRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *ReplenishmentRepository) WithTx(tx *gorm.DB) *ReplenishmentRepository {
+	return &ReplenishmentRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *ReplenishmentRepository) WithContext(ctx context.Context) *ReplenishmentRepository {
+	return &ReplenishmentRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *ReplenishmentRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// availableStockSQL sums the quantity that can be used for replenishment: live stock rows, neither the stock nor its batch on hold
+const availableStockSQL = "SELECT COALESCE(SUM(ps.quantity), 0) FROM product_stocks ps " +
+	"INNER JOIN product_batches pb ON ps.product_batch_id = pb.id AND pb.deleted_at IS NULL " +
+	"WHERE ps.deleted_at IS NULL AND ps.status = 'available' AND pb.status = 'available' " +
+	"AND ps.product_id = rr.product_id AND (rr.location_id IS NULL OR ps.location_id = rr.location_id)"
+
+const replenishmentRuleSelect = "rr.id, rr.product_id, p.name as product_name, rr.location_id, l.name as location_name, l.type as location_type, " +
+	"rr.min_quantity, rr.reorder_point, rr.max_quantity, (" + availableStockSQL + ") as on_hand, rr.version, rr.tenant_id"
+
+// replenishmentRuleListColumns are the fields accepted by ?sort= and filters on the replenishment rule list
+var replenishmentRuleListColumns = utils.ListColumns{
+	"id":            "rr.id",
+	"product_id":    "rr.product_id",
+	"product_name":  "p.name",
+	"location_id":   "rr.location_id",
+	"location_name": "l.name",
+	"min_quantity":  "rr.min_quantity",
+	"reorder_point": "rr.reorder_point",
+	"max_quantity":  "rr.max_quantity",
+	"created_at":    "rr.created_at",
+	"updated_at":    "rr.updated_at",
+}
+
+func (r *ReplenishmentRepository) ruleLevels() *gorm.DB {
+	return r.db().Table("replenishment_rules rr").
+		Select(replenishmentRuleSelect).
+		Joins("INNER JOIN products p ON rr.product_id = p.id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN locations l ON rr.location_id = l.id AND l.deleted_at IS NULL").
+		Where("rr.deleted_at IS NULL")
+}
+
+func (r *ReplenishmentRepository) GetAllReplenishmentRules(query utils.ListQuery) ([]ReplenishmentRuleLevel, int64, error) {
+	var rules []ReplenishmentRuleLevel
+	total, err := utils.FindPage(r.ruleLevels(), query, replenishmentRuleListColumns, "p.name ASC, rr.location_id ASC", &rules)
+	return rules, total, err
+}
+
+func (r *ReplenishmentRepository) GetReplenishmentRuleByID(id uint) (ReplenishmentRuleLevel, error) {
+	var rule ReplenishmentRuleLevel
+	result := r.ruleLevels().Where("rr.id = ?", id).First(&rule)
+	return rule, result.Error
+}
+
+// GetReplenishmentRuleLevels returns every rule with its available quantity, optionally limited to a product and/or location
+func (r *ReplenishmentRepository) GetReplenishmentRuleLevels(productID, locationID *uint) ([]ReplenishmentRuleLevel, error) {
+	var rules []ReplenishmentRuleLevel
+
+	db := r.ruleLevels()
+	if productID != nil {
+		db = db.Where("rr.product_id = ?", *productID)
+	}
+	if locationID != nil {
+		db = db.Where("rr.location_id = ?", *locationID)
+	}
+
+	result := db.Order("rr.id ASC").Find(&rules)
+	return rules, result.Error
+}
+
+// GetReplenishmentRuleModelByID returns model.ReplenishmentRule for service operations
+func (r *ReplenishmentRepository) GetReplenishmentRuleModelByID(id uint) (model.ReplenishmentRule, error) {
+	var rule model.ReplenishmentRule
+	result := r.db().Where("id = ?", id).First(&rule)
+	return rule, result.Error
+}
+
+func (r *ReplenishmentRepository) CreateReplenishmentRule(rule *model.ReplenishmentRule) error {
+	return r.db().Create(rule).Error
+}
+
+func (r *ReplenishmentRepository) UpdateReplenishmentRule(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.ReplenishmentRule{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+func (r *ReplenishmentRepository) DeleteReplenishmentRuleWithAudit(id uint, userID uint) error {
+	// First update the user_updt field to track who deleted the rule
+	updateData := map[string]interface{}{
+		"user_updt":  userID,
+		"updated_at": time.Now(),
+	}
+
+	err := r.db().Model(&model.ReplenishmentRule{}).Where("id = ?", id).Updates(updateData).Error
+	if err != nil {
+		return err
+	}
+
+	// Then perform the soft delete
+	return r.db().Delete(&model.ReplenishmentRule{}, id).Error
+}
+
+// CheckReplenishmentRuleExists reports whether a product already has a rule for the location (nil for the product-wide rule)
+func (r *ReplenishmentRepository) CheckReplenishmentRuleExists(productID uint, locationID *uint, excludeID uint) (bool, error) {
+	var count int64
+	query := r.db().Model(&model.ReplenishmentRule{}).Where("product_id = ?", productID)
+	if locationID == nil {
+		query = query.Where("location_id IS NULL")
+	} else {
+		query = query.Where("location_id = ?", *locationID)
+	}
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+
+	result := query.Count(&count)
+	return count > 0, result.Error
+}
+
+func (r *ReplenishmentRepository) CheckProductExists(productID uint) (bool, error) {
+	var count int64
+	result := r.db().Model(&model.Product{}).Where("id = ?", productID).Count(&count)
+	return count > 0, result.Error
+}
+
+func (r *ReplenishmentRepository) CheckLocationExists(locationID uint) (bool, error) {
+	var count int64
+	result := r.db().Model(&model.Location{}).Where("id = ?", locationID).Count(&count)
+	return count > 0, result.Error
+}
+
+// GetWarehouseStocks returns the available quantity of each product per gudang location, largest first
+func (r *ReplenishmentRepository) GetWarehouseStocks(productIDs []uint) ([]WarehouseStock, error) {
+	var stocks []WarehouseStock
+	if len(productIDs) == 0 {
+		return stocks, nil
+	}
+
+	result := r.db().Table("product_stocks ps").
+		Select("ps.product_id, ps.location_id, l.name as location_name, SUM(ps.quantity) as quantity").
+		Joins("INNER JOIN product_batches pb ON ps.product_batch_id = pb.id AND pb.deleted_at IS NULL").
+		Joins("INNER JOIN locations l ON ps.location_id = l.id AND l.deleted_at IS NULL").
+		Where("ps.deleted_at IS NULL AND ps.status = 'available' AND pb.status = 'available'").
+		Where("l.type = 'gudang' AND ps.product_id IN ?", productIDs).
+		Group("ps.product_id, ps.location_id, l.name").
+		Having("SUM(ps.quantity) > 0").
+		Order("quantity DESC, ps.location_id ASC").
+		Find(&stocks)
+
+	return stocks, result.Error
+}
+
+// replenishmentAlertListColumns are the fields accepted by ?sort= and filters on the replenishment alert list
+var replenishmentAlertListColumns = utils.ListColumns{
+	"id":                    "ra.id",
+	"replenishment_rule_id": "ra.replenishment_rule_id",
+	"product_id":            "ra.product_id",
+	"product_name":          "p.name",
+	"location_id":           "ra.location_id",
+	"priority":              "ra.priority",
+	"status":                "ra.status",
+	"on_hand":               "ra.on_hand",
+	"created_at":            "ra.created_at",
+	"updated_at":            "ra.updated_at",
+	"resolved_at":           "ra.resolved_at",
+}
+
+func (r *ReplenishmentRepository) GetAllReplenishmentAlerts(query utils.ListQuery) ([]replenishmentAlertResponse, int64, error) {
+	var alerts []replenishmentAlertResponse
+
+	db := r.db().Table("replenishment_alerts ra").
+		Select("ra.id, ra.replenishment_rule_id, ra.product_id, p.name as product_name, ra.location_id, l.name as location_name, " +
+			"ra.priority, ra.status, ra.on_hand, ra.suggested_quantity, ra.created_at, ra.updated_at, ra.resolved_at").
+		Joins("INNER JOIN products p ON ra.product_id = p.id").
+		Joins("LEFT JOIN locations l ON ra.location_id = l.id")
+
+	total, err := utils.FindPage(db, query, replenishmentAlertListColumns, "ra.created_at DESC", &alerts)
+	return alerts, total, err
+}
+
+// GetOpenReplenishmentAlerts returns the open alert of every rule that has one, keyed by rule ID
+func (r *ReplenishmentRepository) GetOpenReplenishmentAlerts() (map[uint]model.ReplenishmentAlert, error) {
+	var alerts []model.ReplenishmentAlert
+	result := r.db().Where("status = ?", model.ReplenishmentAlertOpen).Find(&alerts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	byRule := make(map[uint]model.ReplenishmentAlert, len(alerts))
+	for _, alert := range alerts {
+		byRule[alert.ReplenishmentRuleID] = alert
+	}
+	return byRule, nil
+}
+
+func (r *ReplenishmentRepository) CreateReplenishmentAlert(alert *model.ReplenishmentAlert) error {
+	return r.db().Create(alert).Error
+}
+
+func (r *ReplenishmentRepository) UpdateReplenishmentAlert(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.ReplenishmentAlert{}).Where("id = ?", id).Updates(updateData).Error
+}
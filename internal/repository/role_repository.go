@@ -1,34 +1,68 @@
 package repository
 
 import (
-	"myapp/database"
+	"context"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type RoleRepository struct{}
+type RoleRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 func NewRoleRepository() *RoleRepository {
 	return &RoleRepository{}
 }
 
-func (r *RoleRepository) GetAllRoles() ([]model.Role, error) {
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *RoleRepository) WithTx(tx *gorm.DB) *RoleRepository {
+	return &RoleRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *RoleRepository) WithContext(ctx context.Context) *RoleRepository {
+	return &RoleRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *RoleRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// roleListColumns are the fields accepted by ?sort= and filters on the role list
+var roleListColumns = utils.ListColumns{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func (r *RoleRepository) GetAllRoles(query utils.ListQuery) ([]model.Role, int64, error) {
 	var roles []model.Role
-	result := database.DB.Find(&roles)
-	return roles, result.Error
+	total, err := utils.FindPage(r.db().Model(&model.Role{}), query, roleListColumns, "id ASC", &roles)
+	return roles, total, err
 }
 
 func (r *RoleRepository) GetRoleByID(id uint) (model.Role, error) {
 	var role model.Role
-	result := database.DB.First(&role, id)
+	result := r.db().First(&role, id)
+	return role, result.Error
+}
+
+func (r *RoleRepository) GetRoleByName(name string) (model.Role, error) {
+	var role model.Role
+	result := r.db().Where("name = ?", name).First(&role)
 	return role, result.Error
 }
 
 func (r *RoleRepository) CreateRole(role *model.Role) error {
-	return database.DB.Create(role).Error
+	return r.db().Create(role).Error
 }
 
 func (r *RoleRepository) UpdateRole(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 func (r *RoleRepository) DeleteRoleWithAudit(id uint, userID uint) error {
@@ -38,13 +72,13 @@ func (r *RoleRepository) DeleteRoleWithAudit(id uint, userID uint) error {
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.Role{}, id).Error
+	return r.db().Delete(&model.Role{}, id).Error
 }
 
 func (r *RoleRepository) CheckRoleExists(name string) (bool, error) {
@@ -53,7 +87,7 @@ func (r *RoleRepository) CheckRoleExists(name string) (bool, error) {
 	// Log the query parameters
 	// log.Printf("CheckRoleExists - Searching for role name: %s", name)
 
-	query := database.DB.Model(&model.Role{}).Unscoped().Where("name ILIKE ?", name)
+	query := r.db().Model(&model.Role{}).Unscoped().Where("name ILIKE ?", name)
 
 	// Enable debug mode to see the actual SQL query
 	// result := query.Debug().Count(&count)
@@ -64,7 +98,7 @@ func (r *RoleRepository) CheckRoleExists(name string) (bool, error) {
 // GetDeletedRoles returns all soft deleted roles
 func (r *RoleRepository) GetDeletedRoles() ([]model.Role, error) {
 	var roles []model.Role
-	result := database.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&roles)
+	result := r.db().Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&roles)
 	return roles, result.Error
 }
 
@@ -74,5 +108,5 @@ func (r *RoleRepository) RestoreRole(id uint, userID uint) error {
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.Role{}).Where("id = ?", id).Updates(updateData).Error
 }
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type SearchRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+// productSearchResult struct untuk hasil pencarian product dengan rank
+type productSearchResult struct {
+	ID           uint    `json:"id"`
+	BrandID      uint    `json:"brandId"`
+	BrandName    string  `json:"brandName"`
+	CategoryID   uint    `json:"categoryId"`
+	CategoryName string  `json:"categoryName"`
+	Name         string  `json:"name"`
+	Description  *string `json:"description"`
+	BatchCodes   string  `json:"batchCodes"`
+	Barcodes     string  `json:"barcodes"`
+	Rank         float64 `json:"rank"`
+}
+
+// SearchFacet is one bucket of a search facet (brand or category) with the number of matching products
+type SearchFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ProductSearchParams holds the search keyword, optional facet drill-down and paging
+type ProductSearchParams struct {
+	Keyword    string
+	BrandID    *uint
+	CategoryID *uint
+	Limit      int
+	Offset     int
+}
+
+// productSearchTrigramThreshold is the minimum trigram similarity for a fuzzy (non full-text) match, same as pg_trgm's default
+const productSearchTrigramThreshold = 0.3
+
+// productSearchSQL returns every product matching @q with its rank.
+// A product matches when its weighted tsvector (name, batch codes and barcodes > brand and category > description)
+// matches the websearch query, or when one of the names/codes is similar enough to @q (typos, partial codes).
+const productSearchSQL = `
+	WITH docs AS (
+		SELECT p.id, p.name, p.description, p.category_id, c.name AS category_name, c.brand_id, b.name AS brand_name,
+			COALESCE(pb.codes, '') AS batch_codes, COALESCE(pu.codes, '') AS barcodes
+		FROM products p
+		INNER JOIN categories c ON p.category_id = c.id AND c.deleted_at IS NULL
+		INNER JOIN brands b ON c.brand_id = b.id AND b.deleted_at IS NULL
+		LEFT JOIN LATERAL (
+			SELECT string_agg(code_batch, ' ') AS codes FROM product_batches
+			WHERE product_id = p.id AND deleted_at IS NULL
+		) pb ON true
+		LEFT JOIN LATERAL (
+			SELECT string_agg(barcode, ' ') AS codes FROM product_units
+			WHERE product_id = p.id AND deleted_at IS NULL
+		) pu ON true
+		WHERE p.deleted_at IS NULL AND (CAST(@tenant_id AS bigint) IS NULL OR p.tenant_id = @tenant_id)
+	), scored AS (
+		SELECT d.*,
+			setweight(to_tsvector('simple', d.name || ' ' || d.batch_codes || ' ' || d.barcodes), 'A') ||
+			setweight(to_tsvector('simple', d.brand_name || ' ' || d.category_name), 'B') ||
+			setweight(to_tsvector('simple', COALESCE(d.description, '')), 'C') AS document,
+			GREATEST(
+				similarity(d.name, @q),
+				similarity(d.brand_name, @q),
+				similarity(d.category_name, @q),
+				word_similarity(@q, d.batch_codes),
+				word_similarity(@q, d.barcodes)
+			) AS trigram_rank
+		FROM docs d
+	)
+	SELECT s.id, s.brand_id, s.brand_name, s.category_id, s.category_name, s.name, s.description,
+		s.batch_codes, s.barcodes,
+		ts_rank(s.document, websearch_to_tsquery('simple', @q)) + s.trigram_rank AS rank
+	FROM scored s
+	WHERE s.document @@ websearch_to_tsquery('simple', @q) OR s.trigram_rank >= @threshold`
+
+func NewSearchRepository() *SearchRepository {
+	return &SearchRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *SearchRepository) WithTx(tx *gorm.DB) *SearchRepository {
+	return &SearchRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *SearchRepository) WithContext(ctx context.Context) *SearchRepository {
+	return &SearchRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *SearchRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// productSearchFilter narrows the matches to the requested brand/category facets
+func productSearchFilter(params ProductSearchParams) (string, map[string]interface{}) {
+	where := ""
+	args := map[string]interface{}{
+		"q":         params.Keyword,
+		"threshold": productSearchTrigramThreshold,
+	}
+
+	if params.BrandID != nil {
+		where += " AND m.brand_id = @brand_id"
+		args["brand_id"] = *params.BrandID
+	}
+	if params.CategoryID != nil {
+		where += " AND m.category_id = @category_id"
+		args["category_id"] = *params.CategoryID
+	}
+	return where, args
+}
+
+// SearchProducts returns one page of ranked matches and the total number of matches
+func (r *SearchRepository) SearchProducts(params ProductSearchParams) ([]productSearchResult, int64, error) {
+	var products []productSearchResult
+	var total int64
+
+	where, args := productSearchFilter(params)
+	args["tenant_id"] = tenantArg(r.ctx)
+
+	countSQL := "SELECT COUNT(*) FROM (" + productSearchSQL + ") m WHERE true" + where
+	if err := r.db().Raw(countSQL, args).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	args["limit"] = params.Limit
+	args["offset"] = params.Offset
+	pageSQL := "SELECT * FROM (" + productSearchSQL + ") m WHERE true" + where +
+		" ORDER BY m.rank DESC, m.name ASC, m.id ASC LIMIT @limit OFFSET @offset"
+
+	result := r.db().Raw(pageSQL, args).Scan(&products)
+	return products, total, result.Error
+}
+
+// GetProductSearchFacets counts matches per brand and per category.
+// Facets ignore the brand/category drill-down so clients can still show the other options.
+func (r *SearchRepository) GetProductSearchFacets(keyword string) ([]SearchFacet, []SearchFacet, error) {
+	var brands, categories []SearchFacet
+
+	_, args := productSearchFilter(ProductSearchParams{Keyword: keyword})
+	args["tenant_id"] = tenantArg(r.ctx)
+
+	brandSQL := "SELECT m.brand_id AS id, m.brand_name AS name, COUNT(*) AS count FROM (" + productSearchSQL + ") m" +
+		" GROUP BY m.brand_id, m.brand_name ORDER BY count DESC, name ASC"
+	if err := r.db().Raw(brandSQL, args).Scan(&brands).Error; err != nil {
+		return nil, nil, err
+	}
+
+	categorySQL := "SELECT m.category_id AS id, m.category_name AS name, COUNT(*) AS count FROM (" + productSearchSQL + ") m" +
+		" GROUP BY m.category_id, m.category_name ORDER BY count DESC, name ASC"
+	result := r.db().Raw(categorySQL, args).Scan(&categories)
+
+	return brands, categories, result.Error
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SubLocationRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+// SubLocationResponse is a node of a location layout; bins carry their capacity and the quantity stored in them
+type SubLocationResponse struct {
+	ID           uint                   `json:"id"`
+	LocationID   uint                   `json:"locationId"`
+	ParentID     *uint                  `json:"parentId"`
+	Level        string                 `json:"level"`
+	Code         string                 `json:"code"`
+	Path         string                 `json:"path"`
+	Name         *string                `json:"name"`
+	Capacity     *float64               `json:"capacity"`
+	UsedQuantity float64                `json:"usedQuantity"`
+	Description  *string                `json:"description"`
+	Version      uint                   `json:"version"`
+	Children     []*SubLocationResponse `json:"children,omitempty" gorm:"-"`
+}
+
+// PutawayCandidate is a bin of a location with what it already holds, used to rank putaway suggestions
+type PutawayCandidate struct {
+	ID              uint     `json:"id"`
+	Path            string   `json:"path"`
+	Name            *string  `json:"name"`
+	Capacity        *float64 `json:"capacity"`
+	UsedQuantity    float64  `json:"usedQuantity"`
+	ProductQuantity float64  `json:"productQuantity"` // Quantity of the requested product already in the bin
+	OtherProducts   int64    `json:"otherProducts"`   // Number of stock rows of other products in the bin
+}
+
+func NewSubLocationRepository() *SubLocationRepository {
+	return &SubLocationRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *SubLocationRepository) WithTx(tx *gorm.DB) *SubLocationRepository {
+	return &SubLocationRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *SubLocationRepository) WithContext(ctx context.Context) *SubLocationRepository {
+	return &SubLocationRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *SubLocationRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+const subLocationSelect = "sl.id, sl.location_id, sl.parent_id, sl.level, sl.code, sl.path, sl.name, sl.capacity, sl.description, sl.version, " +
+	"COALESCE((SELECT SUM(ps.quantity) FROM product_stocks ps WHERE ps.bin_id = sl.id AND ps.deleted_at IS NULL), 0) as used_quantity"
+
+// GetSubLocationsByLocation returns the whole layout of a location as a flat list ordered by path
+func (r *SubLocationRepository) GetSubLocationsByLocation(locationID uint) ([]*SubLocationResponse, error) {
+	var subLocations []*SubLocationResponse
+
+	result := r.db().Table("sub_locations sl").
+		Select(subLocationSelect).
+		Where("sl.location_id = ? AND sl.deleted_at IS NULL", locationID).
+		Order("sl.path ASC").
+		Find(&subLocations)
+
+	return subLocations, result.Error
+}
+
+func (r *SubLocationRepository) GetSubLocationByID(id uint) (SubLocationResponse, error) {
+	var subLocation SubLocationResponse
+
+	result := r.db().Table("sub_locations sl").
+		Select(subLocationSelect).
+		Where("sl.id = ? AND sl.deleted_at IS NULL", id).
+		First(&subLocation)
+
+	return subLocation, result.Error
+}
+
+// GetSubLocationModelByID returns model.SubLocation for service operations
+func (r *SubLocationRepository) GetSubLocationModelByID(id uint) (model.SubLocation, error) {
+	var subLocation model.SubLocation
+	result := r.db().Where("id = ?", id).First(&subLocation)
+	return subLocation, result.Error
+}
+
+func (r *SubLocationRepository) CreateSubLocation(subLocation *model.SubLocation) error {
+	return r.db().Create(subLocation).Error
+}
+
+func (r *SubLocationRepository) UpdateSubLocation(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.SubLocation{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+func (r *SubLocationRepository) DeleteSubLocationWithAudit(id uint, userID uint) error {
+	// First update the user_updt field to track who deleted the sub-location
+	updateData := map[string]interface{}{
+		"user_updt":  userID,
+		"updated_at": time.Now(),
+	}
+
+	err := r.db().Model(&model.SubLocation{}).Where("id = ?", id).Updates(updateData).Error
+	if err != nil {
+		return err
+	}
+
+	// Then perform the soft delete
+	return r.db().Delete(&model.SubLocation{}, id).Error
+}
+
+func (r *SubLocationRepository) CheckPathExists(locationID uint, path string) (bool, error) {
+	var count int64
+	result := r.db().Model(&model.SubLocation{}).Where("location_id = ? AND path = ?", locationID, path).Count(&count)
+	return count > 0, result.Error
+}
+
+func (r *SubLocationRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	result := r.db().Model(&model.SubLocation{}).Where("parent_id = ?", id).Count(&count)
+	return count, result.Error
+}
+
+func (r *SubLocationRepository) CountStocksInBin(binID uint) (int64, error) {
+	var count int64
+	result := r.db().Model(&model.ProductStock{}).Where("bin_id = ?", binID).Count(&count)
+	return count, result.Error
+}
+
+// GetBinUsedQuantity sums the quantity stored in a bin, leaving out excludeStockID (the stock being moved or changed)
+func (r *SubLocationRepository) GetBinUsedQuantity(binID uint, excludeStockID uint) (float64, error) {
+	var used float64
+	result := r.db().Model(&model.ProductStock{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("bin_id = ? AND id <> ?", binID, excludeStockID).
+		Scan(&used)
+	return used, result.Error
+}
+
+// GetPutawayCandidates returns every bin of a location with its load and how much of productID it already holds
+func (r *SubLocationRepository) GetPutawayCandidates(locationID, productID uint) ([]PutawayCandidate, error) {
+	var candidates []PutawayCandidate
+
+	result := r.db().Table("sub_locations sl").
+		Select("sl.id, sl.path, sl.name, sl.capacity, "+
+			"COALESCE(SUM(ps.quantity), 0) as used_quantity, "+
+			"COALESCE(SUM(ps.quantity) FILTER (WHERE ps.product_id = ?), 0) as product_quantity, "+
+			"COUNT(ps.id) FILTER (WHERE ps.product_id <> ?) as other_products", productID, productID).
+		Joins("LEFT JOIN product_stocks ps ON ps.bin_id = sl.id AND ps.deleted_at IS NULL").
+		Where("sl.location_id = ? AND sl.level = ? AND sl.deleted_at IS NULL", locationID, model.SubLocationLevelBin).
+		Group("sl.id").
+		Order("sl.path ASC").
+		Find(&candidates)
+
+	return candidates, result.Error
+}
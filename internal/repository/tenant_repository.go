@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TenantRepository manages the tenants themselves, which are not scoped to a tenant
+type TenantRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+func NewTenantRepository() *TenantRepository {
+	return &TenantRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *TenantRepository) WithTx(tx *gorm.DB) *TenantRepository {
+	return &TenantRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository that runs its queries with ctx
+func (r *TenantRepository) WithContext(ctx context.Context) *TenantRepository {
+	return &TenantRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *TenantRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// tenantListColumns are the fields accepted by ?sort= and filters on the tenant list
+var tenantListColumns = utils.ListColumns{
+	"id":         "id",
+	"code":       "code",
+	"name":       "name",
+	"is_active":  "is_active",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func (r *TenantRepository) GetAllTenants(query utils.ListQuery) ([]model.Tenant, int64, error) {
+	var tenants []model.Tenant
+	total, err := utils.FindPage(r.db().Model(&model.Tenant{}), query, tenantListColumns, "id ASC", &tenants)
+	return tenants, total, err
+}
+
+func (r *TenantRepository) GetTenantByID(id uint) (model.Tenant, error) {
+	var tenant model.Tenant
+	result := r.db().Where("id = ?", id).First(&tenant)
+	return tenant, result.Error
+}
+
+func (r *TenantRepository) GetTenantByCode(code string) (model.Tenant, error) {
+	var tenant model.Tenant
+	result := r.db().Where("code = ?", code).First(&tenant)
+	return tenant, result.Error
+}
+
+func (r *TenantRepository) CreateTenant(tenant *model.Tenant) error {
+	return r.db().Create(tenant).Error
+}
+
+func (r *TenantRepository) UpdateTenant(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.Tenant{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+func (r *TenantRepository) DeleteTenantWithAudit(id uint, userID uint) error {
+	// First update the user_updt field to track who deleted the tenant
+	updateData := map[string]interface{}{
+		"user_updt":  userID,
+		"is_active":  false,
+		"updated_at": time.Now(),
+	}
+
+	err := r.db().Model(&model.Tenant{}).Where("id = ?", id).Updates(updateData).Error
+	if err != nil {
+		return err
+	}
+
+	// Then perform the soft delete
+	return r.db().Delete(&model.Tenant{}, id).Error
+}
+
+// CheckTenantCodeExists checks if code is taken, deleted tenants included (excluding a specific tenant ID)
+func (r *TenantRepository) CheckTenantCodeExists(code string, excludeID uint) (bool, error) {
+	var count int64
+	query := r.db().Unscoped().Model(&model.Tenant{}).Where("code = ?", code)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	result := query.Count(&count)
+	return count > 0, result.Error
+}
+
+// IsTenantActive reports whether the tenant exists, is not deleted and is active
+func (r *TenantRepository) IsTenantActive(id uint) (bool, error) {
+	var count int64
+	result := r.db().Model(&model.Tenant{}).Where("id = ? AND is_active = ?", id, true).Count(&count)
+	return count > 0, result.Error
+}
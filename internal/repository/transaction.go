@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"myapp/database"
+	"myapp/internal/tenant"
+
+	"gorm.io/gorm"
+)
+
+// Transaction runs fn inside a database transaction; repositories bound with WithTx(tx) take part in it.
+// The transaction is rolled back when fn returns an error and committed otherwise.
+func Transaction(fn func(tx *gorm.DB) error) error {
+	return database.DB.Transaction(fn)
+}
+
+// dbOrTx returns tx when a repository is bound to a transaction, otherwise the shared connection,
+// carrying ctx so the queries are scoped to its tenant
+func dbOrTx(tx *gorm.DB, ctx context.Context) *gorm.DB {
+	db := database.DB
+	if tx != nil {
+		db = tx
+	}
+	if ctx != nil {
+		return db.WithContext(ctx)
+	}
+	return db
+}
+
+// tenantArg returns the tenant of ctx as an argument for raw SQL, which the tenant plugin does not rewrite; nil when unscoped
+func tenantArg(ctx context.Context) interface{} {
+	if id, ok := tenant.FromContext(ctx); ok {
+		return id
+	}
+	return nil
+}
+
+// PingDatabase checks that the database answers within the deadline of ctx
+func PingDatabase(ctx context.Context) error {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -1,36 +1,65 @@
 package repository
 
 import (
+	"context"
 	"errors"
-	"log"
-	"myapp/database"
 	"myapp/internal/model"
+	"myapp/internal/utils"
+	"myapp/pkg/logger"
 
 	"gorm.io/gorm"
 )
 
-type UserRepository struct{}
+var userRepoLog = logger.New("user")
+
+type UserRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
 
 func NewUserRepository() *UserRepository {
 	return &UserRepository{}
 }
 
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	return &UserRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *UserRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
 // Basic GORM queries
-func (r *UserRepository) GetAllUsers() ([]model.User, error) {
+// userListColumns are the fields accepted by ?sort= and filters on the user list
+var userListColumns = utils.ListColumns{
+	"id":         "id",
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+}
+
+func (r *UserRepository) GetAllUsers(query utils.ListQuery) ([]model.User, int64, error) {
 	var users []model.User
-	result := database.DB.Select("id, name, email").Find(&users)
-	return users, result.Error
+	db := r.db().Model(&model.User{}).Select("id, name, email, role_id")
+	total, err := utils.FindPage(db, query, userListColumns, "id ASC", &users)
+	return users, total, err
 }
 
 func (r *UserRepository) GetUsersMinimal() ([]UserMinimal, error) {
 	var users []UserMinimal
-	result := database.DB.Model(&model.User{}).Select("id, name").Find(&users)
+	result := r.db().Model(&model.User{}).Select("id, name").Find(&users)
 	return users, result.Error
 }
 
 func (r *UserRepository) GetUserByID(id uint) (*model.User, error) {
 	var user model.User
-	result := database.DB.First(&user, id)
+	result := r.db().First(&user, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -40,14 +69,14 @@ func (r *UserRepository) GetUserByID(id uint) (*model.User, error) {
 func (r *UserRepository) GetUserByEmail(email string) (*model.User, error) {
 	var user model.User
 	query := "SELECT * FROM users WHERE email = ?"
-	result := database.DB.Raw(query, email).Scan(&user)
+	result := r.db().Raw(query, email).Scan(&user)
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			log.Printf("User with email %s not found", email)
+			userRepoLog.DebugContext(r.ctx, "User not found", "email", email)
 			return nil, nil // Tidak ditemukan
 		}
-		log.Printf("Database error: %v", result.Error)
+		userRepoLog.ErrorContext(r.ctx, "Get user by email failed", "error", result.Error)
 		return nil, result.Error
 	}
 
@@ -56,15 +85,19 @@ func (r *UserRepository) GetUserByEmail(email string) (*model.User, error) {
 }
 
 func (r *UserRepository) CreateUser(user *model.User) error {
-	return database.DB.Create(user).Error
+	return r.db().Create(user).Error
 }
 
+// userTenantCondition limits raw user queries to the tenant passed twice as argument, or to none when it is nil
+const userTenantCondition = "(CAST(? AS bigint) IS NULL OR tenant_id = ?)"
+
 // Raw SQL Queries
 func (r *UserRepository) GetUsersWithRawSQL() ([]model.User, error) {
 	var users []model.User
 
-	query := "SELECT id, name, email, created_at, updated_at FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC"
-	result := database.DB.Raw(query).Scan(&users)
+	query := "SELECT id, name, email, created_at, updated_at FROM users WHERE deleted_at IS NULL AND " + userTenantCondition + " ORDER BY created_at DESC"
+	tenantID := tenantArg(r.ctx)
+	result := r.db().Raw(query, tenantID, tenantID).Scan(&users)
 
 	return users, result.Error
 }
@@ -72,8 +105,9 @@ func (r *UserRepository) GetUsersWithRawSQL() ([]model.User, error) {
 func (r *UserRepository) GetUsersWithStats() ([]UserResult, error) {
 	var users []UserResult
 
-	query := "SELECT id, name, email, COUNT(*) OVER() as total FROM users WHERE deleted_at IS NULL"
-	result := database.DB.Raw(query).Scan(&users)
+	query := "SELECT id, name, email, COUNT(*) OVER() as total FROM users WHERE deleted_at IS NULL AND " + userTenantCondition
+	tenantID := tenantArg(r.ctx)
+	result := r.db().Raw(query, tenantID, tenantID).Scan(&users)
 
 	return users, result.Error
 }
@@ -86,12 +120,14 @@ func (r *UserRepository) SearchUsersRaw(keyword string, limit int, offset int) (
 		FROM users 
 		WHERE (name ILIKE ? OR email ILIKE ?) 
 		AND deleted_at IS NULL 
+		AND ` + userTenantCondition + `
 		ORDER BY created_at DESC 
 		LIMIT ? OFFSET ?
 	`
 
 	searchTerm := "%" + keyword + "%"
-	result := database.DB.Raw(query, searchTerm, searchTerm, limit, offset).Scan(&users)
+	tenantID := tenantArg(r.ctx)
+	result := r.db().Raw(query, searchTerm, searchTerm, tenantID, tenantID, limit, offset).Scan(&users)
 
 	return users, result.Error
 }
@@ -105,9 +141,11 @@ func (r *UserRepository) GetUsersStats() (*UserStats, error) {
 			COUNT(CASE WHEN deleted_at IS NULL THEN 1 END) as active_users,
 			COUNT(CASE WHEN deleted_at IS NOT NULL THEN 1 END) as deleted_users
 		FROM users
+		WHERE ` + userTenantCondition + `
 	`
 
-	result := database.DB.Raw(query).Scan(&stats)
+	tenantID := tenantArg(r.ctx)
+	result := r.db().Raw(query, tenantID, tenantID).Scan(&stats)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -118,7 +156,8 @@ func (r *UserRepository) GetUsersStats() (*UserStats, error) {
 // CheckEmailExists checks if email already exists (excluding a specific user ID)
 func (r *UserRepository) CheckEmailExists(email string, excludeID uint) (bool, error) {
 	var count int64
-	query := database.DB.Model(&model.User{}).Where("email = ?", email)
+	// Emails are unique across tenants, since users sign in with their email alone
+	query := dbOrTx(r.tx, nil).Model(&model.User{}).Where("email = ?", email)
 
 	if excludeID > 0 {
 		query = query.Where("id != ?", excludeID)
@@ -128,7 +167,30 @@ func (r *UserRepository) CheckEmailExists(email string, excludeID uint) (bool, e
 	return count > 0, result.Error
 }
 
+// GetUserAccess returns whether a user has the Admin role and whether they are assigned to a reseller location
+func (r *UserRepository) GetUserAccess(userID uint) (UserAccess, error) {
+	var access UserAccess
+	query := `SELECT
+		EXISTS (SELECT 1 FROM users u INNER JOIN roles ro ON u.role_id = ro.id AND ro.deleted_at IS NULL
+			WHERE u.id = ? AND ro.name = ?) AS is_admin,
+		EXISTS (SELECT 1 FROM locations l WHERE l.user_id = ? AND l.type = 'reseller' AND l.deleted_at IS NULL) AS is_reseller`
+	result := r.db().Raw(query, userID, model.RoleAdmin, userID).Scan(&access)
+	return access, result.Error
+}
+
+// CheckRoleExists checks if a role exists in the tenant
+func (r *UserRepository) CheckRoleExists(roleID uint) (bool, error) {
+	var count int64
+	result := r.db().Model(&model.Role{}).Where("id = ?", roleID).Count(&count)
+	return count > 0, result.Error
+}
+
 // Struct definitions
+type UserAccess struct {
+	IsAdmin    bool `json:"is_admin"`
+	IsReseller bool `json:"is_reseller"`
+}
+
 type UserMinimal struct {
 	ID   uint   `json:"id"`
 	Name string `json:"name"`
@@ -149,7 +211,7 @@ type UserStats struct {
 
 // UpdateUser updates user data
 func (r *UserRepository) UpdateUser(id uint, updateData map[string]interface{}) error {
-	return database.DB.Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
 }
 
 // DeleteUserWithAudit performs soft delete with audit trail
@@ -160,19 +222,19 @@ func (r *UserRepository) DeleteUserWithAudit(id uint, userID uint) error {
 	}
 
 	// Update the audit field first
-	err := database.DB.Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
+	err := r.db().Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
 
 	// Then perform the soft delete
-	return database.DB.Delete(&model.User{}, id).Error
+	return r.db().Delete(&model.User{}, id).Error
 }
 
 // GetDeletedUsers returns all soft deleted users
 func (r *UserRepository) GetDeletedUsers() ([]model.User, error) {
 	var users []model.User
-	result := database.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&users)
+	result := r.db().Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&users)
 	return users, result.Error
 }
 
@@ -182,5 +244,5 @@ func (r *UserRepository) RestoreUser(id uint, userID uint) error {
 		"user_updt":  userID,
 		"deleted_at": nil,
 	}
-	return database.DB.Unscoped().Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
+	return r.db().Unscoped().Model(&model.User{}).Where("id = ?", id).Updates(updateData).Error
 }
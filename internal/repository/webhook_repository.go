@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository struct {
+	tx  *gorm.DB
+	ctx context.Context
+}
+
+// WebhookSubscriptionResponse is a subscription without its secret, with the events split into a list
+type WebhookSubscriptionResponse struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Events      string    `json:"-"`
+	EventList   []string  `gorm:"-" json:"events"`
+	IsActive    bool      `json:"isActive"`
+	Description *string   `json:"description"`
+	Version     uint      `json:"version"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// webhookDeliveryResponse struct untuk response delivery log
+type webhookDeliveryResponse struct {
+	ID                    uint       `json:"id"`
+	WebhookSubscriptionID uint       `json:"webhookSubscriptionId"`
+	Event                 string     `json:"event"`
+	ResourceID            *uint      `json:"resourceId"`
+	Payload               string     `json:"payload"`
+	Status                string     `json:"status"`
+	Attempts              int        `json:"attempts"`
+	NextAttemptAt         *time.Time `json:"nextAttemptAt"`
+	LastAttemptAt         *time.Time `json:"lastAttemptAt"`
+	ResponseStatus        *int       `json:"responseStatus"`
+	LastError             *string    `json:"lastError"`
+	DeliveredAt           *time.Time `json:"deliveredAt"`
+	CreatedAt             time.Time  `json:"createdAt"`
+}
+
+// ExpiringBatch is an available batch whose expiry date falls inside the warning window
+type ExpiringBatch struct {
+	ID          uint      `json:"id"`
+	ProductID   uint      `json:"productId"`
+	ProductName string    `json:"productName"`
+	CodeBatch   *string   `json:"codeBatch"`
+	ExpDate     time.Time `json:"expDate"`
+	Quantity    float64   `json:"quantity"`
+	TenantID    *uint     `json:"-"`
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{}
+}
+
+// WithTx returns a copy of the repository that runs every query inside tx
+func (r *WebhookRepository) WithTx(tx *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{tx: tx, ctx: r.ctx}
+}
+
+// WithContext returns a copy of the repository whose queries are scoped to the tenant of ctx
+func (r *WebhookRepository) WithContext(ctx context.Context) *WebhookRepository {
+	return &WebhookRepository{tx: r.tx, ctx: ctx}
+}
+
+func (r *WebhookRepository) db() *gorm.DB {
+	return dbOrTx(r.tx, r.ctx)
+}
+
+// webhookSubscriptionListColumns are the fields accepted by ?sort= and filters on the webhook list
+var webhookSubscriptionListColumns = utils.ListColumns{
+	"id":         "id",
+	"url":        "url",
+	"is_active":  "is_active",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+func (r *WebhookRepository) subscriptions() *gorm.DB {
+	return r.db().Table("webhook_subscriptions").
+		Select("id, url, events, is_active, description, version, created_at, updated_at").
+		Where("deleted_at IS NULL")
+}
+
+func (r *WebhookRepository) GetAllWebhookSubscriptions(query utils.ListQuery) ([]WebhookSubscriptionResponse, int64, error) {
+	var subscriptions []WebhookSubscriptionResponse
+	total, err := utils.FindPage(r.subscriptions(), query, webhookSubscriptionListColumns, "id ASC", &subscriptions)
+	for i := range subscriptions {
+		subscriptions[i].EventList = SplitWebhookEvents(subscriptions[i].Events)
+	}
+	return subscriptions, total, err
+}
+
+func (r *WebhookRepository) GetWebhookSubscriptionByID(id uint) (WebhookSubscriptionResponse, error) {
+	var subscription WebhookSubscriptionResponse
+	result := r.subscriptions().Where("id = ?", id).First(&subscription)
+	subscription.EventList = SplitWebhookEvents(subscription.Events)
+	return subscription, result.Error
+}
+
+// GetWebhookSubscriptionModelByID returns model.WebhookSubscription for service operations
+func (r *WebhookRepository) GetWebhookSubscriptionModelByID(id uint) (model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	result := r.db().Where("id = ?", id).First(&subscription)
+	return subscription, result.Error
+}
+
+func (r *WebhookRepository) CreateWebhookSubscription(subscription *model.WebhookSubscription) error {
+	return r.db().Create(subscription).Error
+}
+
+func (r *WebhookRepository) UpdateWebhookSubscription(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.WebhookSubscription{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+func (r *WebhookRepository) DeleteWebhookSubscriptionWithAudit(id uint, userID uint) error {
+	// First update the user_updt field to track who deleted the subscription
+	updateData := map[string]interface{}{
+		"user_updt":  userID,
+		"updated_at": time.Now(),
+	}
+
+	err := r.db().Model(&model.WebhookSubscription{}).Where("id = ?", id).Updates(updateData).Error
+	if err != nil {
+		return err
+	}
+
+	// Then perform the soft delete
+	return r.db().Delete(&model.WebhookSubscription{}, id).Error
+}
+
+// subscriptionsForEvent selects the active subscriptions listening to event
+func (r *WebhookRepository) subscriptionsForEvent(event string) *gorm.DB {
+	return r.db().Where("is_active = ?", true).
+		Where("(events = ? OR ',' || events || ',' LIKE ?)", model.WebhookEventAll, "%,"+event+",%")
+}
+
+// GetSubscriptionsForEvent returns the active subscriptions listening to event, leaving out those that
+// already received it for resourceID when one is given
+func (r *WebhookRepository) GetSubscriptionsForEvent(event string, resourceID *uint) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+
+	db := r.subscriptionsForEvent(event)
+	if resourceID != nil {
+		db = db.Where("NOT EXISTS (SELECT 1 FROM webhook_deliveries wd WHERE wd.webhook_subscription_id = webhook_subscriptions.id AND wd.event = ? AND wd.resource_id = ?)", event, *resourceID)
+	}
+
+	result := db.Order("id ASC").Find(&subscriptions)
+	return subscriptions, result.Error
+}
+
+// GetSubscriptionsForOutboxEvent returns the active subscriptions listening to event that have no delivery for the outbox event yet
+func (r *WebhookRepository) GetSubscriptionsForOutboxEvent(event string, outboxEventID uint) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+
+	result := r.subscriptionsForEvent(event).
+		Where("NOT EXISTS (SELECT 1 FROM webhook_deliveries wd WHERE wd.webhook_subscription_id = webhook_subscriptions.id AND wd.outbox_event_id = ?)", outboxEventID).
+		Order("id ASC").
+		Find(&subscriptions)
+
+	return subscriptions, result.Error
+}
+
+func (r *WebhookRepository) CreateWebhookDeliveries(deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db().Create(&deliveries).Error
+}
+
+func (r *WebhookRepository) CreateWebhookDelivery(delivery *model.WebhookDelivery) error {
+	return r.db().Create(delivery).Error
+}
+
+// ClaimDueWebhookDeliveries locks up to limit pending deliveries that are due and pushes their next attempt back by lease,
+// so another worker does not send them again while they are in flight
+func (r *WebhookRepository) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&model.WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookDeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		err = tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Preload("WebhookSubscription").Where("id IN ?", ids).Order("id ASC").Find(&deliveries).Error
+	})
+
+	return deliveries, err
+}
+
+func (r *WebhookRepository) UpdateWebhookDelivery(id uint, updateData map[string]interface{}) error {
+	return r.db().Model(&model.WebhookDelivery{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+// GetWebhookDeliveryModelByID returns model.WebhookDelivery for service operations
+func (r *WebhookRepository) GetWebhookDeliveryModelByID(id uint) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	result := r.db().Where("id = ?", id).First(&delivery)
+	return delivery, result.Error
+}
+
+// webhookDeliveryListColumns are the fields accepted by ?sort= and filters on the delivery log
+var webhookDeliveryListColumns = utils.ListColumns{
+	"id":              "id",
+	"event":           "event",
+	"resource_id":     "resource_id",
+	"status":          "status",
+	"attempts":        "attempts",
+	"response_status": "response_status",
+	"created_at":      "created_at",
+	"delivered_at":    "delivered_at",
+}
+
+func (r *WebhookRepository) deliveries() *gorm.DB {
+	return r.db().Table("webhook_deliveries").
+		Select("id, webhook_subscription_id, event, resource_id, payload, status, attempts, next_attempt_at, last_attempt_at, " +
+			"response_status, last_error, delivered_at, created_at")
+}
+
+func (r *WebhookRepository) GetWebhookDeliveries(subscriptionID uint, query utils.ListQuery) ([]webhookDeliveryResponse, int64, error) {
+	var deliveries []webhookDeliveryResponse
+	db := r.deliveries().Where("webhook_subscription_id = ?", subscriptionID)
+	total, err := utils.FindPage(db, query, webhookDeliveryListColumns, "created_at DESC, id DESC", &deliveries)
+	return deliveries, total, err
+}
+
+func (r *WebhookRepository) GetWebhookDeliveryByID(id uint) (webhookDeliveryResponse, error) {
+	var delivery webhookDeliveryResponse
+	result := r.deliveries().Where("id = ?", id).First(&delivery)
+	return delivery, result.Error
+}
+
+// GetExpiringBatches returns available batches with stock left that expire between today and before
+func (r *WebhookRepository) GetExpiringBatches(today, before time.Time) ([]ExpiringBatch, error) {
+	var batches []ExpiringBatch
+
+	result := r.db().Table("product_batches pb").
+		Select("pb.id, pb.product_id, p.name as product_name, pb.code_batch, pb.exp_date, SUM(ps.quantity) as quantity, pb.tenant_id").
+		Joins("INNER JOIN products p ON pb.product_id = p.id AND p.deleted_at IS NULL").
+		Joins("INNER JOIN product_stocks ps ON ps.product_batch_id = pb.id AND ps.deleted_at IS NULL").
+		Where("pb.deleted_at IS NULL AND pb.status = ?", model.StockStatusAvailable).
+		Where("pb.exp_date >= ? AND pb.exp_date < ?", today, before).
+		Group("pb.id, pb.product_id, p.name, pb.code_batch, pb.exp_date, pb.tenant_id").
+		Having("SUM(ps.quantity) > 0").
+		Order("pb.exp_date ASC, pb.id ASC").
+		Find(&batches)
+
+	return batches, result.Error
+}
+
+// SplitWebhookEvents turns the stored comma separated events into a list
+func SplitWebhookEvents(events string) []string {
+	list := []string{}
+	for _, event := range strings.Split(events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			list = append(list, event)
+		}
+	}
+	return list
+}
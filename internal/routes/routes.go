@@ -2,6 +2,8 @@ package routes
 
 import (
     "github.com/gofiber/fiber/v2"
+    "myapp/internal/handler"
+    "myapp/internal/metrics"
     v1 "myapp/internal/routes/v1"
     // Import v2 untuk future development
     // v2 "myapp/internal/routes/v2"
@@ -26,12 +28,11 @@ func SetupRoutes(app *fiber.App) {
         })
     })
     
-    // Global health check
-    app.Get("/health", func(c *fiber.Ctx) error {
-        return c.JSON(fiber.Map{
-            "status": "OK",
-            "service": "GO-WMS",
-            "message": "Service is running",
-        })
-    })
+    // Global health checks: /health and /health/ready ping Postgres and Redis, /health/live only the process
+    app.Get("/health", handler.HealthReady)
+    app.Get("/health/live", handler.HealthLive)
+    app.Get("/health/ready", handler.HealthReady)
+
+    // Prometheus metrics
+    app.Get("/metrics", metrics.Handler())
 }
\ No newline at end of file
@@ -15,7 +15,7 @@ func SetupAuthRoutes(router fiber.Router) {
     auth.Post("/register", handler.Register)    // POST /api/v1/auth/register
     
     // Protected auth routes (require JWT)
-    authProtected := auth.Group("/", middleware.JWTMiddleware())
+    authProtected := auth.Group("/", middleware.JWTAccountMiddleware())
     authProtected.Get("/profile", handler.GetProfile)    // GET /api/v1/auth/profile
     authProtected.Put("/profile", handler.UpdateProfile) // PUT /api/v1/auth/profile (future)
     authProtected.Post("/logout", handler.Logout)        // POST /api/v1/auth/logout (future)
@@ -3,6 +3,7 @@ package brand
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,15 +14,18 @@ func SetupBrandRoutes(router fiber.Router) {
 	// Protected brand routes (require JWT)
 	brands.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.Brand{})
+
 	// IMPORTANT: Specific routes MUST come BEFORE parameterized routes
 	// Specific routes (no parameters)
 	brands.Get("/", handler.GetBrands)               // GET /api/v1/brands
 	brands.Get("/deleted", handler.GetDeletedBrands) // GET /api/v1/brands/deleted
 
 	// Parameterized routes (MUST be at the end)
-	brands.Get("/:id", handler.GetBrandByID)         // GET /api/v1/brands/:id
-	brands.Post("/", handler.CreateBrand)            // POST /api/v1/brands
-	brands.Put("/:id", handler.UpdateBrand)          // PUT /api/v1/brands/:id
-	brands.Delete("/:id", handler.DeleteBrand)       // DELETE /api/v1/brands/:id
-	brands.Put("/:id/restore", handler.RestoreBrand) // PUT /api/v1/brands/:id/restore
+	brands.Get("/:id", handler.GetBrandByID)                  // GET /api/v1/brands/:id
+	brands.Post("/", handler.CreateBrand)                     // POST /api/v1/brands
+	brands.Put("/:id", ifMatch, handler.UpdateBrand)          // PUT /api/v1/brands/:id
+	brands.Delete("/:id", ifMatch, handler.DeleteBrand)       // DELETE /api/v1/brands/:id
+	brands.Put("/:id/restore", ifMatch, handler.RestoreBrand) // PUT /api/v1/brands/:id/restore
 }
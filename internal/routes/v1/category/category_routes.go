@@ -3,6 +3,7 @@ package category
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,9 @@ func SetupCategoryRoutes(router fiber.Router) {
 	// Protected category routes (require JWT)
 	categories.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.Category{})
+
 	// IMPORTANT: Specific routes MUST come BEFORE parameterized routes
 	// Specific routes (no parameters)
 	categories.Get("/", handler.GetCategories)                      // GET /api/v1/categories
@@ -20,9 +24,9 @@ func SetupCategoryRoutes(router fiber.Router) {
 	categories.Get("/brand/:brandId", handler.GetCategoriesByBrand) // GET /api/v1/categories/brand/:brandId
 
 	// Parameterized routes (MUST be at the end)
-	categories.Get("/:id", handler.GetCategoryByID)         // GET /api/v1/categories/:id
-	categories.Post("/", handler.CreateCategory)            // POST /api/v1/categories
-	categories.Put("/:id", handler.UpdateCategory)          // PUT /api/v1/categories/:id
-	categories.Put("/:id/restore", handler.RestoreCategory) // PUT /api/v1/categories/:id/restore
-	categories.Delete("/:id", handler.DeleteCategory)       // DELETE /api/v1/categories/:id
+	categories.Get("/:id", handler.GetCategoryByID)                  // GET /api/v1/categories/:id
+	categories.Post("/", handler.CreateCategory)                     // POST /api/v1/categories
+	categories.Put("/:id", ifMatch, handler.UpdateCategory)          // PUT /api/v1/categories/:id
+	categories.Put("/:id/restore", ifMatch, handler.RestoreCategory) // PUT /api/v1/categories/:id/restore
+	categories.Delete("/:id", ifMatch, handler.DeleteCategory)       // DELETE /api/v1/categories/:id
 }
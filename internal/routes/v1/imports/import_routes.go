@@ -0,0 +1,18 @@
+package imports
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupImportRoutes(router fiber.Router) {
+	imports := router.Group("/imports")
+	imports.Use(middleware.JWTMiddleware()) // All routes require authentication
+	{
+		// POST /api/v1/imports/:entity?dry_run=true - Import a CSV/XLSX file (multipart field "file")
+		// entity: brands, categories, products, product-batches, product-units, product-stocks
+		imports.Post("/:entity", handler.ImportRecords)
+	}
+}
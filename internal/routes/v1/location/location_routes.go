@@ -3,6 +3,7 @@ package location
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,10 @@ func LocationRoutes(router fiber.Router) {
 	// Apply JWT middleware
 	location.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatchLocation := middleware.IfMatchMiddleware(&model.Location{})
+	ifMatchSubLocation := middleware.IfMatchMiddleware(&model.SubLocation{})
+
 	// GET /api/v1/locations - Get all locations
 	location.Get("/", handler.GetLocations)
 
@@ -28,15 +33,30 @@ func LocationRoutes(router fiber.Router) {
 	// GET /api/v1/locations/:id - Get location by ID
 	location.Get("/:id", handler.GetLocationByID)
 
+	// GET /api/v1/locations/:id/sub-locations - Get the zone > aisle > rack > bin layout of a gudang location
+	location.Get("/:id/sub-locations", handler.GetLocationLayout)
+
+	// POST /api/v1/locations/:id/sub-locations - Create a zone, aisle, rack or bin
+	location.Post("/:id/sub-locations", handler.CreateSubLocation)
+
+	// PUT /api/v1/locations/:id/sub-locations/:subLocationId - Update a sub-location
+	location.Put("/:id/sub-locations/:subLocationId", ifMatchSubLocation, handler.UpdateSubLocation)
+
+	// DELETE /api/v1/locations/:id/sub-locations/:subLocationId - Delete an empty sub-location
+	location.Delete("/:id/sub-locations/:subLocationId", ifMatchSubLocation, handler.DeleteSubLocation)
+
+	// GET /api/v1/locations/:id/putaway-suggestions - Suggest bins for received goods
+	location.Get("/:id/putaway-suggestions", handler.GetPutawaySuggestions)
+
 	// POST /api/v1/locations - Create new location
 	location.Post("/", handler.CreateLocation)
 
 	// PUT /api/v1/locations/:id - Update location by ID
-	location.Put("/:id", handler.UpdateLocation)
+	location.Put("/:id", ifMatchLocation, handler.UpdateLocation)
 
 	// PUT /api/v1/locations/:id/restore - Restore deleted location
-	location.Put("/:id/restore", handler.RestoreLocation)
+	location.Put("/:id/restore", ifMatchLocation, handler.RestoreLocation)
 
 	// DELETE /api/v1/locations/:id - Delete location by ID
-	location.Delete("/:id", handler.DeleteLocation)
+	location.Delete("/:id", ifMatchLocation, handler.DeleteLocation)
 }
@@ -0,0 +1,257 @@
+package v1
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/model"
+	"myapp/internal/openapi"
+	"myapp/internal/repository"
+	"myapp/internal/service"
+	"myapp/pkg/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// openAPIConfig documents the v1 routes served at /api/v1/openapi.json. Every route registered below /api/v1 needs an
+// endpoint here, the route coverage test fails otherwise. Summaries default to the handler name in words.
+var openAPIConfig = openapi.Config{
+	Title:          "GO-WMS API",
+	Version:        "v1",
+	Description:    "Warehouse management API. Responses are wrapped in {code, message, data, error, meta}.",
+	Prefix:         "/api/v1",
+	Endpoints:      endpoints,
+	Authenticators: []string{"JWTMiddleware", "JWTAccountMiddleware", "JWTStreamMiddleware"},
+	Preconditions:  []string{"IfMatchMiddleware"},
+	Idempotency:    []string{"IdempotencyMiddleware"},
+}
+
+// query is an optional query parameter
+func query(name, schemaType, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: schemaType}}
+}
+
+var cursorQuery = []openapi.Parameter{
+	query("cursor", "string", "next_cursor of the previous page, empty for the first page"),
+	query("limit", "integer", "Rows per page"),
+}
+
+var endpoints = map[string]openapi.Endpoint{
+	// Meta
+	"GET /health":       {Summary: "API v1 health check", ContentType: fiber.MIMEApplicationJSON},
+	"GET /openapi.json": {Summary: "OpenAPI document of this API", ContentType: fiber.MIMEApplicationJSON},
+	"GET /docs":         {Summary: "Swagger UI of this API", ContentType: fiber.MIMETextHTML},
+
+	// Auth
+	"POST /auth/login":    {Request: handler.LoginRequest{}, Response: handler.RegisterResponse{}},
+	"POST /auth/register": {Request: handler.RegisterRequest{}, Response: handler.RegisterResponse{}, Status: 201, Description: "Send X-Tenant-ID to register in a tenant other than the default one."},
+	"GET /auth/profile":   {Response: handler.UserResponse{}},
+	"PUT /auth/profile":   {Summary: "Update profile (not implemented yet)"},
+	"POST /auth/logout":   {},
+
+	// Users
+	"GET /users":            {Response: []model.User{}, List: true, Export: true},
+	"GET /users/deleted":    {Response: []model.User{}},
+	"GET /users/minimal":    {Response: []repository.UserMinimal{}},
+	"GET /users/raw":        {Summary: "Get users with stats", Response: []repository.UserResult{}},
+	"GET /users/repository": {Response: []model.User{}},
+	"GET /users/rawQuery":   {Summary: "Get users from repository (alias)", Response: []model.User{}},
+	"GET /users/search": {Response: []model.User{}, Query: []openapi.Parameter{
+		query("q", "string", "Keyword matched against name and email"),
+		query("limit", "integer", "Default 10"),
+		query("offset", "integer", "Default 0"),
+	}},
+	"GET /users/stats":       {Response: repository.UserStats{}},
+	"GET /users/:id":         {Summary: "Get user by ID", Response: model.User{}},
+	"PUT /users/:id/restore": {Response: model.User{}},
+	"PUT /users/:id/role":    {Summary: "Assign a role to a user (admins only)", Request: handler.AssignUserRoleRequest{}, Response: model.User{}},
+
+	// Roles
+	"GET /roles":             {Response: []model.Role{}, List: true, Export: true},
+	"GET /roles/deleted":     {Response: []model.Role{}},
+	"GET /roles/:id":         {Response: model.Role{}},
+	"POST /roles":            {Request: handler.CreateRoleRequest{}, Response: model.Role{}, Status: 201},
+	"PUT /roles/:id":         {Request: handler.UpdateRoleRequest{}, Response: model.Role{}},
+	"PUT /roles/:id/restore": {Response: model.Role{}},
+	"DELETE /roles/:id":      {},
+
+	// Brands
+	"GET /brands":             {Response: []model.Brand{}, List: true, Export: true},
+	"GET /brands/deleted":     {Response: []model.Brand{}},
+	"GET /brands/:id":         {Response: model.Brand{}},
+	"POST /brands":            {Request: handler.CreateBrandRequest{}, Response: model.Brand{}},
+	"PUT /brands/:id":         {Request: handler.UpdateBrandRequest{}, Response: model.Brand{}},
+	"PUT /brands/:id/restore": {Response: model.Brand{}},
+	"DELETE /brands/:id":      {},
+
+	// Categories
+	"GET /categories":                {Response: []model.Category{}, List: true, Export: true},
+	"GET /categories/deleted":        {Response: []model.Category{}},
+	"GET /categories/brand/:brandId": {Response: []model.Category{}},
+	"GET /categories/:id":            {Response: model.Category{}},
+	"POST /categories":               {Request: handler.CreateCategoryRequest{}, Response: model.Category{}, Status: 201},
+	"PUT /categories/:id":            {Request: handler.UpdateCategoryRequest{}, Response: model.Category{}},
+	"PUT /categories/:id/restore":    {Response: model.Category{}},
+	"DELETE /categories/:id":         {},
+
+	// Products
+	"GET /products":                        {Response: []model.Product{}, List: true, Export: true},
+	"GET /products/deleted":                {Response: []model.Product{}},
+	"GET /products/categories/:categoryId": {Response: []model.Product{}},
+	"GET /products/:id":                    {Response: model.Product{}},
+	"GET /products/:productId/batches":     {Response: []model.ProductBatch{}},
+	"POST /products":                       {Request: handler.CreateProductRequest{}, Response: model.Product{}, Status: 201},
+	"PUT /products/:id":                    {Request: handler.UpdateProductRequest{}, Response: model.Product{}},
+	"PUT /products/:id/restore":            {Response: model.Product{}},
+	"DELETE /products/:id":                 {},
+
+	// Product batches
+	"GET /product-batches":             {Response: []model.ProductBatch{}, List: true, Export: true},
+	"GET /product-batches/deleted":     {Response: []model.ProductBatch{}},
+	"GET /product-batches/:id":         {Response: model.ProductBatch{}},
+	"GET /product-batches/:id/trace":   {Summary: "Trace where a batch went", Response: service.BatchTraceReport{}, Export: true},
+	"POST /product-batches":            {Request: handler.CreateProductBatchRequest{}, Response: model.ProductBatch{}, Status: 201},
+	"PUT /product-batches/:id":         {Request: handler.UpdateProductBatchRequest{}, Response: model.ProductBatch{}},
+	"PUT /product-batches/:id/restore": {Response: model.ProductBatch{}},
+	"PUT /product-batches/:id/hold":    {Summary: "Place a product batch on hold", Request: handler.HoldRequest{}, Response: model.ProductBatch{}},
+	"PUT /product-batches/:id/release": {Summary: "Release a product batch from hold", Request: handler.ReleaseHoldRequest{}, Response: model.ProductBatch{}},
+	"DELETE /product-batches/:id":      {},
+
+	// Product units
+	"GET /product-units":                    {Response: []model.ProductUnit{}, List: true, Export: true},
+	"GET /product-units/deleted":            {Response: []model.ProductUnit{}},
+	"GET /product-units/product/:productId": {Response: []model.ProductUnit{}},
+	"GET /product-units/:id":                {Response: model.ProductUnit{}},
+	"POST /product-units":                   {Request: handler.CreateProductUnitRequest{}, Response: model.ProductUnit{}, Status: 201},
+	"PUT /product-units/:id":                {Request: handler.UpdateProductUnitRequest{}, Response: model.ProductUnit{}},
+	"PUT /product-units/:id/restore":        {Response: model.ProductUnit{}},
+	"DELETE /product-units/:id":             {},
+
+	// Product unit tracks
+	"GET /product-unit-tracks":                             {Response: []model.ProductUnitTrack{}, List: true, Export: true},
+	"GET /product-unit-tracks/product/:productId":          {Response: []model.ProductUnitTrack{}},
+	"GET /product-unit-tracks/product-unit/:productUnitId": {Response: []model.ProductUnitTrack{}},
+	"GET /product-unit-tracks/:id":                         {Response: model.ProductUnitTrack{}},
+	"POST /product-unit-tracks":                            {Request: handler.CreateProductUnitTrackRequest{}, Response: model.ProductUnitTrack{}, Status: 201},
+	"DELETE /product-unit-tracks/:id":                      {},
+
+	// Locations
+	"GET /locations":                                     {Response: []model.Location{}, List: true, Export: true},
+	"GET /locations/deleted":                             {Response: []model.Location{}},
+	"GET /locations/user/:userId":                        {Response: []model.Location{}},
+	"GET /locations/type/:type":                          {Summary: "Get locations by type (gudang or reseller)", Response: []model.Location{}},
+	"GET /locations/:id":                                 {Response: model.Location{}},
+	"POST /locations":                                    {Request: handler.CreateLocationRequest{}, Response: model.Location{}, Status: 201},
+	"PUT /locations/:id":                                 {Request: handler.UpdateLocationRequest{}, Response: model.Location{}},
+	"PUT /locations/:id/restore":                         {Response: model.Location{}},
+	"DELETE /locations/:id":                              {},
+	"GET /locations/:id/sub-locations":                   {Summary: "Get the zone, aisle, rack and bin layout of a location", Response: []repository.SubLocationResponse{}},
+	"POST /locations/:id/sub-locations":                  {Request: handler.CreateSubLocationRequest{}, Response: repository.SubLocationResponse{}, Status: 201},
+	"PUT /locations/:id/sub-locations/:subLocationId":    {Request: handler.UpdateSubLocationRequest{}, Response: repository.SubLocationResponse{}},
+	"DELETE /locations/:id/sub-locations/:subLocationId": {},
+	"GET /locations/:id/putaway-suggestions": {Summary: "Suggest bins to put away stock in", Response: []service.PutawaySuggestion{}, Query: []openapi.Parameter{
+		query("product_id", "integer", "Product to put away"),
+		query("quantity", "number", "Quantity to put away"),
+		query("limit", "integer", "Number of suggestions"),
+	}},
+
+	// Product stocks
+	"GET /product-stocks":                    {Response: []model.ProductStock{}, List: true, Export: true},
+	"GET /product-stocks/:id":                {Response: model.ProductStock{}},
+	"GET /product-stocks/product/:productId": {Response: []model.ProductStock{}},
+	"POST /product-stocks":                   {Request: handler.CreateProductStockRequest{}, Response: model.ProductStock{}, Status: 201},
+	"PUT /product-stocks/:id":                {Request: handler.UpdateProductStockRequest{}, Response: model.ProductStock{}},
+	"PUT /product-stocks/:id/hold":           {Summary: "Place a product stock on hold", Request: handler.HoldRequest{}, Response: model.ProductStock{}},
+	"PUT /product-stocks/:id/release":        {Summary: "Release a product stock from hold", Request: handler.ReleaseHoldRequest{}, Response: model.ProductStock{}},
+	"DELETE /product-stocks/:id":             {},
+
+	// Product stock tracks
+	"GET /product-stock-tracks":                    {Response: []model.ProductStockTrack{}, List: true, Export: true},
+	"GET /product-stock-tracks/cursor":             {Summary: "Get product stock tracks by cursor", Response: []model.ProductStockTrack{}, Meta: helper.CursorPagination{}, Query: cursorQuery},
+	"GET /product-stock-tracks/export":             {Summary: "Stream all product stock tracks as NDJSON", List: true, ContentType: "application/x-ndjson"},
+	"GET /product-stock-tracks/:id":                {Response: model.ProductStockTrack{}},
+	"GET /product-stock-tracks/stock/:stockId":     {Response: []model.ProductStockTrack{}},
+	"GET /product-stock-tracks/product/:productId": {Response: []model.ProductStockTrack{}},
+	"POST /product-stock-tracks":                   {Request: service.CreateProductStockTrackRequest{}, Response: model.ProductStockTrack{}, Status: 201},
+	"PUT /product-stock-tracks/:id":                {Request: service.UpdateProductStockTrackRequest{}, Response: model.ProductStockTrack{}},
+	"DELETE /product-stock-tracks/:id":             {},
+
+	// Product items
+	"GET /product-items":                      {Response: []model.ProductItem{}, List: true, Export: true},
+	"GET /product-items/:id":                  {Response: model.ProductItem{}},
+	"GET /product-items/stock/:stockId":       {Response: []model.ProductItem{}},
+	"GET /product-items/product/:productId":   {Response: []model.ProductItem{}},
+	"GET /product-items/location/:locationId": {Response: []model.ProductItem{}},
+	"GET /product-items/summary/by-product":   {Summary: "Get item quantities summed by product", Response: []map[string]interface{}{}},
+	"POST /product-items":                     {Request: handler.CreateProductItemRequest{}, Response: model.ProductItem{}, Status: 201},
+	"PUT /product-items/:id":                  {Request: handler.UpdateProductItemRequest{}, Response: model.ProductItem{}},
+	"DELETE /product-items/:id":               {},
+
+	// Product item tracks
+	"GET /product-item-tracks":                    {Response: []model.ProductItemTrack{}, List: true, Export: true},
+	"GET /product-item-tracks/cursor":             {Summary: "Get product item tracks by cursor", Response: []model.ProductItemTrack{}, Meta: helper.CursorPagination{}, Query: cursorQuery},
+	"GET /product-item-tracks/export":             {Summary: "Stream all product item tracks as NDJSON", List: true, ContentType: "application/x-ndjson"},
+	"GET /product-item-tracks/:id":                {Response: model.ProductItemTrack{}},
+	"GET /product-item-tracks/item/:itemId":       {Response: []model.ProductItemTrack{}},
+	"GET /product-item-tracks/stock/:stockId":     {Response: []model.ProductItemTrack{}},
+	"GET /product-item-tracks/product/:productId": {Response: []model.ProductItemTrack{}},
+	"GET /product-item-tracks/date-range": {Response: []model.ProductItemTrack{}, Query: []openapi.Parameter{
+		query("startDate", "string", "YYYY-MM-DD"),
+		query("endDate", "string", "YYYY-MM-DD"),
+	}},
+	"GET /product-item-tracks/operation/:operation":     {Summary: "Get product item tracks by operation (In, Out, Plus or Minus)", Response: []model.ProductItemTrack{}, List: true},
+	"GET /product-item-tracks/reports/value-by-product": {Summary: "Get the value of item movements by product", Response: []map[string]interface{}{}},
+	"POST /product-item-tracks":                         {Request: handler.CreateProductItemTrackRequest{}, Response: model.ProductItemTrack{}, Status: 201},
+	"PUT /product-item-tracks/:id":                      {Request: handler.UpdateProductItemTrackRequest{}, Response: model.ProductItemTrack{}},
+	"DELETE /product-item-tracks/:id":                   {},
+
+	// Search
+	"GET /search/products": {Response: []service.ProductSearchResponse{}, List: true, Query: []openapi.Parameter{
+		query("q", "string", "Keyword matched against product, brand and category names"),
+		query("brand_id", "integer", "Only products of this brand"),
+		query("category_id", "integer", "Only products of this category"),
+	}},
+
+	// Imports
+	"POST /imports/:entity": {Summary: "Import records from a CSV or XLSX file", Upload: true, Response: service.ImportResult{}, Status: 201, Query: []openapi.Parameter{
+		query("dry_run", "boolean", "Validate the file without saving anything"),
+	}},
+
+	// Replenishment
+	"GET /replenishment/suggestions": {Response: []service.ReplenishmentSuggestion{}, Export: true, Query: []openapi.Parameter{
+		query("product_id", "integer", "Only this product"),
+		query("location_id", "integer", "Only this location"),
+	}},
+	"GET /replenishment/alerts":       {Response: []model.ReplenishmentAlert{}, List: true, Export: true},
+	"POST /replenishment/check":       {Summary: "Run the replenishment check now", Response: service.ReplenishmentCheckResult{}},
+	"GET /replenishment/rules":        {Response: []model.ReplenishmentRule{}, List: true, Export: true},
+	"GET /replenishment/rules/:id":    {Response: model.ReplenishmentRule{}},
+	"POST /replenishment/rules":       {Request: handler.CreateReplenishmentRuleRequest{}, Response: model.ReplenishmentRule{}, Status: 201},
+	"PUT /replenishment/rules/:id":    {Request: handler.UpdateReplenishmentRuleRequest{}, Response: model.ReplenishmentRule{}},
+	"DELETE /replenishment/rules/:id": {},
+
+	// Webhooks
+	"GET /webhooks":                {Response: []repository.WebhookSubscriptionResponse{}, List: true},
+	"GET /webhooks/:id":            {Response: repository.WebhookSubscriptionResponse{}},
+	"POST /webhooks":               {Request: handler.CreateWebhookRequest{}, Response: repository.WebhookSubscriptionResponse{}, Status: 201},
+	"PUT /webhooks/:id":            {Request: handler.UpdateWebhookRequest{}, Response: repository.WebhookSubscriptionResponse{}},
+	"DELETE /webhooks/:id":         {},
+	"GET /webhooks/:id/deliveries": {Response: []model.WebhookDelivery{}, List: true},
+	"POST /webhooks/:id/test":      {Summary: "Send a signed ping event to a webhook", Response: model.WebhookDelivery{}},
+	"POST /webhooks/:id/deliveries/:deliveryId/redeliver": {Summary: "Queue a webhook delivery again", Response: model.WebhookDelivery{}, Status: 202},
+
+	// Outbox
+	"GET /outbox-events": {Response: []model.OutboxEvent{}, List: true},
+
+	// Stream
+	"GET /stream/product-stocks": {Summary: "Server-Sent Events of stock quantity changes", ContentType: "text/event-stream", Query: []openapi.Parameter{
+		query("locationId", "integer", "Only changes of this location"),
+		query("productId", "integer", "Only changes of this product"),
+		query("access_token", "string", "JWT, for clients like EventSource that cannot send headers"),
+	}},
+
+	// Tenants
+	"GET /tenants":        {Response: []model.Tenant{}, List: true},
+	"GET /tenants/:id":    {Response: model.Tenant{}},
+	"POST /tenants":       {Request: handler.CreateTenantRequest{}, Response: model.Tenant{}, Status: 201},
+	"PUT /tenants/:id":    {Request: handler.UpdateTenantRequest{}, Response: model.Tenant{}},
+	"DELETE /tenants/:id": {},
+}
@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myapp/internal/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newTestApp() *fiber.App {
+	app := fiber.New()
+	SetupV1Routes(app)
+	return app
+}
+
+// TestOpenAPICoversEveryRoute fails when a route is registered without an endpoint in openapi.go
+func TestOpenAPICoversEveryRoute(t *testing.T) {
+	app := newTestApp()
+	doc := openapi.Build(app, openAPIConfig)
+
+	for _, route := range openapi.Routes(app, openAPIConfig.Prefix) {
+		if doc.Paths[route.OpenAPIPath()][strings.ToLower(route.Method)] == nil {
+			t.Errorf("%s is missing from the OpenAPI document, document it in endpoints", route.Key())
+		}
+	}
+}
+
+// TestOpenAPIEndpointsAreRoutes fails when an endpoint in openapi.go documents a route that no longer exists
+func TestOpenAPIEndpointsAreRoutes(t *testing.T) {
+	routes := map[string]bool{}
+	for _, route := range openapi.Routes(newTestApp(), openAPIConfig.Prefix) {
+		routes[route.Key()] = true
+	}
+
+	for _, key := range openapi.SortedKeys(endpoints) {
+		if !routes[key] {
+			t.Errorf("endpoint %s does not match a registered route", key)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	resp, err := newTestApp().Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/openapi.json", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+	}
+
+	brand := doc.Paths["/brands/{id}"]["put"]
+	if brand == nil || brand.RequestBody == nil || len(brand.Security) == 0 {
+		t.Fatalf("PUT /brands/{id} should have a request body and require a token, got %+v", brand)
+	}
+	if _, ok := brand.Responses["412"]; !ok {
+		t.Errorf("PUT /brands/{id} should document 412 for its If-Match precondition")
+	}
+	if login := doc.Paths["/auth/login"]["post"]; login == nil || len(login.Security) != 0 {
+		t.Errorf("POST /auth/login should not require a token, got %+v", login)
+	}
+}
+
+// TestDocsAssetsAreServed fails when the docs page loads Swagger UI files the binary does not serve itself
+func TestDocsAssetsAreServed(t *testing.T) {
+	app := newTestApp()
+	for _, path := range []string{"/api/v1/docs/assets/swagger-ui.css", "/api/v1/docs/assets/swagger-ui-bundle.js"} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("GET %s status = %d, want 200", path, resp.StatusCode)
+		}
+	}
+}
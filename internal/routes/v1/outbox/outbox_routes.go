@@ -0,0 +1,17 @@
+package outbox
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupOutboxRoutes(router fiber.Router) {
+	outboxEvents := router.Group("/outbox-events")
+	outboxEvents.Use(middleware.JWTMiddleware(), middleware.AdminMiddleware()) // Admins only, events cover every location
+	{
+		// GET /api/v1/outbox-events - Get all recorded domain events
+		outboxEvents.Get("/", handler.GetOutboxEvents)
+	}
+}
@@ -3,6 +3,7 @@ package productbatch
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,12 +14,18 @@ func RegisterProductBatchRoutes(app fiber.Router) {
 	// All routes require authentication
 	productBatchRoutes.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductBatch{})
+
 	// CRUD operations for product batches
-	productBatchRoutes.Get("/", handler.GetProductBatches)               // GET /api/v1/product-batches
-	productBatchRoutes.Get("/deleted", handler.GetDeletedProductBatches) // GET /api/v1/product-batches/deleted
-	productBatchRoutes.Get("/:id", handler.GetProductBatchByID)          // GET /api/v1/product-batches/:id
-	productBatchRoutes.Post("/", handler.CreateProductBatch)             // POST /api/v1/product-batches
-	productBatchRoutes.Put("/:id", handler.UpdateProductBatch)           // PUT /api/v1/product-batches/:id
-	productBatchRoutes.Put("/:id/restore", handler.RestoreProductBatch)  // PUT /api/v1/product-batches/:id/restore
-	productBatchRoutes.Delete("/:id", handler.DeleteProductBatch)        // DELETE /api/v1/product-batches/:id
+	productBatchRoutes.Get("/", handler.GetProductBatches)                       // GET /api/v1/product-batches
+	productBatchRoutes.Get("/deleted", handler.GetDeletedProductBatches)         // GET /api/v1/product-batches/deleted
+	productBatchRoutes.Get("/:id", handler.GetProductBatchByID)                  // GET /api/v1/product-batches/:id
+	productBatchRoutes.Get("/:id/trace", handler.GetProductBatchTrace)           // GET /api/v1/product-batches/:id/trace
+	productBatchRoutes.Post("/", handler.CreateProductBatch)                     // POST /api/v1/product-batches
+	productBatchRoutes.Put("/:id", ifMatch, handler.UpdateProductBatch)          // PUT /api/v1/product-batches/:id
+	productBatchRoutes.Put("/:id/restore", ifMatch, handler.RestoreProductBatch) // PUT /api/v1/product-batches/:id/restore
+	productBatchRoutes.Put("/:id/hold", ifMatch, handler.HoldProductBatch)       // PUT /api/v1/product-batches/:id/hold
+	productBatchRoutes.Put("/:id/release", ifMatch, handler.ReleaseProductBatch) // PUT /api/v1/product-batches/:id/release
+	productBatchRoutes.Delete("/:id", ifMatch, handler.DeleteProductBatch)       // DELETE /api/v1/product-batches/:id
 }
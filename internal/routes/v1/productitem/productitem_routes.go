@@ -3,6 +3,7 @@ package productitem
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,6 +11,9 @@ import (
 func ProductItemRoutes(router fiber.Router) {
 	items := router.Group("/product-items")
 	items.Use(middleware.JWTMiddleware()) // All routes require authentication
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductItem{})
 	{
 		// GET /api/v1/product-items - Get all product items
 		items.Get("", handler.GetAllProductItems)
@@ -33,9 +37,9 @@ func ProductItemRoutes(router fiber.Router) {
 		items.Post("", handler.CreateProductItem)
 
 		// PUT /api/v1/product-items/:id - Update product item
-		items.Put("/:id", handler.UpdateProductItem)
+		items.Put("/:id", ifMatch, handler.UpdateProductItem)
 
 		// DELETE /api/v1/product-items/:id - Delete product item
-		items.Delete("/:id", handler.DeleteProductItem)
+		items.Delete("/:id", ifMatch, handler.DeleteProductItem)
 	}
 }
@@ -3,6 +3,7 @@ package productitemtrack
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,10 +11,19 @@ import (
 func ProductItemTrackRoutes(router fiber.Router) {
 	tracks := router.Group("/product-item-tracks")
 	tracks.Use(middleware.JWTMiddleware()) // All routes require authentication
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductItemTrack{})
 	{
 		// GET /api/v1/product-item-tracks - Get all item tracks
 		tracks.Get("", handler.GetAllProductItemTracks)
 
+		// GET /api/v1/product-item-tracks/cursor?cursor=&limit= - Keyset pagination on (date, id), newest first
+		tracks.Get("/cursor", handler.GetProductItemTracksByCursor)
+
+		// GET /api/v1/product-item-tracks/export - Stream full (filtered) history as NDJSON
+		tracks.Get("/export", handler.ExportProductItemTracks)
+
 		// GET /api/v1/product-item-tracks/:id - Get item track by ID
 		tracks.Get("/:id", handler.GetProductItemTrackByID)
 
@@ -39,9 +49,9 @@ func ProductItemTrackRoutes(router fiber.Router) {
 		tracks.Post("", handler.CreateProductItemTrack)
 
 		// PUT /api/v1/product-item-tracks/:id - Update item track
-		tracks.Put("/:id", handler.UpdateProductItemTrack)
+		tracks.Put("/:id", ifMatch, handler.UpdateProductItemTrack)
 
 		// DELETE /api/v1/product-item-tracks/:id - Delete item track
-		tracks.Delete("/:id", handler.DeleteProductItemTrack)
+		tracks.Delete("/:id", ifMatch, handler.DeleteProductItemTrack)
 	}
 }
@@ -3,6 +3,7 @@ package productstock
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,6 +11,9 @@ import (
 func ProductStockRoutes(router fiber.Router) {
 	stocks := router.Group("/product-stocks")
 	stocks.Use(middleware.JWTMiddleware()) // All routes require authentication
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductStock{})
 	{
 		// GET /api/v1/product-stocks - Get all product stocks
 		stocks.Get("", handler.GetAllProductStocks)
@@ -24,9 +28,15 @@ func ProductStockRoutes(router fiber.Router) {
 		stocks.Post("", handler.CreateProductStock)
 
 		// PUT /api/v1/product-stocks/:id - Update product stock
-		stocks.Put("/:id", handler.UpdateProductStock)
+		stocks.Put("/:id", ifMatch, handler.UpdateProductStock)
+
+		// PUT /api/v1/product-stocks/:id/hold - Place product stock on hold
+		stocks.Put("/:id/hold", ifMatch, handler.HoldProductStock)
+
+		// PUT /api/v1/product-stocks/:id/release - Release product stock from hold
+		stocks.Put("/:id/release", ifMatch, handler.ReleaseProductStock)
 
 		// DELETE /api/v1/product-stocks/:id - Delete product stock
-		stocks.Delete("/:id", handler.DeleteProductStock)
+		stocks.Delete("/:id", ifMatch, handler.DeleteProductStock)
 	}
 }
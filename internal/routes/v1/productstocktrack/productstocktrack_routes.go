@@ -3,6 +3,7 @@ package productstocktrack
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,10 +11,19 @@ import (
 func ProductStockTrackRoutes(router fiber.Router) {
 	tracks := router.Group("/product-stock-tracks")
 	tracks.Use(middleware.JWTMiddleware()) // All routes require authentication
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductStockTrack{})
 	{
 		// GET /api/v1/product-stock-tracks - Get all stock tracks
 		tracks.Get("", handler.GetAllProductStockTracks)
 
+		// GET /api/v1/product-stock-tracks/cursor?cursor=&limit= - Keyset pagination on (date, id), newest first
+		tracks.Get("/cursor", handler.GetProductStockTracksByCursor)
+
+		// GET /api/v1/product-stock-tracks/export - Stream full (filtered) history as NDJSON
+		tracks.Get("/export", handler.ExportProductStockTracks)
+
 		// GET /api/v1/product-stock-tracks/:id - Get stock track by ID
 		tracks.Get("/:id", handler.GetProductStockTrackByID)
 
@@ -30,9 +40,9 @@ func ProductStockTrackRoutes(router fiber.Router) {
 		tracks.Post("", handler.CreateProductStockTrack)
 
 		// PUT /api/v1/product-stock-tracks/:id - Update stock track
-		tracks.Put("/:id", handler.UpdateProductStockTrack)
+		tracks.Put("/:id", ifMatch, handler.UpdateProductStockTrack)
 
 		// DELETE /api/v1/product-stock-tracks/:id - Delete stock track
-		tracks.Delete("/:id", handler.DeleteProductStockTrack)
+		tracks.Delete("/:id", ifMatch, handler.DeleteProductStockTrack)
 	}
 }
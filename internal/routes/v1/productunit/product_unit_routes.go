@@ -3,6 +3,7 @@ package productunit
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,9 @@ func ProductUnitRoutes(router fiber.Router) {
 	// Apply JWT middleware
 	productUnit.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductUnit{})
+
 	// GET /api/v1/product-units - Get all product units
 	productUnit.Get("/", handler.GetProductUnits)
 
@@ -29,11 +33,11 @@ func ProductUnitRoutes(router fiber.Router) {
 	productUnit.Post("/", handler.CreateProductUnit)
 
 	// PUT /api/v1/product-units/:id - Update product unit by ID
-	productUnit.Put("/:id", handler.UpdateProductUnit)
+	productUnit.Put("/:id", ifMatch, handler.UpdateProductUnit)
 
 	// PUT /api/v1/product-units/:id/restore - Restore deleted product unit
-	productUnit.Put("/:id/restore", handler.RestoreProductUnit)
+	productUnit.Put("/:id/restore", ifMatch, handler.RestoreProductUnit)
 
 	// DELETE /api/v1/product-units/:id - Delete product unit by ID
-	productUnit.Delete("/:id", handler.DeleteProductUnit)
+	productUnit.Delete("/:id", ifMatch, handler.DeleteProductUnit)
 }
@@ -3,6 +3,7 @@ package productunittrack
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,9 @@ func ProductUnitTrackRoutes(router fiber.Router) {
 	// Apply JWT middleware
 	productUnitTrack.Use(middleware.JWTMiddleware())
 
+	// DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ProductUnitTrack{})
+
 	// GET /api/v1/product-unit-tracks - Get all product unit tracks
 	productUnitTrack.Get("/", handler.GetProductUnitTracks)
 
@@ -29,5 +33,5 @@ func ProductUnitTrackRoutes(router fiber.Router) {
 	productUnitTrack.Post("/", handler.CreateProductUnitTrack)
 
 	// DELETE /api/v1/product-unit-tracks/:id - Delete product unit track by ID
-	productUnitTrack.Delete("/:id", handler.DeleteProductUnitTrack)
+	productUnitTrack.Delete("/:id", ifMatch, handler.DeleteProductUnitTrack)
 }
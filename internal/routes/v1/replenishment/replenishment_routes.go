@@ -0,0 +1,42 @@
+package replenishment
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupReplenishmentRoutes(router fiber.Router) {
+	replenishment := router.Group("/replenishment")
+	replenishment.Use(middleware.JWTMiddleware()) // All routes require authentication
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.ReplenishmentRule{})
+	{
+		// GET /api/v1/replenishment/suggestions?product_id=&location_id= - Quantities to order or transfer
+		replenishment.Get("/suggestions", handler.GetReplenishmentSuggestions)
+
+		// GET /api/v1/replenishment/alerts - Alerts raised by the replenishment job
+		replenishment.Get("/alerts", handler.GetReplenishmentAlerts)
+
+		// POST /api/v1/replenishment/check - Run the replenishment job now
+		replenishment.Post("/check", handler.RunReplenishmentCheck)
+
+		// GET /api/v1/replenishment/rules - Get all replenishment rules
+		replenishment.Get("/rules", handler.GetReplenishmentRules)
+
+		// GET /api/v1/replenishment/rules/:id - Get replenishment rule by ID
+		replenishment.Get("/rules/:id", handler.GetReplenishmentRuleByID)
+
+		// POST /api/v1/replenishment/rules - Create replenishment rule
+		replenishment.Post("/rules", handler.CreateReplenishmentRule)
+
+		// PUT /api/v1/replenishment/rules/:id - Update replenishment rule
+		replenishment.Put("/rules/:id", ifMatch, handler.UpdateReplenishmentRule)
+
+		// DELETE /api/v1/replenishment/rules/:id - Delete replenishment rule
+		replenishment.Delete("/rules/:id", ifMatch, handler.DeleteReplenishmentRule)
+	}
+}
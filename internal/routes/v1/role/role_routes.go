@@ -3,6 +3,7 @@ package role
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,15 +14,18 @@ func SetupRoleRoutes(router fiber.Router) {
 	// Protected role routes (require JWT)
 	roles.Use(middleware.JWTMiddleware())
 
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.Role{})
+
 	// IMPORTANT: Specific routes MUST come BEFORE parameterized routes
 	// Specific routes (no parameters)
 	roles.Get("/", handler.GetRoles)               // GET /api/v1/roles
 	roles.Get("/deleted", handler.GetDeletedRoles) // GET /api/v1/roles/deleted
 
 	// Parameterized routes (MUST be at the end)
-	roles.Get("/:id", handler.GetRoleByID)         // GET /api/v1/roles/:id
-	roles.Post("/", handler.CreateRole)            // POST /api/v1/roles
-	roles.Put("/:id", handler.UpdateRole)          // PUT /api/v1/roles/:id
-	roles.Put("/:id/restore", handler.RestoreRole) // PUT /api/v1/roles/:id/restore
-	roles.Delete("/:id", handler.DeleteRole)       // DELETE /api/v1/roles/:id
+	roles.Get("/:id", handler.GetRoleByID)                  // GET /api/v1/roles/:id
+	roles.Post("/", handler.CreateRole)                     // POST /api/v1/roles
+	roles.Put("/:id", ifMatch, handler.UpdateRole)          // PUT /api/v1/roles/:id
+	roles.Put("/:id/restore", ifMatch, handler.RestoreRole) // PUT /api/v1/roles/:id/restore
+	roles.Delete("/:id", ifMatch, handler.DeleteRole)       // DELETE /api/v1/roles/:id
 }
@@ -0,0 +1,17 @@
+package search
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupSearchRoutes(router fiber.Router) {
+	search := router.Group("/search")
+	search.Use(middleware.JWTMiddleware()) // All routes require authentication
+	{
+		// GET /api/v1/search/products?q=keyword&brand_id=&category_id=&page=&page_size= - Ranked product search with facets
+		search.Get("/products", handler.SearchProducts)
+	}
+}
@@ -0,0 +1,17 @@
+package stream
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupStreamRoutes(router fiber.Router) {
+	stream := router.Group("/stream")
+	stream.Use(middleware.JWTStreamMiddleware()) // All routes require authentication, token in header or ?access_token=
+	{
+		// GET /api/v1/stream/product-stocks - Server-Sent Events of stock quantity changes
+		stream.Get("/product-stocks", handler.StreamStockUpdates)
+	}
+}
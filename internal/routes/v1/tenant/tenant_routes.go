@@ -0,0 +1,33 @@
+package tenant
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupTenantRoutes(router fiber.Router) {
+	tenants := router.Group("/tenants")
+	tenants.Use(middleware.JWTAccountMiddleware(), middleware.PlatformMiddleware()) // Platform users only
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.Tenant{})
+	{
+		// GET /api/v1/tenants - Get all tenants
+		tenants.Get("/", handler.GetTenants)
+
+		// GET /api/v1/tenants/:id - Get tenant by ID
+		tenants.Get("/:id", handler.GetTenantByID)
+
+		// POST /api/v1/tenants - Create tenant
+		tenants.Post("/", handler.CreateTenant)
+
+		// PUT /api/v1/tenants/:id - Update tenant
+		tenants.Put("/:id", ifMatch, handler.UpdateTenant)
+
+		// DELETE /api/v1/tenants/:id - Delete tenant
+		tenants.Delete("/:id", ifMatch, handler.DeleteTenant)
+	}
+}
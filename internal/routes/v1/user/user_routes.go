@@ -3,6 +3,7 @@ package user
 import (
 	"myapp/internal/handler"
 	"myapp/internal/middleware"
+	"myapp/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -14,6 +15,9 @@ func SetupUserRoutes(router fiber.Router) {
 	// Protected user routes (require JWT)
 	users.Use(middleware.JWTMiddleware())
 
+	// PUT must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.User{})
+
 	// IMPORTANT: Specific routes MUST come BEFORE parameterized routes
 	// Specific routes (no parameters)
 	users.Get("/", handler.GetUsers)                         // GET /api/v1/users
@@ -26,6 +30,7 @@ func SetupUserRoutes(router fiber.Router) {
 	users.Get("/rawQuery", handler.GetUsersFromRepository)   // GET /api/v1/users/rawQuery
 
 	// Parameterized routes (MUST be at the end)
-	users.Get("/:id", handler.GetUserByIDRaw)      // GET /api/v1/users/:id
-	users.Put("/:id/restore", handler.RestoreUser) // PUT /api/v1/users/:id/restore
+	users.Get("/:id", handler.GetUserByIDRaw)                                    // GET /api/v1/users/:id
+	users.Put("/:id/restore", ifMatch, handler.RestoreUser)                               // PUT /api/v1/users/:id/restore
+	users.Put("/:id/role", middleware.AdminMiddleware(), ifMatch, handler.AssignUserRole) // PUT /api/v1/users/:id/role (admins only)
 }
@@ -1,10 +1,14 @@
 package v1
 
 import (
+	"myapp/internal/middleware"
+	"myapp/internal/openapi"
 	"myapp/internal/routes/v1/auth"
 	"myapp/internal/routes/v1/brand"
 	"myapp/internal/routes/v1/category"
+	"myapp/internal/routes/v1/imports"
 	"myapp/internal/routes/v1/location"
+	"myapp/internal/routes/v1/outbox"
 	"myapp/internal/routes/v1/product"
 	"myapp/internal/routes/v1/productbatch"
 	"myapp/internal/routes/v1/productitem"
@@ -13,8 +17,13 @@ import (
 	"myapp/internal/routes/v1/productstocktrack"
 	"myapp/internal/routes/v1/productunit"
 	"myapp/internal/routes/v1/productunittrack"
+	"myapp/internal/routes/v1/replenishment"
 	"myapp/internal/routes/v1/role"
+	"myapp/internal/routes/v1/search"
+	"myapp/internal/routes/v1/stream"
+	"myapp/internal/routes/v1/tenant"
 	"myapp/internal/routes/v1/user"
+	"myapp/internal/routes/v1/webhook"
 
 	"github.com/gofiber/fiber/v2"
 	// Import modules lain di sini untuk future development
@@ -26,8 +35,13 @@ func SetupV1Routes(app *fiber.App) {
 	// Create v1 API group
 	v1 := app.Group("/api/v1")
 
-	// Setup module routes
+	// Auth routes come before the idempotency middleware: their responses carry tokens, which must not be stored
 	auth.SetupAuthRoutes(v1)
+
+	// Retried POST/PUT/PATCH/DELETE requests with the same Idempotency-Key get the first response back
+	v1.Use(middleware.IdempotencyMiddleware())
+
+	// Setup module routes
 	user.SetupUserRoutes(v1)
 	role.SetupRoleRoutes(v1)
 	brand.SetupBrandRoutes(v1)
@@ -41,6 +55,13 @@ func SetupV1Routes(app *fiber.App) {
 	productstocktrack.ProductStockTrackRoutes(v1)
 	productitem.ProductItemRoutes(v1)
 	productitemtrack.ProductItemTrackRoutes(v1)
+	search.SetupSearchRoutes(v1)
+	imports.SetupImportRoutes(v1)
+	replenishment.SetupReplenishmentRoutes(v1)
+	webhook.SetupWebhookRoutes(v1)
+	outbox.SetupOutboxRoutes(v1)
+	stream.SetupStreamRoutes(v1)
+	tenant.SetupTenantRoutes(v1)
 
 	// Future modules
 	// order.SetupOrderRoutes(v1)
@@ -54,4 +75,9 @@ func SetupV1Routes(app *fiber.App) {
 			"message": "GO-WMS API v1 is running",
 		})
 	})
+
+	// OpenAPI document generated from the routes above, and a Swagger UI page reading it
+	v1.Get("/openapi.json", openapi.SpecHandler(openAPIConfig))
+	v1.Get("/docs", openapi.DocsHandler())
+	v1.Use("/docs/assets", openapi.AssetsHandler())
 }
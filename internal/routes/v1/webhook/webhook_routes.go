@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"myapp/internal/handler"
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupWebhookRoutes(router fiber.Router) {
+	webhooks := router.Group("/webhooks")
+	webhooks.Use(middleware.JWTMiddleware(), middleware.AdminMiddleware()) // Admins only, events cover every location
+
+	// PUT and DELETE must send the ETag of the row in If-Match
+	ifMatch := middleware.IfMatchMiddleware(&model.WebhookSubscription{})
+	{
+		// GET /api/v1/webhooks - Get all webhook subscriptions
+		webhooks.Get("/", handler.GetWebhooks)
+
+		// GET /api/v1/webhooks/:id - Get webhook subscription by ID
+		webhooks.Get("/:id", handler.GetWebhookByID)
+
+		// POST /api/v1/webhooks - Create webhook subscription
+		webhooks.Post("/", handler.CreateWebhook)
+
+		// PUT /api/v1/webhooks/:id - Update webhook subscription
+		webhooks.Put("/:id", ifMatch, handler.UpdateWebhook)
+
+		// DELETE /api/v1/webhooks/:id - Delete webhook subscription
+		webhooks.Delete("/:id", ifMatch, handler.DeleteWebhook)
+
+		// GET /api/v1/webhooks/:id/deliveries - Delivery log
+		webhooks.Get("/:id/deliveries", handler.GetWebhookDeliveries)
+
+		// POST /api/v1/webhooks/:id/test - Send a ping event now
+		webhooks.Post("/:id/test", handler.TestWebhook)
+
+		// POST /api/v1/webhooks/:id/deliveries/:deliveryId/redeliver - Queue a delivery again
+		webhooks.Post("/:id/deliveries/:deliveryId/redeliver", handler.RedeliverWebhook)
+	}
+}
@@ -1,13 +1,18 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+
+	"gorm.io/gorm"
 )
 
 type BrandService struct {
 	brandRepo *repository.BrandRepository
+	ctx       context.Context
 }
 
 func NewBrandService() *BrandService {
@@ -16,12 +21,34 @@ func NewBrandService() *BrandService {
 	}
 }
 
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *BrandService) WithTx(tx *gorm.DB) *BrandService {
+	return &BrandService{
+		brandRepo: s.brandRepo.WithTx(tx),
+		ctx:       s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *BrandService) WithContext(ctx context.Context) *BrandService {
+	return &BrandService{
+		brandRepo: s.brandRepo.WithContext(ctx),
+		ctx:       ctx,
+	}
+}
+
 // Business logic methods
-func (s *BrandService) GetAllBrands() ([]model.Brand, error) {
-	return s.brandRepo.GetAllBrands()
+func (s *BrandService) GetAllBrands(query utils.ListQuery) ([]model.Brand, int64, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.GetAllBrands")
+	defer span.End()
+
+	return s.brandRepo.GetAllBrands(query)
 }
 
 func (s *BrandService) GetBrandByID(id uint) (*model.Brand, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.GetBrandByID")
+	defer span.End()
+
 	brand, err := s.brandRepo.GetBrandByID(id)
 	if err != nil {
 		return nil, err
@@ -30,12 +57,15 @@ func (s *BrandService) GetBrandByID(id uint) (*model.Brand, error) {
 }
 
 func (s *BrandService) CreateBrand(name string, description *string, userID uint) (*model.Brand, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.CreateBrand")
+	defer span.End()
+
 	if name == "" {
-		return nil, errors.New("brand name is required")
+		return nil, apperror.Validation("brand_name_required", "brand name is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if brand exists
@@ -44,7 +74,7 @@ func (s *BrandService) CreateBrand(name string, description *string, userID uint
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("brand already exists")
+		return nil, apperror.Conflict("brand_already_exists", "brand already exists")
 	}
 
 	brand := &model.Brand{
@@ -58,18 +88,21 @@ func (s *BrandService) CreateBrand(name string, description *string, userID uint
 }
 
 func (s *BrandService) UpdateBrand(id uint, name string, description *string, userID uint) (*model.Brand, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.UpdateBrand")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid brand ID")
+		return nil, apperror.Validation("invalid_brand_id", "invalid brand ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if brand exists
 	brand, err := s.brandRepo.GetBrandByID(id)
 	if err != nil {
-		return nil, errors.New("brand not found")
+		return nil, apperror.NotFound("brand_not_found", "brand not found")
 	}
 
 	// Check if new name conflicts with existing brands
@@ -79,7 +112,7 @@ func (s *BrandService) UpdateBrand(id uint, name string, description *string, us
 			return nil, err
 		}
 		if exists {
-			return nil, errors.New("brand name already in use")
+			return nil, apperror.Conflict("brand_name_already_in_use", "brand name already in use")
 		}
 	}
 
@@ -107,18 +140,21 @@ func (s *BrandService) UpdateBrand(id uint, name string, description *string, us
 }
 
 func (s *BrandService) DeleteBrand(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "BrandService.DeleteBrand")
+	defer span.End()
+
 	if id == 0 {
-		return errors.New("invalid brand ID")
+		return apperror.Validation("invalid_brand_id", "invalid brand ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if brand exists
 	_, err := s.brandRepo.GetBrandByID(id)
 	if err != nil {
-		return errors.New("brand not found")
+		return apperror.NotFound("brand_not_found", "brand not found")
 	}
 
 	return s.brandRepo.DeleteBrandWithAudit(id, userID)
@@ -126,17 +162,23 @@ func (s *BrandService) DeleteBrand(id uint, userID uint) error {
 
 // GetDeletedBrands returns all soft deleted brands
 func (s *BrandService) GetDeletedBrands() ([]model.Brand, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.GetDeletedBrands")
+	defer span.End()
+
 	return s.brandRepo.GetDeletedBrands()
 }
 
 // RestoreBrand restores a soft deleted brand
 func (s *BrandService) RestoreBrand(id uint, userID uint) (*model.Brand, error) {
+	s, span := startSpan(s.ctx, s, "BrandService.RestoreBrand")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid brand ID")
+		return nil, apperror.Validation("invalid_brand_id", "invalid brand ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if brand exists in deleted records
@@ -154,7 +196,7 @@ func (s *BrandService) RestoreBrand(id uint, userID uint) (*model.Brand, error)
 	}
 
 	if foundBrand == nil {
-		return nil, errors.New("deleted brand not found")
+		return nil, apperror.NotFound("deleted_brand_not_found", "deleted brand not found")
 	}
 
 	err = s.brandRepo.RestoreBrand(id, userID)
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/repository"
+	"myapp/pkg/redis"
+)
+
+// CacheService empties the Redis cache, such as after data was changed in the database by hand
+type CacheService struct {
+	brandRepo *repository.BrandRepository
+	ctx       context.Context
+}
+
+func NewCacheService() *CacheService {
+	return &CacheService{
+		brandRepo: repository.NewBrandRepository(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories run with ctx
+func (s *CacheService) WithContext(ctx context.Context) *CacheService {
+	return &CacheService{
+		brandRepo: s.brandRepo.WithContext(ctx),
+		ctx:       ctx,
+	}
+}
+
+// Flush removes the cached entries of every tenant and leaves the other Redis data, such as outbox streams, alone
+func (s *CacheService) Flush() error {
+	s, span := startSpan(s.ctx, s, "CacheService.Flush")
+	defer span.End()
+
+	return s.brandRepo.FlushCache()
+}
+
+// FlushDB empties the configured Redis database, outbox streams included
+func (s *CacheService) FlushDB() error {
+	s, span := startSpan(s.ctx, s, "CacheService.FlushDB")
+	defer span.End()
+
+	return redis.FlushDB(s.ctx)
+}
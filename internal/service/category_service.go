@@ -1,13 +1,18 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+
+	"gorm.io/gorm"
 )
 
 type CategoryService struct {
 	categoryRepo *repository.CategoryRepository
+	ctx          context.Context
 }
 
 func NewCategoryService() *CategoryService {
@@ -16,14 +21,36 @@ func NewCategoryService() *CategoryService {
 	}
 }
 
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *CategoryService) WithTx(tx *gorm.DB) *CategoryService {
+	return &CategoryService{
+		categoryRepo: s.categoryRepo.WithTx(tx),
+		ctx:          s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *CategoryService) WithContext(ctx context.Context) *CategoryService {
+	return &CategoryService{
+		categoryRepo: s.categoryRepo.WithContext(ctx),
+		ctx:          ctx,
+	}
+}
+
 // Business logic methods
-func (s *CategoryService) GetAllCategories() (interface{}, error) {
-	return s.categoryRepo.GetAllCategories()
+func (s *CategoryService) GetAllCategories(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.GetAllCategories")
+	defer span.End()
+
+	return s.categoryRepo.GetAllCategories(query)
 }
 
 func (s *CategoryService) GetCategoriesByBrand(brandID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.GetCategoriesByBrand")
+	defer span.End()
+
 	if brandID == 0 {
-		return nil, errors.New("invalid brand ID")
+		return nil, apperror.Validation("invalid_brand_id", "invalid brand ID")
 	}
 
 	// Check if brand exists
@@ -32,13 +59,16 @@ func (s *CategoryService) GetCategoriesByBrand(brandID uint) (interface{}, error
 		return nil, err
 	}
 	if !brandExists {
-		return nil, errors.New("brand not found")
+		return nil, apperror.NotFound("brand_not_found", "brand not found")
 	}
 
 	return s.categoryRepo.GetCategoriesByBrand(brandID)
 }
 
 func (s *CategoryService) GetCategoryByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.GetCategoryByID")
+	defer span.End()
+
 	category, err := s.categoryRepo.GetCategoryByID(id)
 	if err != nil {
 		return nil, err
@@ -47,16 +77,19 @@ func (s *CategoryService) GetCategoryByID(id uint) (interface{}, error) {
 }
 
 func (s *CategoryService) CreateCategory(brandID uint, name string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.CreateCategory")
+	defer span.End()
+
 	if brandID == 0 {
-		return nil, errors.New("brand ID is required")
+		return nil, apperror.Validation("brand_id_required", "brand ID is required")
 	}
 
 	if name == "" {
-		return nil, errors.New("category name is required")
+		return nil, apperror.Validation("category_name_required", "category name is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if brand exists
@@ -65,7 +98,7 @@ func (s *CategoryService) CreateCategory(brandID uint, name string, description
 		return nil, err
 	}
 	if !brandExists {
-		return nil, errors.New("brand not found")
+		return nil, apperror.NotFound("brand_not_found", "brand not found")
 	}
 
 	// Check if category exists for this brand
@@ -74,7 +107,7 @@ func (s *CategoryService) CreateCategory(brandID uint, name string, description
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("category already exists for this brand")
+		return nil, apperror.Conflict("category_already_exists_for_this_brand", "category already exists for this brand")
 	}
 
 	category := &model.Category{
@@ -99,18 +132,21 @@ func (s *CategoryService) CreateCategory(brandID uint, name string, description
 }
 
 func (s *CategoryService) UpdateCategory(id uint, brandID uint, name string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.UpdateCategory")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid category ID")
+		return nil, apperror.Validation("invalid_category_id", "invalid category ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if category exists
 	category, err := s.categoryRepo.GetCategoryByID(id)
 	if err != nil {
-		return nil, errors.New("category not found")
+		return nil, apperror.NotFound("category_not_found", "category not found")
 	}
 
 	// If brand ID is being changed, check if new brand exists
@@ -120,7 +156,7 @@ func (s *CategoryService) UpdateCategory(id uint, brandID uint, name string, des
 			return nil, err
 		}
 		if !brandExists {
-			return nil, errors.New("brand not found")
+			return nil, apperror.NotFound("brand_not_found", "brand not found")
 		}
 	}
 
@@ -136,7 +172,7 @@ func (s *CategoryService) UpdateCategory(id uint, brandID uint, name string, des
 			return nil, err
 		}
 		if exists {
-			return nil, errors.New("category name already in use for this brand")
+			return nil, apperror.Conflict("category_name_already_in_use_for_this_brand", "category name already in use for this brand")
 		}
 	}
 
@@ -167,18 +203,21 @@ func (s *CategoryService) UpdateCategory(id uint, brandID uint, name string, des
 }
 
 func (s *CategoryService) DeleteCategory(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "CategoryService.DeleteCategory")
+	defer span.End()
+
 	if id == 0 {
-		return errors.New("invalid category ID")
+		return apperror.Validation("invalid_category_id", "invalid category ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if category exists
 	_, err := s.categoryRepo.GetCategoryByID(id)
 	if err != nil {
-		return errors.New("category not found")
+		return apperror.NotFound("category_not_found", "category not found")
 	}
 
 	return s.categoryRepo.DeleteCategoryWithAudit(id, userID)
@@ -186,16 +225,22 @@ func (s *CategoryService) DeleteCategory(id uint, userID uint) error {
 
 // GetDeletedCategories returns all soft deleted categories
 func (s *CategoryService) GetDeletedCategories() (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.GetDeletedCategories")
+	defer span.End()
+
 	return s.categoryRepo.GetDeletedCategories()
 }
 
 // RestoreCategory restores a soft deleted category
 func (s *CategoryService) RestoreCategory(id uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "CategoryService.RestoreCategory")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid category ID")
+		return nil, apperror.Validation("invalid_category_id", "invalid category ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.categoryRepo.RestoreCategory(id, userID)
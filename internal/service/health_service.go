@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/repository"
+	"myapp/pkg/redis"
+	"time"
+)
+
+// Health statuses of the report and of every check
+const (
+	HealthOK       = "OK"       // Everything answers
+	HealthDegraded = "DEGRADED" // Redis is down, the API still serves from the database
+	HealthDown     = "DOWN"     // Postgres is down, the API cannot serve requests
+
+	HealthCheckUp       = "up"
+	HealthCheckDown     = "down"
+	HealthCheckDisabled = "disabled"
+)
+
+// HealthCheckTimeout bounds every dependency check
+const HealthCheckTimeout = 2 * time.Second
+
+// HealthCheck is the result of pinging one dependency
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthReport is the readiness of the API and the checks it is made of
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// Ready reports whether the API can serve requests, which takes the database; Redis is only a cache
+func (r HealthReport) Ready() bool {
+	return r.Status != HealthDown
+}
+
+type HealthService struct{}
+
+func NewHealthService() *HealthService {
+	return &HealthService{}
+}
+
+// Check pings Postgres and, when it is enabled, Redis
+func (s *HealthService) Check(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthOK, Checks: map[string]HealthCheck{}}
+
+	report.Checks["database"] = runHealthCheck(ctx, repository.PingDatabase)
+	if report.Checks["database"].Status != HealthCheckUp {
+		report.Status = HealthDown
+	}
+
+	if redis.Client == nil {
+		report.Checks["redis"] = HealthCheck{Status: HealthCheckDisabled}
+		return report
+	}
+	report.Checks["redis"] = runHealthCheck(ctx, redis.Ping)
+	if report.Checks["redis"].Status != HealthCheckUp && report.Status == HealthOK {
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+func runHealthCheck(ctx context.Context, ping func(ctx context.Context) error) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	check := HealthCheck{Status: HealthCheckUp, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		check.Status = HealthCheckDown
+		check.Error = err.Error()
+	}
+	return check
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/pkg/apperror"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Idempotency key settings
+const (
+	IdempotencyKeyMaxLength = 255
+	IdempotencyKeyTTL       = 24 * time.Hour // Default for IDEMPOTENCY_KEY_TTL, how long responses are kept for retries
+	IdempotencyLockTimeout  = time.Minute    // A key not touched for this long while processing was abandoned and is taken over by the next retry
+)
+
+// Errors returned when a key cannot be used for a request
+var (
+	ErrIdempotencyKeyReused     = apperror.Validation("idempotency_key_reused", "idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = apperror.Conflict("idempotency_key_in_progress", "a request with this idempotency key is still being processed")
+)
+
+type IdempotencyService struct {
+	idempotencyRepo *repository.IdempotencyRepository
+	ctx             context.Context
+}
+
+func NewIdempotencyService() *IdempotencyService {
+	return &IdempotencyService{
+		idempotencyRepo: repository.NewIdempotencyRepository(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *IdempotencyService) WithContext(ctx context.Context) *IdempotencyService {
+	return &IdempotencyService{
+		idempotencyRepo: s.idempotencyRepo.WithContext(ctx),
+		ctx:             ctx,
+	}
+}
+
+// Begin claims key for a request. When the key is new it is stored as processing and returned with started set, and the
+// request should run. When a retry of a completed request comes in, the stored key is returned to replay its response.
+func (s *IdempotencyService) Begin(scope, key, method, path, fingerprint string, ttl time.Duration) (model.IdempotencyKey, bool, error) {
+	s, span := startSpan(s.ctx, s, "IdempotencyService.Begin")
+	defer span.End()
+
+	if key == "" || len(key) > IdempotencyKeyMaxLength {
+		return model.IdempotencyKey{}, false, apperror.Validation("invalid_idempotency_key", "idempotency key must be 1-255 characters")
+	}
+
+	// The second attempt follows the removal of an expired or abandoned key
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		record := model.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Method:      method,
+			Path:        path,
+			Fingerprint: fingerprint,
+			Status:      model.IdempotencyKeyProcessing,
+			ExpiresAt:   now.Add(ttl),
+		}
+		created, err := s.idempotencyRepo.CreateIdempotencyKey(&record)
+		if err != nil {
+			return model.IdempotencyKey{}, false, err
+		}
+		if created {
+			return record, true, nil
+		}
+
+		existing, err := s.idempotencyRepo.GetIdempotencyKey(scope, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return model.IdempotencyKey{}, false, err
+		}
+
+		abandoned := existing.Status == model.IdempotencyKeyProcessing && existing.UpdatedAt.Before(now.Add(-IdempotencyLockTimeout))
+		switch {
+		case existing.ExpiresAt.Before(now) || abandoned:
+			if err := s.idempotencyRepo.DeleteStaleIdempotencyKey(existing.ID, existing.UpdatedAt); err != nil {
+				return model.IdempotencyKey{}, false, err
+			}
+			continue
+		case existing.Fingerprint != fingerprint:
+			return existing, false, ErrIdempotencyKeyReused
+		case existing.Status == model.IdempotencyKeyProcessing:
+			return existing, false, ErrIdempotencyKeyInProgress
+		}
+		return existing, false, nil
+	}
+
+	return model.IdempotencyKey{}, false, ErrIdempotencyKeyInProgress
+}
+
+// Touch marks the key of a request that is still running as alive, so it is not taken over as abandoned
+func (s *IdempotencyService) Touch(id uint) error {
+	s, span := startSpan(s.ctx, s, "IdempotencyService.Touch")
+	defer span.End()
+
+	return s.idempotencyRepo.UpdateIdempotencyKey(id, map[string]interface{}{"updated_at": time.Now()})
+}
+
+// Complete stores the response of the request that claimed the key
+func (s *IdempotencyService) Complete(id uint, responseStatus int, contentType, etag string, body []byte) error {
+	s, span := startSpan(s.ctx, s, "IdempotencyService.Complete")
+	defer span.End()
+
+	return s.idempotencyRepo.UpdateIdempotencyKey(id, map[string]interface{}{
+		"status":          model.IdempotencyKeyCompleted,
+		"response_status": responseStatus,
+		"content_type":    contentType,
+		"etag":            etag,
+		"response_body":   body,
+	})
+}
+
+// Release removes the key of a request that failed, so the client can retry it with the same key
+func (s *IdempotencyService) Release(id uint) error {
+	s, span := startSpan(s.ctx, s, "IdempotencyService.Release")
+	defer span.End()
+
+	return s.idempotencyRepo.DeleteIdempotencyKey(id)
+}
+
+// DeleteExpiredKeys removes the keys whose retry window is over and returns how many were removed
+func (s *IdempotencyService) DeleteExpiredKeys() (int64, error) {
+	s, span := startSpan(s.ctx, s, "IdempotencyService.DeleteExpiredKeys")
+	defer span.End()
+
+	return s.idempotencyRepo.DeleteExpiredIdempotencyKeys(time.Now())
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errImportRolledBack makes the import transaction roll back after a dry run or when any row failed
+var errImportRolledBack = errors.New("import rolled back")
+
+// ImportRowError describes why a single row (or one of its columns) could not be imported
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ImportRowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("row %d, column %s: %s", e.Row, e.Column, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// ImportResult is the report returned by every import, committed or not
+type ImportResult struct {
+	Entity    string           `json:"entity"`
+	DryRun    bool             `json:"dry_run"`
+	TotalRows int              `json:"total_rows"`
+	Valid     int              `json:"valid_rows"`
+	Committed bool             `json:"committed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// importer describes one importable entity: the columns every row must fill and how a row is created
+type importer struct {
+	required []string
+	create   func(svc *ImportService, row importRow, userID uint) error
+}
+
+var importers = map[string]importer{
+	"brands": {
+		required: []string{"name"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			_, err := svc.brand.CreateBrand(row.get("name"), row.optional("description"), userID)
+			return err
+		},
+	},
+	"categories": {
+		required: []string{"brand", "name"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			brandID, err := row.brandID(svc)
+			if err != nil {
+				return err
+			}
+			_, err = svc.category.CreateCategory(brandID, row.get("name"), row.optional("description"), userID)
+			return err
+		},
+	},
+	"products": {
+		required: []string{"brand", "category", "name"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			categoryID, err := row.categoryID(svc)
+			if err != nil {
+				return err
+			}
+			_, err = svc.product.CreateProduct(categoryID, row.get("name"), row.optional("description"), userID)
+			return err
+		},
+	},
+	"product-batches": {
+		required: []string{"brand", "category", "product", "exp_date"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			productID, err := row.productID(svc)
+			if err != nil {
+				return err
+			}
+			expDate, err := row.date("exp_date")
+			if err != nil {
+				return err
+			}
+			unitPrice, err := row.float("unit_price")
+			if err != nil {
+				return err
+			}
+			_, err = svc.batch.CreateProductBatch(productID, row.optional("code_batch"), unitPrice, expDate, row.optional("description"), userID)
+			return err
+		},
+	},
+	"product-units": {
+		required: []string{"brand", "category", "product", "code_batch", "location"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			productID, batchID, err := row.batchID(svc)
+			if err != nil {
+				return err
+			}
+			locationID, err := row.locationID(svc)
+			if err != nil {
+				return err
+			}
+			quantity, err := row.float("quantity")
+			if err != nil {
+				return err
+			}
+			unitPrice, err := row.float("unit_price")
+			if err != nil {
+				return err
+			}
+			unitPriceRetail, err := row.float("unit_price_retail")
+			if err != nil {
+				return err
+			}
+			_, err = svc.unit.CreateProductUnit(productID, locationID, batchID, row.optional("name"), quantity, unitPrice, unitPriceRetail, row.optional("barcode"), row.optional("description"), userID)
+			return err
+		},
+	},
+	"product-stocks": {
+		required: []string{"brand", "category", "product", "code_batch", "location", "quantity"},
+		create: func(svc *ImportService, row importRow, userID uint) error {
+			productID, batchID, err := row.batchID(svc)
+			if err != nil {
+				return err
+			}
+			locationID, err := row.locationID(svc)
+			if err != nil {
+				return err
+			}
+			quantity, err := row.float("quantity")
+			if err != nil {
+				return err
+			}
+			if quantity == nil || *quantity < 0 {
+				return row.fail("quantity", "must be a number greater than or equal to 0")
+			}
+			binID, err := row.binID(svc, locationID)
+			if err != nil {
+				return err
+			}
+			_, err = svc.stock.CreateProductStock(batchID, productID, locationID, binID, quantity, userID)
+			return err
+		},
+	},
+}
+
+// importRow wraps a table row with typed accessors; conversion errors carry the row and column
+type importRow struct {
+	utils.TableRow
+}
+
+func (r importRow) fail(column, message string) error {
+	return ImportRowError{Row: r.Line, Column: column, Message: message}
+}
+
+func (r importRow) get(column string) string {
+	return r.Values[column]
+}
+
+// optional returns nil for an empty cell
+func (r importRow) optional(column string) *string {
+	value := r.Values[column]
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (r importRow) float(column string) (*float64, error) {
+	value := r.Values[column]
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, r.fail(column, "must be a number")
+	}
+	return &number, nil
+}
+
+func (r importRow) date(column string) (time.Time, error) {
+	value := r.Values[column]
+	if value == "" {
+		return time.Time{}, r.fail(column, "is required")
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, r.fail(column, "must be a date in YYYY-MM-DD format")
+	}
+	return date, nil
+}
+
+func (r importRow) brandID(svc *ImportService) (uint, error) {
+	id, err := svc.lookup.FindBrandID(r.get("brand"))
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, r.fail("brand", "brand not found")
+	}
+	return id, nil
+}
+
+func (r importRow) categoryID(svc *ImportService) (uint, error) {
+	brandID, err := r.brandID(svc)
+	if err != nil {
+		return 0, err
+	}
+	id, err := svc.lookup.FindCategoryID(brandID, r.get("category"))
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, r.fail("category", "category not found for this brand")
+	}
+	return id, nil
+}
+
+func (r importRow) productID(svc *ImportService) (uint, error) {
+	categoryID, err := r.categoryID(svc)
+	if err != nil {
+		return 0, err
+	}
+	id, err := svc.lookup.FindProductID(categoryID, r.get("product"))
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, r.fail("product", "product not found for this category")
+	}
+	return id, nil
+}
+
+func (r importRow) batchID(svc *ImportService) (uint, uint, error) {
+	productID, err := r.productID(svc)
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := svc.lookup.FindProductBatchID(productID, r.get("code_batch"))
+	if err != nil {
+		return 0, 0, err
+	}
+	if id == 0 {
+		return 0, 0, r.fail("code_batch", "product batch not found for this product")
+	}
+	return productID, id, nil
+}
+
+func (r importRow) locationID(svc *ImportService) (uint, error) {
+	id, err := svc.lookup.FindLocationID(r.get("location"))
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, r.fail("location", "location not found")
+	}
+	return id, nil
+}
+
+// binID resolves the optional bin column (a bin path such as A-01-03-B2) inside the row's location
+func (r importRow) binID(svc *ImportService, locationID uint) (*uint, error) {
+	path := r.get("bin")
+	if path == "" {
+		return nil, nil
+	}
+	id, err := svc.lookup.FindBinID(locationID, path)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, r.fail("bin", "bin not found in this location")
+	}
+	return &id, nil
+}
+
+// ImportService creates records from uploaded files through the regular services, so every row follows the same rules
+type ImportService struct {
+	lookup   *repository.ImportRepository
+	brand    *BrandService
+	category *CategoryService
+	product  *ProductService
+	batch    *ProductBatchService
+	unit     *ProductUnitService
+	stock    *ProductStockService
+	ctx      context.Context
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{
+		lookup:   repository.NewImportRepository(),
+		brand:    NewBrandService(),
+		category: NewCategoryService(),
+		product:  NewProductService(),
+		batch:    NewProductBatchService(),
+		unit:     NewProductUnitService(),
+		stock:    NewProductStockService(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ImportService) WithContext(ctx context.Context) *ImportService {
+	return &ImportService{
+		lookup:   s.lookup.WithContext(ctx),
+		brand:    s.brand.WithContext(ctx),
+		category: s.category.WithContext(ctx),
+		product:  s.product.WithContext(ctx),
+		batch:    s.batch.WithContext(ctx),
+		unit:     s.unit.WithContext(ctx),
+		stock:    s.stock.WithContext(ctx),
+		ctx:      ctx,
+	}
+}
+
+// withTx returns a copy of the service whose lookups and services run inside tx
+func (s *ImportService) withTx(tx *gorm.DB) *ImportService {
+	return &ImportService{
+		lookup:   s.lookup.WithTx(tx),
+		brand:    s.brand.WithTx(tx),
+		category: s.category.WithTx(tx),
+		product:  s.product.WithTx(tx),
+		batch:    s.batch.WithTx(tx),
+		unit:     s.unit.WithTx(tx),
+		stock:    s.stock.WithTx(tx),
+		ctx:      s.ctx,
+	}
+}
+
+// Import creates one record per row of table inside a single transaction.
+// Every row is validated (and reported) even after a failure; the transaction is only committed
+// when all rows succeeded and dryRun is false, so a file is imported entirely or not at all.
+func (s *ImportService) Import(entity string, table utils.Table, dryRun bool, userID uint) (*ImportResult, error) {
+	s, span := startSpan(s.ctx, s, "ImportService.Import")
+	defer span.End()
+
+	imp, ok := importers[entity]
+	if !ok {
+		return nil, apperror.NotFound("unsupported_import_entity", "unsupported import entity")
+	}
+
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	for _, column := range imp.required {
+		if !table.HasColumn(column) {
+			return nil, apperror.Validation("missing_required_column", fmt.Sprintf("missing required column %q", column))
+		}
+	}
+
+	result := &ImportResult{
+		Entity:    entity,
+		DryRun:    dryRun,
+		TotalRows: len(table.Rows),
+		Errors:    []ImportRowError{},
+	}
+
+	err := repository.Transaction(func(tx *gorm.DB) error {
+		svc := s.withTx(tx)
+
+		for _, tableRow := range table.Rows {
+			row := importRow{TableRow: tableRow}
+
+			if err := s.importRow(tx, svc, imp, row, userID); err != nil {
+				var rowErr ImportRowError
+				if !errors.As(err, &rowErr) {
+					rowErr = ImportRowError{Row: row.Line, Message: err.Error()}
+				}
+				result.Errors = append(result.Errors, rowErr)
+				continue
+			}
+			result.Valid++
+		}
+
+		if dryRun || len(result.Errors) > 0 {
+			return errImportRolledBack
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		return nil, err
+	}
+
+	result.Committed = err == nil
+	if result.Committed {
+		// Creates inside the transaction invalidated the cache before commit; drop it again now the rows are visible
+		s.brand.brandRepo.InvalidateListCache()
+	}
+	return result, nil
+}
+
+// importRow runs a single row behind a savepoint so a failed statement does not abort the rest of the transaction
+func (s *ImportService) importRow(tx *gorm.DB, svc *ImportService, imp importer, row importRow, userID uint) error {
+	for _, column := range imp.required {
+		if row.get(column) == "" {
+			return row.fail(column, "is required")
+		}
+	}
+
+	if err := tx.SavePoint("import_row").Error; err != nil {
+		return err
+	}
+	if err := imp.create(svc, row, userID); err != nil {
+		if rollbackErr := tx.RollbackTo("import_row").Error; rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	return nil
+}
@@ -1,14 +1,17 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"strings"
 )
 
 type LocationService struct {
 	locationRepo *repository.LocationRepository
+	ctx          context.Context
 }
 
 func NewLocationService() *LocationService {
@@ -17,17 +20,31 @@ func NewLocationService() *LocationService {
 	}
 }
 
-func (s *LocationService) GetAllLocations() (interface{}, error) {
-	locations, err := s.locationRepo.GetAllLocations()
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *LocationService) WithContext(ctx context.Context) *LocationService {
+	return &LocationService{
+		locationRepo: s.locationRepo.WithContext(ctx),
+		ctx:          ctx,
+	}
+}
+
+func (s *LocationService) GetAllLocations(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetAllLocations")
+	defer span.End()
+
+	locations, total, err := s.locationRepo.GetAllLocations(query)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return locations, nil
+	return locations, total, nil
 }
 
 func (s *LocationService) GetLocationsByUser(userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetLocationsByUser")
+	defer span.End()
+
 	if userID == 0 {
-		return nil, errors.New("invalid user ID")
+		return nil, apperror.Validation("invalid_user_id", "invalid user ID")
 	}
 
 	locations, err := s.locationRepo.GetLocationsByUser(userID)
@@ -37,9 +54,23 @@ func (s *LocationService) GetLocationsByUser(userID uint) (interface{}, error) {
 	return locations, nil
 }
 
+// GetLocationIDsByUser returns the IDs of the locations assigned to a user
+func (s *LocationService) GetLocationIDsByUser(userID uint) ([]uint, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetLocationIDsByUser")
+	defer span.End()
+
+	if userID == 0 {
+		return nil, apperror.Validation("invalid_user_id", "invalid user ID")
+	}
+	return s.locationRepo.GetLocationIDsByUser(userID)
+}
+
 func (s *LocationService) GetLocationsByType(locationType string) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetLocationsByType")
+	defer span.End()
+
 	if locationType == "" {
-		return nil, errors.New("location type is required")
+		return nil, apperror.Validation("location_type_required", "location type is required")
 	}
 
 	// Validate location type
@@ -52,7 +83,7 @@ func (s *LocationService) GetLocationsByType(locationType string) (interface{},
 		}
 	}
 	if !isValid {
-		return nil, errors.New("invalid location type. Must be 'gudang' or 'reseller'")
+		return nil, apperror.Validation("invalid_location_type", "invalid location type. Must be 'gudang' or 'reseller'")
 	}
 
 	locations, err := s.locationRepo.GetLocationsByType(strings.ToLower(locationType))
@@ -63,6 +94,9 @@ func (s *LocationService) GetLocationsByType(locationType string) (interface{},
 }
 
 func (s *LocationService) GetLocationByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetLocationByID")
+	defer span.End()
+
 	location, err := s.locationRepo.GetLocationByID(id)
 	if err != nil {
 		return nil, err
@@ -71,20 +105,23 @@ func (s *LocationService) GetLocationByID(id uint) (interface{}, error) {
 }
 
 func (s *LocationService) CreateLocation(userID uint, name string, address *string, phoneNumber *string, locationType string, createdByUserID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.CreateLocation")
+	defer span.End()
+
 	if userID == 0 {
-		return nil, errors.New("user ID is required")
+		return nil, apperror.Validation("user_id_required", "user ID is required")
 	}
 
 	if name == "" {
-		return nil, errors.New("location name is required")
+		return nil, apperror.Validation("location_name_required", "location name is required")
 	}
 
 	if locationType == "" {
-		return nil, errors.New("location type is required")
+		return nil, apperror.Validation("location_type_required", "location type is required")
 	}
 
 	if createdByUserID == 0 {
-		return nil, errors.New("created by user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "created by user ID is required for audit trail")
 	}
 
 	// Validate location type
@@ -97,7 +134,7 @@ func (s *LocationService) CreateLocation(userID uint, name string, address *stri
 		}
 	}
 	if !isValid {
-		return nil, errors.New("invalid location type. Must be 'gudang' or 'reseller'")
+		return nil, apperror.Validation("invalid_location_type", "invalid location type. Must be 'gudang' or 'reseller'")
 	}
 
 	// Check if user exists
@@ -106,7 +143,7 @@ func (s *LocationService) CreateLocation(userID uint, name string, address *stri
 		return nil, err
 	}
 	if !userExists {
-		return nil, errors.New("user not found")
+		return nil, apperror.NotFound("user_not_found", "user not found")
 	}
 
 	// Check if location name already exists for this user
@@ -115,7 +152,7 @@ func (s *LocationService) CreateLocation(userID uint, name string, address *stri
 		return nil, err
 	}
 	if nameExists {
-		return nil, errors.New("location name already exists for this user")
+		return nil, apperror.Conflict("location_name_already_exists_for_this_user", "location name already exists for this user")
 	}
 
 	location := &model.Location{
@@ -142,18 +179,21 @@ func (s *LocationService) CreateLocation(userID uint, name string, address *stri
 }
 
 func (s *LocationService) UpdateLocation(id uint, userID uint, name *string, address *string, phoneNumber *string, locationType *string, updatedByUserID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.UpdateLocation")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid location ID")
+		return nil, apperror.Validation("invalid_location_id", "invalid location ID")
 	}
 
 	if updatedByUserID == 0 {
-		return nil, errors.New("updated by user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "updated by user ID is required for audit trail")
 	}
 
 	// Check if location exists
 	oldLocation, err := s.locationRepo.GetLocationModelByID(id)
 	if err != nil {
-		return nil, errors.New("location not found")
+		return nil, apperror.NotFound("location_not_found", "location not found")
 	}
 
 	// If user ID is being changed, check if new user exists
@@ -163,7 +203,7 @@ func (s *LocationService) UpdateLocation(id uint, userID uint, name *string, add
 			return nil, err
 		}
 		if !userExists {
-			return nil, errors.New("user not found")
+			return nil, apperror.NotFound("user_not_found", "user not found")
 		}
 	}
 
@@ -179,7 +219,7 @@ func (s *LocationService) UpdateLocation(id uint, userID uint, name *string, add
 			return nil, err
 		}
 		if nameExists {
-			return nil, errors.New("location name already exists for this user")
+			return nil, apperror.Conflict("location_name_already_exists_for_this_user", "location name already exists for this user")
 		}
 	}
 
@@ -194,7 +234,7 @@ func (s *LocationService) UpdateLocation(id uint, userID uint, name *string, add
 			}
 		}
 		if !isValid {
-			return nil, errors.New("invalid location type. Must be 'gudang' or 'reseller'")
+			return nil, apperror.Validation("invalid_location_type", "invalid location type. Must be 'gudang' or 'reseller'")
 		}
 	}
 
@@ -232,18 +272,21 @@ func (s *LocationService) UpdateLocation(id uint, userID uint, name *string, add
 }
 
 func (s *LocationService) DeleteLocation(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "LocationService.DeleteLocation")
+	defer span.End()
+
 	if id == 0 {
-		return errors.New("invalid location ID")
+		return apperror.Validation("invalid_location_id", "invalid location ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if location exists
 	_, err := s.locationRepo.GetLocationModelByID(id)
 	if err != nil {
-		return errors.New("location not found")
+		return apperror.NotFound("location_not_found", "location not found")
 	}
 
 	return s.locationRepo.DeleteLocationWithAudit(id, userID)
@@ -251,16 +294,22 @@ func (s *LocationService) DeleteLocation(id uint, userID uint) error {
 
 // GetDeletedLocations returns all soft deleted locations
 func (s *LocationService) GetDeletedLocations() (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.GetDeletedLocations")
+	defer span.End()
+
 	return s.locationRepo.GetDeletedLocations()
 }
 
 // RestoreLocation restores a soft deleted location
 func (s *LocationService) RestoreLocation(id uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "LocationService.RestoreLocation")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid location ID")
+		return nil, apperror.Validation("invalid_location_id", "invalid location ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.locationRepo.RestoreLocation(id, userID)
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MetricsService struct {
+	metricsRepo *repository.MetricsRepository
+	ctx         context.Context
+}
+
+// InventoryTotals are the business figures exported as metrics, per tenant
+type InventoryTotals struct {
+	StockValue     []repository.TenantTotal
+	ExpiredBatches []repository.TenantTotal
+}
+
+func NewMetricsService() *MetricsService {
+	return &MetricsService{
+		metricsRepo: repository.NewMetricsRepository(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *MetricsService) WithTx(tx *gorm.DB) *MetricsService {
+	return &MetricsService{
+		metricsRepo: s.metricsRepo.WithTx(tx),
+		ctx:         s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *MetricsService) WithContext(ctx context.Context) *MetricsService {
+	return &MetricsService{
+		metricsRepo: s.metricsRepo.WithContext(ctx),
+		ctx:         ctx,
+	}
+}
+
+// GetInventoryTotals returns the stock value and the number of expired batches with stock left of every tenant
+func (s *MetricsService) GetInventoryTotals() (InventoryTotals, error) {
+	s, span := startSpan(s.ctx, s, "MetricsService.GetInventoryTotals")
+	defer span.End()
+
+	stockValue, err := s.metricsRepo.GetStockValueByTenant()
+	if err != nil {
+		return InventoryTotals{}, err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	expiredBatches, err := s.metricsRepo.CountExpiredBatchesByTenant(today)
+	if err != nil {
+		return InventoryTotals{}, err
+	}
+
+	return InventoryTotals{StockValue: stockValue, ExpiredBatches: expiredBatches}, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/tenant"
+	"myapp/internal/utils"
+	"myapp/pkg/tracing"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outbox relay settings
+const (
+	OutboxMaxAttempts    = 10 // An event is marked failed after this many relay attempts
+	OutboxRetryBaseDelay = 5 * time.Second
+	OutboxRetryMaxDelay  = time.Hour
+	OutboxRelayBatch     = 100 // Events relayed per run
+	OutboxRelayLease     = time.Minute
+	OutboxPurgeBatch     = 1000 // Published events removed per statement
+)
+
+type OutboxService struct {
+	outboxRepo *repository.OutboxRepository
+	ctx        context.Context
+}
+
+func NewOutboxService() *OutboxService {
+	return &OutboxService{
+		outboxRepo: repository.NewOutboxRepository(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *OutboxService) WithTx(tx *gorm.DB) *OutboxService {
+	return &OutboxService{
+		outboxRepo: s.outboxRepo.WithTx(tx),
+		ctx:        s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *OutboxService) WithContext(ctx context.Context) *OutboxService {
+	return &OutboxService{
+		outboxRepo: s.outboxRepo.WithContext(ctx),
+		ctx:        ctx,
+	}
+}
+
+// inTransaction runs fn on svc when it is already bound to a transaction, otherwise on a copy bound to a new one,
+// so a change and the events it records are committed or rolled back together
+func inTransaction[S any](svc S, inTx bool, withTx func(*gorm.DB) S, fn func(S) error) error {
+	if inTx {
+		return fn(svc)
+	}
+	return repository.Transaction(func(tx *gorm.DB) error {
+		return fn(withTx(tx))
+	})
+}
+
+// Record writes event to the outbox; the service must be bound to the transaction of the change it describes
+func (s *OutboxService) Record(event events.Event) error {
+	s, span := startSpan(s.ctx, s, "OutboxService.Record")
+	defer span.End()
+
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.outboxRepo.CreateOutboxEvent(&model.OutboxEvent{
+		EventType:     event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		Payload:       string(payload),
+		UserID:        event.UserID,
+		Status:        model.OutboxEventPending,
+		NextAttemptAt: &now,
+	})
+}
+
+func (s *OutboxService) GetAllOutboxEvents(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "OutboxService.GetAllOutboxEvents")
+	defer span.End()
+
+	return s.outboxRepo.GetAllOutboxEvents(query)
+}
+
+// Relay publishes due events, oldest first, to every sink that has not accepted them yet and returns how many were attempted.
+// An event is published once all sinks accept it; otherwise it is retried with backoff until OutboxMaxAttempts.
+func (s *OutboxService) Relay(ctx context.Context, sinks []events.Sink) (int, error) {
+	ctx, span := tracing.Start(ctx, "OutboxService.Relay")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	outboxEvents, err := s.outboxRepo.ClaimPendingOutboxEvents(time.Now(), OutboxRelayLease, OutboxRelayBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, outboxEvent := range outboxEvents {
+		if err := s.relayEvent(ctx, outboxEvent, sinks); err != nil {
+			return i, err
+		}
+	}
+	return len(outboxEvents), nil
+}
+
+func (s *OutboxService) relayEvent(ctx context.Context, outboxEvent model.OutboxEvent, sinks []events.Sink) error {
+	envelope := events.Envelope{
+		ID:            outboxEvent.ID,
+		Type:          outboxEvent.EventType,
+		AggregateType: outboxEvent.AggregateType,
+		AggregateID:   outboxEvent.AggregateID,
+		UserID:        outboxEvent.UserID,
+		TenantID:      outboxEvent.TenantID,
+		OccurredAt:    outboxEvent.CreatedAt,
+		Data:          json.RawMessage(outboxEvent.Payload),
+	}
+
+	published := []string{}
+	if outboxEvent.PublishedSinks != "" {
+		published = strings.Split(outboxEvent.PublishedSinks, ",")
+	}
+	var failures []string
+	for _, sink := range sinks {
+		if slices.Contains(published, sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(ctx, envelope); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+			continue
+		}
+		published = append(published, sink.Name())
+	}
+
+	now := time.Now()
+	attempts := outboxEvent.Attempts + 1
+	updateData := map[string]interface{}{
+		"attempts":        attempts,
+		"published_sinks": strings.Join(published, ","),
+	}
+
+	if len(failures) == 0 {
+		updateData["status"] = model.OutboxEventPublished
+		updateData["published_at"] = now
+		updateData["next_attempt_at"] = nil
+		updateData["last_error"] = nil
+	} else if attempts >= OutboxMaxAttempts {
+		updateData["status"] = model.OutboxEventFailed
+		updateData["next_attempt_at"] = nil
+		updateData["last_error"] = strings.Join(failures, "; ")
+	} else {
+		updateData["next_attempt_at"] = now.Add(backoffDelay(attempts, OutboxRetryBaseDelay, OutboxRetryMaxDelay))
+		updateData["last_error"] = strings.Join(failures, "; ")
+	}
+
+	return s.outboxRepo.UpdateOutboxEvent(outboxEvent.ID, updateData)
+}
+
+// PurgePublishedEvents removes the events published more than retention ago, in batches of OutboxPurgeBatch so no
+// statement holds locks on a large part of the table, and returns how many were removed
+func (s *OutboxService) PurgePublishedEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := tracing.Start(ctx, "OutboxService.PurgePublishedEvents")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	cutoff := time.Now().Add(-retention)
+	var removed int64
+	for ctx.Err() == nil {
+		deleted, err := s.outboxRepo.DeletePublishedOutboxEvents(cutoff, OutboxPurgeBatch)
+		removed += deleted
+		if err != nil || deleted < OutboxPurgeBatch {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// backoffDelay doubles base for every failed attempt after the first, capped at max
+func backoffDelay(attempts int, base, max time.Duration) time.Duration {
+	delay := time.Duration(float64(base) * math.Pow(2, float64(attempts-1)))
+	if delay > max || delay <= 0 {
+		return max
+	}
+	return delay
+}
+
+// WebhookSink turns relayed events into webhook deliveries for the subscriptions listening to their type
+type WebhookSink struct {
+	webhookService *WebhookService
+}
+
+func NewWebhookSink() *WebhookSink {
+	return &WebhookSink{webhookService: NewWebhookService()}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, envelope events.Envelope) error {
+	return s.webhookService.WithContext(tenant.ForRow(ctx, envelope.TenantID)).PublishOutboxEvent(envelope)
+}
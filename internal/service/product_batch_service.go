@@ -1,32 +1,81 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ProductBatchService struct {
-	batchRepo    *repository.ProductBatchRepository
-	trackService *ProductBatchTrackService
+	batchRepo     *repository.ProductBatchRepository
+	trackService  *ProductBatchTrackService
+	outboxService *OutboxService
+	inTx          bool
+	ctx           context.Context
 }
 
 func NewProductBatchService() *ProductBatchService {
 	return &ProductBatchService{
-		batchRepo:    repository.NewProductBatchRepository(),
-		trackService: NewProductBatchTrackService(),
+		batchRepo:     repository.NewProductBatchRepository(),
+		trackService:  NewProductBatchTrackService(),
+		outboxService: NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductBatchService) WithTx(tx *gorm.DB) *ProductBatchService {
+	return &ProductBatchService{
+		batchRepo:     s.batchRepo.WithTx(tx),
+		trackService:  s.trackService.WithTx(tx),
+		outboxService: s.outboxService.WithTx(tx),
+		inTx:          true,
+		ctx:           s.ctx,
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductBatchService) WithContext(ctx context.Context) *ProductBatchService {
+	return &ProductBatchService{
+		batchRepo:     s.batchRepo.WithContext(ctx),
+		trackService:  s.trackService.WithContext(ctx),
+		outboxService: s.outboxService.WithContext(ctx),
+		inTx:          s.inTx,
+		ctx:           ctx,
+	}
+}
+
+// recordEvent writes a product batch event to the outbox, in the transaction of the change
+func (s *ProductBatchService) recordEvent(eventType string, id uint, userID uint, data interface{}) error {
+	return s.outboxService.Record(events.Event{
+		Type:          eventType,
+		AggregateType: events.AggregateProductBatch,
+		AggregateID:   id,
+		UserID:        &userID,
+		Data:          data,
+	})
+}
+
 // Business logic methods
-func (s *ProductBatchService) GetAllProductBatches() (interface{}, error) {
-	return s.batchRepo.GetAllProductBatches()
+func (s *ProductBatchService) GetAllProductBatches(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.GetAllProductBatches")
+	defer span.End()
+
+	return s.batchRepo.GetAllProductBatches(query)
 }
 
 func (s *ProductBatchService) GetProductBatchesByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.GetProductBatchesByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	// Check if product exists
@@ -35,13 +84,16 @@ func (s *ProductBatchService) GetProductBatchesByProduct(productID uint) (interf
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	return s.batchRepo.GetProductBatchesByProduct(productID)
 }
 
 func (s *ProductBatchService) GetProductBatchByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.GetProductBatchByID")
+	defer span.End()
+
 	batch, err := s.batchRepo.GetProductBatchByID(id)
 	if err != nil {
 		return nil, err
@@ -50,16 +102,29 @@ func (s *ProductBatchService) GetProductBatchByID(id uint) (interface{}, error)
 }
 
 func (s *ProductBatchService) CreateProductBatch(productID uint, codeBatch *string, unitPrice *float64, expDate time.Time, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.CreateProductBatch")
+	defer span.End()
+
+	var createdBatch interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductBatchService) error {
+		var err error
+		createdBatch, err = s.createProductBatch(productID, codeBatch, unitPrice, expDate, description, userID)
+		return err
+	})
+	return createdBatch, err
+}
+
+func (s *ProductBatchService) createProductBatch(productID uint, codeBatch *string, unitPrice *float64, expDate time.Time, description *string, userID uint) (interface{}, error) {
 	if productID == 0 {
-		return nil, errors.New("product ID is required")
+		return nil, apperror.Validation("product_id_required", "product ID is required")
 	}
 
 	if expDate.IsZero() {
-		return nil, errors.New("expiry date is required")
+		return nil, apperror.Validation("expiry_date_required", "expiry date is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product exists
@@ -68,7 +133,7 @@ func (s *ProductBatchService) CreateProductBatch(productID uint, codeBatch *stri
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	// Note: No duplicate check for batches since they can have different exp_date, code_batch, etc.
@@ -104,22 +169,44 @@ func (s *ProductBatchService) CreateProductBatch(productID uint, codeBatch *stri
 		}
 	}
 
+	err = s.recordEvent(events.BatchCreated, batch.ID, userID, createdBatch)
+	if err != nil {
+		return nil, err
+	}
+
 	return createdBatch, nil
 }
 
 func (s *ProductBatchService) UpdateProductBatch(id uint, productID uint, codeBatch *string, unitPrice *float64, expDate time.Time, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.UpdateProductBatch")
+	defer span.End()
+
+	var updatedBatch interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductBatchService) error {
+		var err error
+		updatedBatch, err = s.updateProductBatch(id, productID, codeBatch, unitPrice, expDate, description, userID)
+		return err
+	})
+	return updatedBatch, err
+}
+
+func (s *ProductBatchService) updateProductBatch(id uint, productID uint, codeBatch *string, unitPrice *float64, expDate time.Time, description *string, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product batch ID")
+		return nil, apperror.Validation("invalid_product_batch_id", "invalid product batch ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if batch exists using model for business logic
 	oldBatch, err := s.batchRepo.GetProductBatchModelByID(id)
 	if err != nil {
-		return nil, errors.New("product batch not found")
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
+	}
+	previousBatch, err := s.batchRepo.GetProductBatchByID(id)
+	if err != nil {
+		return nil, err
 	}
 
 	// If product ID is being changed, check if new product exists
@@ -129,7 +216,7 @@ func (s *ProductBatchService) UpdateProductBatch(id uint, productID uint, codeBa
 			return nil, err
 		}
 		if !productExists {
-			return nil, errors.New("product not found")
+			return nil, apperror.NotFound("product_not_found", "product not found")
 		}
 	}
 
@@ -176,22 +263,36 @@ func (s *ProductBatchService) UpdateProductBatch(id uint, productID uint, codeBa
 		return nil, err
 	}
 
+	err = s.recordEvent(events.BatchUpdated, id, userID, events.Changes{Before: previousBatch, After: updatedBatch})
+	if err != nil {
+		return nil, err
+	}
+
 	return updatedBatch, nil
 }
 
 func (s *ProductBatchService) DeleteProductBatch(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.DeleteProductBatch")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductBatchService) error {
+		return s.deleteProductBatch(id, userID)
+	})
+}
+
+func (s *ProductBatchService) deleteProductBatch(id uint, userID uint) error {
 	if id == 0 {
-		return errors.New("invalid product batch ID")
+		return apperror.Validation("invalid_product_batch_id", "invalid product batch ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if batch exists using model for tracking
 	batchToDelete, err := s.batchRepo.GetProductBatchModelByID(id)
 	if err != nil {
-		return errors.New("product batch not found")
+		return apperror.NotFound("product_batch_not_found", "product batch not found")
 	}
 
 	// Create tracking record for deletion (before actual deletion)
@@ -202,21 +303,42 @@ func (s *ProductBatchService) DeleteProductBatch(id uint, userID uint) error {
 		// log.Printf("Failed to create tracking record: %v", err)
 	}
 
-	return s.batchRepo.DeleteProductBatchWithAudit(id, userID)
+	err = s.batchRepo.DeleteProductBatchWithAudit(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.recordEvent(events.BatchDeleted, id, userID, events.Deleted{ID: id})
 }
 
 // GetDeletedProductBatches returns all soft deleted product batches
 func (s *ProductBatchService) GetDeletedProductBatches() (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.GetDeletedProductBatches")
+	defer span.End()
+
 	return s.batchRepo.GetDeletedProductBatches()
 }
 
 // RestoreProductBatch restores a soft deleted product batch
 func (s *ProductBatchService) RestoreProductBatch(id uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.RestoreProductBatch")
+	defer span.End()
+
+	var restoredBatch interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductBatchService) error {
+		var err error
+		restoredBatch, err = s.restoreProductBatch(id, userID)
+		return err
+	})
+	return restoredBatch, err
+}
+
+func (s *ProductBatchService) restoreProductBatch(id uint, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product batch ID")
+		return nil, apperror.Validation("invalid_product_batch_id", "invalid product batch ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.batchRepo.RestoreProductBatch(id, userID)
@@ -228,5 +350,165 @@ func (s *ProductBatchService) RestoreProductBatch(id uint, userID uint) (interfa
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.BatchRestored, id, userID, restoredBatch)
+	if err != nil {
+		return nil, err
+	}
 	return restoredBatch, nil
 }
+
+// HoldProductBatch places a batch on hold (quarantined, on-hold, damaged or expired); no stock of a held batch can be taken out
+func (s *ProductBatchService) HoldProductBatch(id uint, status, reason string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.HoldProductBatch")
+	defer span.End()
+
+	if !model.IsHoldStatus(status) {
+		return nil, apperror.Validation("invalid_hold_status", "invalid hold status")
+	}
+	return s.setProductBatchStatus(id, status, reason, userID)
+}
+
+// ReleaseProductBatch makes a held batch available again
+func (s *ProductBatchService) ReleaseProductBatch(id uint, reason string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.ReleaseProductBatch")
+	defer span.End()
+
+	return s.setProductBatchStatus(id, model.StockStatusAvailable, reason, userID)
+}
+
+func (s *ProductBatchService) setProductBatchStatus(id uint, status, reason string, userID uint) (interface{}, error) {
+	var updatedBatch interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductBatchService) error {
+		var err error
+		updatedBatch, err = s.updateProductBatchStatus(id, status, reason, userID)
+		return err
+	})
+	return updatedBatch, err
+}
+
+func (s *ProductBatchService) updateProductBatchStatus(id uint, status, reason string, userID uint) (interface{}, error) {
+	if id == 0 {
+		return nil, apperror.Validation("invalid_product_batch_id", "invalid product batch ID")
+	}
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	updateData, err := statusUpdateData(status, reason, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := s.batchRepo.GetProductBatchModelByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
+	}
+	if status == model.StockStatusAvailable && batch.Status == model.StockStatusAvailable {
+		return nil, apperror.Conflict("product_batch_is_not_on_hold", "product batch is not on hold")
+	}
+
+	if err := s.batchRepo.UpdateProductBatch(id, updateData); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Product batch placed on hold (%s): %s", status, updateData["status_reason"])
+	if status == model.StockStatusAvailable {
+		description = fmt.Sprintf("Product batch released from hold (%s): %s", batch.Status, updateData["status_reason"])
+	}
+	err = s.trackService.TrackCustomAction(id, description, userID)
+	if err != nil {
+		// Log error but don't fail the status change
+		// Consider using a logger here
+		// log.Printf("Failed to create tracking record: %v", err)
+	}
+
+	updatedBatch, err := s.batchRepo.GetProductBatchByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordEvent(events.BatchStatusChanged, id, userID, map[string]interface{}{
+		"previousStatus": batch.Status,
+		"status":         status,
+		"reason":         updateData["status_reason"],
+		"batch":          updatedBatch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updatedBatch, nil
+}
+
+// BatchTraceSummary totals the quantities of a batch across every location
+type BatchTraceSummary struct {
+	Locations       int     `json:"locations"`
+	QuantityIn      float64 `json:"quantityIn"`
+	QuantityOut     float64 `json:"quantityOut"`
+	CurrentQuantity float64 `json:"currentQuantity"`
+	Movements       int     `json:"movements"`
+	Users           int     `json:"users"`
+}
+
+// BatchTraceReport is the recall report of a batch: where it went, what moved and who touched it
+type BatchTraceReport struct {
+	Batch       repository.BatchTraceHeader     `json:"batch"`
+	Summary     BatchTraceSummary               `json:"summary"`
+	Locations   []repository.BatchTraceLocation `json:"locations"`
+	Movements   []repository.BatchTraceMovement `json:"movements"`
+	Users       []repository.BatchTraceUser     `json:"users"`
+	GeneratedAt time.Time                       `json:"generatedAt"`
+}
+
+// GetBatchTrace follows the stocks, items and tracks of a batch to build its recall report
+func (s *ProductBatchService) GetBatchTrace(id uint) (*BatchTraceReport, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchService.GetBatchTrace")
+	defer span.End()
+
+	if id == 0 {
+		return nil, apperror.Validation("invalid_product_batch_id", "invalid product batch ID")
+	}
+
+	// The header lookup is tenant scoped, so the raw trace queries below only run for a batch of the caller's tenant
+	header, err := s.batchRepo.GetBatchTraceHeader(id)
+	if err != nil {
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
+	}
+
+	locations, err := s.batchRepo.GetBatchTraceLocations(id)
+	if err != nil {
+		return nil, err
+	}
+
+	movements, err := s.batchRepo.GetBatchTraceMovements(id)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.batchRepo.GetBatchTraceUsers(id)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := BatchTraceSummary{
+		Locations: len(locations),
+		Movements: len(movements),
+		Users:     len(users),
+	}
+	for _, location := range locations {
+		summary.QuantityIn += location.QuantityIn
+		summary.QuantityOut += location.QuantityOut
+		if !location.Deleted {
+			summary.CurrentQuantity += location.CurrentQuantity
+		}
+	}
+
+	return &BatchTraceReport{
+		Batch:       header,
+		Summary:     summary,
+		Locations:   locations,
+		Movements:   movements,
+		Users:       users,
+		GeneratedAt: time.Now(),
+	}, nil
+}
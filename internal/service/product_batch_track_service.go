@@ -1,15 +1,19 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"myapp/internal/model"
 	"myapp/internal/repository"
 	"myapp/internal/utils"
+
+	"gorm.io/gorm"
 )
 
 type ProductBatchTrackService struct {
 	repository    *repository.ProductBatchTrackRepository
 	trackingUtils *utils.ProductBatchTrackingUtils
+	ctx           context.Context
 }
 
 func NewProductBatchTrackService() *ProductBatchTrackService {
@@ -19,23 +23,53 @@ func NewProductBatchTrackService() *ProductBatchTrackService {
 	}
 }
 
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductBatchTrackService) WithTx(tx *gorm.DB) *ProductBatchTrackService {
+	return &ProductBatchTrackService{
+		repository:    s.repository.WithTx(tx),
+		trackingUtils: s.trackingUtils,
+		ctx:           s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductBatchTrackService) WithContext(ctx context.Context) *ProductBatchTrackService {
+	return &ProductBatchTrackService{
+		repository:    s.repository.WithContext(ctx),
+		trackingUtils: s.trackingUtils,
+		ctx:           ctx,
+	}
+}
+
 // GetAllTracks retrieves all product batch tracking records
 func (s *ProductBatchTrackService) GetAllTracks() ([]model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetAllTracks")
+	defer span.End()
+
 	return s.repository.GetAllTracks()
 }
 
 // GetTracksByProductBatchID retrieves all tracking records for a specific product batch
 func (s *ProductBatchTrackService) GetTracksByProductBatchID(productBatchID uint) ([]model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetTracksByProductBatchID")
+	defer span.End()
+
 	return s.repository.GetTracksByProductBatchID(productBatchID)
 }
 
 // GetTrackByID retrieves a specific tracking record by ID
 func (s *ProductBatchTrackService) GetTrackByID(id uint) (model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetTrackByID")
+	defer span.End()
+
 	return s.repository.GetTrackByID(id)
 }
 
 // CreateTrackingRecord creates a new tracking record for product batch changes
 func (s *ProductBatchTrackService) CreateTrackingRecord(productBatchID uint, description string, userID uint) (model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.CreateTrackingRecord")
+	defer span.End()
+
 	track := model.ProductBatchTrack{
 		ProductBatchID: productBatchID,
 		Description:    description,
@@ -53,6 +87,9 @@ func (s *ProductBatchTrackService) CreateTrackingRecord(productBatchID uint, des
 
 // TrackCreate creates a tracking record for product batch creation
 func (s *ProductBatchTrackService) TrackCreate(productBatch model.ProductBatch, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.TrackCreate")
+	defer span.End()
+
 	description := s.trackingUtils.GenerateCreateDescription(productBatch)
 	_, err := s.CreateTrackingRecord(productBatch.ID, description, userID)
 	return err
@@ -60,6 +97,9 @@ func (s *ProductBatchTrackService) TrackCreate(productBatch model.ProductBatch,
 
 // TrackUpdateFromChanges creates a tracking record for product batch updates using update data
 func (s *ProductBatchTrackService) TrackUpdateFromChanges(updateData map[string]interface{}, oldBatch model.ProductBatch, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.TrackUpdateFromChanges")
+	defer span.End()
+
 	description := s.trackingUtils.GenerateUpdateDescriptionFromChanges(updateData, oldBatch)
 	_, err := s.CreateTrackingRecord(oldBatch.ID, description, userID)
 	return err
@@ -67,6 +107,9 @@ func (s *ProductBatchTrackService) TrackUpdateFromChanges(updateData map[string]
 
 // TrackUpdate creates a tracking record for product batch updates (legacy method, kept for compatibility)
 func (s *ProductBatchTrackService) TrackUpdate(oldBatch, newBatch model.ProductBatch, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.TrackUpdate")
+	defer span.End()
+
 	// This method is less precise, use TrackUpdateFromChanges instead
 	description := "Product batch updated"
 	_, err := s.CreateTrackingRecord(newBatch.ID, description, userID)
@@ -75,6 +118,9 @@ func (s *ProductBatchTrackService) TrackUpdate(oldBatch, newBatch model.ProductB
 
 // TrackDelete creates a tracking record for product batch deletion
 func (s *ProductBatchTrackService) TrackDelete(productBatch model.ProductBatch, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.TrackDelete")
+	defer span.End()
+
 	description := s.trackingUtils.GenerateDeleteDescription(productBatch)
 	_, err := s.CreateTrackingRecord(productBatch.ID, description, userID)
 	return err
@@ -82,22 +128,34 @@ func (s *ProductBatchTrackService) TrackDelete(productBatch model.ProductBatch,
 
 // TrackCustomAction creates a tracking record for custom actions
 func (s *ProductBatchTrackService) TrackCustomAction(productBatchID uint, customDescription string, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.TrackCustomAction")
+	defer span.End()
+
 	_, err := s.CreateTrackingRecord(productBatchID, customDescription, userID)
 	return err
 }
 
 // GetTracksByUserID retrieves all tracking records made by a specific user
 func (s *ProductBatchTrackService) GetTracksByUserID(userID uint) ([]model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetTracksByUserID")
+	defer span.End()
+
 	return s.repository.GetTracksByUserID(userID)
 }
 
 // GetLatestTrackForProductBatch retrieves the most recent tracking record for a product batch
 func (s *ProductBatchTrackService) GetLatestTrackForProductBatch(productBatchID uint) (model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetLatestTrackForProductBatch")
+	defer span.End()
+
 	return s.repository.GetLatestTrackForProductBatch(productBatchID)
 }
 
 // GetProductBatchHistory retrieves the complete history of changes for a product batch
 func (s *ProductBatchTrackService) GetProductBatchHistory(productBatchID uint) ([]model.ProductBatchTrack, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.GetProductBatchHistory")
+	defer span.End()
+
 	tracks, err := s.repository.GetTracksByProductBatchID(productBatchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get product batch history: %w", err)
@@ -107,10 +165,16 @@ func (s *ProductBatchTrackService) GetProductBatchHistory(productBatchID uint) (
 
 // CountTracksByProductBatchID counts tracking records for a specific product batch
 func (s *ProductBatchTrackService) CountTracksByProductBatchID(productBatchID uint) (int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.CountTracksByProductBatchID")
+	defer span.End()
+
 	return s.repository.CountTracksByProductBatchID(productBatchID)
 }
 
 // DeleteTrack removes a tracking record (rarely used, for admin purposes)
 func (s *ProductBatchTrackService) DeleteTrack(id uint) error {
+	s, span := startSpan(s.ctx, s, "ProductBatchTrackService.DeleteTrack")
+	defer span.End()
+
 	return s.repository.DeleteTrack(id)
 }
@@ -1,48 +1,90 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ProductItemService struct {
-	itemRepo     *repository.ProductItemRepository
-	stockRepo    *repository.ProductStockRepository
-	trackService *ProductItemTrackService
+	itemRepo      *repository.ProductItemRepository
+	stockRepo     *repository.ProductStockRepository
+	trackService  *ProductItemTrackService
+	outboxService *OutboxService
+	inTx          bool
+	ctx           context.Context
 }
 
 func NewProductItemService() *ProductItemService {
 	return &ProductItemService{
-		itemRepo:     repository.NewProductItemRepository(),
-		stockRepo:    repository.NewProductStockRepository(),
-		trackService: NewProductItemTrackService(),
+		itemRepo:      repository.NewProductItemRepository(),
+		stockRepo:     repository.NewProductStockRepository(),
+		trackService:  NewProductItemTrackService(),
+		outboxService: NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductItemService) WithTx(tx *gorm.DB) *ProductItemService {
+	return &ProductItemService{
+		itemRepo:      s.itemRepo.WithTx(tx),
+		stockRepo:     s.stockRepo.WithTx(tx),
+		trackService:  s.trackService,
+		outboxService: s.outboxService.WithTx(tx),
+		inTx:          true,
+		ctx:           s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductItemService) WithContext(ctx context.Context) *ProductItemService {
+	return &ProductItemService{
+		itemRepo:      s.itemRepo.WithContext(ctx),
+		stockRepo:     s.stockRepo.WithContext(ctx),
+		trackService:  s.trackService.WithContext(ctx),
+		outboxService: s.outboxService.WithContext(ctx),
+		inTx:          s.inTx,
+		ctx:           ctx,
 	}
 }
 
 // Business logic methods
-func (s *ProductItemService) GetAllProductItems() (interface{}, error) {
-	return s.itemRepo.GetAllProductItems()
+func (s *ProductItemService) GetAllProductItems(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetAllProductItems")
+	defer span.End()
+
+	return s.itemRepo.GetAllProductItems(query)
 }
 
 func (s *ProductItemService) GetProductItemsByStock(stockID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetProductItemsByStock")
+	defer span.End()
+
 	if stockID == 0 {
-		return nil, errors.New("invalid stock ID")
+		return nil, apperror.Validation("invalid_stock_id", "invalid stock ID")
 	}
 
 	// Check if stock exists
 	_, err := s.stockRepo.GetProductStockModelByID(stockID)
 	if err != nil {
-		return nil, errors.New("product stock not found")
+		return nil, apperror.NotFound("product_stock_not_found", "product stock not found")
 	}
 
 	return s.itemRepo.GetProductItemsByStock(stockID)
 }
 
 func (s *ProductItemService) GetProductItemsByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetProductItemsByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	// Check if product exists
@@ -51,15 +93,18 @@ func (s *ProductItemService) GetProductItemsByProduct(productID uint) (interface
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	return s.itemRepo.GetProductItemsByProduct(productID)
 }
 
 func (s *ProductItemService) GetProductItemsByLocation(locationID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetProductItemsByLocation")
+	defer span.End()
+
 	if locationID == 0 {
-		return nil, errors.New("invalid location ID")
+		return nil, apperror.Validation("invalid_location_id", "invalid location ID")
 	}
 
 	// Location functionality would need specific repository method
@@ -68,19 +113,40 @@ func (s *ProductItemService) GetProductItemsByLocation(locationID uint) (interfa
 }
 
 func (s *ProductItemService) GetProductItemByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetProductItemByID")
+	defer span.End()
+
 	return s.itemRepo.GetProductItemByID(id)
 }
 
 func (s *ProductItemService) CreateProductItem(productStockID, productID, productBatchID uint, locationID *uint, stockIn, stockOut, quantity *float64, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.CreateProductItem")
+	defer span.End()
+
+	var createdItem interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemService) error {
+		var err error
+		createdItem, err = s.createProductItem(productStockID, productID, productBatchID, locationID, stockIn, stockOut, quantity, userID)
+		return err
+	})
+	return createdItem, err
+}
+
+func (s *ProductItemService) createProductItem(productStockID, productID, productBatchID uint, locationID *uint, stockIn, stockOut, quantity *float64, userID uint) (interface{}, error) {
 	// Validate required fields
 	if productStockID == 0 || productID == 0 || productBatchID == 0 {
-		return nil, errors.New("product stock ID, product ID, and product batch ID are required")
+		return nil, apperror.Validation("product_stock_product_and_batch_required", "product stock ID, product ID, and product batch ID are required")
 	}
 
 	// Check if product stock exists
 	_, err := s.stockRepo.GetProductStockModelByID(productStockID)
 	if err != nil {
-		return nil, errors.New("product stock not found")
+		return nil, apperror.NotFound("product_stock_not_found", "product stock not found")
+	}
+
+	// Items cannot be created from held stock (quarantined, on-hold, damaged or expired stock or batch)
+	if err := ensureStockAvailable(s.stockRepo, productStockID); err != nil {
+		return nil, err
 	}
 
 	// Check if product exists
@@ -89,7 +155,7 @@ func (s *ProductItemService) CreateProductItem(productStockID, productID, produc
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	// Check if product batch exists
@@ -98,7 +164,7 @@ func (s *ProductItemService) CreateProductItem(productStockID, productID, produc
 		return nil, err
 	}
 	if !batchExists {
-		return nil, errors.New("product batch not found")
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
 	}
 
 	// Check if location exists (if provided)
@@ -109,7 +175,7 @@ func (s *ProductItemService) CreateProductItem(productStockID, productID, produc
 	// Validate stock in/out values
 	if stockIn != nil && stockOut != nil {
 		if *stockIn > 0 && *stockOut > 0 {
-			return nil, errors.New("cannot have both stock in and stock out values")
+			return nil, apperror.Validation("stock_in_and_out_together", "cannot have both stock in and stock out values")
 		}
 	}
 
@@ -146,21 +212,73 @@ func (s *ProductItemService) CreateProductItem(productStockID, productID, produc
 	// }
 
 	// Return created item
-	return s.itemRepo.GetProductItemByID(item.ID)
+	createdItem, err := s.itemRepo.GetProductItemByID(item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An item is a stock in or out of its stock row
+	err = s.outboxService.Record(events.Event{
+		Type:          events.StockMoved,
+		AggregateType: events.AggregateProductStock,
+		AggregateID:   productStockID,
+		UserID:        &userID,
+		Data: events.StockMovement{
+			ProductStockID: productStockID,
+			ProductItemID:  &item.ID,
+			ProductID:      productID,
+			ProductBatchID: productBatchID,
+			StockIn:        stockIn,
+			StockOut:       stockOut,
+			Stock:          createdItem,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return createdItem, nil
 }
 
 func (s *ProductItemService) UpdateProductItem(id uint, productStockID, productID *uint, locationID *uint, stockIn, stockOut, quantity *float64, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.UpdateProductItem")
+	defer span.End()
+
+	var updatedItem interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemService) error {
+		var err error
+		updatedItem, err = s.updateProductItem(id, productStockID, productID, locationID, stockIn, stockOut, quantity, userID)
+		return err
+	})
+	return updatedItem, err
+}
+
+func (s *ProductItemService) updateProductItem(id uint, productStockID, productID *uint, locationID *uint, stockIn, stockOut, quantity *float64, userID uint) (interface{}, error) {
 	// Check if item exists
-	_, err := s.itemRepo.GetProductItemModelByID(id)
+	existingItem, err := s.itemRepo.GetProductItemModelByID(id)
 	if err != nil {
 		return nil, err
 	}
+	previousItem, err := s.itemRepo.GetProductItemByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recording a stock-out or moving the item to another stock needs that stock to be available
+	targetStockID := existingItem.ProductStockID
+	if productStockID != nil && *productStockID > 0 {
+		targetStockID = *productStockID
+	}
+	if (stockOut != nil && *stockOut > 0) || targetStockID != existingItem.ProductStockID {
+		if err := ensureStockAvailable(s.stockRepo, targetStockID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Validate product stock ID if being updated
 	if productStockID != nil && *productStockID > 0 {
 		_, err := s.stockRepo.GetProductStockModelByID(*productStockID)
 		if err != nil {
-			return nil, errors.New("product stock not found")
+			return nil, apperror.NotFound("product_stock_not_found", "product stock not found")
 		}
 	}
 
@@ -171,7 +289,7 @@ func (s *ProductItemService) UpdateProductItem(id uint, productStockID, productI
 			return nil, err
 		}
 		if !productExists {
-			return nil, errors.New("product not found")
+			return nil, apperror.NotFound("product_not_found", "product not found")
 		}
 	}
 
@@ -183,7 +301,7 @@ func (s *ProductItemService) UpdateProductItem(id uint, productStockID, productI
 	// Validate stock in/out values
 	if stockIn != nil && stockOut != nil {
 		if *stockIn > 0 && *stockOut > 0 {
-			return nil, errors.New("cannot have both stock in and stock out values")
+			return nil, apperror.Validation("stock_in_and_out_together", "cannot have both stock in and stock out values")
 		}
 	}
 
@@ -221,10 +339,28 @@ func (s *ProductItemService) UpdateProductItem(id uint, productStockID, productI
 	// }
 
 	// Return updated item
-	return s.itemRepo.GetProductItemByID(id)
+	updatedItem, err := s.itemRepo.GetProductItemByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordEvent(events.ItemUpdated, id, userID, events.Changes{Before: previousItem, After: updatedItem})
+	if err != nil {
+		return nil, err
+	}
+	return updatedItem, nil
 }
 
 func (s *ProductItemService) DeleteProductItem(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductItemService.DeleteProductItem")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemService) error {
+		return s.deleteProductItem(id, userID)
+	})
+}
+
+func (s *ProductItemService) deleteProductItem(id uint, userID uint) error {
 	// Check if item exists
 	// existingItem, err := s.itemRepo.GetProductItemModelByID(id)
 	// if err != nil {
@@ -243,13 +379,32 @@ func (s *ProductItemService) DeleteProductItem(id uint, userID uint) error {
 	// 	_, _ = s.trackService.CreateProductItemTrack(trackReq, userID)
 	// }
 
-	return s.itemRepo.DeleteProductItemWithAudit(id, userID)
+	err := s.itemRepo.DeleteProductItemWithAudit(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.recordEvent(events.ItemDeleted, id, userID, events.Deleted{ID: id})
+}
+
+// recordEvent writes a product item event to the outbox, in the transaction of the change
+func (s *ProductItemService) recordEvent(eventType string, id uint, userID uint, data interface{}) error {
+	return s.outboxService.Record(events.Event{
+		Type:          eventType,
+		AggregateType: events.AggregateProductItem,
+		AggregateID:   id,
+		UserID:        &userID,
+		Data:          data,
+	})
 }
 
 // Additional business logic methods
 func (s *ProductItemService) GetProductItemsByBatch(batchID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.GetProductItemsByBatch")
+	defer span.End()
+
 	if batchID == 0 {
-		return nil, errors.New("invalid batch ID")
+		return nil, apperror.Validation("invalid_batch_id", "invalid batch ID")
 	}
 
 	// Check if batch exists
@@ -258,23 +413,26 @@ func (s *ProductItemService) GetProductItemsByBatch(batchID uint) (interface{},
 		return nil, err
 	}
 	if !batchExists {
-		return nil, errors.New("product batch not found")
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
 	}
 
 	return []interface{}{}, nil // Placeholder - method not implemented in repository
 }
 
 func (s *ProductItemService) ProcessStockMovement(stockID uint, stockIn, stockOut *float64, locationID *uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemService.ProcessStockMovement")
+	defer span.End()
+
 	if stockID == 0 {
-		return nil, errors.New("invalid stock ID")
+		return nil, apperror.Validation("invalid_stock_id", "invalid stock ID")
 	}
 
 	if (stockIn == nil || *stockIn <= 0) && (stockOut == nil || *stockOut <= 0) {
-		return nil, errors.New("either stock in or stock out must be provided")
+		return nil, apperror.Validation("stock_in_or_out_required", "either stock in or stock out must be provided")
 	}
 
 	if stockIn != nil && stockOut != nil && *stockIn > 0 && *stockOut > 0 {
-		return nil, errors.New("cannot process both stock in and stock out in the same transaction")
+		return nil, apperror.Validation("stock_in_and_out_together", "cannot process both stock in and stock out in the same transaction")
 	}
 
 	// Get stock details
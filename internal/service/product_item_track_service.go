@@ -1,62 +1,178 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ProductItemTrackService struct {
-	trackRepo *repository.ProductItemTrackRepository
-	itemRepo  *repository.ProductItemRepository
-	stockRepo *repository.ProductStockRepository
+	trackRepo     *repository.ProductItemTrackRepository
+	itemRepo      *repository.ProductItemRepository
+	stockRepo     *repository.ProductStockRepository
+	outboxService *OutboxService
+	inTx          bool
+	ctx           context.Context
 }
 
 func NewProductItemTrackService() *ProductItemTrackService {
 	return &ProductItemTrackService{
-		trackRepo: repository.NewProductItemTrackRepository(),
-		itemRepo:  repository.NewProductItemRepository(),
-		stockRepo: repository.NewProductStockRepository(),
+		trackRepo:     repository.NewProductItemTrackRepository(),
+		itemRepo:      repository.NewProductItemRepository(),
+		stockRepo:     repository.NewProductStockRepository(),
+		outboxService: NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductItemTrackService) WithTx(tx *gorm.DB) *ProductItemTrackService {
+	return &ProductItemTrackService{
+		trackRepo:     s.trackRepo.WithTx(tx),
+		itemRepo:      s.itemRepo.WithTx(tx),
+		stockRepo:     s.stockRepo.WithTx(tx),
+		outboxService: s.outboxService.WithTx(tx),
+		inTx:          true,
+		ctx:           s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductItemTrackService) WithContext(ctx context.Context) *ProductItemTrackService {
+	return &ProductItemTrackService{
+		trackRepo:     s.trackRepo.WithContext(ctx),
+		itemRepo:      s.itemRepo.WithContext(ctx),
+		stockRepo:     s.stockRepo.WithContext(ctx),
+		outboxService: s.outboxService.WithContext(ctx),
+		inTx:          s.inTx,
+		ctx:           ctx,
 	}
 }
 
+// recordMovement writes the stock.moved event of a track change to the outbox, in the transaction of the change;
+// delta is the change of the stock quantity the track stands for
+func (s *ProductItemTrackService) recordMovement(track model.ProductItemTrack, delta float64, userID uint) error {
+	stock, err := s.stockRepo.GetProductStockByID(track.ProductStockID)
+	if err != nil {
+		return err
+	}
+
+	stockIn, stockOut := trackMovement(delta)
+	return s.outboxService.Record(events.Event{
+		Type:          events.StockMoved,
+		AggregateType: events.AggregateProductStock,
+		AggregateID:   track.ProductStockID,
+		UserID:        &userID,
+		Data: events.StockMovement{
+			ProductStockID:     track.ProductStockID,
+			ProductItemTrackID: &track.ID,
+			ProductID:          track.ProductID,
+			ProductBatchID:     track.ProductBatchID,
+			StockIn:            stockIn,
+			StockOut:           stockOut,
+			Stock:              stock,
+		},
+	})
+}
+
 // Business logic methods
-func (s *ProductItemTrackService) GetAllProductItemTracks() (interface{}, error) {
-	return s.trackRepo.GetAllProductItemTracks()
+func (s *ProductItemTrackService) GetAllProductItemTracks(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetAllProductItemTracks")
+	defer span.End()
+
+	return s.trackRepo.GetAllProductItemTracks(query)
+}
+
+// GetProductItemTracksByCursor returns one keyset page (newest first) and the cursor of the next page, empty when there is none
+func (s *ProductItemTrackService) GetProductItemTracksByCursor(query utils.ListQuery, cursor utils.CursorQuery) (interface{}, string, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTracksByCursor")
+	defer span.End()
+
+	tracks, err := s.trackRepo.GetProductItemTracksByCursor(query, cursor.After, cursor.Limit+1, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(tracks) > cursor.Limit {
+		tracks = tracks[:cursor.Limit]
+		last := tracks[len(tracks)-1]
+		nextCursor = utils.EncodeCursor(utils.TrackCursor{Date: last.Date, ID: last.ID})
+	}
+	return tracks, nextCursor, nil
+}
+
+// ExportProductItemTracks walks the filtered history in chronological order, batch by batch, and calls write for every track
+func (s *ProductItemTrackService) ExportProductItemTracks(query utils.ListQuery, write func(track interface{}) error) error {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.ExportProductItemTracks")
+	defer span.End()
+
+	var cursor *utils.TrackCursor
+	for {
+		tracks, err := s.trackRepo.GetProductItemTracksByCursor(query, cursor, utils.ExportBatchSize, true)
+		if err != nil {
+			return err
+		}
+
+		for _, track := range tracks {
+			if err := write(track); err != nil {
+				return err
+			}
+		}
+
+		if len(tracks) < utils.ExportBatchSize {
+			return nil
+		}
+		last := tracks[len(tracks)-1]
+		cursor = &utils.TrackCursor{Date: last.Date, ID: last.ID}
+	}
 }
 
 func (s *ProductItemTrackService) GetProductItemTracksByItem(itemID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTracksByItem")
+	defer span.End()
+
 	if itemID == 0 {
-		return nil, errors.New("invalid item ID")
+		return nil, apperror.Validation("invalid_item_id", "invalid item ID")
 	}
 
 	// Check if item exists
 	_, err := s.itemRepo.GetProductItemModelByID(itemID)
 	if err != nil {
-		return nil, errors.New("product item not found")
+		return nil, apperror.NotFound("product_item_not_found", "product item not found")
 	}
 
 	return s.trackRepo.GetProductItemTracksByItem(itemID)
 }
 
 func (s *ProductItemTrackService) GetProductItemTracksByStock(stockID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTracksByStock")
+	defer span.End()
+
 	if stockID == 0 {
-		return nil, errors.New("invalid stock ID")
+		return nil, apperror.Validation("invalid_stock_id", "invalid stock ID")
 	}
 
 	// Check if stock exists
 	_, err := s.stockRepo.GetProductStockModelByID(stockID)
 	if err != nil {
-		return nil, errors.New("product stock not found")
+		return nil, apperror.NotFound("product_stock_not_found", "product stock not found")
 	}
 
 	return s.trackRepo.GetProductItemTracksByStock(stockID)
 }
 
 func (s *ProductItemTrackService) GetProductItemTracksByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTracksByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	// Check if product exists
@@ -65,36 +181,55 @@ func (s *ProductItemTrackService) GetProductItemTracksByProduct(productID uint)
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	return s.trackRepo.GetProductItemTracksByProduct(productID)
 }
 
 func (s *ProductItemTrackService) GetProductItemTracksByDateRange(startDate, endDate time.Time) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTracksByDateRange")
+	defer span.End()
+
 	if startDate.IsZero() || endDate.IsZero() {
-		return nil, errors.New("start date and end date are required")
+		return nil, apperror.Validation("date_range_required", "start date and end date are required")
 	}
 
 	if startDate.After(endDate) {
-		return nil, errors.New("start date cannot be after end date")
+		return nil, apperror.Validation("invalid_date_range", "start date cannot be after end date")
 	}
 
 	return s.trackRepo.GetProductItemTracksByDateRange(startDate, endDate)
 }
 
 func (s *ProductItemTrackService) GetProductItemTrackByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.GetProductItemTrackByID")
+	defer span.End()
+
 	return s.trackRepo.GetProductItemTrackByID(id)
 }
 
 func (s *ProductItemTrackService) CreateProductItemTrack(productItemID uint, productStockID, productID, productBatchID *uint, date time.Time, unitPrice *string, stockIn, stockOut, quantity *float64, operation *string, stock *float64, description *string, action string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.CreateProductItemTrack")
+	defer span.End()
+
+	var createdTrack interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemTrackService) error {
+		var err error
+		createdTrack, err = s.createProductItemTrack(productItemID, productStockID, productID, productBatchID, date, unitPrice, stockIn, stockOut, quantity, operation, stock, description, userID)
+		return err
+	})
+	return createdTrack, err
+}
+
+func (s *ProductItemTrackService) createProductItemTrack(productItemID uint, productStockID, productID, productBatchID *uint, date time.Time, unitPrice *string, stockIn, stockOut, quantity *float64, operation *string, stock *float64, description *string, userID uint) (interface{}, error) {
 	// Validate required fields
 	if productItemID == 0 {
-		return nil, errors.New("product item ID is required")
+		return nil, apperror.Validation("product_item_id_required", "product item ID is required")
 	}
 
 	if date.IsZero() {
-		return nil, errors.New("date is required")
+		return nil, apperror.Validation("date_required", "date is required")
 	}
 
 	// Check if product item exists and get details
@@ -129,6 +264,13 @@ func (s *ProductItemTrackService) CreateProductItemTrack(productItemID uint, pro
 		defaultOperation = "In"
 	}
 
+	// Stock-out movements are not allowed on held stock
+	if defaultOperation == "Out" || defaultOperation == "Minus" {
+		if err := ensureStockAvailable(s.stockRepo, defaultProductStockID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Calculate current stock if not provided
 	currentStock := float64(0)
 	if item.Quantity != nil {
@@ -164,13 +306,30 @@ func (s *ProductItemTrackService) CreateProductItemTrack(productItemID uint, pro
 		return nil, err
 	}
 
+	if err := s.recordMovement(*track, trackQuantity(track.Operation, track.Quantity), userID); err != nil {
+		return nil, err
+	}
+
 	// Return created track
 	return s.trackRepo.GetProductItemTrackByID(track.ID)
 }
 
 func (s *ProductItemTrackService) UpdateProductItemTrack(id uint, date *time.Time, unitPrice *string, quantity *float64, operation *string, stock *float64, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.UpdateProductItemTrack")
+	defer span.End()
+
+	var updatedTrack interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemTrackService) error {
+		var err error
+		updatedTrack, err = s.updateProductItemTrack(id, date, unitPrice, quantity, operation, stock, description, userID)
+		return err
+	})
+	return updatedTrack, err
+}
+
+func (s *ProductItemTrackService) updateProductItemTrack(id uint, date *time.Time, unitPrice *string, quantity *float64, operation *string, stock *float64, description *string, userID uint) (interface{}, error) {
 	// Check if track exists
-	_, err := s.trackRepo.GetProductItemTrackModelByID(id)
+	previousTrack, err := s.trackRepo.GetProductItemTrackModelByID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -190,19 +349,19 @@ func (s *ProductItemTrackService) UpdateProductItemTrack(id uint, date *time.Tim
 	}
 	if quantity != nil {
 		if *quantity <= 0 {
-			return nil, errors.New("quantity must be greater than 0")
+			return nil, apperror.Validation("quantity_must_be_greater_than_0", "quantity must be greater than 0")
 		}
 		updateData["quantity"] = *quantity
 	}
 	if operation != nil {
 		if *operation != "In" && *operation != "Out" && *operation != "Plus" && *operation != "Minus" {
-			return nil, errors.New("operation must be 'In', 'Out', 'Plus', or 'Minus'")
+			return nil, apperror.Validation("invalid_operation", "operation must be 'In', 'Out', 'Plus', or 'Minus'")
 		}
 		updateData["operation"] = *operation
 	}
 	if stock != nil {
 		if *stock < 0 {
-			return nil, errors.New("stock cannot be negative")
+			return nil, apperror.Validation("stock_cannot_be_negative", "stock cannot be negative")
 		}
 		updateData["stock"] = *stock
 	}
@@ -210,21 +369,56 @@ func (s *ProductItemTrackService) UpdateProductItemTrack(id uint, date *time.Tim
 		updateData["description"] = *description
 	}
 
+	// An edit that leaves a stock-out is checked like creating one, held stock does not allow it
+	resultingOperation := previousTrack.Operation
+	if operation != nil {
+		resultingOperation = *operation
+	}
+	if resultingOperation == "Out" || resultingOperation == "Minus" {
+		if err := ensureStockAvailable(s.stockRepo, previousTrack.ProductStockID); err != nil {
+			return nil, err
+		}
+	}
+
 	err = s.trackRepo.UpdateProductItemTrack(id, updateData)
 	if err != nil {
 		return nil, err
 	}
 
+	// The event carries the difference between the movement before and after the edit
+	track, err := s.trackRepo.GetProductItemTrackModelByID(id)
+	if err != nil {
+		return nil, err
+	}
+	delta := trackQuantity(track.Operation, track.Quantity) - trackQuantity(previousTrack.Operation, previousTrack.Quantity)
+	if err := s.recordMovement(track, delta, userID); err != nil {
+		return nil, err
+	}
+
 	// Return updated track
 	return s.trackRepo.GetProductItemTrackByID(id)
 }
 
 func (s *ProductItemTrackService) DeleteProductItemTrack(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductItemTrackService.DeleteProductItemTrack")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductItemTrackService) error {
+		return s.deleteProductItemTrack(id, userID)
+	})
+}
+
+func (s *ProductItemTrackService) deleteProductItemTrack(id uint, userID uint) error {
 	// Check if track exists
-	_, err := s.trackRepo.GetProductItemTrackModelByID(id)
+	track, err := s.trackRepo.GetProductItemTrackModelByID(id)
 	if err != nil {
 		return err
 	}
 
-	return s.trackRepo.DeleteProductItemTrackWithAudit(id, userID)
+	if err := s.trackRepo.DeleteProductItemTrackWithAudit(id, userID); err != nil {
+		return err
+	}
+
+	// Deleting a track takes its movement back
+	return s.recordMovement(track, -trackQuantity(track.Operation, track.Quantity), userID)
 }
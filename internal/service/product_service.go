@@ -1,29 +1,75 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+
+	"gorm.io/gorm"
 )
 
 type ProductService struct {
-	productRepo *repository.ProductRepository
+	productRepo   *repository.ProductRepository
+	outboxService *OutboxService
+	inTx          bool
+	ctx           context.Context
 }
 
 func NewProductService() *ProductService {
 	return &ProductService{
-		productRepo: repository.NewProductRepository(),
+		productRepo:   repository.NewProductRepository(),
+		outboxService: NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductService) WithTx(tx *gorm.DB) *ProductService {
+	return &ProductService{
+		productRepo:   s.productRepo.WithTx(tx),
+		outboxService: s.outboxService.WithTx(tx),
+		inTx:          true,
+		ctx:           s.ctx,
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductService) WithContext(ctx context.Context) *ProductService {
+	return &ProductService{
+		productRepo:   s.productRepo.WithContext(ctx),
+		outboxService: s.outboxService.WithContext(ctx),
+		inTx:          s.inTx,
+		ctx:           ctx,
+	}
+}
+
+// recordEvent writes a product event to the outbox, in the transaction of the change
+func (s *ProductService) recordEvent(eventType string, id uint, userID uint, data interface{}) error {
+	return s.outboxService.Record(events.Event{
+		Type:          eventType,
+		AggregateType: events.AggregateProduct,
+		AggregateID:   id,
+		UserID:        &userID,
+		Data:          data,
+	})
+}
+
 // Business logic methods
-func (s *ProductService) GetAllProducts() (interface{}, error) {
-	return s.productRepo.GetAllProducts()
+func (s *ProductService) GetAllProducts(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.GetAllProducts")
+	defer span.End()
+
+	return s.productRepo.GetAllProducts(query)
 }
 
 func (s *ProductService) GetProductsByCategory(categoryID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.GetProductsByCategory")
+	defer span.End()
+
 	if categoryID == 0 {
-		return nil, errors.New("invalid category ID")
+		return nil, apperror.Validation("invalid_category_id", "invalid category ID")
 	}
 
 	// Check if category exists
@@ -32,13 +78,16 @@ func (s *ProductService) GetProductsByCategory(categoryID uint) (interface{}, er
 		return nil, err
 	}
 	if !categoryExists {
-		return nil, errors.New("category not found")
+		return nil, apperror.NotFound("category_not_found", "category not found")
 	}
 
 	return s.productRepo.GetProductsByCategory(categoryID)
 }
 
 func (s *ProductService) GetProductByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.GetProductByID")
+	defer span.End()
+
 	product, err := s.productRepo.GetProductByID(id)
 	if err != nil {
 		return nil, err
@@ -47,16 +96,29 @@ func (s *ProductService) GetProductByID(id uint) (interface{}, error) {
 }
 
 func (s *ProductService) CreateProduct(categoryID uint, name string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.CreateProduct")
+	defer span.End()
+
+	var createdProduct interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductService) error {
+		var err error
+		createdProduct, err = s.createProduct(categoryID, name, description, userID)
+		return err
+	})
+	return createdProduct, err
+}
+
+func (s *ProductService) createProduct(categoryID uint, name string, description *string, userID uint) (interface{}, error) {
 	if categoryID == 0 {
-		return nil, errors.New("category ID is required")
+		return nil, apperror.Validation("category_id_required", "category ID is required")
 	}
 
 	if name == "" {
-		return nil, errors.New("product name is required")
+		return nil, apperror.Validation("product_name_required", "product name is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if category exists
@@ -65,7 +127,7 @@ func (s *ProductService) CreateProduct(categoryID uint, name string, description
 		return nil, err
 	}
 	if !categoryExists {
-		return nil, errors.New("category not found")
+		return nil, apperror.NotFound("category_not_found", "category not found")
 	}
 
 	// Check if product exists for this category
@@ -74,7 +136,7 @@ func (s *ProductService) CreateProduct(categoryID uint, name string, description
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("product already exists for this category")
+		return nil, apperror.Conflict("product_already_exists_for_this_category", "product already exists for this category")
 	}
 
 	product := &model.Product{
@@ -95,22 +157,44 @@ func (s *ProductService) CreateProduct(categoryID uint, name string, description
 		return nil, err
 	}
 
+	err = s.recordEvent(events.ProductCreated, product.ID, userID, createdProduct)
+	if err != nil {
+		return nil, err
+	}
+
 	return createdProduct, nil
 }
 
 func (s *ProductService) UpdateProduct(id uint, categoryID uint, name string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.UpdateProduct")
+	defer span.End()
+
+	var updatedProduct interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductService) error {
+		var err error
+		updatedProduct, err = s.updateProduct(id, categoryID, name, description, userID)
+		return err
+	})
+	return updatedProduct, err
+}
+
+func (s *ProductService) updateProduct(id uint, categoryID uint, name string, description *string, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product exists
 	product, err := s.productRepo.GetProductModelByID(id)
 	if err != nil {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
+	}
+	previousProduct, err := s.productRepo.GetProductByID(id)
+	if err != nil {
+		return nil, err
 	}
 
 	// If category ID is being changed, check if new category exists
@@ -120,7 +204,7 @@ func (s *ProductService) UpdateProduct(id uint, categoryID uint, name string, de
 			return nil, err
 		}
 		if !categoryExists {
-			return nil, errors.New("category not found")
+			return nil, apperror.NotFound("category_not_found", "category not found")
 		}
 	}
 
@@ -136,7 +220,7 @@ func (s *ProductService) UpdateProduct(id uint, categoryID uint, name string, de
 			return nil, err
 		}
 		if exists {
-			return nil, errors.New("product already exists for this category")
+			return nil, apperror.Conflict("product_already_exists_for_this_category", "product already exists for this category")
 		}
 	}
 
@@ -163,39 +247,74 @@ func (s *ProductService) UpdateProduct(id uint, categoryID uint, name string, de
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.ProductUpdated, id, userID, events.Changes{Before: previousProduct, After: updatedProduct})
+	if err != nil {
+		return nil, err
+	}
 	return updatedProduct, nil
 }
 
 func (s *ProductService) DeleteProduct(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductService.DeleteProduct")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductService) error {
+		return s.deleteProduct(id, userID)
+	})
+}
+
+func (s *ProductService) deleteProduct(id uint, userID uint) error {
 	if id == 0 {
-		return errors.New("invalid product ID")
+		return apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product exists
 	_, err := s.productRepo.GetProductModelByID(id)
 	if err != nil {
-		return errors.New("product not found")
+		return apperror.NotFound("product_not_found", "product not found")
 	}
 
-	return s.productRepo.DeleteProductWithAudit(id, userID)
+	err = s.productRepo.DeleteProductWithAudit(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.recordEvent(events.ProductDeleted, id, userID, events.Deleted{ID: id})
 }
 
 // GetDeletedProducts returns all soft deleted products
 func (s *ProductService) GetDeletedProducts() (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.GetDeletedProducts")
+	defer span.End()
+
 	return s.productRepo.GetDeletedProducts()
 }
 
 // RestoreProduct restores a soft deleted product
 func (s *ProductService) RestoreProduct(id uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductService.RestoreProduct")
+	defer span.End()
+
+	var restoredProduct interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductService) error {
+		var err error
+		restoredProduct, err = s.restoreProduct(id, userID)
+		return err
+	})
+	return restoredProduct, err
+}
+
+func (s *ProductService) restoreProduct(id uint, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.productRepo.RestoreProduct(id, userID)
@@ -207,5 +326,10 @@ func (s *ProductService) RestoreProduct(id uint, userID uint) (interface{}, erro
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.ProductRestored, id, userID, restoredProduct)
+	if err != nil {
+		return nil, err
+	}
 	return restoredProduct, nil
 }
@@ -1,15 +1,23 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ProductStockTrackService struct {
-	trackRepo *repository.ProductStockTrackRepository
-	stockRepo *repository.ProductStockRepository
+	trackRepo     *repository.ProductStockTrackRepository
+	stockRepo     *repository.ProductStockRepository
+	outboxService *OutboxService
+	inTx          bool
+	ctx           context.Context
 }
 
 type CreateProductStockTrackRequest struct {
@@ -30,33 +38,156 @@ type UpdateProductStockTrackRequest struct {
 
 func NewProductStockTrackService() *ProductStockTrackService {
 	return &ProductStockTrackService{
-		trackRepo: repository.NewProductStockTrackRepository(),
-		stockRepo: repository.NewProductStockRepository(),
+		trackRepo:     repository.NewProductStockTrackRepository(),
+		stockRepo:     repository.NewProductStockRepository(),
+		outboxService: NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductStockTrackService) WithTx(tx *gorm.DB) *ProductStockTrackService {
+	return &ProductStockTrackService{
+		trackRepo:     s.trackRepo.WithTx(tx),
+		stockRepo:     s.stockRepo.WithTx(tx),
+		outboxService: s.outboxService.WithTx(tx),
+		inTx:          true,
+		ctx:           s.ctx,
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductStockTrackService) WithContext(ctx context.Context) *ProductStockTrackService {
+	return &ProductStockTrackService{
+		trackRepo:     s.trackRepo.WithContext(ctx),
+		stockRepo:     s.stockRepo.WithContext(ctx),
+		outboxService: s.outboxService.WithContext(ctx),
+		inTx:          s.inTx,
+		ctx:           ctx,
+	}
+}
+
+// recordMovement writes the stock.moved event of a track change to the outbox, in the transaction of the change;
+// delta is the change of the stock quantity the track stands for
+func (s *ProductStockTrackService) recordMovement(track model.ProductStockTrack, delta float64, userID uint) error {
+	stock, err := s.stockRepo.GetProductStockByID(track.ProductStockID)
+	if err != nil {
+		return err
+	}
+
+	stockIn, stockOut := trackMovement(delta)
+	return s.outboxService.Record(events.Event{
+		Type:          events.StockMoved,
+		AggregateType: events.AggregateProductStock,
+		AggregateID:   track.ProductStockID,
+		UserID:        &userID,
+		Data: events.StockMovement{
+			ProductStockID:      track.ProductStockID,
+			ProductStockTrackID: &track.ID,
+			ProductID:           track.ProductID,
+			ProductBatchID:      track.ProductBatchID,
+			StockIn:             stockIn,
+			StockOut:            stockOut,
+			Stock:               stock,
+		},
+	})
+}
+
+// trackQuantity is the signed change of stock a track stands for: In and Plus add, Out and Minus take out
+func trackQuantity(operation string, quantity float64) float64 {
+	if operation == "Out" || operation == "Minus" {
+		return -quantity
+	}
+	return quantity
+}
+
+// trackMovement splits the change of a stock quantity into the stock in and stock out of a stock.moved event
+func trackMovement(delta float64) (stockIn, stockOut *float64) {
+	if delta > 0 {
+		return &delta, nil
+	}
+	if delta < 0 {
+		out := -delta
+		return nil, &out
+	}
+	return nil, nil
+}
+
 // Business logic methods
-func (s *ProductStockTrackService) GetAllProductStockTracks() (interface{}, error) {
-	return s.trackRepo.GetAllProductStockTracks()
+func (s *ProductStockTrackService) GetAllProductStockTracks(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.GetAllProductStockTracks")
+	defer span.End()
+
+	return s.trackRepo.GetAllProductStockTracks(query)
+}
+
+// GetProductStockTracksByCursor returns one keyset page (newest first) and the cursor of the next page, empty when there is none
+func (s *ProductStockTrackService) GetProductStockTracksByCursor(query utils.ListQuery, cursor utils.CursorQuery) (interface{}, string, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.GetProductStockTracksByCursor")
+	defer span.End()
+
+	tracks, err := s.trackRepo.GetProductStockTracksByCursor(query, cursor.After, cursor.Limit+1, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(tracks) > cursor.Limit {
+		tracks = tracks[:cursor.Limit]
+		last := tracks[len(tracks)-1]
+		nextCursor = utils.EncodeCursor(utils.TrackCursor{Date: last.DateTrack, ID: last.ID})
+	}
+	return tracks, nextCursor, nil
+}
+
+// ExportProductStockTracks walks the filtered history in chronological order, batch by batch, and calls write for every track
+func (s *ProductStockTrackService) ExportProductStockTracks(query utils.ListQuery, write func(track interface{}) error) error {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.ExportProductStockTracks")
+	defer span.End()
+
+	var cursor *utils.TrackCursor
+	for {
+		tracks, err := s.trackRepo.GetProductStockTracksByCursor(query, cursor, utils.ExportBatchSize, true)
+		if err != nil {
+			return err
+		}
+
+		for _, track := range tracks {
+			if err := write(track); err != nil {
+				return err
+			}
+		}
+
+		if len(tracks) < utils.ExportBatchSize {
+			return nil
+		}
+		last := tracks[len(tracks)-1]
+		cursor = &utils.TrackCursor{Date: last.DateTrack, ID: last.ID}
+	}
 }
 
 func (s *ProductStockTrackService) GetProductStockTracksByStock(stockID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.GetProductStockTracksByStock")
+	defer span.End()
+
 	if stockID == 0 {
-		return nil, errors.New("invalid stock ID")
+		return nil, apperror.Validation("invalid_stock_id", "invalid stock ID")
 	}
 
 	// Check if stock exists
 	_, err := s.stockRepo.GetProductStockModelByID(stockID)
 	if err != nil {
-		return nil, errors.New("product stock not found")
+		return nil, apperror.NotFound("product_stock_not_found", "product stock not found")
 	}
 
 	return s.trackRepo.GetProductStockTracksByStock(stockID)
 }
 
 func (s *ProductStockTrackService) GetProductStockTracksByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.GetProductStockTracksByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	// Check if product exists
@@ -65,20 +196,36 @@ func (s *ProductStockTrackService) GetProductStockTracksByProduct(productID uint
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	return s.trackRepo.GetProductStockTracksByProduct(productID)
 }
 
 func (s *ProductStockTrackService) GetProductStockTrackByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.GetProductStockTrackByID")
+	defer span.End()
+
 	return s.trackRepo.GetProductStockTrackByID(id)
 }
 
 func (s *ProductStockTrackService) CreateProductStockTrack(req CreateProductStockTrackRequest, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.CreateProductStockTrack")
+	defer span.End()
+
+	var createdTrack interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductStockTrackService) error {
+		var err error
+		createdTrack, err = s.createProductStockTrack(req, userID)
+		return err
+	})
+	return createdTrack, err
+}
+
+func (s *ProductStockTrackService) createProductStockTrack(req CreateProductStockTrackRequest, userID uint) (interface{}, error) {
 	// Validate required fields
 	if req.ProductStockID == 0 {
-		return nil, errors.New("product stock ID is required")
+		return nil, apperror.Validation("product_stock_id_required", "product stock ID is required")
 	}
 
 	// Check if product stock exists and get details
@@ -104,6 +251,13 @@ func (s *ProductStockTrackService) CreateProductStockTrack(req CreateProductStoc
 		operation = *req.Operation
 	}
 
+	// Stock-out movements are not allowed on held stock
+	if operation == "Minus" {
+		if err := ensureStockAvailable(s.stockRepo, req.ProductStockID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Calculate current stock if not provided
 	currentStock := float64(0)
 	if stock.Quantity != nil {
@@ -137,13 +291,30 @@ func (s *ProductStockTrackService) CreateProductStockTrack(req CreateProductStoc
 		return nil, err
 	}
 
+	if err := s.recordMovement(*track, trackQuantity(track.Operation, track.Quantity), userID); err != nil {
+		return nil, err
+	}
+
 	// Return created track
 	return s.trackRepo.GetProductStockTrackByID(track.ID)
 }
 
 func (s *ProductStockTrackService) UpdateProductStockTrack(id uint, req UpdateProductStockTrackRequest, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.UpdateProductStockTrack")
+	defer span.End()
+
+	var updatedTrack interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductStockTrackService) error {
+		var err error
+		updatedTrack, err = s.updateProductStockTrack(id, req, userID)
+		return err
+	})
+	return updatedTrack, err
+}
+
+func (s *ProductStockTrackService) updateProductStockTrack(id uint, req UpdateProductStockTrackRequest, userID uint) (interface{}, error) {
 	// Check if track exists
-	_, err := s.trackRepo.GetProductStockTrackModelByID(id)
+	previousTrack, err := s.trackRepo.GetProductStockTrackModelByID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -157,38 +328,73 @@ func (s *ProductStockTrackService) UpdateProductStockTrack(id uint, req UpdatePr
 	// Update provided fields
 	if req.Quantity != nil {
 		if *req.Quantity <= 0 {
-			return nil, errors.New("quantity must be greater than 0")
+			return nil, apperror.Validation("quantity_must_be_greater_than_0", "quantity must be greater than 0")
 		}
 		updateData["quantity"] = *req.Quantity
 	}
 	if req.Operation != nil {
 		if *req.Operation != "Plus" && *req.Operation != "Minus" {
-			return nil, errors.New("operation must be 'Plus' or 'Minus'")
+			return nil, apperror.Validation("invalid_operation", "operation must be 'Plus' or 'Minus'")
 		}
 		updateData["operation"] = *req.Operation
 	}
 	if req.Stock != nil {
 		if *req.Stock < 0 {
-			return nil, errors.New("stock cannot be negative")
+			return nil, apperror.Validation("stock_cannot_be_negative", "stock cannot be negative")
 		}
 		updateData["stock"] = *req.Stock
 	}
 
+	// An edit that leaves a stock-out is checked like creating one, held stock does not allow it
+	operation := previousTrack.Operation
+	if req.Operation != nil {
+		operation = *req.Operation
+	}
+	if operation == "Minus" {
+		if err := ensureStockAvailable(s.stockRepo, previousTrack.ProductStockID); err != nil {
+			return nil, err
+		}
+	}
+
 	err = s.trackRepo.UpdateProductStockTrack(id, updateData)
 	if err != nil {
 		return nil, err
 	}
 
+	// The event carries the difference between the movement before and after the edit
+	track, err := s.trackRepo.GetProductStockTrackModelByID(id)
+	if err != nil {
+		return nil, err
+	}
+	delta := trackQuantity(track.Operation, track.Quantity) - trackQuantity(previousTrack.Operation, previousTrack.Quantity)
+	if err := s.recordMovement(track, delta, userID); err != nil {
+		return nil, err
+	}
+
 	// Return updated track
 	return s.trackRepo.GetProductStockTrackByID(id)
 }
 
 func (s *ProductStockTrackService) DeleteProductStockTrack(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductStockTrackService.DeleteProductStockTrack")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductStockTrackService) error {
+		return s.deleteProductStockTrack(id, userID)
+	})
+}
+
+func (s *ProductStockTrackService) deleteProductStockTrack(id uint, userID uint) error {
 	// Check if track exists
-	_, err := s.trackRepo.GetProductStockTrackModelByID(id)
+	track, err := s.trackRepo.GetProductStockTrackModelByID(id)
 	if err != nil {
 		return err
 	}
 
-	return s.trackRepo.DeleteProductStockTrackWithAudit(id, userID)
+	if err := s.trackRepo.DeleteProductStockTrackWithAudit(id, userID); err != nil {
+		return err
+	}
+
+	// Deleting a track takes its movement back
+	return s.recordMovement(track, -trackQuantity(track.Operation, track.Quantity), userID)
 }
\ No newline at end of file
@@ -1,32 +1,80 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 type ProductUnitService struct {
 	productUnitRepo  *repository.ProductUnitRepository
 	trackUnitService *ProductUnitTrackService
+	outboxService    *OutboxService
+	inTx             bool
+	ctx              context.Context
 }
 
 func NewProductUnitService() *ProductUnitService {
 	return &ProductUnitService{
 		productUnitRepo:  repository.NewProductUnitRepository(),
 		trackUnitService: NewProductUnitTrackService(),
+		outboxService:    NewOutboxService(),
+	}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductUnitService) WithTx(tx *gorm.DB) *ProductUnitService {
+	return &ProductUnitService{
+		productUnitRepo:  s.productUnitRepo.WithTx(tx),
+		trackUnitService: s.trackUnitService.WithTx(tx),
+		outboxService:    s.outboxService.WithTx(tx),
+		inTx:             true,
+		ctx:              s.ctx,
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductUnitService) WithContext(ctx context.Context) *ProductUnitService {
+	return &ProductUnitService{
+		productUnitRepo:  s.productUnitRepo.WithContext(ctx),
+		trackUnitService: s.trackUnitService.WithContext(ctx),
+		outboxService:    s.outboxService.WithContext(ctx),
+		inTx:             s.inTx,
+		ctx:              ctx,
+	}
+}
+
+// recordEvent writes a product unit event to the outbox, in the transaction of the change
+func (s *ProductUnitService) recordEvent(eventType string, id uint, userID uint, data interface{}) error {
+	return s.outboxService.Record(events.Event{
+		Type:          eventType,
+		AggregateType: events.AggregateProductUnit,
+		AggregateID:   id,
+		UserID:        &userID,
+		Data:          data,
+	})
+}
+
 // Business logic methods
-func (s *ProductUnitService) GetAllProductUnits() (interface{}, error) {
-	return s.productUnitRepo.GetAllProductUnits()
+func (s *ProductUnitService) GetAllProductUnits(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.GetAllProductUnits")
+	defer span.End()
+
+	return s.productUnitRepo.GetAllProductUnits(query)
 }
 
 func (s *ProductUnitService) GetProductUnitsByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.GetProductUnitsByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	// Check if product exists
@@ -35,13 +83,16 @@ func (s *ProductUnitService) GetProductUnitsByProduct(productID uint) (interface
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	return s.productUnitRepo.GetProductUnitsByProduct(productID)
 }
 
 func (s *ProductUnitService) GetProductUnitByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.GetProductUnitByID")
+	defer span.End()
+
 	unit, err := s.productUnitRepo.GetProductUnitByID(id)
 	if err != nil {
 		return nil, err
@@ -50,20 +101,33 @@ func (s *ProductUnitService) GetProductUnitByID(id uint) (interface{}, error) {
 }
 
 func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint, productBatchID uint, name *string, quantity *float64, unitPrice *float64, unitPriceRetail *float64, barcode *string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.CreateProductUnit")
+	defer span.End()
+
+	var createdUnit interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductUnitService) error {
+		var err error
+		createdUnit, err = s.createProductUnit(productID, locationID, productBatchID, name, quantity, unitPrice, unitPriceRetail, barcode, description, userID)
+		return err
+	})
+	return createdUnit, err
+}
+
+func (s *ProductUnitService) createProductUnit(productID uint, locationID uint, productBatchID uint, name *string, quantity *float64, unitPrice *float64, unitPriceRetail *float64, barcode *string, description *string, userID uint) (interface{}, error) {
 	if productID == 0 {
-		return nil, errors.New("product ID is required")
+		return nil, apperror.Validation("product_id_required", "product ID is required")
 	}
 
 	if locationID == 0 {
-		return nil, errors.New("location ID is required")
+		return nil, apperror.Validation("location_id_required", "location ID is required")
 	}
 
 	if productBatchID == 0 {
-		return nil, errors.New("product batch ID is required")
+		return nil, apperror.Validation("product_batch_id_required", "product batch ID is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product exists
@@ -72,7 +136,7 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 		return nil, err
 	}
 	if !productExists {
-		return nil, errors.New("product not found")
+		return nil, apperror.NotFound("product_not_found", "product not found")
 	}
 
 	// Check if product batch exists
@@ -81,7 +145,7 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 		return nil, err
 	}
 	if !batchExists {
-		return nil, errors.New("product batch not found")
+		return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
 	}
 
 	// Check if product unit with same name exists for this product and location (if name is provided)
@@ -91,7 +155,7 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 			return nil, err
 		}
 		if exists {
-			return nil, errors.New("product unit with this name already exists for this product and location")
+			return nil, apperror.Conflict("product_unit_with_this_name_already_exists_for_this_product_and_location", "product unit with this name already exists for this product and location")
 		}
 	}
 
@@ -111,7 +175,7 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 		if err == nil {
 			// Barcode sudah ada, cek apakah product_id sama
 			if unit.ProductId != productID {
-				return nil, errors.New("barcode already exists for another product")
+				return nil, apperror.Conflict("barcode_already_exists_for_another_product", "barcode already exists for another product")
 			}
 
 			// Product_id sama, cek apakah kombinasi name dan location_id sama
@@ -126,7 +190,7 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 			}
 
 			if unit.LocationId == locationID && unitName == currentName {
-				return nil, errors.New("product unit with same name and location already exists")
+				return nil, apperror.Conflict("product_unit_with_same_name_and_location_already_exists", "product unit with same name and location already exists")
 			}
 		}
 		// Jika err != nil berarti barcode tidak ditemukan, jadi aman untuk create
@@ -163,30 +227,52 @@ func (s *ProductUnitService) CreateProductUnit(productID uint, locationID uint,
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.UnitCreated, productUnit.ID, userID, res)
+	if err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
 func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, locationID uint, productBatchID uint, name *string, quantity *float64, UnitPrice *float64, unitPriceRetail *float64, barcode *string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.UpdateProductUnit")
+	defer span.End()
+
+	var updatedUnit interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductUnitService) error {
+		var err error
+		updatedUnit, err = s.updateProductUnit(id, productID, locationID, productBatchID, name, quantity, UnitPrice, unitPriceRetail, barcode, description, userID)
+		return err
+	})
+	return updatedUnit, err
+}
+
+func (s *ProductUnitService) updateProductUnit(id uint, productID uint, locationID uint, productBatchID uint, name *string, quantity *float64, UnitPrice *float64, unitPriceRetail *float64, barcode *string, description *string, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product unit ID")
+		return nil, apperror.Validation("invalid_product_unit_id", "invalid product unit ID")
 	}
 
 	if locationID == 0 {
-		return nil, errors.New("invalid location ID")
+		return nil, apperror.Validation("invalid_location_id", "invalid location ID")
 	}
 
 	if productBatchID == 0 {
-		return nil, errors.New("product batch ID is required")
+		return nil, apperror.Validation("product_batch_id_required", "product batch ID is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product unit exists
 	oldBatch, err := s.productUnitRepo.GetProductUnitByIDModel(id)
 	if err != nil {
-		return nil, errors.New("product unit not found")
+		return nil, apperror.NotFound("product_unit_not_found", "product unit not found")
+	}
+	previousProductUnit, err := s.productUnitRepo.GetProductUnitByID(id)
+	if err != nil {
+		return nil, err
 	}
 
 	// If product ID is being changed, check if new product exists
@@ -196,7 +282,7 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 			return nil, err
 		}
 		if !productExists {
-			return nil, errors.New("product not found")
+			return nil, apperror.NotFound("product_not_found", "product not found")
 		}
 	}
 
@@ -207,7 +293,7 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 			return nil, err
 		}
 		if !batchExists {
-			return nil, errors.New("product batch not found")
+			return nil, apperror.NotFound("product_batch_not_found", "product batch not found")
 		}
 	}
 
@@ -236,7 +322,7 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 				return nil, err
 			}
 			if exists {
-				return nil, errors.New("product unit name already in use for this product and location")
+				return nil, apperror.Conflict("product_unit_name_already_in_use_for_this_product_and_location", "product unit name already in use for this product and location")
 			}
 		}
 	}
@@ -268,7 +354,7 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 			if err == nil {
 				// Barcode sudah ada, cek apakah product_id sama
 				if unit.ProductId != productID {
-					return nil, errors.New("barcode already exists for another product")
+					return nil, apperror.Conflict("barcode_already_exists_for_another_product", "barcode already exists for another product")
 				}
 
 				// Product_id sama, cek apakah kombinasi name dan location_id sama
@@ -283,7 +369,7 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 				}
 
 				if unit.LocationId == locationID && unitName == currentName {
-					return nil, errors.New("product unit with same name and location already exists")
+					return nil, apperror.Conflict("product_unit_with_same_name_and_location_already_exists", "product unit with same name and location already exists")
 				}
 			}
 		}
@@ -292,11 +378,11 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 
 	// Basic validation
 	if quantity != nil && *quantity < 0 {
-		return nil, errors.New("quantity cannot be negative")
+		return nil, apperror.Validation("quantity_cannot_be_negative", "quantity cannot be negative")
 	}
 
 	if UnitPrice != nil && *UnitPrice < 0 {
-		return nil, errors.New("UnitPrice cannot be negative")
+		return nil, apperror.Validation("unit_price_negative", "UnitPrice cannot be negative")
 	}
 
 	// Update the model fields
@@ -346,22 +432,36 @@ func (s *ProductUnitService) UpdateProductUnit(id uint, productID uint, location
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.UnitUpdated, id, userID, events.Changes{Before: previousProductUnit, After: updatedProductUnit})
+	if err != nil {
+		return nil, err
+	}
 	return updatedProductUnit, nil
 }
 
 func (s *ProductUnitService) DeleteProductUnit(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.DeleteProductUnit")
+	defer span.End()
+
+	return inTransaction(s, s.inTx, s.WithTx, func(s *ProductUnitService) error {
+		return s.deleteProductUnit(id, userID)
+	})
+}
+
+func (s *ProductUnitService) deleteProductUnit(id uint, userID uint) error {
 	if id == 0 {
-		return errors.New("invalid product unit ID")
+		return apperror.Validation("invalid_product_unit_id", "invalid product unit ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product unit exists
 	unitDelete, err := s.productUnitRepo.GetProductUnitByIDModel(id)
 	if err != nil {
-		return errors.New("product unit not found")
+		return apperror.NotFound("product_unit_not_found", "product unit not found")
 	}
 
 	// Create tracking record for deletion (before actual deletion)
@@ -370,21 +470,42 @@ func (s *ProductUnitService) DeleteProductUnit(id uint, userID uint) error {
 		// return nil, err
 	}
 
-	return s.productUnitRepo.DeleteProductUnitWithAudit(id, userID)
+	err = s.productUnitRepo.DeleteProductUnitWithAudit(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.recordEvent(events.UnitDeleted, id, userID, events.Deleted{ID: id})
 }
 
 // GetDeletedProductUnits returns all soft deleted product units
 func (s *ProductUnitService) GetDeletedProductUnits() (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.GetDeletedProductUnits")
+	defer span.End()
+
 	return s.productUnitRepo.GetDeletedProductUnits()
 }
 
 // RestoreProductUnit restores a soft deleted product unit
 func (s *ProductUnitService) RestoreProductUnit(id uint, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitService.RestoreProductUnit")
+	defer span.End()
+
+	var restoredUnit interface{}
+	err := inTransaction(s, s.inTx, s.WithTx, func(s *ProductUnitService) error {
+		var err error
+		restoredUnit, err = s.restoreProductUnit(id, userID)
+		return err
+	})
+	return restoredUnit, err
+}
+
+func (s *ProductUnitService) restoreProductUnit(id uint, userID uint) (interface{}, error) {
 	if id == 0 {
-		return nil, errors.New("invalid product unit ID")
+		return nil, apperror.Validation("invalid_product_unit_id", "invalid product unit ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.productUnitRepo.RestoreProductUnit(id, userID)
@@ -396,5 +517,10 @@ func (s *ProductUnitService) RestoreProductUnit(id uint, userID uint) (interface
 	if err != nil {
 		return nil, err
 	}
+
+	err = s.recordEvent(events.UnitRestored, id, userID, restoredUnit)
+	if err != nil {
+		return nil, err
+	}
 	return restoredUnit, nil
 }
@@ -1,15 +1,19 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
 	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+
+	"gorm.io/gorm"
 )
 
 type ProductUnitTrackService struct {
 	productUnitTrackRepo *repository.ProductUnitTrackRepository
 	trackingUnitUtils    *utils.ProductUnitTrackingUtils
+	ctx                  context.Context
 }
 
 func NewProductUnitTrackService() *ProductUnitTrackService {
@@ -19,14 +23,38 @@ func NewProductUnitTrackService() *ProductUnitTrackService {
 	}
 }
 
+// WithTx returns a copy of the service whose repositories run inside tx
+func (s *ProductUnitTrackService) WithTx(tx *gorm.DB) *ProductUnitTrackService {
+	return &ProductUnitTrackService{
+		productUnitTrackRepo: s.productUnitTrackRepo.WithTx(tx),
+		trackingUnitUtils:    s.trackingUnitUtils,
+		ctx:                  s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ProductUnitTrackService) WithContext(ctx context.Context) *ProductUnitTrackService {
+	return &ProductUnitTrackService{
+		productUnitTrackRepo: s.productUnitTrackRepo.WithContext(ctx),
+		trackingUnitUtils:    s.trackingUnitUtils,
+		ctx:                  ctx,
+	}
+}
+
 // Business logic methods
-func (s *ProductUnitTrackService) GetAllProductUnitTracks() (interface{}, error) {
-	return s.productUnitTrackRepo.GetAllProductUnitTracks()
+func (s *ProductUnitTrackService) GetAllProductUnitTracks(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.GetAllProductUnitTracks")
+	defer span.End()
+
+	return s.productUnitTrackRepo.GetAllProductUnitTracks(query)
 }
 
 func (s *ProductUnitTrackService) GetProductUnitTracksByProductUnit(productUnitID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.GetProductUnitTracksByProductUnit")
+	defer span.End()
+
 	if productUnitID == 0 {
-		return nil, errors.New("invalid product unit ID")
+		return nil, apperror.Validation("invalid_product_unit_id", "invalid product unit ID")
 	}
 
 	// Check if product unit exists
@@ -35,21 +63,27 @@ func (s *ProductUnitTrackService) GetProductUnitTracksByProductUnit(productUnitI
 		return nil, err
 	}
 	if !productUnitExists {
-		return nil, errors.New("product unit not found")
+		return nil, apperror.NotFound("product_unit_not_found", "product unit not found")
 	}
 
 	return s.productUnitTrackRepo.GetProductUnitTracksByProductUnit(productUnitID)
 }
 
 func (s *ProductUnitTrackService) GetProductUnitTracksByProduct(productID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.GetProductUnitTracksByProduct")
+	defer span.End()
+
 	if productID == 0 {
-		return nil, errors.New("invalid product ID")
+		return nil, apperror.Validation("invalid_product_id", "invalid product ID")
 	}
 
 	return s.productUnitTrackRepo.GetProductUnitTracksByProduct(productID)
 }
 
 func (s *ProductUnitTrackService) GetProductUnitTrackByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.GetProductUnitTrackByID")
+	defer span.End()
+
 	track, err := s.productUnitTrackRepo.GetProductUnitTrackByID(id)
 	if err != nil {
 		return nil, err
@@ -61,17 +95,19 @@ func (s *ProductUnitTrackService) CreateProductUnitTrack(
 	productUnitID uint,
 	description string,
 	userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.CreateProductUnitTrack")
+	defer span.End()
 
 	if productUnitID == 0 {
-		return nil, errors.New("product unit ID is required")
+		return nil, apperror.Validation("product_unit_id_required", "product unit ID is required")
 	}
 
 	if description == "" {
-		return nil, errors.New("transaction type is required")
+		return nil, apperror.Validation("transaction_type_required", "transaction type is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product unit exists
@@ -80,7 +116,7 @@ func (s *ProductUnitTrackService) CreateProductUnitTrack(
 		return nil, err
 	}
 	if !productUnitExists {
-		return nil, errors.New("product unit not found")
+		return nil, apperror.NotFound("product_unit_not_found", "product unit not found")
 	}
 
 	productUnitTrack := &model.ProductUnitTrack{
@@ -104,18 +140,21 @@ func (s *ProductUnitTrackService) CreateProductUnitTrack(
 }
 
 func (s *ProductUnitTrackService) DeleteProductUnitTrack(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.DeleteProductUnitTrack")
+	defer span.End()
+
 	if id == 0 {
-		return errors.New("invalid product unit track ID")
+		return apperror.Validation("invalid_product_unit_track_id", "invalid product unit track ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if product unit track exists
 	_, err := s.productUnitTrackRepo.GetProductUnitTrackModelByID(id)
 	if err != nil {
-		return errors.New("product unit track not found")
+		return apperror.NotFound("product_unit_track_not_found", "product unit track not found")
 	}
 
 	return s.productUnitTrackRepo.DeleteProductUnitTrackWithAudit(id, userID)
@@ -123,6 +162,9 @@ func (s *ProductUnitTrackService) DeleteProductUnitTrack(id uint, userID uint) e
 
 // TrackCreate creates a tracking record for product unit creation
 func (s *ProductUnitTrackService) TrackCreate(productUnit model.ProductUnit, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.TrackCreate")
+	defer span.End()
+
 	description := s.trackingUnitUtils.GenerateCreateDescriptionProductUnit(productUnit)
 	_, err := s.CreateProductUnitTrack(productUnit.ID, description, userID)
 	return err
@@ -130,6 +172,9 @@ func (s *ProductUnitTrackService) TrackCreate(productUnit model.ProductUnit, use
 
 // TrackUpdateFromChanges creates a tracking record for product unit updates using update data
 func (s *ProductUnitTrackService) TrackUpdateFromChangesProductUnit(updateData map[string]interface{}, oldBatch model.ProductUnit, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.TrackUpdateFromChangesProductUnit")
+	defer span.End()
+
 	description := s.trackingUnitUtils.GenerateUpdateDescriptionFromChangesProductUnit(updateData, oldBatch)
 	_, err := s.CreateProductUnitTrack(oldBatch.ID, description, userID)
 	return err
@@ -137,6 +182,9 @@ func (s *ProductUnitTrackService) TrackUpdateFromChangesProductUnit(updateData m
 
 // TrackDelete creates a tracking record for product unit deletion
 func (s *ProductUnitTrackService) TrackDeleteProductUnit(productUnit model.ProductUnit, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ProductUnitTrackService.TrackDeleteProductUnit")
+	defer span.End()
+
 	description := s.trackingUnitUtils.GenerateDeleteDescriptionProductUnit(productUnit)
 	_, err := s.CreateProductUnitTrack(productUnit.ID, description, userID)
 	return err
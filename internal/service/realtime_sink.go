@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"myapp/internal/events"
+	"myapp/internal/realtime"
+	"myapp/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// RealtimeSink streams the current state of a stock row to dashboards whenever a stock event is relayed
+type RealtimeSink struct {
+	stockRepo *repository.ProductStockRepository
+	hub       *realtime.Hub
+}
+
+func NewRealtimeSink(hub *realtime.Hub) *RealtimeSink {
+	return &RealtimeSink{
+		stockRepo: repository.NewProductStockRepository(),
+		hub:       hub,
+	}
+}
+
+func (s *RealtimeSink) Name() string {
+	return "realtime"
+}
+
+func (s *RealtimeSink) Publish(ctx context.Context, envelope events.Envelope) error {
+	if envelope.AggregateType != events.AggregateProductStock {
+		return nil
+	}
+
+	stock, err := s.stockRepo.GetProductStockModelByIDUnscoped(envelope.AggregateID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	update := realtime.StockUpdate{
+		EventID:        envelope.ID,
+		TenantID:       stock.TenantID,
+		Event:          envelope.Type,
+		ProductStockID: stock.ID,
+		ProductID:      stock.ProductID,
+		ProductBatchID: stock.ProductBatchID,
+		LocationID:     stock.LocationID,
+		BinID:          stock.BinID,
+		Quantity:       stock.Quantity,
+		Status:         stock.Status,
+		Deleted:        stock.DeletedAt.Valid,
+		OccurredAt:     envelope.OccurredAt,
+	}
+
+	if envelope.Type == events.StockMoved {
+		var movement events.StockMovement
+		if err := json.Unmarshal(envelope.Data, &movement); err == nil {
+			update.StockIn = movement.StockIn
+			update.StockOut = movement.StockOut
+			update.FromLocationID = movement.FromLocationID
+		}
+	}
+
+	return s.hub.Publish(ctx, update)
+}
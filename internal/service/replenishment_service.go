@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/tenant"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"sort"
+	"time"
+)
+
+// ReplenishmentTransfer proposes moving stock from a gudang to the location of a rule
+type ReplenishmentTransfer struct {
+	FromLocationID   uint    `json:"fromLocationId"`
+	FromLocationName string  `json:"fromLocationName"`
+	Quantity         float64 `json:"quantity"`
+}
+
+// ReplenishmentSuggestion is a rule at or below its reorder point with the quantity needed to get back to its maximum.
+// Reseller locations are refilled by transfers from gudang stock first; whatever cannot be transferred has to be ordered.
+type ReplenishmentSuggestion struct {
+	repository.ReplenishmentRuleLevel
+	Priority          string                  `json:"priority"` // critical (below minimum), reorder
+	SuggestedQuantity float64                 `json:"suggestedQuantity"`
+	Transfers         []ReplenishmentTransfer `json:"transfers"`
+	OrderQuantity     float64                 `json:"orderQuantity"`
+}
+
+// ReplenishmentCheckResult summarizes one run of the replenishment check
+type ReplenishmentCheckResult struct {
+	Rules    int `json:"rules"`
+	Opened   int `json:"opened"`
+	Updated  int `json:"updated"`
+	Resolved int `json:"resolved"`
+}
+
+type ReplenishmentService struct {
+	replenishmentRepo *repository.ReplenishmentRepository
+	webhookService    *WebhookService
+	ctx               context.Context
+}
+
+func NewReplenishmentService() *ReplenishmentService {
+	return &ReplenishmentService{
+		replenishmentRepo: repository.NewReplenishmentRepository(),
+		webhookService:    NewWebhookService(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *ReplenishmentService) WithContext(ctx context.Context) *ReplenishmentService {
+	return &ReplenishmentService{
+		replenishmentRepo: s.replenishmentRepo.WithContext(ctx),
+		webhookService:    s.webhookService.WithContext(ctx),
+		ctx:               ctx,
+	}
+}
+
+func (s *ReplenishmentService) GetAllReplenishmentRules(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.GetAllReplenishmentRules")
+	defer span.End()
+
+	return s.replenishmentRepo.GetAllReplenishmentRules(query)
+}
+
+func (s *ReplenishmentService) GetReplenishmentRuleByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.GetReplenishmentRuleByID")
+	defer span.End()
+
+	rule, err := s.replenishmentRepo.GetReplenishmentRuleByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("replenishment_rule_not_found", "replenishment rule not found")
+	}
+	return rule, nil
+}
+
+func (s *ReplenishmentService) CreateReplenishmentRule(productID uint, locationID *uint, minQuantity, reorderPoint, maxQuantity float64, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.CreateReplenishmentRule")
+	defer span.End()
+
+	if productID == 0 {
+		return nil, apperror.Validation("product_id_required", "product ID is required")
+	}
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+	if locationID != nil && *locationID == 0 {
+		locationID = nil
+	}
+
+	if err := validateReplenishmentLevels(minQuantity, reorderPoint, maxQuantity); err != nil {
+		return nil, err
+	}
+
+	productExists, err := s.replenishmentRepo.CheckProductExists(productID)
+	if err != nil {
+		return nil, err
+	}
+	if !productExists {
+		return nil, apperror.NotFound("product_not_found", "product not found")
+	}
+
+	if locationID != nil {
+		locationExists, err := s.replenishmentRepo.CheckLocationExists(*locationID)
+		if err != nil {
+			return nil, err
+		}
+		if !locationExists {
+			return nil, apperror.NotFound("location_not_found", "location not found")
+		}
+	}
+
+	ruleExists, err := s.replenishmentRepo.CheckReplenishmentRuleExists(productID, locationID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if ruleExists {
+		return nil, apperror.Conflict("replenishment_rule_already_exists_for_this_product_and_location", "replenishment rule already exists for this product and location")
+	}
+
+	rule := &model.ReplenishmentRule{
+		ProductID:    productID,
+		LocationID:   locationID,
+		MinQuantity:  minQuantity,
+		ReorderPoint: reorderPoint,
+		MaxQuantity:  maxQuantity,
+		UserIns:      &userID,
+		UserUpdt:     &userID,
+	}
+
+	err = s.replenishmentRepo.CreateReplenishmentRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replenishmentRepo.GetReplenishmentRuleByID(rule.ID)
+}
+
+func (s *ReplenishmentService) UpdateReplenishmentRule(id uint, minQuantity, reorderPoint, maxQuantity *float64, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.UpdateReplenishmentRule")
+	defer span.End()
+
+	if id == 0 {
+		return nil, apperror.Validation("invalid_replenishment_rule_id", "invalid replenishment rule ID")
+	}
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	rule, err := s.replenishmentRepo.GetReplenishmentRuleModelByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("replenishment_rule_not_found", "replenishment rule not found")
+	}
+
+	if minQuantity != nil {
+		rule.MinQuantity = *minQuantity
+	}
+	if reorderPoint != nil {
+		rule.ReorderPoint = *reorderPoint
+	}
+	if maxQuantity != nil {
+		rule.MaxQuantity = *maxQuantity
+	}
+	if err := validateReplenishmentLevels(rule.MinQuantity, rule.ReorderPoint, rule.MaxQuantity); err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]interface{}{
+		"min_quantity":  rule.MinQuantity,
+		"reorder_point": rule.ReorderPoint,
+		"max_quantity":  rule.MaxQuantity,
+		"user_updt":     userID,
+		"updated_at":    time.Now(),
+	}
+
+	err = s.replenishmentRepo.UpdateReplenishmentRule(id, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replenishmentRepo.GetReplenishmentRuleByID(id)
+}
+
+func (s *ReplenishmentService) DeleteReplenishmentRule(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.DeleteReplenishmentRule")
+	defer span.End()
+
+	if id == 0 {
+		return apperror.Validation("invalid_replenishment_rule_id", "invalid replenishment rule ID")
+	}
+	if userID == 0 {
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	_, err := s.replenishmentRepo.GetReplenishmentRuleModelByID(id)
+	if err != nil {
+		return apperror.NotFound("replenishment_rule_not_found", "replenishment rule not found")
+	}
+
+	return s.replenishmentRepo.DeleteReplenishmentRuleWithAudit(id, userID)
+}
+
+// validateReplenishmentLevels requires 0 <= min <= reorder point < max
+func validateReplenishmentLevels(minQuantity, reorderPoint, maxQuantity float64) error {
+	if minQuantity < 0 {
+		return apperror.Validation("minimum_quantity_cannot_be_negative", "minimum quantity cannot be negative")
+	}
+	if reorderPoint < minQuantity {
+		return apperror.Validation("reorder_point_below_min_quantity", "reorder point cannot be lower than the minimum quantity")
+	}
+	if maxQuantity <= reorderPoint {
+		return apperror.Validation("max_quantity_not_above_reorder_point", "maximum quantity must be greater than the reorder point")
+	}
+	return nil
+}
+
+// GetReplenishmentSuggestions proposes quantities for every rule at or below its reorder point.
+// Critical rules (below minimum) are served first, so they get the gudang stock available for transfers before the others.
+func (s *ReplenishmentService) GetReplenishmentSuggestions(productID, locationID *uint) ([]ReplenishmentSuggestion, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.GetReplenishmentSuggestions")
+	defer span.End()
+
+	rules, err := s.replenishmentRepo.GetReplenishmentRuleLevels(productID, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []ReplenishmentSuggestion{}
+	for _, rule := range rules {
+		if suggestion, ok := suggestReplenishment(rule); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Priority != b.Priority {
+			return a.Priority == model.ReplenishmentPriorityCritical
+		}
+		return a.OnHand/a.MaxQuantity < b.OnHand/b.MaxQuantity
+	})
+
+	if err := s.planTransfers(suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// suggestReplenishment returns the suggestion for a rule, or false when its stock is above the reorder point
+func suggestReplenishment(rule repository.ReplenishmentRuleLevel) (ReplenishmentSuggestion, bool) {
+	if rule.OnHand > rule.ReorderPoint {
+		return ReplenishmentSuggestion{}, false
+	}
+
+	priority := model.ReplenishmentPriorityReorder
+	if rule.OnHand < rule.MinQuantity {
+		priority = model.ReplenishmentPriorityCritical
+	}
+
+	quantity := rule.MaxQuantity - rule.OnHand
+	return ReplenishmentSuggestion{
+		ReplenishmentRuleLevel: rule,
+		Priority:               priority,
+		SuggestedQuantity:      quantity,
+		Transfers:              []ReplenishmentTransfer{},
+		OrderQuantity:          quantity,
+	}, true
+}
+
+// planTransfers fills reseller suggestions from gudang stock, largest warehouse first.
+// A gudang never gives away stock it needs itself: its own rule's reorder point for the product stays reserved.
+func (s *ReplenishmentService) planTransfers(suggestions []ReplenishmentSuggestion) error {
+	productIDs := []uint{}
+	seen := map[uint]bool{}
+	for _, suggestion := range suggestions {
+		if isResellerRule(suggestion.ReplenishmentRuleLevel) && !seen[suggestion.ProductID] {
+			seen[suggestion.ProductID] = true
+			productIDs = append(productIDs, suggestion.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	warehouses, err := s.replenishmentRepo.GetWarehouseStocks(productIDs)
+	if err != nil {
+		return err
+	}
+
+	// Quantities a gudang keeps for itself, from its own location rules
+	reserved := map[[2]uint]float64{}
+	gudangRules, err := s.replenishmentRepo.GetReplenishmentRuleLevels(nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, rule := range gudangRules {
+		if rule.LocationID != nil && rule.LocationType != nil && *rule.LocationType == "gudang" {
+			reserved[[2]uint{rule.ProductID, *rule.LocationID}] = rule.ReorderPoint
+		}
+	}
+
+	available := make([]warehouseStockLeft, 0, len(warehouses))
+	for _, warehouse := range warehouses {
+		left := warehouse.Quantity - reserved[[2]uint{warehouse.ProductID, warehouse.LocationID}]
+		if left > 0 {
+			available = append(available, warehouseStockLeft{WarehouseStock: warehouse, Left: left})
+		}
+	}
+
+	for i := range suggestions {
+		suggestion := &suggestions[i]
+		if !isResellerRule(suggestion.ReplenishmentRuleLevel) {
+			continue
+		}
+		for j := range available {
+			warehouse := &available[j]
+			if suggestion.OrderQuantity <= 0 {
+				break
+			}
+			if warehouse.ProductID != suggestion.ProductID || warehouse.Left <= 0 {
+				continue
+			}
+			quantity := suggestion.OrderQuantity
+			if warehouse.Left < quantity {
+				quantity = warehouse.Left
+			}
+			warehouse.Left -= quantity
+			suggestion.OrderQuantity -= quantity
+			suggestion.Transfers = append(suggestion.Transfers, ReplenishmentTransfer{
+				FromLocationID:   warehouse.LocationID,
+				FromLocationName: warehouse.LocationName,
+				Quantity:         quantity,
+			})
+		}
+	}
+	return nil
+}
+
+// warehouseStockLeft tracks how much gudang stock is still free while transfers are planned
+type warehouseStockLeft struct {
+	repository.WarehouseStock
+	Left float64
+}
+
+func isResellerRule(rule repository.ReplenishmentRuleLevel) bool {
+	return rule.LocationID != nil && rule.LocationType != nil && *rule.LocationType == "reseller"
+}
+
+func (s *ReplenishmentService) GetAllReplenishmentAlerts(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.GetAllReplenishmentAlerts")
+	defer span.End()
+
+	return s.replenishmentRepo.GetAllReplenishmentAlerts(query)
+}
+
+// RunReplenishmentCheck compares every rule with the current stock: it opens an alert when a rule reaches its reorder point,
+// refreshes the alert while the rule stays low, and resolves it once stock is back above the reorder point.
+func (s *ReplenishmentService) RunReplenishmentCheck() (ReplenishmentCheckResult, error) {
+	s, span := startSpan(s.ctx, s, "ReplenishmentService.RunReplenishmentCheck")
+	defer span.End()
+
+	result := ReplenishmentCheckResult{}
+
+	rules, err := s.replenishmentRepo.GetReplenishmentRuleLevels(nil, nil)
+	if err != nil {
+		return result, err
+	}
+	result.Rules = len(rules)
+
+	openAlerts, err := s.replenishmentRepo.GetOpenReplenishmentAlerts()
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		alert, hasAlert := openAlerts[rule.ID]
+		delete(openAlerts, rule.ID)
+
+		suggestion, low := suggestReplenishment(rule)
+		switch {
+		case low && !hasAlert:
+			err = s.openAlert(rule, suggestion)
+			result.Opened++
+		case low && hasAlert:
+			err = s.replenishmentRepo.UpdateReplenishmentAlert(alert.ID, map[string]interface{}{
+				"priority":           suggestion.Priority,
+				"on_hand":            rule.OnHand,
+				"suggested_quantity": suggestion.SuggestedQuantity,
+				"updated_at":         now,
+			})
+			result.Updated++
+		case !low && hasAlert:
+			err = s.resolveAlert(alert.ID, rule.OnHand, now)
+			result.Resolved++
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Alerts left over belong to rules that were deleted since
+	for _, alert := range openAlerts {
+		if err := s.resolveAlert(alert.ID, alert.OnHand, now); err != nil {
+			return result, err
+		}
+		result.Resolved++
+	}
+
+	return result, nil
+}
+
+// openAlert records a new alert and notifies stock.low webhook subscribers
+func (s *ReplenishmentService) openAlert(rule repository.ReplenishmentRuleLevel, suggestion ReplenishmentSuggestion) error {
+	alert := &model.ReplenishmentAlert{
+		ReplenishmentRuleID: rule.ID,
+		ProductID:           rule.ProductID,
+		LocationID:          rule.LocationID,
+		Priority:            suggestion.Priority,
+		Status:              model.ReplenishmentAlertOpen,
+		OnHand:              rule.OnHand,
+		SuggestedQuantity:   suggestion.SuggestedQuantity,
+		TenantID:            rule.TenantID,
+	}
+	if err := s.replenishmentRepo.CreateReplenishmentAlert(alert); err != nil {
+		return err
+	}
+
+	// The job runs without a tenant, so only the subscribers of the rule's tenant are notified
+	webhookService := s.webhookService.WithContext(tenant.ForRow(context.Background(), rule.TenantID))
+	return webhookService.Publish(model.WebhookEventStockLow, &alert.ID, map[string]interface{}{
+		"replenishmentAlertId": alert.ID,
+		"replenishmentRuleId":  rule.ID,
+		"productId":            rule.ProductID,
+		"productName":          rule.ProductName,
+		"locationId":           rule.LocationID,
+		"locationName":         rule.LocationName,
+		"priority":             suggestion.Priority,
+		"onHand":               rule.OnHand,
+		"reorderPoint":         rule.ReorderPoint,
+		"minQuantity":          rule.MinQuantity,
+		"suggestedQuantity":    suggestion.SuggestedQuantity,
+	})
+}
+
+func (s *ReplenishmentService) resolveAlert(id uint, onHand float64, now time.Time) error {
+	return s.replenishmentRepo.UpdateReplenishmentAlert(id, map[string]interface{}{
+		"status":      model.ReplenishmentAlertResolved,
+		"on_hand":     onHand,
+		"resolved_at": now,
+		"updated_at":  now,
+	})
+}
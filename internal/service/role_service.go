@@ -1,14 +1,16 @@
 package service
 
 import (
-	"errors"
-	"log"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
 )
 
 type RoleService struct {
 	roleRepo *repository.RoleRepository
+	ctx      context.Context
 }
 
 func NewRoleService() *RoleService {
@@ -17,12 +19,26 @@ func NewRoleService() *RoleService {
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *RoleService) WithContext(ctx context.Context) *RoleService {
+	return &RoleService{
+		roleRepo: s.roleRepo.WithContext(ctx),
+		ctx:      ctx,
+	}
+}
+
 // Business logic methods
-func (s *RoleService) GetAllRoles() ([]model.Role, error) {
-	return s.roleRepo.GetAllRoles()
+func (s *RoleService) GetAllRoles(query utils.ListQuery) ([]model.Role, int64, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.GetAllRoles")
+	defer span.End()
+
+	return s.roleRepo.GetAllRoles(query)
 }
 
 func (s *RoleService) GetRoleByID(id uint) (*model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.GetRoleByID")
+	defer span.End()
+
 	role, err := s.roleRepo.GetRoleByID(id)
 	if err != nil {
 		return nil, err
@@ -30,13 +46,28 @@ func (s *RoleService) GetRoleByID(id uint) (*model.Role, error) {
 	return &role, nil
 }
 
+// GetRoleByName finds a role of the tenant by its name, such as Admin
+func (s *RoleService) GetRoleByName(name string) (*model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.GetRoleByName")
+	defer span.End()
+
+	role, err := s.roleRepo.GetRoleByName(name)
+	if err != nil {
+		return nil, apperror.NotFound("role_not_found", "role not found")
+	}
+	return &role, nil
+}
+
 func (s *RoleService) CreateRole(name, description string, userID uint) (*model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.CreateRole")
+	defer span.End()
+
 	if name == "" {
-		return nil, errors.New("role name is required")
+		return nil, apperror.Validation("role_name_required", "role name is required")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if role exists
@@ -45,7 +76,7 @@ func (s *RoleService) CreateRole(name, description string, userID uint) (*model.
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("role already exists")
+		return nil, apperror.Conflict("role_already_exists", "role already exists")
 	}
 
 	role := &model.Role{
@@ -59,18 +90,21 @@ func (s *RoleService) CreateRole(name, description string, userID uint) (*model.
 }
 
 func (s *RoleService) UpdateRole(id uint, name, description string, userID uint) (*model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.UpdateRole")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid role ID")
+		return nil, apperror.Validation("invalid_role_id", "invalid role ID")
 	}
 
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if role exists
 	role, err := s.roleRepo.GetRoleByID(id)
 	if err != nil {
-		return nil, errors.New("role not found")
+		return nil, apperror.NotFound("role_not_found", "role not found")
 	}
 
 	// Check if new name conflicts with existing roles
@@ -79,9 +113,8 @@ func (s *RoleService) UpdateRole(id uint, name, description string, userID uint)
 		if err != nil {
 			return nil, err
 		}
-		log.Println("cek status", exists)
 		if exists {
-			return nil, errors.New("role name already in use")
+			return nil, apperror.Conflict("role_name_already_in_use", "role name already in use")
 		}
 	}
 
@@ -109,18 +142,21 @@ func (s *RoleService) UpdateRole(id uint, name, description string, userID uint)
 }
 
 func (s *RoleService) DeleteRole(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "RoleService.DeleteRole")
+	defer span.End()
+
 	if id == 0 {
-		return errors.New("invalid role ID")
+		return apperror.Validation("invalid_role_id", "invalid role ID")
 	}
 
 	if userID == 0 {
-		return errors.New("user ID is required for audit trail")
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	// Check if role exists
 	_, err := s.roleRepo.GetRoleByID(id)
 	if err != nil {
-		return errors.New("role not found")
+		return apperror.NotFound("role_not_found", "role not found")
 	}
 
 	return s.roleRepo.DeleteRoleWithAudit(id, userID)
@@ -128,16 +164,22 @@ func (s *RoleService) DeleteRole(id uint, userID uint) error {
 
 // GetDeletedRoles returns all soft deleted roles
 func (s *RoleService) GetDeletedRoles() ([]model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.GetDeletedRoles")
+	defer span.End()
+
 	return s.roleRepo.GetDeletedRoles()
 }
 
 // RestoreRole restores a soft deleted role
 func (s *RoleService) RestoreRole(id uint, userID uint) (*model.Role, error) {
+	s, span := startSpan(s.ctx, s, "RoleService.RestoreRole")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid role ID")
+		return nil, apperror.Validation("invalid_role_id", "invalid role ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.roleRepo.RestoreRole(id, userID)
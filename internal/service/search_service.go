@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"strings"
+)
+
+type SearchService struct {
+	searchRepo *repository.SearchRepository
+	ctx        context.Context
+}
+
+// ProductSearchFacets groups the brand and category facets of a product search
+type ProductSearchFacets struct {
+	Brands     []repository.SearchFacet `json:"brands"`
+	Categories []repository.SearchFacet `json:"categories"`
+}
+
+// ProductSearchResponse is the data returned by product search
+type ProductSearchResponse struct {
+	Items  interface{}         `json:"items"`
+	Facets ProductSearchFacets `json:"facets"`
+}
+
+func NewSearchService() *SearchService {
+	return &SearchService{
+		searchRepo: repository.NewSearchRepository(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *SearchService) WithContext(ctx context.Context) *SearchService {
+	return &SearchService{
+		searchRepo: s.searchRepo.WithContext(ctx),
+		ctx:        ctx,
+	}
+}
+
+// SearchProducts runs a ranked product search; query provides page/page_size, brandID and categoryID drill into facets
+func (s *SearchService) SearchProducts(keyword string, brandID, categoryID *uint, query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "SearchService.SearchProducts")
+	defer span.End()
+
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil, 0, apperror.Validation("search_keyword_required", "search keyword is required")
+	}
+
+	products, total, err := s.searchRepo.SearchProducts(repository.ProductSearchParams{
+		Keyword:    keyword,
+		BrandID:    brandID,
+		CategoryID: categoryID,
+		Limit:      query.PageSize,
+		Offset:     query.Offset(),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	brands, categories, err := s.searchRepo.GetProductSearchFacets(keyword)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ProductSearchResponse{
+		Items: products,
+		Facets: ProductSearchFacets{
+			Brands:     brands,
+			Categories: categories,
+		},
+	}, total, nil
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/pkg/apperror"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPutawaySuggestions is the number of bins suggested when no limit is given
+const DefaultPutawaySuggestions = 5
+
+// Putaway reasons, in order of preference
+const (
+	PutawayReasonConsolidate = "consolidate" // The bin already holds this product
+	PutawayReasonEmpty       = "empty"       // The bin is empty
+	PutawayReasonMixed       = "mixed"       // The bin holds other products but has room
+)
+
+// PutawaySuggestion is a bin that can take the received quantity, with the reason it was picked
+type PutawaySuggestion struct {
+	repository.PutawayCandidate
+	FreeCapacity *float64 `json:"freeCapacity"` // Null for bins without a capacity limit
+	Reason       string   `json:"reason"`
+}
+
+type SubLocationService struct {
+	subLocationRepo *repository.SubLocationRepository
+	locationRepo    *repository.LocationRepository
+	ctx             context.Context
+}
+
+func NewSubLocationService() *SubLocationService {
+	return &SubLocationService{
+		subLocationRepo: repository.NewSubLocationRepository(),
+		locationRepo:    repository.NewLocationRepository(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *SubLocationService) WithContext(ctx context.Context) *SubLocationService {
+	return &SubLocationService{
+		subLocationRepo: s.subLocationRepo.WithContext(ctx),
+		locationRepo:    s.locationRepo.WithContext(ctx),
+		ctx:             ctx,
+	}
+}
+
+// GetLocationLayout returns the zones of a location with their aisles, racks and bins nested below them
+func (s *SubLocationService) GetLocationLayout(locationID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "SubLocationService.GetLocationLayout")
+	defer span.End()
+
+	if _, err := s.getWarehouse(locationID); err != nil {
+		return nil, err
+	}
+
+	subLocations, err := s.subLocationRepo.GetSubLocationsByLocation(locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are ordered by path, so every parent is indexed before its children
+	byID := make(map[uint]*repository.SubLocationResponse, len(subLocations))
+	zones := []*repository.SubLocationResponse{}
+	for _, node := range subLocations {
+		byID[node.ID] = node
+		if node.ParentID == nil {
+			zones = append(zones, node)
+		} else if parent, ok := byID[*node.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	// Roll the bin quantities up so every zone, aisle and rack shows what it stores
+	var rollUp func(node *repository.SubLocationResponse) float64
+	rollUp = func(node *repository.SubLocationResponse) float64 {
+		for _, child := range node.Children {
+			node.UsedQuantity += rollUp(child)
+		}
+		return node.UsedQuantity
+	}
+	for _, zone := range zones {
+		rollUp(zone)
+	}
+
+	return zones, nil
+}
+
+func (s *SubLocationService) CreateSubLocation(locationID uint, parentID *uint, level, code string, name *string, capacity *float64, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "SubLocationService.CreateSubLocation")
+	defer span.End()
+
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	level = strings.ToLower(strings.TrimSpace(level))
+	parentLevel, ok := model.SubLocationParentLevel[level]
+	if !ok {
+		return nil, apperror.Validation("invalid_sub_location_level", "invalid sub-location level. Must be 'zone', 'aisle', 'rack' or 'bin'")
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, apperror.Validation("sub_location_code_required", "sub-location code is required")
+	}
+	if strings.Contains(code, "-") {
+		return nil, apperror.Validation("invalid_sub_location_code", "sub-location code cannot contain '-'")
+	}
+
+	if capacity != nil {
+		if level != model.SubLocationLevelBin {
+			return nil, apperror.Validation("capacity_only_on_bins", "capacity can only be set on bins")
+		}
+		if *capacity < 0 {
+			return nil, apperror.Validation("capacity_cannot_be_negative", "capacity cannot be negative")
+		}
+	}
+
+	if _, err := s.getWarehouse(locationID); err != nil {
+		return nil, err
+	}
+
+	// The parent must belong to the same location and sit exactly one level above
+	path := code
+	if parentLevel == "" {
+		if parentID != nil {
+			return nil, apperror.Validation("invalid_parent", "invalid parent: zones cannot have a parent")
+		}
+	} else {
+		if parentID == nil {
+			return nil, apperror.Validation("invalid_parent", fmt.Sprintf("invalid parent: a %s needs a parent of level %s", level, parentLevel))
+		}
+		parent, err := s.subLocationRepo.GetSubLocationModelByID(*parentID)
+		if err != nil || parent.LocationID != locationID {
+			return nil, apperror.NotFound("parent_sub_location_not_found", "parent sub-location not found")
+		}
+		if parent.Level != parentLevel {
+			return nil, apperror.Validation("invalid_parent", fmt.Sprintf("invalid parent: a %s needs a parent of level %s", level, parentLevel))
+		}
+		path = parent.Path + "-" + code
+	}
+
+	pathExists, err := s.subLocationRepo.CheckPathExists(locationID, path)
+	if err != nil {
+		return nil, err
+	}
+	if pathExists {
+		return nil, apperror.Conflict("sub_location_code_already_exists_at_this_level", "sub-location code already exists at this level")
+	}
+
+	subLocation := &model.SubLocation{
+		LocationID:  locationID,
+		ParentID:    parentID,
+		Level:       level,
+		Code:        code,
+		Path:        path,
+		Name:        name,
+		Capacity:    capacity,
+		Description: description,
+		UserIns:     &userID,
+	}
+
+	err = s.subLocationRepo.CreateSubLocation(subLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.subLocationRepo.GetSubLocationByID(subLocation.ID)
+}
+
+// UpdateSubLocation changes the name, capacity or description; code, level and parent are fixed once created
+func (s *SubLocationService) UpdateSubLocation(locationID, id uint, name *string, capacity *float64, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "SubLocationService.UpdateSubLocation")
+	defer span.End()
+
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	subLocation, err := s.getSubLocation(locationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]interface{}{
+		"user_updt":  userID,
+		"updated_at": time.Now(),
+	}
+	if name != nil {
+		updateData["name"] = name
+	}
+	if description != nil {
+		updateData["description"] = description
+	}
+	if capacity != nil {
+		if subLocation.Level != model.SubLocationLevelBin {
+			return nil, apperror.Validation("capacity_only_on_bins", "capacity can only be set on bins")
+		}
+		if *capacity < 0 {
+			return nil, apperror.Validation("capacity_cannot_be_negative", "capacity cannot be negative")
+		}
+		used, err := s.subLocationRepo.GetBinUsedQuantity(id, 0)
+		if err != nil {
+			return nil, err
+		}
+		if used > *capacity {
+			return nil, apperror.Validation("capacity_below_stored_quantity", fmt.Sprintf("capacity cannot be lower than the %g already stored in the bin", used))
+		}
+		updateData["capacity"] = *capacity
+	}
+
+	err = s.subLocationRepo.UpdateSubLocation(id, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.subLocationRepo.GetSubLocationByID(id)
+}
+
+// DeleteSubLocation removes an empty node: it must have no children and, for bins, no stock
+func (s *SubLocationService) DeleteSubLocation(locationID, id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "SubLocationService.DeleteSubLocation")
+	defer span.End()
+
+	if userID == 0 {
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	if _, err := s.getSubLocation(locationID, id); err != nil {
+		return err
+	}
+
+	children, err := s.subLocationRepo.CountChildren(id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return apperror.Conflict("sub_location_still_has_children", "sub-location still has children")
+	}
+
+	stocks, err := s.subLocationRepo.CountStocksInBin(id)
+	if err != nil {
+		return err
+	}
+	if stocks > 0 {
+		return apperror.Conflict("bin_still_holds_stock", "bin still holds stock")
+	}
+
+	return s.subLocationRepo.DeleteSubLocationWithAudit(id, userID)
+}
+
+// SuggestPutaway ranks the bins of a location that can take quantity of a product:
+// bins already holding the product first, then empty bins, then the others; best fit (least free space left) first.
+func (s *SubLocationService) SuggestPutaway(locationID, productID uint, quantity float64, limit int) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "SubLocationService.SuggestPutaway")
+	defer span.End()
+
+	if productID == 0 {
+		return nil, apperror.Validation("product_id_required", "product ID is required")
+	}
+	if quantity <= 0 {
+		return nil, apperror.Validation("quantity_must_be_greater_than_0", "quantity must be greater than 0")
+	}
+	if limit <= 0 {
+		limit = DefaultPutawaySuggestions
+	}
+
+	if _, err := s.getWarehouse(locationID); err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.subLocationRepo.GetPutawayCandidates(locationID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []PutawaySuggestion{}
+	for _, candidate := range candidates {
+		suggestion := PutawaySuggestion{PutawayCandidate: candidate}
+		if candidate.Capacity != nil {
+			free := *candidate.Capacity - candidate.UsedQuantity
+			if free < quantity {
+				continue
+			}
+			suggestion.FreeCapacity = &free
+		}
+
+		switch {
+		case candidate.ProductQuantity > 0:
+			suggestion.Reason = PutawayReasonConsolidate
+		case candidate.UsedQuantity == 0 && candidate.OtherProducts == 0:
+			suggestion.Reason = PutawayReasonEmpty
+		default:
+			suggestion.Reason = PutawayReasonMixed
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	rank := map[string]int{PutawayReasonConsolidate: 0, PutawayReasonEmpty: 1, PutawayReasonMixed: 2}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if rank[a.Reason] != rank[b.Reason] {
+			return rank[a.Reason] < rank[b.Reason]
+		}
+		// Unlimited bins come after bins with a known free capacity
+		if a.FreeCapacity == nil || b.FreeCapacity == nil {
+			return a.FreeCapacity != nil && b.FreeCapacity == nil
+		}
+		return *a.FreeCapacity < *b.FreeCapacity
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// getWarehouse returns the location when it exists and is a gudang; only warehouses have a storage layout
+func (s *SubLocationService) getWarehouse(locationID uint) (model.Location, error) {
+	if locationID == 0 {
+		return model.Location{}, apperror.Validation("invalid_location_id", "invalid location ID")
+	}
+	location, err := s.locationRepo.GetLocationModelByID(locationID)
+	if err != nil {
+		return model.Location{}, apperror.NotFound("location_not_found", "location not found")
+	}
+	if location.Type != "gudang" {
+		return model.Location{}, apperror.Validation("sub_locations_only_in_gudang", "sub-locations are only available for gudang locations")
+	}
+	return location, nil
+}
+
+func (s *SubLocationService) getSubLocation(locationID, id uint) (model.SubLocation, error) {
+	if id == 0 {
+		return model.SubLocation{}, apperror.Validation("invalid_sub_location_id", "invalid sub-location ID")
+	}
+	subLocation, err := s.subLocationRepo.GetSubLocationModelByID(id)
+	if err != nil || subLocation.LocationID != locationID {
+		return model.SubLocation{}, apperror.NotFound("sub_location_not_found", "sub-location not found")
+	}
+	return subLocation, nil
+}
+
+// checkBinForStock makes sure binID is a bin of locationID with room for quantity, not counting excludeStockID
+func checkBinForStock(subLocationRepo *repository.SubLocationRepository, binID, locationID uint, quantity float64, excludeStockID uint) error {
+	bin, err := subLocationRepo.GetSubLocationModelByID(binID)
+	if err != nil || bin.LocationID != locationID {
+		return apperror.Validation("bin_not_found_in_this_location", "bin not found in this location")
+	}
+	if bin.Level != model.SubLocationLevelBin {
+		return apperror.Validation("stock_only_in_bins", "stock can only be placed in a bin")
+	}
+	if bin.Capacity == nil {
+		return nil
+	}
+
+	used, err := subLocationRepo.GetBinUsedQuantity(binID, excludeStockID)
+	if err != nil {
+		return err
+	}
+	if used+quantity > *bin.Capacity {
+		return apperror.Conflict("bin_capacity_exceeded", fmt.Sprintf("bin capacity exceeded: %g of %g already used", used, *bin.Capacity))
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"regexp"
+	"strings"
+)
+
+// tenantCodePattern is a lowercase slug such as acme or acme-retail
+var tenantCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+type TenantService struct {
+	tenantRepo *repository.TenantRepository
+	ctx        context.Context
+}
+
+func NewTenantService() *TenantService {
+	return &TenantService{
+		tenantRepo: repository.NewTenantRepository(),
+	}
+}
+
+// WithContext returns a copy of the service whose repositories run with ctx
+func (s *TenantService) WithContext(ctx context.Context) *TenantService {
+	return &TenantService{
+		tenantRepo: s.tenantRepo.WithContext(ctx),
+		ctx:        ctx,
+	}
+}
+
+func (s *TenantService) GetAllTenants(query utils.ListQuery) ([]model.Tenant, int64, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.GetAllTenants")
+	defer span.End()
+
+	return s.tenantRepo.GetAllTenants(query)
+}
+
+func (s *TenantService) GetTenantByID(id uint) (*model.Tenant, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.GetTenantByID")
+	defer span.End()
+
+	if id == 0 {
+		return nil, apperror.Validation("invalid_tenant_id", "invalid tenant ID")
+	}
+
+	tenant, err := s.tenantRepo.GetTenantByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("tenant_not_found", "tenant not found")
+	}
+	return &tenant, nil
+}
+
+// GetTenantByCode finds a tenant by its code, such as default
+func (s *TenantService) GetTenantByCode(code string) (*model.Tenant, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.GetTenantByCode")
+	defer span.End()
+
+	tenant, err := s.tenantRepo.GetTenantByCode(code)
+	if err != nil {
+		return nil, apperror.NotFound("tenant_not_found", "tenant not found")
+	}
+	return &tenant, nil
+}
+
+func (s *TenantService) CreateTenant(code, name string, userID uint) (*model.Tenant, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.CreateTenant")
+	defer span.End()
+
+	code = strings.TrimSpace(code)
+	name = strings.TrimSpace(name)
+	if !tenantCodePattern.MatchString(code) {
+		return nil, apperror.Validation("invalid_tenant_code", "tenant code must be 2-50 lowercase letters, digits or dashes")
+	}
+	if name == "" {
+		return nil, apperror.Validation("tenant_name_required", "tenant name is required")
+	}
+
+	exists, err := s.tenantRepo.CheckTenantCodeExists(code, 0)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, apperror.Conflict("tenant_code_already_exists", "tenant code already exists")
+	}
+
+	tenant := &model.Tenant{
+		Code:     code,
+		Name:     name,
+		IsActive: true,
+		UserIns:  &userID,
+	}
+	if err := s.tenantRepo.CreateTenant(tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *TenantService) UpdateTenant(id uint, code, name *string, isActive *bool, userID uint) (*model.Tenant, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.UpdateTenant")
+	defer span.End()
+
+	tenant, err := s.GetTenantByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := map[string]interface{}{
+		"user_updt": userID,
+	}
+
+	if code != nil && strings.TrimSpace(*code) != tenant.Code {
+		newCode := strings.TrimSpace(*code)
+		if tenant.Code == model.DefaultTenantCode {
+			return nil, apperror.Conflict("default_tenant_code_cannot_be_changed", "default tenant code cannot be changed")
+		}
+		if !tenantCodePattern.MatchString(newCode) {
+			return nil, apperror.Validation("invalid_tenant_code", "tenant code must be 2-50 lowercase letters, digits or dashes")
+		}
+		exists, err := s.tenantRepo.CheckTenantCodeExists(newCode, id)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, apperror.Conflict("tenant_code_already_exists", "tenant code already exists")
+		}
+		updateData["code"] = newCode
+	}
+	if name != nil {
+		if strings.TrimSpace(*name) == "" {
+			return nil, apperror.Validation("tenant_name_required", "tenant name is required")
+		}
+		updateData["name"] = strings.TrimSpace(*name)
+	}
+	if isActive != nil {
+		updateData["is_active"] = *isActive
+	}
+
+	if err := s.tenantRepo.UpdateTenant(id, updateData); err != nil {
+		return nil, err
+	}
+	return s.GetTenantByID(id)
+}
+
+// DeleteTenant deactivates and soft deletes a tenant; its data is kept but no longer reachable
+func (s *TenantService) DeleteTenant(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "TenantService.DeleteTenant")
+	defer span.End()
+
+	tenant, err := s.GetTenantByID(id)
+	if err != nil {
+		return err
+	}
+	if tenant.Code == model.DefaultTenantCode {
+		return apperror.Conflict("default_tenant_cannot_be_deleted", "default tenant cannot be deleted")
+	}
+	return s.tenantRepo.DeleteTenantWithAudit(id, userID)
+}
+
+// IsTenantActive reports whether requests may act on the tenant
+func (s *TenantService) IsTenantActive(id uint) (bool, error) {
+	s, span := startSpan(s.ctx, s, "TenantService.IsTenantActive")
+	defer span.End()
+
+	return s.tenantRepo.IsTenantActive(id)
+}
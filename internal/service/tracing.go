@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"myapp/pkg/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the span of a service method, named Service.Method, as a child of the span in ctx. It returns a
+// copy of s bound to the span, so the queries and nested service calls of the method become its children.
+func startSpan[S interface{ WithContext(context.Context) S }](ctx context.Context, s S, name string) (S, trace.Span) {
+	ctx, span := tracing.Start(ctx, name)
+	return s.WithContext(ctx), span
+}
@@ -1,16 +1,21 @@
 package service
 
 import (
-	"errors"
-	"log"
+	"context"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"myapp/pkg/logger"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var userLog = logger.New("user")
+
 type UserService struct {
 	userRepo *repository.UserRepository
+	ctx      context.Context
 }
 
 func NewUserService() *UserService {
@@ -19,39 +24,59 @@ func NewUserService() *UserService {
 	}
 }
 
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *UserService) WithContext(ctx context.Context) *UserService {
+	return &UserService{
+		userRepo: s.userRepo.WithContext(ctx),
+		ctx:      ctx,
+	}
+}
+
 // Business logic methods
-func (s *UserService) GetAllUsers() ([]model.User, error) {
-	return s.userRepo.GetAllUsers()
+func (s *UserService) GetAllUsers(query utils.ListQuery) ([]model.User, int64, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetAllUsers")
+	defer span.End()
+
+	return s.userRepo.GetAllUsers(query)
 }
 
 func (s *UserService) GetUsersMinimal() ([]repository.UserMinimal, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUsersMinimal")
+	defer span.End()
+
 	return s.userRepo.GetUsersMinimal()
 }
 
 func (s *UserService) GetUserByID(id uint) (*model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUserByID")
+	defer span.End()
+
 	user, err := s.userRepo.GetUserByID(id)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, apperror.NotFound("user_not_found", "user not found")
 	}
 	return user, nil
 }
 
 func (s *UserService) CreateUser(name, email, password string) (*model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.CreateUser")
+	defer span.End()
+
 	// Check if email already exists using the new method
 	exists, err := s.userRepo.CheckEmailExists(email, 0)
 	if err != nil {
-		log.Printf("Error checking email existence: %v", err)
+		userLog.ErrorContext(s.ctx, "Checking email existence failed", "error", err)
 		return nil, err
 	}
 	if exists {
-		log.Printf("Email already exists: %s", email)
-		return nil, errors.New("email already exists")
+		userLog.DebugContext(s.ctx, "Email already exists", "email", email)
+		return nil, apperror.Conflict("email_already_exists", "email already exists")
 	}
 
-	log.Printf("Email is available, creating new user for: %s", email)
+	userLog.DebugContext(s.ctx, "Email is available, creating new user", "email", email)
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -67,57 +92,119 @@ func (s *UserService) CreateUser(name, email, password string) (*model.User, err
 
 	err = s.userRepo.CreateUser(user)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
+		userLog.ErrorContext(s.ctx, "Creating user failed", "error", err)
 		return nil, err
 	}
 
-	log.Printf("User created successfully with ID: %d", user.ID)
+	userLog.DebugContext(s.ctx, "User created", "user_id", user.ID)
 	return user, nil
 }
 
 func (s *UserService) AuthenticateUser(email, password string) (*model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.AuthenticateUser")
+	defer span.End()
+
 	user, err := s.userRepo.GetUserByEmail(email)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, apperror.Unauthorized("invalid_credentials", "invalid credentials")
 	}
 
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, apperror.Unauthorized("invalid_credentials", "invalid credentials")
 	}
 
 	return user, nil
 }
 
 func (s *UserService) SearchUsers(keyword string, limit, offset int) ([]model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.SearchUsers")
+	defer span.End()
+
 	return s.userRepo.SearchUsersRaw(keyword, limit, offset)
 }
 
 func (s *UserService) GetUsersStats() (*repository.UserStats, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUsersStats")
+	defer span.End()
+
 	return s.userRepo.GetUsersStats()
 }
 
 func (s *UserService) GetUsersWithRawSQL() ([]model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUsersWithRawSQL")
+	defer span.End()
+
 	return s.userRepo.GetUsersWithRawSQL()
 }
 
 func (s *UserService) GetUsersWithStats() ([]repository.UserResult, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUsersWithStats")
+	defer span.End()
+
 	return s.userRepo.GetUsersWithStats()
 }
 
 // GetDeletedUsers returns all soft deleted users
 func (s *UserService) GetDeletedUsers() ([]model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetDeletedUsers")
+	defer span.End()
+
 	return s.userRepo.GetDeletedUsers()
 }
 
+// GetUserAccess returns what limits apply to a user: admins see every location, other users assigned to a reseller
+// location only see their own locations
+func (s *UserService) GetUserAccess(userID uint) (repository.UserAccess, error) {
+	s, span := startSpan(s.ctx, s, "UserService.GetUserAccess")
+	defer span.End()
+
+	return s.userRepo.GetUserAccess(userID)
+}
+
+// AssignRole sets the role of a user; a nil roleID removes it
+func (s *UserService) AssignRole(id uint, roleID *uint) (*model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.AssignRole")
+	defer span.End()
+
+	if id == 0 {
+		return nil, apperror.Validation("invalid_user_id", "invalid user ID")
+	}
+
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil || user == nil {
+		return nil, apperror.NotFound("user_not_found", "user not found")
+	}
+
+	if roleID != nil {
+		exists, err := s.userRepo.CheckRoleExists(*roleID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, apperror.NotFound("role_not_found", "role not found")
+		}
+	}
+
+	err = s.userRepo.UpdateUser(id, map[string]interface{}{"role_id": roleID})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetUserByID(id)
+}
+
 // RestoreUser restores a soft deleted user
 func (s *UserService) RestoreUser(id uint, userID uint) (*model.User, error) {
+	s, span := startSpan(s.ctx, s, "UserService.RestoreUser")
+	defer span.End()
+
 	if id == 0 {
-		return nil, errors.New("invalid user ID")
+		return nil, apperror.Validation("invalid_user_id", "invalid user ID")
 	}
 	if userID == 0 {
-		return nil, errors.New("user ID is required for audit trail")
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
 	}
 
 	err := s.userRepo.RestoreUser(id, userID)
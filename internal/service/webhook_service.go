@@ -0,0 +1,622 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/tenant"
+	"myapp/internal/utils"
+	"myapp/pkg/apperror"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivery settings
+const (
+	WebhookMaxAttempts    = 8                // A delivery is marked failed after this many attempts
+	WebhookRetryBaseDelay = 30 * time.Second // Delay after the first failure, doubled on every further failure
+	WebhookRetryMaxDelay  = 6 * time.Hour
+	WebhookRequestTimeout = 10 * time.Second
+	WebhookDeliveryBatch  = 50 // Deliveries sent per worker run
+)
+
+// WebhookEventPayload is the JSON body POSTed to subscribers
+type WebhookEventPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookSubscriptionWithSecret is returned once, when a subscription is created or its secret is rotated
+type WebhookSubscriptionWithSecret struct {
+	repository.WebhookSubscriptionResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	client      *http.Client
+	ctx         context.Context
+}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		webhookRepo: repository.NewWebhookRepository(),
+		client:      newWebhookClient(),
+	}
+}
+
+// newWebhookClient returns the client deliveries are sent with. It checks the address of every connection it opens,
+// redirects included, so a subscriber host that resolves to an internal address is refused even when its DNS record
+// changed after the URL was validated.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: WebhookRequestTimeout, Control: checkWebhookAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // A proxy would be dialed and checked instead of the subscriber
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: WebhookRequestTimeout, Transport: transport}
+}
+
+// WithTx returns a copy of the service whose repositories run inside tx, so events are only queued when tx commits
+func (s *WebhookService) WithTx(tx *gorm.DB) *WebhookService {
+	return &WebhookService{
+		webhookRepo: s.webhookRepo.WithTx(tx),
+		client:      s.client,
+		ctx:         s.ctx,
+	}
+}
+
+// WithContext returns a copy of the service whose repositories are scoped to the tenant of ctx
+func (s *WebhookService) WithContext(ctx context.Context) *WebhookService {
+	return &WebhookService{
+		webhookRepo: s.webhookRepo.WithContext(ctx),
+		client:      s.client,
+		ctx:         ctx,
+	}
+}
+
+func (s *WebhookService) GetAllWebhookSubscriptions(query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.GetAllWebhookSubscriptions")
+	defer span.End()
+
+	return s.webhookRepo.GetAllWebhookSubscriptions(query)
+}
+
+func (s *WebhookService) GetWebhookSubscriptionByID(id uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.GetWebhookSubscriptionByID")
+	defer span.End()
+
+	subscription, err := s.webhookRepo.GetWebhookSubscriptionByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("webhook_subscription_not_found", "webhook subscription not found")
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) CreateWebhookSubscription(rawURL string, eventNames []string, secret *string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.CreateWebhookSubscription")
+	defer span.End()
+
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	if err := validateWebhookURL(s.ctx, rawURL); err != nil {
+		return nil, err
+	}
+
+	normalizedEvents, err := normalizeWebhookEvents(eventNames)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptionSecret, err := webhookSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &model.WebhookSubscription{
+		URL:         rawURL,
+		Events:      normalizedEvents,
+		Secret:      subscriptionSecret,
+		IsActive:    true,
+		Description: description,
+		UserIns:     &userID,
+	}
+
+	err = s.webhookRepo.CreateWebhookSubscription(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	createdSubscription, err := s.webhookRepo.GetWebhookSubscriptionByID(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return WebhookSubscriptionWithSecret{WebhookSubscriptionResponse: createdSubscription, Secret: subscriptionSecret}, nil
+}
+
+// UpdateWebhookSubscription changes the given fields; a non-nil secret rotates it ("" generates a new one) and the new secret is returned
+func (s *WebhookService) UpdateWebhookSubscription(id uint, rawURL *string, eventNames []string, isActive *bool, secret *string, description *string, userID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.UpdateWebhookSubscription")
+	defer span.End()
+
+	if id == 0 {
+		return nil, apperror.Validation("invalid_webhook_subscription_id", "invalid webhook subscription ID")
+	}
+
+	if userID == 0 {
+		return nil, apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	_, err := s.webhookRepo.GetWebhookSubscriptionModelByID(id)
+	if err != nil {
+		return nil, apperror.NotFound("webhook_subscription_not_found", "webhook subscription not found")
+	}
+
+	updateData := map[string]interface{}{
+		"user_updt": userID,
+	}
+
+	if rawURL != nil {
+		if err := validateWebhookURL(s.ctx, *rawURL); err != nil {
+			return nil, err
+		}
+		updateData["url"] = *rawURL
+	}
+
+	if eventNames != nil {
+		normalizedEvents, err := normalizeWebhookEvents(eventNames)
+		if err != nil {
+			return nil, err
+		}
+		updateData["events"] = normalizedEvents
+	}
+
+	if isActive != nil {
+		updateData["is_active"] = *isActive
+	}
+
+	if description != nil {
+		updateData["description"] = description
+	}
+
+	var rotatedSecret string
+	if secret != nil {
+		rotatedSecret, err = webhookSecret(secret)
+		if err != nil {
+			return nil, err
+		}
+		updateData["secret"] = rotatedSecret
+	}
+
+	err = s.webhookRepo.UpdateWebhookSubscription(id, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedSubscription, err := s.webhookRepo.GetWebhookSubscriptionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret != nil {
+		return WebhookSubscriptionWithSecret{WebhookSubscriptionResponse: updatedSubscription, Secret: rotatedSecret}, nil
+	}
+	return updatedSubscription, nil
+}
+
+func (s *WebhookService) DeleteWebhookSubscription(id uint, userID uint) error {
+	s, span := startSpan(s.ctx, s, "WebhookService.DeleteWebhookSubscription")
+	defer span.End()
+
+	if id == 0 {
+		return apperror.Validation("invalid_webhook_subscription_id", "invalid webhook subscription ID")
+	}
+
+	if userID == 0 {
+		return apperror.Validation("user_id_required", "user ID is required for audit trail")
+	}
+
+	_, err := s.webhookRepo.GetWebhookSubscriptionModelByID(id)
+	if err != nil {
+		return apperror.NotFound("webhook_subscription_not_found", "webhook subscription not found")
+	}
+
+	return s.webhookRepo.DeleteWebhookSubscriptionWithAudit(id, userID)
+}
+
+func (s *WebhookService) GetWebhookDeliveries(subscriptionID uint, query utils.ListQuery) (interface{}, int64, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.GetWebhookDeliveries")
+	defer span.End()
+
+	_, err := s.webhookRepo.GetWebhookSubscriptionModelByID(subscriptionID)
+	if err != nil {
+		return nil, 0, apperror.NotFound("webhook_subscription_not_found", "webhook subscription not found")
+	}
+
+	return s.webhookRepo.GetWebhookDeliveries(subscriptionID, query)
+}
+
+// SendTestWebhook queues a ping event for the subscription and delivers it right away, returning the resulting delivery
+func (s *WebhookService) SendTestWebhook(subscriptionID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.SendTestWebhook")
+	defer span.End()
+
+	subscription, err := s.webhookRepo.GetWebhookSubscriptionModelByID(subscriptionID)
+	if err != nil {
+		return nil, apperror.NotFound("webhook_subscription_not_found", "webhook subscription not found")
+	}
+
+	payload, err := webhookPayload(model.WebhookEventPing, map[string]interface{}{"webhookSubscriptionId": subscription.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	// No next attempt yet, so the worker leaves it alone while it is sent here
+	delivery := &model.WebhookDelivery{
+		WebhookSubscriptionID: subscription.ID,
+		Event:                 model.WebhookEventPing,
+		Payload:               payload,
+		Status:                model.WebhookDeliveryPending,
+	}
+	if err := s.webhookRepo.CreateWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	delivery.WebhookSubscription = subscription
+	if err := s.deliver(*delivery); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.GetWebhookDeliveryByID(delivery.ID)
+}
+
+// RedeliverWebhookDelivery puts a delivery back in the queue with a fresh set of attempts
+func (s *WebhookService) RedeliverWebhookDelivery(subscriptionID, deliveryID uint) (interface{}, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.RedeliverWebhookDelivery")
+	defer span.End()
+
+	delivery, err := s.webhookRepo.GetWebhookDeliveryModelByID(deliveryID)
+	if err != nil || delivery.WebhookSubscriptionID != subscriptionID {
+		return nil, apperror.NotFound("webhook_delivery_not_found", "webhook delivery not found")
+	}
+
+	err = s.webhookRepo.UpdateWebhookDelivery(delivery.ID, map[string]interface{}{
+		"status":          model.WebhookDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.GetWebhookDeliveryByID(delivery.ID)
+}
+
+// Publish queues event for every active subscription listening to it
+func (s *WebhookService) Publish(event string, resourceID *uint, data interface{}) error {
+	s, span := startSpan(s.ctx, s, "WebhookService.Publish")
+	defer span.End()
+
+	return s.publish(event, resourceID, data, false)
+}
+
+// PublishOnce is Publish for events that must reach each subscription only once per resource, such as batch.expiring
+func (s *WebhookService) PublishOnce(event string, resourceID uint, data interface{}) error {
+	s, span := startSpan(s.ctx, s, "WebhookService.PublishOnce")
+	defer span.End()
+
+	return s.publish(event, &resourceID, data, true)
+}
+
+func (s *WebhookService) publish(event string, resourceID *uint, data interface{}, once bool) error {
+	var dedupID *uint
+	if once {
+		dedupID = resourceID
+	}
+
+	subscriptions, err := s.webhookRepo.GetSubscriptionsForEvent(event, dedupID)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	payload, err := webhookPayload(event, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]model.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookSubscriptionID: subscription.ID,
+			Event:                 event,
+			ResourceID:            resourceID,
+			Payload:               payload,
+			Status:                model.WebhookDeliveryPending,
+			NextAttemptAt:         &now,
+		})
+	}
+
+	return s.webhookRepo.CreateWebhookDeliveries(deliveries)
+}
+
+// PublishOutboxEvent queues a relayed domain event for its subscribers; subscriptions that already have a delivery
+// for the outbox event are skipped, so relaying it again does not send it twice
+func (s *WebhookService) PublishOutboxEvent(envelope events.Envelope) error {
+	s, span := startSpan(s.ctx, s, "WebhookService.PublishOutboxEvent")
+	defer span.End()
+
+	subscriptions, err := s.webhookRepo.GetSubscriptionsForOutboxEvent(envelope.Type, envelope.ID)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookEventPayload{Event: envelope.Type, CreatedAt: envelope.OccurredAt, Data: envelope.Data})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]model.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookSubscriptionID: subscription.ID,
+			OutboxEventID:         &envelope.ID,
+			Event:                 envelope.Type,
+			ResourceID:            &envelope.AggregateID,
+			Payload:               string(payload),
+			Status:                model.WebhookDeliveryPending,
+			NextAttemptAt:         &now,
+		})
+	}
+
+	return s.webhookRepo.CreateWebhookDeliveries(deliveries)
+}
+
+// DeliverDueWebhooks sends the deliveries whose next attempt is due and returns how many were attempted
+func (s *WebhookService) DeliverDueWebhooks() (int, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.DeliverDueWebhooks")
+	defer span.End()
+
+	deliveries, err := s.webhookRepo.ClaimDueWebhookDeliveries(time.Now(), 2*WebhookRequestTimeout, WebhookDeliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, delivery := range deliveries {
+		// On shutdown the rest of the batch is left claimed, and claimed again once the lease runs out
+		if s.ctx.Err() != nil {
+			return i, nil
+		}
+		if err := s.deliver(delivery); err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// NotifyExpiringBatches publishes batch.expiring for available batches with stock that expire within the given number of days
+func (s *WebhookService) NotifyExpiringBatches(days int) (int, error) {
+	s, span := startSpan(s.ctx, s, "WebhookService.NotifyExpiringBatches")
+	defer span.End()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	batches, err := s.webhookRepo.GetExpiringBatches(today, today.AddDate(0, 0, days+1))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, batch := range batches {
+		if err := s.WithContext(tenant.ForRow(context.Background(), batch.TenantID)).PublishOnce(model.WebhookEventBatchExpiring, batch.ID, batch); err != nil {
+			return 0, err
+		}
+	}
+	return len(batches), nil
+}
+
+// deliver makes one attempt and records its outcome. An attempt cut off by shutdown is still recorded, and retried
+// like any other failure.
+func (s *WebhookService) deliver(delivery model.WebhookDelivery) error {
+	updateData := s.attempt(delivery, time.Now())
+	return s.webhookRepo.WithContext(context.WithoutCancel(s.ctx)).UpdateWebhookDelivery(delivery.ID, updateData)
+}
+
+// attempt sends delivery once and returns the changes recording the outcome: succeeded on a 2xx response, otherwise
+// retried with backoff until WebhookMaxAttempts, when it is marked failed
+func (s *WebhookService) attempt(delivery model.WebhookDelivery, now time.Time) map[string]interface{} {
+	attempts := delivery.Attempts + 1
+	updateData := map[string]interface{}{
+		"attempts":        attempts,
+		"last_attempt_at": now,
+	}
+
+	subscription := delivery.WebhookSubscription
+	var statusCode int
+	var sendErr error
+	switch {
+	case subscription.ID == 0:
+		// Preload skips soft-deleted subscriptions
+		sendErr = errors.New("webhook subscription was deleted")
+		attempts = WebhookMaxAttempts
+	case !subscription.IsActive && delivery.Event != model.WebhookEventPing:
+		sendErr = errors.New("webhook subscription is disabled")
+		attempts = WebhookMaxAttempts
+	default:
+		statusCode, sendErr = s.send(subscription, delivery, now)
+	}
+
+	if statusCode != 0 {
+		updateData["response_status"] = statusCode
+	}
+
+	if sendErr == nil {
+		updateData["status"] = model.WebhookDeliverySucceeded
+		updateData["delivered_at"] = now
+		updateData["next_attempt_at"] = nil
+		updateData["last_error"] = nil
+	} else if attempts >= WebhookMaxAttempts {
+		updateData["status"] = model.WebhookDeliveryFailed
+		updateData["next_attempt_at"] = nil
+		updateData["last_error"] = sendErr.Error()
+	} else {
+		updateData["next_attempt_at"] = now.Add(WebhookRetryDelay(attempts))
+		updateData["last_error"] = sendErr.Error()
+	}
+	return updateData
+}
+
+// send POSTs the payload with its signature headers and returns the response status; it is aborted when the
+// context of the service is done
+func (s *WebhookService) send(subscription model.WebhookSubscription, delivery model.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(s.ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "myapp-webhooks/1.0")
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", utils.SignWebhookPayload(subscription.Secret, timestamp, body))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// WebhookRetryDelay is the wait before the next attempt after the given number of failed attempts
+func WebhookRetryDelay(attempts int) time.Duration {
+	return backoffDelay(attempts, WebhookRetryBaseDelay, WebhookRetryMaxDelay)
+}
+
+func webhookPayload(event string, data interface{}) (string, error) {
+	payload, err := json.Marshal(WebhookEventPayload{Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// validateWebhookURL checks that rawURL is an http or https URL whose host only resolves to public addresses
+func validateWebhookURL(ctx context.Context, rawURL string) error {
+	if rawURL == "" {
+		return apperror.Validation("webhook_url_required", "webhook URL is required")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return apperror.Validation("invalid_webhook_url", "webhook URL must be an absolute http or https URL")
+	}
+
+	addresses, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return apperror.Validation("unresolvable_webhook_host", fmt.Sprintf("webhook host %q cannot be resolved", parsed.Hostname()))
+	}
+	for _, address := range addresses {
+		if !webhookAddressAllowed(address) {
+			return apperror.Validation("private_webhook_address", fmt.Sprintf("webhook host %q resolves to %s, which is not a public address", parsed.Hostname(), address))
+		}
+	}
+	return nil
+}
+
+// checkWebhookAddress is the Control of the webhook dialer, called with the resolved address of every connection
+func checkWebhookAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !webhookAddressAllowed(ip) {
+		return fmt.Errorf("webhook address %s is not a public address", ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, private but not covered by netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// webhookAddressAllowed reports whether webhooks may be sent to ip: loopback, link-local (cloud metadata services
+// included), private and unspecified addresses are refused unless WEBHOOK_ALLOW_PRIVATE_NETWORKS=true, for local
+// development against a stand-in subscriber
+func webhookAddressAllowed(ip netip.Addr) bool {
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true" {
+		return true
+	}
+	ip = ip.Unmap()
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsPrivate() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// normalizeWebhookEvents checks the events and joins them for storage; * subscribes to every event
+func normalizeWebhookEvents(names []string) (string, error) {
+	if len(names) == 0 {
+		return "", apperror.Validation("at_least_one_webhook_event_required", "at least one webhook event is required")
+	}
+
+	normalized := make([]string, 0, len(names))
+	for _, event := range names {
+		event = strings.TrimSpace(event)
+		if event == model.WebhookEventAll {
+			return model.WebhookEventAll, nil
+		}
+		if !slices.Contains(model.WebhookEvents, event) && !slices.Contains(events.Types, event) {
+			return "", apperror.Validation("unknown_webhook_event", fmt.Sprintf("unknown webhook event %q", event))
+		}
+		if !slices.Contains(normalized, event) {
+			normalized = append(normalized, event)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// webhookSecret returns the given secret, or a random one when none is given
+func webhookSecret(secret *string) (string, error) {
+	if secret != nil && *secret != "" {
+		if len(*secret) < 16 {
+			return "", apperror.Validation("webhook_secret_too_short", "webhook secret must be at least 16 characters")
+		}
+		return *secret, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
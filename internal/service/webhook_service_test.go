@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"io"
+	"myapp/internal/model"
+	"myapp/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "0123456789abcdef0123456789abcdef"
+
+// receivedWebhook is what the stand-in subscriber was sent
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookReceiver starts a stand-in subscriber answering status and recording the requests it gets
+func newWebhookReceiver(t *testing.T, status int) (*httptest.Server, chan receivedWebhook) {
+	t.Helper()
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	received := make(chan receivedWebhook, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedWebhook{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func testDelivery(url string, attempts int) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		ID:       42,
+		Event:    model.WebhookEventPing,
+		Payload:  `{"event":"ping","data":{"webhookSubscriptionId":7}}`,
+		Status:   model.WebhookDeliveryPending,
+		Attempts: attempts,
+		WebhookSubscription: model.WebhookSubscription{
+			ID:       7,
+			URL:      url,
+			Secret:   testWebhookSecret,
+			IsActive: true,
+		},
+	}
+}
+
+func TestWebhookAttemptSendsSignedPayload(t *testing.T) {
+	server, received := newWebhookReceiver(t, http.StatusNoContent)
+	delivery := testDelivery(server.URL, 0)
+	now := time.Now()
+
+	updateData := NewWebhookService().WithContext(context.Background()).attempt(delivery, now)
+
+	request := <-received
+	if string(request.body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", request.body, delivery.Payload)
+	}
+	if got := request.header.Get("X-Webhook-Event"); got != model.WebhookEventPing {
+		t.Errorf("X-Webhook-Event = %q, want %q", got, model.WebhookEventPing)
+	}
+	if got := request.header.Get("X-Webhook-Delivery"); got != "42" {
+		t.Errorf("X-Webhook-Delivery = %q, want 42", got)
+	}
+	timestamp, err := strconv.ParseInt(request.header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil || timestamp != now.Unix() {
+		t.Fatalf("X-Webhook-Timestamp = %q, want %d", request.header.Get("X-Webhook-Timestamp"), now.Unix())
+	}
+	if !utils.VerifyWebhookSignature(testWebhookSecret, timestamp, request.body, request.header.Get("X-Webhook-Signature"), time.Minute) {
+		t.Errorf("X-Webhook-Signature %q does not verify", request.header.Get("X-Webhook-Signature"))
+	}
+
+	if updateData["status"] != model.WebhookDeliverySucceeded {
+		t.Errorf("status = %v, want %s", updateData["status"], model.WebhookDeliverySucceeded)
+	}
+	if updateData["response_status"] != http.StatusNoContent || updateData["attempts"] != 1 {
+		t.Errorf("response_status = %v, attempts = %v, want 204 and 1", updateData["response_status"], updateData["attempts"])
+	}
+}
+
+func TestWebhookAttemptRetriesWithBackoff(t *testing.T) {
+	server, received := newWebhookReceiver(t, http.StatusInternalServerError)
+	now := time.Now()
+
+	updateData := NewWebhookService().WithContext(context.Background()).attempt(testDelivery(server.URL, 2), now)
+	<-received
+
+	if _, ok := updateData["status"]; ok {
+		t.Errorf("status = %v, want the delivery left pending", updateData["status"])
+	}
+	if updateData["attempts"] != 3 || updateData["response_status"] != http.StatusInternalServerError {
+		t.Errorf("attempts = %v, response_status = %v, want 3 and 500", updateData["attempts"], updateData["response_status"])
+	}
+	if next, want := updateData["next_attempt_at"], now.Add(4*WebhookRetryBaseDelay); next != want {
+		t.Errorf("next_attempt_at = %v, want %v", next, want)
+	}
+	if updateData["last_error"] == nil {
+		t.Error("last_error is not set")
+	}
+}
+
+func TestWebhookAttemptDeadLettersAfterMaxAttempts(t *testing.T) {
+	server, received := newWebhookReceiver(t, http.StatusBadGateway)
+
+	updateData := NewWebhookService().WithContext(context.Background()).attempt(testDelivery(server.URL, WebhookMaxAttempts-1), time.Now())
+	<-received
+
+	if updateData["status"] != model.WebhookDeliveryFailed {
+		t.Errorf("status = %v, want %s", updateData["status"], model.WebhookDeliveryFailed)
+	}
+	if next, ok := updateData["next_attempt_at"]; !ok || next != nil {
+		t.Errorf("next_attempt_at = %v, want nil", next)
+	}
+}
+
+func TestWebhookAttemptDeadLettersDeletedSubscription(t *testing.T) {
+	delivery := testDelivery("http://127.0.0.1:1", 0)
+	delivery.WebhookSubscription = model.WebhookSubscription{}
+
+	updateData := NewWebhookService().WithContext(context.Background()).attempt(delivery, time.Now())
+
+	if updateData["status"] != model.WebhookDeliveryFailed {
+		t.Errorf("status = %v, want %s", updateData["status"], model.WebhookDeliveryFailed)
+	}
+}
+
+func TestWebhookAttemptStopsWhenContextIsDone(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	updateData := NewWebhookService().WithContext(ctx).attempt(testDelivery(server.URL, 0), time.Now())
+
+	if requests.Load() != 0 {
+		t.Errorf("subscriber got %d requests after shutdown, want 0", requests.Load())
+	}
+	if updateData["next_attempt_at"] == nil || updateData["last_error"] == nil {
+		t.Errorf("update = %v, want the attempt scheduled for a retry", updateData)
+	}
+}
+
+func TestWebhookAttemptRefusesPrivateAddresses(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	updateData := NewWebhookService().WithContext(context.Background()).attempt(testDelivery(server.URL, 0), time.Now())
+
+	if requests.Load() != 0 {
+		t.Errorf("subscriber on %s got %d requests, want 0", server.URL, requests.Load())
+	}
+	if updateData["last_error"] == nil {
+		t.Errorf("update = %v, want the refused connection recorded", updateData)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	for rawURL, valid := range map[string]bool{
+		"https://93.184.215.14/hooks":    true,
+		"http://127.0.0.1:9000/":         false,
+		"http://localhost:9000/":         false,
+		"http://169.254.169.254/latest/": false,
+		"http://10.0.0.5/":               false,
+		"http://192.168.1.10/":           false,
+		"http://100.64.0.1/":             false,
+		"http://[::1]/":                  false,
+		"http://[::ffff:127.0.0.1]/":     false,
+		"http://0.0.0.0/":                false,
+		"ftp://93.184.215.14/":           false,
+		"/relative":                      false,
+	} {
+		if err := validateWebhookURL(context.Background(), rawURL); (err == nil) != valid {
+			t.Errorf("validateWebhookURL(%q) = %v, want valid %v", rawURL, err, valid)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  WebhookRetryBaseDelay,
+		2:  2 * WebhookRetryBaseDelay,
+		5:  16 * WebhookRetryBaseDelay,
+		20: WebhookRetryMaxDelay,
+	} {
+		if got := WebhookRetryDelay(attempts); got != want {
+			t.Errorf("WebhookRetryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package tenant
+
+import (
+	"reflect"
+	"regexp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Column is the column holding the tenant of a row
+const Column = "tenant_id"
+
+// scopedSetting marks a statement that already has the tenant condition, since a statement can run more than once (Count then Find)
+const scopedSetting = "tenant:scoped"
+
+// tableExpr matches the table given to db.Table, with an optional alias: products, "products" or product_stocks ps
+var tableExpr = regexp.MustCompile(`(?i)^\s*"?(\w+)"?(?:\s+(?:as\s+)?"?(\w+)"?)?\s*$`)
+
+// Plugin scopes GORM statements to the tenant of their context: every query, update and delete on a tenant table
+// gets a tenant_id condition, and created rows get the tenant_id. Statements without a tenant are left alone, which is
+// how background jobs work across tenants. Raw SQL is not rewritten and has to filter on tenant_id itself.
+type Plugin struct {
+	models []interface{}
+	tables map[string]bool
+}
+
+// NewPlugin returns a plugin for models, which must all have a TenantID field
+func NewPlugin(models ...interface{}) *Plugin {
+	return &Plugin{models: models, tables: make(map[string]bool)}
+}
+
+func (p *Plugin) Name() string {
+	return "tenant"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	for _, model := range p.models {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			return err
+		}
+		p.tables[statement.Schema.Table] = true
+	}
+
+	callback := db.Callback()
+	if err := callback.Create().Before("gorm:create").Register("tenant:assign", p.assign); err != nil {
+		return err
+	}
+	if err := callback.Query().Before("gorm:query").Register("tenant:scope", p.scope); err != nil {
+		return err
+	}
+	if err := callback.Row().Before("gorm:row").Register("tenant:scope", p.scope); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("tenant:scope", p.scope); err != nil {
+		return err
+	}
+	return callback.Delete().Before("gorm:delete").Register("tenant:scope", p.scope)
+}
+
+// scope adds "<table or alias>.tenant_id = ?" to statements on tenant tables
+func (p *Plugin) scope(db *gorm.DB) {
+	statement := db.Statement
+	if db.Error != nil || statement.SQL.Len() > 0 {
+		return
+	}
+	id, ok := FromContext(statement.Context)
+	if !ok {
+		return
+	}
+	if _, done := statement.Settings.Load(scopedSetting); done {
+		return
+	}
+
+	qualifier := p.qualifier(statement)
+	if qualifier == "" {
+		return
+	}
+
+	statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: qualifier, Name: Column}, Value: id},
+	}})
+	statement.Settings.Store(scopedSetting, true)
+}
+
+// qualifier returns the name the statement's table is referred to by, or "" when it is not a tenant table
+func (p *Plugin) qualifier(statement *gorm.Statement) string {
+	table := statement.Table
+	if statement.TableExpr != nil {
+		match := tableExpr.FindStringSubmatch(statement.TableExpr.SQL)
+		if match == nil {
+			return ""
+		}
+		table = match[1]
+	} else if table == "" && statement.Schema != nil {
+		table = statement.Schema.Table
+	}
+
+	if !p.tables[table] {
+		return ""
+	}
+	if statement.Table != "" {
+		return statement.Table
+	}
+	return table
+}
+
+// assign sets TenantID on created rows that do not have one yet
+func (p *Plugin) assign(db *gorm.DB) {
+	statement := db.Statement
+	if db.Error != nil || statement.Schema == nil {
+		return
+	}
+	id, ok := FromContext(statement.Context)
+	if !ok {
+		return
+	}
+	field := statement.Schema.LookUpField("TenantID")
+	if field == nil {
+		return
+	}
+
+	setTenant := func(row reflect.Value) {
+		if _, zero := field.ValueOf(statement.Context, row); zero {
+			db.AddError(field.Set(statement.Context, row, id))
+		}
+	}
+
+	switch value := reflect.Indirect(statement.ReflectValue); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			setTenant(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		setTenant(value)
+	}
+}
@@ -0,0 +1,32 @@
+// Package tenant carries the tenant of a request through context.Context and scopes GORM queries to it.
+package tenant
+
+import "context"
+
+// HeaderName is the request header platform users set to choose the tenant they act on
+const HeaderName = "X-Tenant-ID"
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx that belongs to tenant id
+func WithID(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ctx belongs to, if any
+func FromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	id, ok := ctx.Value(contextKey{}).(uint)
+	return id, ok && id != 0
+}
+
+// ForRow returns ctx scoped to the tenant a row belongs to, for background jobs that work across tenants;
+// ctx is returned unchanged for rows without a tenant
+func ForRow(ctx context.Context, id *uint) context.Context {
+	if id == nil || *id == 0 {
+		return ctx
+	}
+	return WithID(ctx, *id)
+}
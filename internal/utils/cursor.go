@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultCursorLimit = 20
+	MaxCursorLimit     = 100
+	ExportBatchSize    = 500
+)
+
+// TrackCursor is the keyset position of a track row, ordered by (date, id)
+type TrackCursor struct {
+	Date time.Time `json:"d"`
+	ID   uint      `json:"i"`
+}
+
+// CursorQuery holds the parsed "cursor" and "limit" query parameters
+type CursorQuery struct {
+	After *TrackCursor
+	Limit int
+}
+
+// EncodeCursor returns the opaque string handed to clients as next_cursor
+func EncodeCursor(cursor TrackCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor; an empty string means "start from the beginning"
+func DecodeCursor(value string) (*TrackCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidListQuery("malformed cursor")
+	}
+
+	var cursor TrackCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, invalidListQuery("malformed cursor")
+	}
+	return &cursor, nil
+}
+
+// ParseCursorQuery builds a CursorQuery from query string values (as returned by fiber's c.Queries())
+func ParseCursorQuery(params map[string]string) (CursorQuery, error) {
+	query := CursorQuery{Limit: DefaultCursorLimit}
+
+	if limit, ok := params["limit"]; ok && limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return query, invalidListQuery("limit must be a positive integer")
+		}
+		if value > MaxCursorLimit {
+			value = MaxCursorLimit
+		}
+		query.Limit = value
+	}
+
+	after, err := DecodeCursor(params["cursor"])
+	if err != nil {
+		return query, err
+	}
+	query.After = after
+
+	return query, nil
+}
+
+// ApplyKeyset restricts db to rows strictly after cursor in (date, id) order and sorts accordingly.
+// Newest-first (descending) is used for browsing, ascending for chronological exports.
+// limit is applied as-is; callers ask for one extra row to detect whether another page exists.
+func ApplyKeyset(db *gorm.DB, dateColumn, idColumn string, cursor *TrackCursor, limit int, ascending bool) *gorm.DB {
+	direction, comparator := "DESC", "<"
+	if ascending {
+		direction, comparator = "ASC", ">"
+	}
+
+	if cursor != nil {
+		db = db.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", dateColumn, idColumn, comparator), cursor.Date, cursor.ID)
+	}
+
+	return db.
+		Order(fmt.Sprintf("%s %s", dateColumn, direction)).
+		Order(fmt.Sprintf("%s %s", idColumn, direction)).
+		Limit(limit)
+}
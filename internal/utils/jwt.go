@@ -10,20 +10,25 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID   uint   `json:"user_id"`
+	Email    string `json:"email"`
+	TenantID *uint  `json:"tenant_id,omitempty"` // Tenant of the user
+	Platform bool   `json:"platform,omitempty"`  // Set for platform users, who have no tenant
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID uint, email string) (string, error) {
+// GenerateJWT issues a token for a user of tenantID, or for a platform user when tenantID is nil
+func GenerateJWT(userID uint, email string, tenantID *uint) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		return "", errors.New("JWT_SECRET not found")
 	}
 
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:   userID,
+		Email:    email,
+		TenantID: tenantID,
+		Platform: tenantID == nil,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
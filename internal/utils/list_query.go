@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"fmt"
+	"myapp/pkg/apperror"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPage     = 1
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// MaxExportRows caps the number of rows a single list export may return
+	MaxExportRows = 50000
+)
+
+// ErrInvalidListQuery is returned when page, page_size, sort or a filter operator cannot be parsed
+var ErrInvalidListQuery = apperror.New(http.StatusBadRequest, "invalid_list_query", "invalid list query")
+
+// invalidListQuery is ErrInvalidListQuery with what was wrong, still matching it in errors.Is
+func invalidListQuery(format string, args ...interface{}) error {
+	return apperror.New(ErrInvalidListQuery.Status, ErrInvalidListQuery.Code, ErrInvalidListQuery.Message+": "+fmt.Sprintf(format, args...))
+}
+
+// filterOperators maps the operator accepted in "field[op]=value" to its SQL form
+var filterOperators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "ILIKE",
+	"in":   "IN",
+}
+
+// reservedListParams are query parameters that are never treated as filters
+var reservedListParams = map[string]bool{
+	"page":      true,
+	"page_size": true,
+	"sort":      true,
+	"cursor":    true,
+	"limit":     true,
+	"format":    true,
+}
+
+var filterKeyPattern = regexp.MustCompile(`^([a-z][a-z0-9_]*)(?:\[([a-z]+)\])?$`)
+
+// numericListFields are the decimal fields of the lists; ids, dates and booleans are told apart by their names
+var numericListFields = map[string]bool{
+	"quantity":          true,
+	"stock":             true,
+	"stock_in":          true,
+	"stock_out":         true,
+	"unit_price":        true,
+	"unit_price_retail": true,
+	"min_quantity":      true,
+	"reorder_point":     true,
+	"max_quantity":      true,
+	"on_hand":           true,
+	"attempts":          true,
+	"response_status":   true,
+}
+
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+// filterDateLayouts are the date and time forms accepted in filters on *_at and date fields
+var filterDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// likeEscaper escapes the wildcards of ILIKE so a like filter matches its value literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SortField is a single "sort" entry, e.g. "-created_at" becomes {Field: "created_at", Desc: true}
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// FilterField is a single filter parsed from "field=value" or "field[op]=value"
+type FilterField struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// ListQuery holds pagination, sorting and filtering options for list endpoints.
+// The zero value means "no pagination, no filters, default order".
+type ListQuery struct {
+	Page     int
+	PageSize int
+	Sorts    []SortField
+	Filters  []FilterField
+}
+
+// ListColumns maps the public field names of a list endpoint to the SQL columns used for filtering and sorting
+type ListColumns map[string]string
+
+// ParseListQuery builds a ListQuery from query string values (as returned by fiber's c.Queries())
+func ParseListQuery(params map[string]string) (ListQuery, error) {
+	query := ListQuery{
+		Page:     DefaultPage,
+		PageSize: DefaultPageSize,
+	}
+
+	if page, ok := params["page"]; ok && page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil || value < 1 {
+			return query, invalidListQuery("page must be a positive integer")
+		}
+		query.Page = value
+	}
+
+	if pageSize, ok := params["page_size"]; ok && pageSize != "" {
+		value, err := strconv.Atoi(pageSize)
+		if err != nil || value < 1 {
+			return query, invalidListQuery("page_size must be a positive integer")
+		}
+		if value > MaxPageSize {
+			value = MaxPageSize
+		}
+		query.PageSize = value
+	}
+
+	if sortParam, ok := params["sort"]; ok && sortParam != "" {
+		for _, part := range strings.Split(sortParam, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			field := SortField{Field: part}
+			if strings.HasPrefix(part, "-") {
+				field = SortField{Field: strings.TrimPrefix(part, "-"), Desc: true}
+			}
+			query.Sorts = append(query.Sorts, field)
+		}
+	}
+
+	// Iterate keys in a stable order so the generated SQL (and cache keys) are deterministic
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedListParams[key] {
+			continue
+		}
+
+		match := filterKeyPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		operator := match[2]
+		if operator == "" {
+			operator = "eq"
+		}
+		if _, ok := filterOperators[operator]; !ok {
+			return query, invalidListQuery("unsupported filter operator %q on %s", operator, match[1])
+		}
+
+		filter := FilterField{
+			Field:    match[1],
+			Operator: operator,
+			Value:    params[key],
+		}
+		if err := filter.validate(); err != nil {
+			return query, err
+		}
+		query.Filters = append(query.Filters, filter)
+	}
+
+	return query, nil
+}
+
+// validate checks that the value of a filter fits the type of its field, so a value the database cannot cast, such
+// as id[gt]=abc, is a 400 instead of a failed query. like compares text, so any value goes.
+func (f FilterField) validate() error {
+	if f.Operator == "like" {
+		return nil
+	}
+	values := []string{f.Value}
+	if f.Operator == "in" {
+		values = strings.Split(f.Value, ",")
+	}
+	for _, value := range values {
+		if !filterValueValid(f.Field, value) {
+			return invalidListQuery("invalid value %q for %s", value, f.Field)
+		}
+	}
+	return nil
+}
+
+// filterValueValid tells whether value can be compared with field, going by the names the lists give their fields:
+// id and *_id are integers, *_at and *date are dates, is_* are booleans, numericListFields are decimals and the
+// rest are text
+func filterValueValid(field, value string) bool {
+	switch {
+	case field == "id" || strings.HasSuffix(field, "_id"):
+		if !integerPattern.MatchString(value) {
+			return false
+		}
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case strings.HasSuffix(field, "_at") || strings.HasSuffix(field, "date"):
+		for _, layout := range filterDateLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	case strings.HasPrefix(field, "is_"):
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case numericListFields[field]:
+		return decimalPattern.MatchString(value)
+	}
+	return true
+}
+
+// IsPaginated reports whether LIMIT/OFFSET should be applied
+func (q ListQuery) IsPaginated() bool {
+	return q.PageSize > 0
+}
+
+// ForExport returns a copy that keeps filters and sorting but returns up to MaxExportRows rows in a single page
+func (q ListQuery) ForExport() ListQuery {
+	q.Page = 1
+	q.PageSize = MaxExportRows
+	return q
+}
+
+// Offset returns the number of rows to skip for the current page
+func (q ListQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.PageSize
+}
+
+// CacheKey returns a deterministic representation of the query, suitable as a Redis key suffix
+func (q ListQuery) CacheKey() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "p=%d:s=%d", q.Page, q.PageSize)
+	for _, s := range q.Sorts {
+		fmt.Fprintf(&b, ":o=%s/%t", s.Field, s.Desc)
+	}
+	for _, f := range q.Filters {
+		fmt.Fprintf(&b, ":f=%s/%s/%s", f.Field, f.Operator, f.Value)
+	}
+	return b.String()
+}
+
+// ApplyFilters adds a WHERE condition for every filter whose field is known in columns.
+// Filters on unknown fields are ignored so list endpoints can share the query string with other parameters.
+func (q ListQuery) ApplyFilters(db *gorm.DB, columns ListColumns) *gorm.DB {
+	for _, filter := range q.Filters {
+		column, ok := columns[filter.Field]
+		if !ok {
+			continue
+		}
+
+		operator := filterOperators[filter.Operator]
+		switch filter.Operator {
+		case "in":
+			values := strings.Split(filter.Value, ",")
+			db = db.Where(fmt.Sprintf("%s IN ?", column), values)
+		case "like":
+			db = db.Where(fmt.Sprintf(`%s::text ILIKE ? ESCAPE '\'`, column), "%"+likeEscaper.Replace(filter.Value)+"%")
+		default:
+			db = db.Where(fmt.Sprintf("%s %s ?", column, operator), filter.Value)
+		}
+	}
+	return db
+}
+
+// ApplySort adds the requested ORDER BY clauses, falling back to defaultOrder when none were requested.
+// The primary key (columns["id"]) is always appended as a tie-breaker to keep page boundaries stable; it follows
+// the direction of the primary sort, so rows with equal sort values read in the same direction as the rest.
+func (q ListQuery) ApplySort(db *gorm.DB, columns ListColumns, defaultOrder string) (*gorm.DB, error) {
+	desc := defaultOrderIsDesc(defaultOrder)
+	if len(q.Sorts) == 0 {
+		db = db.Order(defaultOrder)
+	} else {
+		desc = q.Sorts[0].Desc
+	}
+
+	for _, s := range q.Sorts {
+		column, ok := columns[s.Field]
+		if !ok {
+			return db, invalidListQuery("cannot sort by %s", s.Field)
+		}
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		db = db.Order(fmt.Sprintf("%s %s", column, direction))
+	}
+
+	if idColumn, ok := columns["id"]; ok {
+		direction := "ASC"
+		if desc {
+			direction = "DESC"
+		}
+		db = db.Order(idColumn + " " + direction)
+	}
+	return db, nil
+}
+
+// defaultOrderIsDesc tells whether the first clause of an ORDER BY, such as "pst.date DESC, id DESC", is descending
+func defaultOrderIsDesc(order string) bool {
+	first, _, _ := strings.Cut(order, ",")
+	fields := strings.Fields(first)
+	return len(fields) > 1 && strings.EqualFold(fields[len(fields)-1], "DESC")
+}
+
+// ApplyPagination adds LIMIT/OFFSET when the query is paginated
+func (q ListQuery) ApplyPagination(db *gorm.DB) *gorm.DB {
+	if !q.IsPaginated() {
+		return db
+	}
+	return db.Limit(q.PageSize).Offset(q.Offset())
+}
+
+// FindPage applies filters, counts the matching rows, then loads the requested page into dest.
+// db must already contain the table, joins, select and base conditions of the list.
+func FindPage(db *gorm.DB, q ListQuery, columns ListColumns, defaultOrder string, dest interface{}) (int64, error) {
+	filtered := q.ApplyFilters(db, columns).Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	ordered, err := q.ApplySort(filtered, columns, defaultOrder)
+	if err != nil {
+		return 0, err
+	}
+
+	return total, q.ApplyPagination(ordered).Find(dest).Error
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MaxTableRows limits the number of data rows accepted in a single uploaded file
+const MaxTableRows = 10000
+
+// ErrUnsupportedTableFormat is returned when an uploaded file is neither CSV nor XLSX
+var ErrUnsupportedTableFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+
+// TableRow is one data row keyed by normalized header name; Line is the row number in the file (header is line 1)
+type TableRow struct {
+	Line   int
+	Values map[string]string
+}
+
+// Table is a parsed CSV/XLSX sheet: normalized header names and the non-empty data rows below them
+type Table struct {
+	Headers []string
+	Rows    []TableRow
+}
+
+// HasColumn reports whether the header contains column
+func (t Table) HasColumn(column string) bool {
+	for _, header := range t.Headers {
+		if header == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadTable parses a CSV or XLSX (first sheet) upload, choosing the format from the file extension
+func ReadTable(filename string, r io.Reader) (Table, error) {
+	var records [][]string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		records, err = readCSVRecords(r)
+	case ".xlsx":
+		records, err = readXLSXRecords(r)
+	default:
+		return Table{}, ErrUnsupportedTableFormat
+	}
+	if err != nil {
+		return Table{}, err
+	}
+
+	return buildTable(records)
+}
+
+func readCSVRecords(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader.ReadAll()
+}
+
+func readXLSXRecords(r io.Reader) ([][]string, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	return file.GetRows(sheets[0])
+}
+
+// normalizeHeader turns "Code Batch" / "code-batch" into "code_batch"
+func normalizeHeader(header string) string {
+	header = strings.TrimPrefix(header, "\uFEFF") // Excel adds a BOM to UTF-8 CSV files
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(header)
+}
+
+func buildTable(records [][]string) (Table, error) {
+	if len(records) == 0 {
+		return Table{}, errors.New("file is empty")
+	}
+
+	table := Table{}
+	for _, header := range records[0] {
+		table.Headers = append(table.Headers, normalizeHeader(header))
+	}
+
+	for i, record := range records[1:] {
+		values := make(map[string]string, len(table.Headers))
+		empty := true
+		for col, header := range table.Headers {
+			if header == "" || col >= len(record) {
+				continue
+			}
+			value := strings.TrimSpace(record[col])
+			if value != "" {
+				empty = false
+			}
+			values[header] = value
+		}
+		if empty {
+			continue
+		}
+
+		table.Rows = append(table.Rows, TableRow{Line: i + 2, Values: values})
+		if len(table.Rows) > MaxTableRows {
+			return Table{}, fmt.Errorf("file has more than %d rows", MaxTableRows)
+		}
+	}
+
+	return table, nil
+}
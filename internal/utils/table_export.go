@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/xuri/excelize/v2"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// exportField is a column of an exported list: the JSON name of a struct field and how to reach it
+type exportField struct {
+	name  string
+	index []int
+}
+
+// FlattenRows turns a slice of structs (as returned by the list services) into a header and string rows.
+// Columns follow the JSON names of the fields; nested relations, slices and fields tagged json:"-" are left out.
+func FlattenRows(data interface{}) ([]string, [][]string, error) {
+	value := reflect.ValueOf(data)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice {
+		return nil, nil, errors.New("export data must be a list")
+	}
+
+	elemType := value.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, nil, errors.New("export data must be a list of records")
+	}
+
+	fields := exportFields(elemType, nil)
+	headers := make([]string, len(fields))
+	for i, field := range fields {
+		headers[i] = field.name
+	}
+
+	rows := make([][]string, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		item := reflect.Indirect(value.Index(i))
+		row := make([]string, len(fields))
+		for j, field := range fields {
+			row[j] = formatCell(item.FieldByIndex(field.index))
+		}
+		rows = append(rows, row)
+	}
+
+	return headers, rows, nil
+}
+
+func exportFields(t reflect.Type, parent []int) []exportField {
+	var fields []exportField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+
+		// Embedded structs such as gorm.Model contribute their own columns
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, exportFields(field.Type, index)...)
+			continue
+		}
+
+		if !isExportableType(field.Type) {
+			continue
+		}
+		fields = append(fields, exportField{name: name, index: index})
+	}
+	return fields
+}
+
+func isExportableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t.Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	}
+	return true
+}
+
+func formatCell(value reflect.Value) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case driver.Valuer:
+		inner, err := v.Value()
+		if err != nil || inner == nil {
+			return ""
+		}
+		return formatCell(reflect.ValueOf(inner))
+	}
+
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// TableSection is a titled table; multi-section exports write one XLSX sheet or one PDF block per section
+type TableSection struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+}
+
+// WriteCSV writes headers and rows as CSV
+func WriteCSV(w io.Writer, headers []string, rows [][]string) error {
+	return WriteCSVSections(w, []TableSection{{Headers: headers, Rows: rows}})
+}
+
+// WriteCSVSections writes each section as its own block (title line, header, rows) separated by an empty line
+func WriteCSVSections(w io.Writer, sections []TableSection) error {
+	writer := csv.NewWriter(w)
+	for i, section := range sections {
+		if i > 0 {
+			if err := writer.Write([]string{}); err != nil {
+				return err
+			}
+		}
+		if section.Title != "" {
+			if err := writer.Write([]string{section.Title}); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(section.Headers); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(section.Rows); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteXLSX writes headers and rows as a single-sheet workbook, numbers are stored as numeric cells
+func WriteXLSX(w io.Writer, sheet string, headers []string, rows [][]string) error {
+	return WriteXLSXSections(w, []TableSection{{Title: sheet, Headers: headers, Rows: rows}})
+}
+
+// WriteXLSXSections writes one sheet per section, named after the section title
+func WriteXLSXSections(w io.Writer, sections []TableSection) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	bold, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	for i, section := range sections {
+		if i == 0 {
+			err = file.SetSheetName("Sheet1", section.Title)
+		} else {
+			_, err = file.NewSheet(section.Title)
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeXLSXSheet(file, section, bold); err != nil {
+			return err
+		}
+	}
+
+	return file.Write(w)
+}
+
+func writeXLSXSheet(file *excelize.File, section TableSection, headerStyle int) error {
+	stream, err := file.NewStreamWriter(section.Title)
+	if err != nil {
+		return err
+	}
+
+	headerRow := make([]interface{}, len(section.Headers))
+	for i, header := range section.Headers {
+		headerRow[i] = excelize.Cell{StyleID: headerStyle, Value: header}
+	}
+	if err := stream.SetRow("A1", headerRow); err != nil {
+		return err
+	}
+
+	for i, row := range section.Rows {
+		cells := make([]interface{}, len(row))
+		for j, value := range row {
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				cells[j] = number
+			} else {
+				cells[j] = value
+			}
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := stream.SetRow(cell, cells); err != nil {
+			return err
+		}
+	}
+
+	return stream.Flush()
+}
+
+// WritePDF renders headers and rows as a printable landscape table, repeating the header on every page
+func WritePDF(w io.Writer, title string, headers []string, rows [][]string) error {
+	summary := []string{fmt.Sprintf("Generated %s - %d rows", time.Now().Format("2006-01-02 15:04"), len(rows))}
+	return WritePDFDocument(w, title, summary, []TableSection{{Headers: headers, Rows: rows}})
+}
+
+// WritePDFDocument renders a landscape document: a title, summary lines, then every section as a titled table.
+// A section's column header is repeated when its table continues on a new page.
+func WritePDFDocument(w io.Writer, title string, summary []string, sections []TableSection) error {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 10)
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	printable := pageWidth - left - right
+
+	const lineHeight = 6.0
+	var current *TableSection
+	var widths []float64
+
+	printHeader := func() {
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetFillColor(230, 230, 230)
+		for i, header := range current.Headers {
+			pdf.CellFormat(widths[i], lineHeight, translate(fitText(pdf, header, widths[i])), "1", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 8)
+	}
+
+	// Repeat the column header of the table being printed when it overflows onto a new page
+	pdf.SetHeaderFunc(func() {
+		if current != nil {
+			printHeader()
+		}
+	})
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 9, translate(title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range summary {
+		pdf.CellFormat(0, 5, translate(line), "", 1, "L", false, 0, "")
+	}
+
+	for i := range sections {
+		section := &sections[i]
+		pdf.Ln(4)
+		if section.Title != "" {
+			pdf.SetFont("Helvetica", "B", 11)
+			pdf.CellFormat(0, 7, translate(section.Title), "", 1, "L", false, 0, "")
+		}
+
+		current, widths = section, pdfColumnWidths(section.Headers, section.Rows, printable)
+		printHeader()
+		for _, row := range section.Rows {
+			for j, value := range row {
+				pdf.CellFormat(widths[j], lineHeight, translate(fitText(pdf, value, widths[j])), "1", 0, "L", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+		current = nil
+	}
+
+	return pdf.Output(w)
+}
+
+// pdfColumnWidths shares the printable width between columns, proportionally to their longest value (capped)
+func pdfColumnWidths(headers []string, rows [][]string, total float64) []float64 {
+	const minChars, maxChars = 4, 40
+
+	lengths := make([]float64, len(headers))
+	sum := 0.0
+	for i, header := range headers {
+		longest := len(header)
+		for _, row := range rows {
+			if len(row[i]) > longest {
+				longest = len(row[i])
+			}
+		}
+		if longest < minChars {
+			longest = minChars
+		}
+		if longest > maxChars {
+			longest = maxChars
+		}
+		lengths[i] = float64(longest)
+		sum += lengths[i]
+	}
+
+	widths := make([]float64, len(headers))
+	for i := range lengths {
+		widths[i] = total * lengths[i] / sum
+	}
+	return widths
+}
+
+// fitText shortens text with an ellipsis so it fits in a cell of the given width
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	const padding = 2.0
+	if pdf.GetStringWidth(text) <= width-padding {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
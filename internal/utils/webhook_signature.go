@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignaturePrefix is prepended to the hex digest in the X-Webhook-Signature header
+const WebhookSignaturePrefix = "sha256="
+
+// SignWebhookPayload returns the X-Webhook-Signature value for a payload sent at timestamp (unix seconds):
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the subscription secret
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return WebhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a received signature and rejects timestamps older than tolerance (0 skips the age check)
+func VerifyWebhookSignature(secret string, timestamp int64, payload []byte, signature string, tolerance time.Duration) bool {
+	if tolerance > 0 && time.Since(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return false
+	}
+	if !strings.HasPrefix(signature, WebhookSignaturePrefix) {
+		return false
+	}
+	expected := SignWebhookPayload(secret, timestamp, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -1,48 +1,128 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"myapp/database"
+	"myapp/internal/cli"
+	"myapp/internal/jobs"
+	"myapp/internal/metrics"
+	"myapp/internal/middleware"
+	"myapp/internal/realtime"
 	"myapp/internal/routes"
+	"myapp/pkg/helper"
+	"myapp/pkg/logger"
 	"myapp/pkg/redis"
+	"myapp/pkg/tracing"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 )
 
+// DefaultShutdownTimeout bounds the shutdown when SHUTDOWN_TIMEOUT is not set
+const DefaultShutdownTimeout = 30 * time.Second
+
 func main() {
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+		slog.Info("No .env file found")
+	}
+	logger.Init()
+
+	// go run . <command> runs an operational command instead of serving, see cli.Usage
+	command, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+	if err := run(command, args); err != nil {
+		var usageErr *cli.UsageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintln(os.Stderr, usageErr.Error())
+			os.Exit(2)
+		}
+		fatal("Command "+command+" failed", err)
+	}
+}
+
+// run runs command with its arguments
+func run(command string, args []string) error {
+	switch command {
+	case "serve":
+		if len(args) > 0 {
+			return &cli.UsageError{Problem: "serve takes no arguments", Usage: cli.Usage}
+		}
+		serve()
+		return nil
+	case "migrate":
+		return cli.Migrate(args)
+	case "seed":
+		return cli.Seed(args)
+	case "user":
+		return cli.User(args)
+	case "cache":
+		return cli.Cache(args)
+	case "help", "-h", "--help":
+		return &cli.UsageError{Usage: cli.Usage}
+	}
+	return &cli.UsageError{Problem: fmt.Sprintf("unknown command %q", command), Usage: cli.Usage}
+}
+
+// serve runs the API server and the background jobs until SIGINT or SIGTERM. It neither migrates nor seeds: the
+// schema is changed with migrate and seed data inserted with seed.
+func serve() {
+	// Spans of requests, service methods, queries and Redis commands go to the exporter in OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		fatal("Tracing setup error", err)
 	}
 
 	// 1. Koneksi DB
 	if err := database.ConnectDB(); err != nil {
-		log.Fatal("DB connection error: ", err)
+		fatal("DB connection error", err)
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
 	}
 
 	// 2. Koneksi Redis
 	if err := redis.InitRedis(); err != nil {
-		log.Printf("Redis connection warning: %v", err)
-		log.Println("Application will continue without Redis caching")
+		slog.Warn("Redis connection failed, the application will continue without Redis caching", "error", err)
 	}
 
-	// 3. Migration
-	if err := database.Migrate(); err != nil {
-		log.Fatal("Migration error: ", err)
+	// 3. Refuse to serve a schema behind the migrations of this build
+	if err := database.CheckMigrations(context.Background()); err != nil {
+		fatal("Migration check error", err)
 	}
 
-	// 4. Seeder
-	if err := database.Seed(); err != nil {
-		log.Fatal("Seed error: ", err)
-	}
+	// SIGINT or SIGTERM (a deploy) stops the background jobs and starts the shutdown below
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	app := fiber.New()
+	// 4. Background jobs
+	workers := jobs.NewManager(ctx)
+	jobs.StartReplenishmentJob(workers)
+	jobs.StartWebhookJobs(workers)
+	jobs.StartOutboxRelay(workers)
+	jobs.StartIdempotencyCleanup(workers)
+	realtime.StockHub.Run(ctx)
 
-	app.Use(logger.New())
-	app.Use(cors.New())
+	// Errors handlers return are answered by helper.ErrorHandler from their typed apperror status and code
+	app := fiber.New(fiber.Config{ErrorHandler: helper.ErrorHandler})
+
+	// Request IDs, request scoped log attributes and one access log line per request
+	app.Use(middleware.RequestLogger())
+	// A server span per request, the parent of the spans its handler starts
+	app.Use(middleware.Tracing())
+	// Latency and status of every request by route, served on /metrics
+	app.Use(middleware.Metrics())
+	// Browsers may only read the ETag a PUT or DELETE sends back in If-Match, and the request ID, when they are exposed
+	app.Use(cors.New(cors.Config{ExposeHeaders: fiber.HeaderETag + ", " + fiber.HeaderXRequestID}))
 
 	// Setup all routes
 	routes.SetupRoutes(app)
@@ -52,6 +132,67 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("🚀 Server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+	slog.Info("Server starting", "port", port)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.Listen(":" + port)
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal("Server error", err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process instead of waiting for the shutdown
+	stop()
+	shutdown(app, workers, shutdownTracing)
+}
+
+// shutdown lets the requests in flight, such as stock movements, and the job runs in progress finish, then flushes
+// the traces and closes Redis and the database pool. Whatever still runs after SHUTDOWN_TIMEOUT is cut off.
+func shutdown(app *fiber.App, workers *jobs.Manager, shutdownTracing func(context.Context) error) {
+	timeout := shutdownTimeout()
+	slog.Info("Shutting down", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stock streams never end by themselves and would hold the server until the deadline
+	realtime.StockHub.Close()
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		slog.Warn("Draining connections failed", "error", err)
+	}
+	if err := workers.Wait(ctx); err != nil {
+		slog.Warn("Jobs still running at the deadline", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Flushing traces failed", "error", err)
+	}
+	if err := redis.Close(); err != nil {
+		slog.Warn("Closing Redis failed", "error", err)
+	}
+	if err := database.Close(); err != nil {
+		slog.Warn("Closing database failed", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT as a Go duration, such as 30s
+func shutdownTimeout() time.Duration {
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return DefaultShutdownTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		slog.Warn("Invalid SHUTDOWN_TIMEOUT, using the default", "value", value, "default", DefaultShutdownTimeout.String())
+		return DefaultShutdownTimeout
+	}
+	return timeout
+}
+
+// fatal logs err and exits, for errors the server cannot start with
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,90 @@
+// Package apperror defines the typed errors services return to handlers. Each error carries the HTTP status it is
+// answered with, a stable machine readable code clients can switch on, a human readable message and optional
+// details, so handlers and clients no longer depend on the wording of messages.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is a domain error answered with Status
+type Error struct {
+	Status  int         // HTTP status of the response
+	Code    string      // Stable machine readable code, e.g. product_unit_not_found
+	Message string      // Human readable message, free to change
+	Details interface{} // Optional structured details, e.g. the conflicting constraint
+	Err     error       // Optional cause, kept for logs and errors.Is/As
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so a sentinel still matches after WithDetails or Wrap copied it
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e with details
+func (e *Error) WithDetails(details interface{}) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Wrap returns a copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// New returns an error answered with status
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// NotFound is a missing resource (404)
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict is a request that clashes with the current state, such as a duplicate name or a held stock (409)
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// Validation is a request whose values break a business rule (422)
+func Validation(code, message string) *Error {
+	return New(http.StatusUnprocessableEntity, code, message)
+}
+
+// Forbidden is a request the user is not allowed to make (403)
+func Forbidden(code, message string) *Error {
+	return New(http.StatusForbidden, code, message)
+}
+
+// Unauthorized is a request without valid credentials (401)
+func Unauthorized(code, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+// PreconditionFailed is a request whose If-Match precondition no longer holds (412)
+func PreconditionFailed(code, message string) *Error {
+	return New(http.StatusPreconditionFailed, code, message)
+}
+
+// As returns the Error in err's chain, if any
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
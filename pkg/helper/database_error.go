@@ -1,3 +1,76 @@
 package helper
 
-// This file is intentionally empty - database error handling moved to individual handlers
+import (
+	"errors"
+	"myapp/pkg/apperror"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// PostgreSQL error codes mapped to client errors, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation        = "23505"
+	pgForeignKeyViolation    = "23503"
+	pgNotNullViolation       = "23502"
+	pgCheckViolation         = "23514"
+	pgStringDataTruncation   = "22001"
+	pgInvalidTextInput       = "22P02"
+	pgSerializationFailure   = "40001"
+	pgDeadlockDetected       = "40P01"
+	pgNumericValueOutOfRange = "22003"
+)
+
+// DatabaseError maps a GORM or PostgreSQL error to the typed error it is answered with, or returns nil for errors
+// that are not the client's fault (connection lost, syntax errors), which stay 500s.
+func DatabaseError(err error) *apperror.Error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound("not_found", "record not found").Wrap(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	details := map[string]string{}
+	if pgErr.ConstraintName != "" {
+		details["constraint"] = pgErr.ConstraintName
+	}
+	if pgErr.ColumnName != "" {
+		details["column"] = pgErr.ColumnName
+	}
+
+	var appErr *apperror.Error
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		appErr = apperror.Conflict("duplicate", "a record with the same values already exists")
+	case pgForeignKeyViolation:
+		// Deleting a row others point to, or pointing to a row that does not exist
+		if strings.HasPrefix(pgErr.Message, "update or delete") {
+			appErr = apperror.Conflict("still_referenced", "the record is still referenced by other records")
+		} else {
+			appErr = apperror.Validation("reference_not_found", "a referenced record does not exist")
+		}
+	case pgNotNullViolation:
+		appErr = apperror.Validation("missing_value", "a required value is missing")
+	case pgCheckViolation:
+		appErr = apperror.Validation("check_violation", "a value is not allowed")
+	case pgStringDataTruncation:
+		appErr = apperror.Validation("value_too_long", "a value is too long")
+	case pgNumericValueOutOfRange:
+		appErr = apperror.Validation("value_out_of_range", "a value is out of range")
+	case pgInvalidTextInput:
+		appErr = apperror.Validation("invalid_value", "a value has an invalid format")
+	case pgSerializationFailure, pgDeadlockDetected:
+		appErr = apperror.Conflict("concurrent_update", "the record was changed by a concurrent request, retry")
+	default:
+		return nil
+	}
+
+	if len(details) > 0 {
+		appErr = appErr.WithDetails(details)
+	}
+	return appErr.Wrap(err)
+}
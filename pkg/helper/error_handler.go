@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"errors"
+	"myapp/pkg/apperror"
+	"myapp/pkg/logger"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errorLog = logger.New("error")
+
+// ErrorBody is APIResponse.Error of a typed error: its machine readable code and optional details
+type ErrorBody struct {
+	Code    string      `json:"code"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ErrorHandler answers the errors handlers and middleware return: typed apperror errors with their status, code and
+// details, database errors through DatabaseError, request validation errors with 422 and fiber errors (unknown
+// routes, oversized bodies) with their status. Anything else is logged and answered with a 500 that does not leak it.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return Fail(c, fiber.StatusUnprocessableEntity, "Validation failed", validationErrors)
+	}
+
+	appErr, ok := apperror.As(err)
+	if !ok {
+		appErr = DatabaseError(err)
+	}
+	if appErr != nil {
+		return Fail(c, appErr.Status, capitalize(appErr.Message), ErrorBody{Code: appErr.Code, Details: appErr.Details})
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return Fail(c, fiberErr.Code, fiberErr.Message, nil)
+	}
+
+	errorLog.ErrorContext(c.UserContext(), "Request failed", "method", c.Method(), "path", c.Path(), "error", err)
+	return Fail(c, fiber.StatusInternalServerError, "Internal server error", ErrorBody{Code: "internal_error"})
+}
+
+// ErrorStatus is the status ErrorHandler answers err with, for middleware that records the status of a request
+// before the error is answered
+func ErrorStatus(err error) int {
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return fiber.StatusUnprocessableEntity
+	}
+
+	appErr, ok := apperror.As(err)
+	if !ok {
+		appErr = DatabaseError(err)
+	}
+	if appErr != nil {
+		return appErr.Status
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// capitalize turns a service message into a response message: product not found becomes Product not found
+func capitalize(message string) string {
+	r, size := utf8.DecodeRuneInString(message)
+	if r == utf8.RuneError {
+		return message
+	}
+	return string(unicode.ToUpper(r)) + message[size:]
+}
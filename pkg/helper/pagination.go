@@ -0,0 +1,45 @@
+package helper
+
+// Pagination is returned in APIResponse.Meta by list endpoints
+type Pagination struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewPagination builds pagination metadata; a pageSize of 0 means the whole result was returned in one page
+func NewPagination(page, pageSize int, totalItems int64) *Pagination {
+	if pageSize <= 0 {
+		return &Pagination{
+			Page:       1,
+			PageSize:   int(totalItems),
+			TotalItems: totalItems,
+			TotalPages: 1,
+		}
+	}
+
+	totalPages := int((totalItems + int64(pageSize) - 1) / int64(pageSize))
+	return &Pagination{
+		Page:       page,
+		PageSize:   pageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+}
+
+// CursorPagination is returned in APIResponse.Meta by keyset (cursor) paginated endpoints
+type CursorPagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewCursorPagination builds cursor metadata; an empty nextCursor means the last page was reached
+func NewCursorPagination(limit int, nextCursor string) *CursorPagination {
+	return &CursorPagination{
+		Limit:      limit,
+		NextCursor: nextCursor,
+		HasMore:    nextCursor != "",
+	}
+}
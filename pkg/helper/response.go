@@ -7,6 +7,7 @@ type APIResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   interface{} `json:"error,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
 }
 
 // SuccessMessage untuk response tanpa data (hanya message)
@@ -33,6 +34,16 @@ func SuccessOptional(c *fiber.Ctx, code int, message string, data ...interface{}
 	return c.Status(code).JSON(response)
 }
 
+// SuccessWithMeta untuk response list yang membawa metadata (misalnya pagination)
+func SuccessWithMeta(c *fiber.Ctx, code int, message string, data interface{}, meta interface{}) error {
+	return c.Status(code).JSON(APIResponse{
+		Code:    code,
+		Message: message,
+		Data:    data,
+		Meta:    meta,
+	})
+}
+
 func Fail(c *fiber.Ctx, code int, message string, err interface{}) error {
 	return c.Status(code).JSON(APIResponse{
 		Code:    code,
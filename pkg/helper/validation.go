@@ -0,0 +1,146 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FieldError is one failed `validate` rule of a request field, returned in APIResponse.Error of a 422 response
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name of the field, with the path for nested fields (items[0].quantity)
+	Rule    string `json:"rule"`    // The failed rule, e.g. required or oneof
+	Message string `json:"message"` // Human readable message
+}
+
+// ValidationErrors are the failed rules of a request body
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON names, which is what clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate checks the `validate` tags of a parsed request body and returns ValidationErrors when a rule fails
+func Validate(req interface{}) error {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	result := make(ValidationErrors, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		field := fieldPath(fieldError)
+		result[i] = FieldError{
+			Field:   field,
+			Rule:    fieldError.Tag(),
+			Message: validationMessage(field, fieldError),
+		}
+	}
+	return result
+}
+
+// ValidationFail sends the failed rules of Validate as a 422 response
+func ValidationFail(c *fiber.Ctx, err error) error {
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return Fail(c, 422, "Validation failed", validationErrors)
+	}
+	return Fail(c, 400, "Invalid request body", err.Error())
+}
+
+// fieldPath drops the struct name from the namespace: CreateWebhookRequest.events[0] becomes events[0]
+func fieldPath(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func validationMessage(field string, fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(strings.Fields(param), ", "))
+	case "datetime":
+		return fmt.Sprintf("%s must be a date in the format %s", field, dateLayout.Replace(param))
+	case "min", "max", "len":
+		return sizeMessage(field, fieldError.Tag(), fieldError.Kind(), param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
+	case "gtfield":
+		return fmt.Sprintf("%s must be greater than %s", field, lowerFirst(param))
+	case "gtefield":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, lowerFirst(param))
+	}
+	return fmt.Sprintf("%s is invalid (%s)", field, fieldError.Tag())
+}
+
+// dateLayout spells a Go time layout the way clients know it: 2006-01-02 becomes YYYY-MM-DD
+var dateLayout = strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD", "15", "hh", "04", "mm", "05", "ss")
+
+// sizeMessage words min, max and len, whose param is a length for strings and slices and a value for numbers
+func sizeMessage(field, tag string, kind reflect.Kind, param string) string {
+	bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[tag]
+	switch kind {
+	case reflect.String:
+		return fmt.Sprintf("%s must be %s %s characters long", field, bound, param)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s must have %s %s items", field, bound, param)
+	}
+	return fmt.Sprintf("%s must be %s %s", field, bound, param)
+}
+
+// lowerFirst turns the Go name of a compared field into its JSON name: ReorderPoint becomes reorderPoint
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,113 @@
+// Package logger writes the application log as JSON lines through log/slog.
+//
+// Every line carries the attributes stored in its context with With: the request middleware adds the request ID and
+// route, authentication adds the user and tenant. Loggers made with New add the component that wrote the line, such
+// as brand or redis. Passwords, tokens and secrets are redacted before a line is written, see redact.go.
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// level is the lowest level written, set from LOG_LEVEL by Init
+var level = new(slog.LevelVar)
+
+func init() {
+	// Installed before main runs, so package level loggers and the standard log package write JSON from the start
+	slog.SetDefault(slog.New(newContextHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))))
+}
+
+// Init sets the level from LOG_LEVEL: debug, info (default), warn or error. It runs after the .env file is loaded.
+func Init() {
+	value := os.Getenv("LOG_LEVEL")
+	if value == "" {
+		return
+	}
+
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+		slog.Warn("Invalid LOG_LEVEL, using info", "component", "logger", "value", value)
+		return
+	}
+	level.Set(l)
+}
+
+// New returns a logger whose lines carry component, e.g. brand
+func New(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+type attrsKey struct{}
+
+// With returns a copy of ctx whose log lines carry args, given as slog key-value pairs or attributes
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append(Attrs(ctx), argsToAttrs(args)...)
+	return context.WithValue(ctx, attrsKey{}, attrs[:len(attrs):len(attrs)])
+}
+
+// Attrs returns the attributes With stored in ctx
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var record slog.Record
+	record.Add(args...)
+
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
+
+// contextHandler adds the attributes of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func newContextHandler(handler slog.Handler) *contextHandler {
+	return &contextHandler{Handler: handler}
+}
+
+// Handle adds the attributes of ctx the line does not set itself, so a handler logging user_id of another user does
+// not write the key twice
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := Attrs(ctx)
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	keys := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		keys[attr.Key] = true
+		return true
+	})
+
+	record = record.Clone()
+	for _, attr := range attrs {
+		if !keys[attr.Key] {
+			record.AddAttrs(attr)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newContextHandler(h.Handler.WithAttrs(attrs))
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return newContextHandler(h.Handler.WithGroup(name))
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of a sensitive field
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of attribute and JSON field names whose values are never logged
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "api_key", "apikey"}
+
+var (
+	// sensitiveText matches key=value and "key": "value" pairs of sensitive fields inside messages and strings
+	sensitiveText = regexp.MustCompile(`(?i)("?[\w-]*(?:password|token|secret|authorization|api_key|apikey)"?\s*[:=]\s*)("[^"]*"|Bearer\s+\S+|[^\s,&"]+)`)
+	// jwtText matches a JSON web token wherever it appears
+	jwtText = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]+`)
+)
+
+// IsSensitive reports whether the value of a field called key must not be logged
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactText removes the values of sensitive fields and tokens from text
+func RedactText(text string) string {
+	text = jwtText.ReplaceAllString(text, Redacted)
+	return sensitiveText.ReplaceAllString(text, "${1}"+Redacted)
+}
+
+// redactAttr is the ReplaceAttr of the JSON handler: it redacts attributes named like a secret, secrets written into
+// strings, and the sensitive fields of structs and maps, which are otherwise written as JSON as they are
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactText(attr.Value.String()))
+	case slog.KindAny:
+		value := attr.Value.Any()
+		if err, ok := value.(error); ok {
+			return slog.String(attr.Key, RedactText(err.Error()))
+		}
+		if redacted, ok := redactValue(value); ok {
+			return slog.Any(attr.Key, redacted)
+		}
+	}
+	return attr
+}
+
+// redactValue returns structs, maps and slices as their JSON with sensitive fields redacted
+func redactValue(value any) (any, bool) {
+	kind := reflect.Indirect(reflect.ValueOf(value)).Kind()
+	if kind != reflect.Struct && kind != reflect.Map && kind != reflect.Slice && kind != reflect.Array {
+		return nil, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, false
+	}
+	return redactJSON(decoded), true
+}
+
+func redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if IsSensitive(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	case string:
+		return RedactText(v)
+	}
+	return value
+}
@@ -0,0 +1,21 @@
+package redis
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Cache lookups through Get: a miss is a key that is not cached or a lookup that failed, either way the caller
+// falls back to the database
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "redis_cache_hits_total",
+		Help: "Cache lookups answered from Redis.",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "redis_cache_misses_total",
+		Help: "Cache lookups that found nothing in Redis or failed.",
+	})
+)
+
+// Collectors returns the cache metrics for the metrics registry
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{cacheHits, cacheMisses}
+}
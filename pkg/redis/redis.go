@@ -3,7 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
-	"log"
+	"myapp/pkg/logger"
 	"os"
 	"strconv"
 	"time"
@@ -11,6 +11,8 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+var redisLog = logger.New("redis")
+
 var (
 	Client    *redis.Client
 	Ctx       = context.Background()
@@ -25,7 +27,7 @@ func InitRedis() error {
 	IsEnabled = enabled == "true"
 
 	if !IsEnabled {
-		log.Println("[REDIS] Redis is disabled in configuration")
+		redisLog.Info("Redis is disabled in configuration")
 		return nil
 	}
 
@@ -70,7 +72,7 @@ func InitRedis() error {
 			Password: password,
 			DB:       db,
 		}
-		log.Printf("[REDIS] Connecting to Redis via Unix socket: %s", unixSocket)
+		redisLog.Info("Connecting to Redis via Unix socket", "socket", unixSocket)
 	} else {
 		// Use TCP connection
 		options = &redis.Options{
@@ -78,80 +80,97 @@ func InitRedis() error {
 			Password: password,
 			DB:       db,
 		}
-		log.Printf("[REDIS] Connecting to Redis via TCP: %s:%s", host, port)
+		redisLog.Info("Connecting to Redis via TCP", "addr", options.Addr)
 	}
 
 	Client = redis.NewClient(options)
+	Client.AddHook(tracingHook{})
 
 	// Test connection
 	_, err := Client.Ping(Ctx).Result()
 	if err != nil {
-		log.Printf("[REDIS] Failed to connect to Redis: %v", err)
+		redisLog.Error("Failed to connect to Redis", "error", err)
 		IsEnabled = false
 		return err
 	}
 
-	if unixSocket != "" {
-		log.Printf("[REDIS] Successfully connected to Redis via Unix socket: %s (DB: %d)", unixSocket, db)
-	} else {
-		log.Printf("[REDIS] Successfully connected to Redis via TCP: %s:%s (DB: %d)", host, port, db)
-	}
-	log.Printf("[REDIS] Cache TTL set to %v", TTL)
+	redisLog.Info("Connected to Redis", "network", options.Network, "addr", options.Addr, "db", db, "ttl", TTL.String())
 	return nil
 }
 
-// Set stores a key-value pair in Redis with TTL
-func Set(key string, value interface{}) error {
+// TenantKey prefixes key with the tenant it belongs to, so tenants never read or invalidate each other's cache.
+// Keys of tenant 0 (no tenant) are left as they are.
+func TenantKey(tenantID uint, key string) string {
+	if tenantID == 0 {
+		return key
+	}
+	return fmt.Sprintf("tenant:%d:%s", tenantID, key)
+}
+
+// AnyTenantKey turns key into a pattern that matches it in the cache of every tenant, for DeletePattern
+func AnyTenantKey(key string) string {
+	return "tenant:*:" + key
+}
+
+// Set stores a key-value pair in Redis with TTL. Like Get, Delete and DeletePattern it accepts a nil ctx.
+func Set(ctx context.Context, key string, value interface{}) error {
+	ctx = contextOrDefault(ctx)
 	if !IsEnabled || Client == nil {
 		return nil
 	}
 
-	err := Client.Set(Ctx, key, value, TTL).Err()
+	err := Client.Set(ctx, key, value, TTL).Err()
 	if err != nil {
-		log.Printf("[REDIS] Error setting key %s: %v", key, err)
+		redisLog.WarnContext(ctx, "Setting key failed", "key", key, "error", err)
 	}
 	return err
 }
 
 // Get retrieves a value from Redis by key
-func Get(key string) (string, error) {
+func Get(ctx context.Context, key string) (string, error) {
+	ctx = contextOrDefault(ctx)
 	if !IsEnabled || Client == nil {
 		return "", fmt.Errorf("redis not enabled")
 	}
 
-	val, err := Client.Get(Ctx, key).Result()
+	val, err := Client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		cacheMisses.Inc()
 		return "", fmt.Errorf("key not found")
 	}
 	if err != nil {
-		log.Printf("[REDIS] Error getting key %s: %v", key, err)
+		cacheMisses.Inc()
+		redisLog.WarnContext(ctx, "Getting key failed", "key", key, "error", err)
 		return "", err
 	}
+	cacheHits.Inc()
 	return val, nil
 }
 
 // Delete removes a key from Redis
-func Delete(key string) error {
+func Delete(ctx context.Context, key string) error {
+	ctx = contextOrDefault(ctx)
 	if !IsEnabled || Client == nil {
 		return nil
 	}
 
-	err := Client.Del(Ctx, key).Err()
+	err := Client.Del(ctx, key).Err()
 	if err != nil {
-		log.Printf("[REDIS] Error deleting key %s: %v", key, err)
+		redisLog.WarnContext(ctx, "Deleting key failed", "key", key, "error", err)
 	}
 	return err
 }
 
 // DeletePattern removes all keys matching a pattern
-func DeletePattern(pattern string) error {
+func DeletePattern(ctx context.Context, pattern string) error {
+	ctx = contextOrDefault(ctx)
 	if !IsEnabled || Client == nil {
 		return nil
 	}
 
-	keys, err := Client.Keys(Ctx, pattern).Result()
+	keys, err := Client.Keys(ctx, pattern).Result()
 	if err != nil {
-		log.Printf("[REDIS] Error finding keys with pattern %s: %v", pattern, err)
+		redisLog.WarnContext(ctx, "Finding keys failed", "pattern", pattern, "error", err)
 		return err
 	}
 
@@ -159,34 +178,101 @@ func DeletePattern(pattern string) error {
 		return nil
 	}
 
-	err = Client.Del(Ctx, keys...).Err()
+	err = Client.Del(ctx, keys...).Err()
 	if err != nil {
-		log.Printf("[REDIS] Error deleting keys with pattern %s: %v", pattern, err)
+		redisLog.WarnContext(ctx, "Deleting keys failed", "pattern", pattern, "error", err)
 	} else {
-		log.Printf("[REDIS] Deleted %d keys with pattern %s", len(keys), pattern)
+		redisLog.DebugContext(ctx, "Deleted keys", "pattern", pattern, "keys", len(keys))
+	}
+	return err
+}
+
+// XAdd appends an entry to a stream, trimming it to about maxLen entries when maxLen > 0
+func XAdd(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) (string, error) {
+	if !IsEnabled || Client == nil {
+		return "", fmt.Errorf("redis not enabled")
+	}
+
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+
+	id, err := Client.XAdd(ctx, args).Result()
+	if err != nil {
+		redisLog.WarnContext(ctx, "Adding to stream failed", "stream", stream, "error", err)
+	}
+	return id, err
+}
+
+// Publish sends message to every subscriber of channel
+func Publish(ctx context.Context, channel string, message interface{}) error {
+	if !IsEnabled || Client == nil {
+		return fmt.Errorf("redis not enabled")
+	}
+
+	err := Client.Publish(ctx, channel, message).Err()
+	if err != nil {
+		redisLog.WarnContext(ctx, "Publishing failed", "channel", channel, "error", err)
 	}
 	return err
 }
 
-// FlushAll clears all Redis data (use with caution)
-func FlushAll() error {
+// Subscribe listens to channel; the subscription reconnects by itself and must be closed by the caller
+func Subscribe(ctx context.Context, channel string) (*redis.PubSub, error) {
+	if !IsEnabled || Client == nil {
+		return nil, fmt.Errorf("redis not enabled")
+	}
+
+	pubsub := Client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		redisLog.WarnContext(ctx, "Subscribing failed", "channel", channel, "error", err)
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// FlushDB clears all data of the configured database (REDIS_DB), leaving the other databases of the server alone
+func FlushDB(ctx context.Context) error {
 	if !IsEnabled || Client == nil {
 		return nil
 	}
 
-	err := Client.FlushAll(Ctx).Err()
+	ctx = contextOrDefault(ctx)
+	err := Client.FlushDB(ctx).Err()
 	if err != nil {
-		log.Printf("[REDIS] Error flushing all data: %v", err)
+		redisLog.ErrorContext(ctx, "Flushing database failed", "error", err)
 	} else {
-		log.Println("[REDIS] All Redis data flushed")
+		redisLog.InfoContext(ctx, "Redis database flushed")
 	}
 	return err
 }
 
+// Ping checks that Redis answers within the deadline of ctx, also after it failed to connect at startup
+func Ping(ctx context.Context) error {
+	if Client == nil {
+		return fmt.Errorf("redis not enabled")
+	}
+	return Client.Ping(ctx).Err()
+}
+
+// contextOrDefault falls back to Ctx for callers without a context
+func contextOrDefault(ctx context.Context) context.Context {
+	if ctx == nil {
+		return Ctx
+	}
+	return ctx
+}
+
 // Close closes the Redis connection
 func Close() error {
 	if Client != nil {
-		log.Println("[REDIS] Closing Redis connection")
+		redisLog.Info("Closing Redis connection")
 		return Client.Close()
 	}
 	return nil
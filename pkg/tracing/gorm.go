@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey holds the span of a statement between its before and after callbacks
+const spanKey = "tracing:span"
+
+// GormPlugin gives every GORM statement a span, a child of the span in the context of the statement. The span
+// carries the SQL with placeholders, never the values bound to them.
+type GormPlugin struct{}
+
+// NewGormPlugin returns the tracing plugin, registered with db.Use
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{}
+}
+
+func (p *GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Create().Before("gorm:create").Register("tracing:before_create", p.before("INSERT")); err != nil {
+		return err
+	}
+	if err := callback.Create().After("gorm:create").Register("tracing:after_create", p.after); err != nil {
+		return err
+	}
+	if err := callback.Query().Before("gorm:query").Register("tracing:before_query", p.before("SELECT")); err != nil {
+		return err
+	}
+	if err := callback.Query().After("gorm:query").Register("tracing:after_query", p.after); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("tracing:before_update", p.before("UPDATE")); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:update").Register("tracing:after_update", p.after); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("DELETE")); err != nil {
+		return err
+	}
+	if err := callback.Delete().After("gorm:delete").Register("tracing:after_delete", p.after); err != nil {
+		return err
+	}
+	if err := callback.Row().Before("gorm:row").Register("tracing:before_row", p.before("SELECT")); err != nil {
+		return err
+	}
+	if err := callback.Row().After("gorm:row").Register("tracing:after_row", p.after); err != nil {
+		return err
+	}
+	if err := callback.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("RAW")); err != nil {
+		return err
+	}
+	return callback.Raw().After("gorm:raw").Register("tracing:after_raw", p.after)
+}
+
+// before starts the span of a statement, named after its operation and table: SELECT product_stocks
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+
+		_, span := Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(db.Statement.Table),
+		))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// after ends the span of a statement with its SQL, the rows it affected and its error
+func (p *GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
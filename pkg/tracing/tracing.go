@@ -0,0 +1,84 @@
+// Package tracing sets up OpenTelemetry tracing and starts the spans of the application.
+//
+// Requests get a span from the tracing middleware, service methods from Start, GORM statements from the plugin in
+// gorm.go and Redis commands from the hook in pkg/redis. OTEL_TRACES_EXPORTER picks where spans go: otlp sends them
+// over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, stdout prints them for local debugging and none (the default)
+// turns tracing off. The other OTEL_* variables of the SDK, such as OTEL_SERVICE_NAME and OTEL_TRACES_SAMPLER, apply.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"myapp/pkg/logger"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName names the service in traces when OTEL_SERVICE_NAME is not set
+const DefaultServiceName = "go-wms"
+
+// instrumentationName identifies the spans started by this application
+const instrumentationName = "myapp"
+
+var tracingLog = logger.New("tracing")
+
+// Init installs the tracer provider chosen with OTEL_TRACES_EXPORTER. The returned function flushes the spans still
+// buffered and must be called before the process exits.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	// Incoming traceparent headers continue the trace of the caller, with or without an exporter
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(ctx, strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	tracingLog.Info("Tracing enabled", "exporter", os.Getenv("OTEL_TRACES_EXPORTER"), "service", serviceName)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	case "stdout", "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, use otlp, stdout or none", name)
+}
+
+// Tracer returns the tracer of the application, from the provider installed when it is called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span as a child of the span in ctx. A nil ctx, as held by services and repositories used without
+// WithContext, starts a new trace.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, opts...)
+}